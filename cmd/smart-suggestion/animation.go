@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// animationSupported reports whether the plugin's loading animation can be
+// shown: term must be able to move the cursor, which rules out dumb terminals
+// such as Emacs shell buffers, and fd must be a terminal.
+func animationSupported(term string, fd int) bool {
+	switch strings.TrimSpace(term) {
+	case "", "dumb", "unknown":
+		return false
+	}
+	return isTerminalFunc(fd)
+}
+
+// runCheckAnimation exits 1 when the plugin should not draw its loading
+// animation, and 0 when it may.
+func runCheckAnimation(cmd *cobra.Command, args []string) {
+	if !animationSupported(os.Getenv("TERM"), int(os.Stderr.Fd())) {
+		exitFunc(1)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestAnimationSupported(t *testing.T) {
+	oldIsTerminal := isTerminalFunc
+	t.Cleanup(func() { isTerminalFunc = oldIsTerminal })
+
+	cases := []struct {
+		term     string
+		terminal bool
+		want     bool
+	}{
+		{term: "xterm-256color", terminal: true, want: true},
+		{term: "dumb", terminal: true, want: false},
+		{term: "", terminal: true, want: false},
+		{term: "xterm-256color", terminal: false, want: false},
+	}
+	for _, tc := range cases {
+		isTerminalFunc = func(fd int) bool { return tc.terminal }
+		if got := animationSupported(tc.term, 2); got != tc.want {
+			t.Errorf("TERM=%q, terminal=%v: expected %v, got %v", tc.term, tc.terminal, tc.want, got)
+		}
+	}
+}
+
+func TestMainCheckAnimation(t *testing.T) {
+	oldIsTerminal := isTerminalFunc
+	t.Cleanup(func() { isTerminalFunc = oldIsTerminal })
+	isTerminalFunc = func(fd int) bool { return true }
+
+	t.Setenv("TERM", "dumb")
+	if exitCode, out := runMain(t, "check-animation"); exitCode != 1 || out != "" {
+		t.Errorf("TERM=dumb: expected exit code 1 and no output, got %d and %q", exitCode, out)
+	}
+
+	t.Setenv("TERM", "xterm-256color")
+	if exitCode, _ := runMain(t, "check-animation"); exitCode != -1 {
+		t.Errorf("TERM=xterm-256color: expected a successful exit, got exit code %d", exitCode)
+	}
+}
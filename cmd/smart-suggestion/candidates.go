@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// writeCandidates fetches n suggestions for req and writes the distinct ones to
+// --output, one per line: =/+ prefixed in raw format, as full command lines in
+// human format. safe turns replacements into non-destructive ones, as it does
+// for a single suggestion.
+func writeCandidates(req *suggestRequest, n int, human, safe bool) error {
+	start := time.Now()
+	suggestions, err := fetchCandidates(req, n)
+	recordSessionMetrics(start, err)
+	if timeout, ok := timedOut(err); ok {
+		fmt.Fprintf(os.Stderr, "Suggestion timed out after %s\n", timeout)
+		exitFunc(timeoutExitCode)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var builder strings.Builder
+	for _, suggestion := range suggestions {
+		suggestion = cleanSuggestion(req.inputBefore, req.inputAfter, suggestion)
+		if safe {
+			suggestion = neutralizeReplacement(req.inputBefore, req.inputAfter, suggestion)
+		}
+		if suggestion == "" {
+			continue
+		}
+		if human {
+			suggestion = candidateCommand(req.inputBefore, req.inputAfter, suggestion)
+		}
+		builder.WriteString(suggestion + "\n")
+	}
+
+	debug.Log("Fetched candidates", map[string]any{
+		"provider":   req.providerName,
+		"input":      req.userInput,
+		"candidates": suggestions,
+	})
+
+	if outputFile == "" {
+		return nil
+	}
+	return writeSuggestion(outputFile, builder.String())
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestMainCandidates(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	seq := &sequenceProvider{responses: []string{"<reasoning>r</reasoning>=ls -la", "=ls -la", "+lh", "=ls -1\nrm -rf /"}}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return seq, nil
+	}
+	_, out := runMain(t, "--provider", "mock", "--input", "ls -", "--candidates", "4", "--format", "raw")
+	if out != "=ls -la\n+lh\n=ls -1\n" {
+		t.Errorf("expected each distinct candidate on its own line, got %q", out)
+	}
+	if seq.calls != 4 {
+		t.Errorf("expected 4 requests, got %d", seq.calls)
+	}
+
+	seq = &sequenceProvider{responses: []string{"=ls -la", "+lh"}}
+	_, out = runMain(t, "--provider", "mock", "--input", "ls -", "--candidates", "2", "--format", "human")
+	if out != "ls -la\nls -lh\n" {
+		t.Errorf("expected full command lines, got %q", out)
+	}
+}
+
+func TestMainCandidatesInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"--candidates", "0"},
+		{"--candidates", "2", "--stream"},
+		{"--candidates", "2", "--meta", "-"},
+	} {
+		exitCode, _ := runMain(t, append([]string{"--provider", "mock", "--input", "ls"}, args...)...)
+		if exitCode != 1 {
+			t.Errorf("%s: expected exit code 1, got %d", strings.Join(args, " "), exitCode)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minCompletionOverlap is the shortest overlap between the end of the input
+// and the start of a completion that is treated as re-typed text. A single
+// character is too ambiguous: "git ad" completed with "d" is a plain "add",
+// and "git commit -" completed with "-amend" is "--amend". Only a one-letter
+// word typed again as a whole ("git s" completed with "status") is trusted.
+const minCompletionOverlap = 2
+
+// completionSuffix cleans a "+" completion so it can be appended to input
+// as-is. Models sometimes repeat the whole input, re-type the end of the word
+// being completed, or add a space the input already ends with.
+func completionSuffix(input, completion string) string {
+	if input == "" {
+		return completion
+	}
+
+	// The whole command line came back.
+	if trimmed := strings.TrimLeft(completion, " "); strings.HasPrefix(trimmed, input) {
+		return trimmed[len(input):]
+	}
+
+	if strings.HasSuffix(input, " ") {
+		return strings.TrimLeft(completion, " ")
+	}
+
+	// The end of the current word was typed again.
+	word := input[strings.LastIndex(input, " ")+1:]
+	for k := len(word); k > 0; k-- {
+		if k < minCompletionOverlap && (k < len(word) || !unicode.IsLetter(rune(word[0]))) {
+			break
+		}
+		if strings.HasPrefix(completion, word[len(word)-k:]) {
+			return completion[k:]
+		}
+	}
+	return completion
+}
+
+// cleanSuggestion applies completionSuffix to "+" suggestions and leaves new
+// commands untouched. before and after are the input on either side of the
+// cursor, where the completion is inserted.
+func cleanSuggestion(before, after, suggestion string) string {
+	if completion, ok := strings.CutPrefix(suggestion, "+"); ok {
+		return "+" + separateFromCursorText(before, after, completionSuffix(before, completion))
+	}
+	return suggestion
+}
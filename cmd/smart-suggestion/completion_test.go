@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestCompletionSuffix(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      string
+		completion string
+		expected   string
+	}{
+		{name: "plain suffix", input: "ls -", completion: "la", expected: "la"},
+		{name: "empty input", input: "", completion: "ls -la", expected: "ls -la"},
+		{name: "needed leading space", input: "ls", completion: " -la", expected: " -la"},
+		{name: "doubled space", input: "ls ", completion: " -la", expected: "-la"},
+		{name: "whole line repeated", input: "git sta", completion: "git status", expected: "tus"},
+		{name: "whole line after space", input: "git", completion: " git status", expected: " status"},
+		{name: "whole line with trailing space", input: "git ", completion: "git status", expected: "status"},
+		{name: "word retyped", input: "git sta", completion: "status", expected: "tus"},
+		{name: "end of word retyped", input: "git sta", completion: "tatus", expected: "tus"},
+		{name: "single letter word retyped", input: "git s", completion: "status", expected: "tatus"},
+		{name: "single dash kept", input: "git commit -", completion: "-amend", expected: "-amend"},
+		{name: "double dash retyped", input: "ls --", completion: "--all", expected: "all"},
+		{name: "single char overlap kept", input: "git ad", completion: "d", expected: "d"},
+		{name: "doubled letter kept", input: "npm i expre", completion: "ss", expected: "ss"},
+		{name: "no overlap", input: "docker ps", completion: " -a", expected: " -a"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := completionSuffix(tc.input, tc.completion)
+			if got != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCleanSuggestion(t *testing.T) {
+	if got := cleanSuggestion("ls ", "", "+ -la"); got != "+-la" {
+		t.Errorf("expected %q, got %q", "+-la", got)
+	}
+	if got := cleanSuggestion("ls ", "", "=ls -la"); got != "=ls -la" {
+		t.Errorf("expected new commands to be untouched, got %q", got)
+	}
+}
+
+func TestRunSuggestCleansCompletion(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+	})
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "+git status"}, nil
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "git sta"
+	providerName = "mock"
+	sendContext = false
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(data) != "+tus" {
+		t.Errorf("expected %q, got %q", "+tus", string(data))
+	}
+}
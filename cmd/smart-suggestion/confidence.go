@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// minConfidenceEnv sets the confidence below which a suggestion is dropped.
+const minConfidenceEnv = "SMART_SUGGESTION_MIN_CONFIDENCE"
+
+// minConfidence returns the threshold from SMART_SUGGESTION_MIN_CONFIDENCE,
+// or 0 when it is unset.
+func minConfidence() (float64, error) {
+	v := strings.TrimSpace(os.Getenv(minConfidenceEnv))
+	if v == "" {
+		return 0, nil
+	}
+	threshold, err := strconv.ParseFloat(v, 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		return 0, fmt.Errorf("invalid %s %q: must be a number between 0 and 1", minConfidenceEnv, v)
+	}
+	return threshold, nil
+}
+
+// tooUnsure reports whether c is known and below threshold. Providers without
+// log probabilities never report a confidence, so their suggestions are kept.
+func tooUnsure(c *provider.Confidence, threshold float64) bool {
+	return c != nil && c.Known && c.Value < threshold
+}
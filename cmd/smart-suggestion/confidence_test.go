@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// confidentProvider answers like mockProvider and reports confidence to the
+// recorder on the context, the way providers exposing log probabilities do.
+type confidentProvider struct {
+	mockProvider
+	confidence float64
+}
+
+func (p *confidentProvider) FetchWithHistory(ctx context.Context, input, systemPrompt string, history []provider.Message) (string, error) {
+	if c := provider.ConfidenceFromContext(ctx); c != nil {
+		*c = provider.Confidence{Value: p.confidence, Known: true}
+	}
+	return p.mockProvider.FetchWithHistory(ctx, input, systemPrompt, history)
+}
+
+func TestMinConfidence(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: " 0.6 ", want: 0.6},
+		{value: "1", want: 1},
+		{value: "high", wantErr: true},
+		{value: "-0.1", wantErr: true},
+		{value: "1.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv(minConfidenceEnv, tt.value)
+			got, err := minConfidence()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunSuggestMinConfidence(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldMeta := metaFile
+	oldInput := input
+	oldProvider := providerName
+	oldDebug := dbg
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		metaFile = oldMeta
+		input = oldInput
+		providerName = oldProvider
+		dbg = oldDebug
+		sendContext = oldContext
+	})
+
+	tests := []struct {
+		name       string
+		threshold  string
+		confidence float64
+		want       string
+		wantType   string
+	}{
+		{name: "above threshold", threshold: "0.5", confidence: 0.9, want: "=ls -la", wantType: "command"},
+		{name: "below threshold", threshold: "0.5", confidence: 0.3, want: "", wantType: ""},
+		{name: "no threshold", confidence: 0.1, want: "=ls -la", wantType: "command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(minConfidenceEnv, tt.threshold)
+			selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+				return &confidentProvider{mockProvider: mockProvider{response: "=ls -la"}, confidence: tt.confidence}, nil
+			}
+			dir := t.TempDir()
+			outputFile = filepath.Join(dir, "output.txt")
+			metaFile = filepath.Join(dir, "meta.json")
+			input = "list files"
+			providerName = "mock"
+			dbg = false
+			sendContext = false
+
+			cmd := &cobra.Command{}
+			cmd.SetContext(context.Background())
+			if err := runSuggest(cmd, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			content, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("failed to read output: %v", err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, string(content))
+			}
+
+			data, err := os.ReadFile(metaFile)
+			if err != nil {
+				t.Fatalf("failed to read metadata: %v", err)
+			}
+			var meta suggestionMeta
+			if err := json.Unmarshal(data, &meta); err != nil {
+				t.Fatalf("invalid metadata %q: %v", data, err)
+			}
+			if meta.Type != tt.wantType {
+				t.Errorf("expected type %q, got %q", tt.wantType, meta.Type)
+			}
+			if meta.Confidence == nil || *meta.Confidence != tt.confidence {
+				t.Errorf("expected confidence %v, got %v", tt.confidence, meta.Confidence)
+			}
+		})
+	}
+}
+
+func TestRunSuggestMinConfidenceUnknown(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldDebug := dbg
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		dbg = oldDebug
+		sendContext = oldContext
+	})
+
+	// Providers without log probabilities are never held back by the threshold.
+	t.Setenv(minConfidenceEnv, "0.99")
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "=ls -la"}, nil
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "list files"
+	providerName = "mock"
+	dbg = false
+	sendContext = false
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(content) != "=ls -la" {
+		t.Errorf("expected '=ls -la', got %q", string(content))
+	}
+}
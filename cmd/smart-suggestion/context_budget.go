@@ -0,0 +1,91 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// contextHeadingRegex matches the "# Title:" headings that start each context
+// section in the system prompt and the user message.
+var contextHeadingRegex = regexp.MustCompile(`(?m)^# ([^\n]+):\n`)
+
+// budgetSections maps context headings to the names used in the budget report.
+// Text under other headings is counted as part of the message it appears in.
+var budgetSections = map[string]string{
+	"This is the alias defined in your shell": "aliases",
+	"Available PATH commands":                 "commands",
+	"Shell history":                           "history",
+	"Scrollback":                              "scrollback",
+	"User input":                              "input",
+}
+
+type sectionSize struct {
+	bytes int
+	lines int
+}
+
+type contextSizes map[string]*sectionSize
+
+func (s contextSizes) add(name, part string) {
+	part = strings.TrimSpace(part)
+	if part == "" {
+		return
+	}
+	if s[name] == nil {
+		s[name] = &sectionSize{}
+	}
+	s[name].bytes += len(part)
+	s[name].lines += strings.Count(part, "\n") + 1
+}
+
+// addSections adds the size of each section of text, counting text outside
+// the known sections under fallback.
+func (s contextSizes) addSections(text, fallback string) {
+	name, start := fallback, 0
+	for _, m := range contextHeadingRegex.FindAllStringSubmatchIndex(text, -1) {
+		s.add(name, text[start:m[0]])
+		name = fallback
+		if section, ok := budgetSections[text[m[2]:m[3]]]; ok {
+			name = section
+		}
+		start = m[1]
+	}
+	s.add(name, text[start:])
+}
+
+// contextBudget reports the byte and line counts of each part of the request:
+// the system prompt, aliases, commands, history, scrollback, the few-shot
+// examples and the input, plus the totals.
+func contextBudget(systemPrompt, userInput string, history []provider.Message) map[string]any {
+	sizes := make(contextSizes)
+	sizes.addSections(systemPrompt, "system")
+	sizes.addSections(userInput, "input")
+	for _, msg := range history {
+		sizes.add("examples", msg.Content)
+	}
+
+	report := make(map[string]any)
+	var totalBytes, totalLines int
+	for name, size := range sizes {
+		report[name+"_bytes"] = size.bytes
+		report[name+"_lines"] = size.lines
+		totalBytes += size.bytes
+		totalLines += size.lines
+	}
+	report["total_bytes"] = totalBytes
+	report["total_lines"] = totalLines
+	return report
+}
+
+var debugLogFunc = debug.Log
+
+// logContextBudget writes the context budget of r to the debug log, so an
+// oversized section is easy to spot when suggestions ignore the context.
+func (r *suggestRequest) logContextBudget() {
+	report := contextBudget(r.systemPrompt, r.userInput, r.history)
+	report["provider"] = r.providerName
+	debugLogFunc("Context budget", report)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+	"github.com/xyenon/smart-suggestion/internal/shellcontext"
+)
+
+func TestContextBudget(t *testing.T) {
+	systemPrompt := "You are a shell expert.\n\n# Context:\n\nYou are user alice.\n\n# This is the alias defined in your shell:\n\nll='ls -l'\ngs='git status'\n\n# Available PATH commands:\n\nls\ngit"
+	userInput := "The last command took 2 seconds.\n\n# Shell history:\n\nls\ncd src\n\n# Scrollback:\n\n$ make\nerror: no rule\n$\n\n# User input:\n\nmak"
+	history := []provider.Message{
+		{Role: "user", Content: "# User input:\n\nlist files"},
+		{Role: "assistant", Content: "=ls"},
+	}
+
+	report := contextBudget(systemPrompt, userInput, history)
+
+	want := map[string]int{
+		"system_bytes":     len("You are a shell expert.") + len("You are user alice."),
+		"system_lines":     2,
+		"aliases_bytes":    len("ll='ls -l'\ngs='git status'"),
+		"aliases_lines":    2,
+		"commands_lines":   2,
+		"history_bytes":    len("ls\ncd src"),
+		"history_lines":    2,
+		"scrollback_bytes": len("$ make\nerror: no rule\n$"),
+		"scrollback_lines": 3,
+		"input_bytes":      len("The last command took 2 seconds.") + len("mak"),
+		"input_lines":      2,
+		"examples_bytes":   len("# User input:\n\nlist files") + len("=ls"),
+		"examples_lines":   4,
+	}
+	totalBytes, totalLines := 0, 0
+	for key, value := range report {
+		n, ok := value.(int)
+		if !ok {
+			t.Fatalf("expected %s to be an int, got %T", key, value)
+		}
+		switch {
+		case key == "total_bytes" || key == "total_lines":
+		case strings.HasSuffix(key, "_bytes"):
+			totalBytes += n
+		default:
+			totalLines += n
+		}
+	}
+	for key, expected := range want {
+		if report[key] != expected {
+			t.Errorf("expected %s=%d, got %v", key, expected, report[key])
+		}
+	}
+	if report["total_bytes"] != totalBytes || report["total_lines"] != totalLines {
+		t.Errorf("expected totals %d bytes / %d lines, got %v / %v", totalBytes, totalLines, report["total_bytes"], report["total_lines"])
+	}
+}
+
+func TestPrepareSuggestRequestLogsContextBudget(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldSystem := buildSystemContextFunc
+	oldUser := buildUserContextFunc
+	oldLog := debugLogFunc
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		buildSystemContextFunc = oldSystem
+		buildUserContextFunc = oldUser
+		debugLogFunc = oldLog
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+	})
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "=ls"}, nil
+	}
+	buildSystemContextFunc = func(opts shellcontext.SystemContextOptions) (string, error) {
+		return "# This is the alias defined in your shell:\n\nll='ls -l'", nil
+	}
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
+		return "# Scrollback:\n\nline one\nline two", nil
+	}
+	var logged map[string]any
+	debugLogFunc = func(message string, data map[string]any) {
+		if message == "Context budget" {
+			logged = data
+		}
+	}
+	input = "list"
+	providerName = "mock"
+	sendContext = true
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if _, err := prepareSuggestRequest(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if logged == nil {
+		t.Fatal("expected a context budget to be logged")
+	}
+	for _, key := range []string{"system_bytes", "aliases_bytes", "scrollback_bytes", "scrollback_lines", "input_bytes", "examples_bytes", "total_bytes", "total_lines"} {
+		if _, ok := logged[key]; !ok {
+			t.Errorf("expected %s in the budget, got %v", key, logged)
+		}
+	}
+	if logged["scrollback_lines"] != 2 || logged["provider"] != "mock" {
+		t.Errorf("unexpected budget: %v", logged)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// cursorMarker stands in for the cursor in the input sent to the model when
+// --input-cursor points into the middle of the line.
+const cursorMarker = "<CURSOR>"
+
+// cursorNote tells the model where a completion goes when the cursor is not at
+// the end of the line.
+const cursorNote = "\n\n(The cursor is at " + cursorMarker + ". A + completion is inserted there, not at the end of the line, so it must not repeat the text after the cursor.)"
+
+// splitAtCursor splits input at the byte offset cursor. A negative cursor
+// stands for the end of the input.
+func splitAtCursor(input string, cursor int) (before, after string, err error) {
+	if cursor < 0 {
+		return input, "", nil
+	}
+	if cursor > len(input) {
+		return "", "", fmt.Errorf("invalid --input-cursor %d: past the end of the %d-byte input", cursor, len(input))
+	}
+	if cursor < len(input) && !utf8.RuneStart(input[cursor]) {
+		return "", "", fmt.Errorf("invalid --input-cursor %d: inside a multi-byte character", cursor)
+	}
+	return input[:cursor], input[cursor:], nil
+}
+
+// markCursor returns the input as the model sees it: unchanged when the
+// cursor is at the end, otherwise with the cursor marked and explained.
+func markCursor(before, after string) string {
+	if after == "" {
+		return before
+	}
+	return before + cursorMarker + after + cursorNote
+}
+
+// separateFromCursorText keeps a completion inserted between two words from
+// running into the word after the cursor. Responses are trimmed, so the space
+// a model ends such a completion with is lost.
+func separateFromCursorText(before, after, completion string) string {
+	betweenWords := (before == "" || strings.HasSuffix(before, " ")) && after != "" && !strings.HasPrefix(after, " ")
+	if betweenWords && completion != "" && !strings.HasSuffix(completion, " ") {
+		return completion + " "
+	}
+	return completion
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestSplitAtCursor(t *testing.T) {
+	cases := []struct {
+		input  string
+		cursor int
+		before string
+		after  string
+		err    bool
+	}{
+		{input: "git commit file.txt", cursor: -1, before: "git commit file.txt"},
+		{input: "git commit file.txt", cursor: 19, before: "git commit file.txt"},
+		{input: "git commit file.txt", cursor: 11, before: "git commit ", after: "file.txt"},
+		{input: "git commit file.txt", cursor: 0, after: "git commit file.txt"},
+		{input: "ls", cursor: 3, err: true},
+		{input: "cat é", cursor: 5, err: true},
+	}
+	for _, tc := range cases {
+		before, after, err := splitAtCursor(tc.input, tc.cursor)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q at %d: expected an error", tc.input, tc.cursor)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q at %d: unexpected error: %v", tc.input, tc.cursor, err)
+		}
+		if before != tc.before || after != tc.after {
+			t.Errorf("%q at %d: expected %q|%q, got %q|%q", tc.input, tc.cursor, tc.before, tc.after, before, after)
+		}
+	}
+}
+
+func TestMarkCursor(t *testing.T) {
+	if got := markCursor("ls -", ""); got != "ls -" {
+		t.Errorf("expected the input unchanged at the end of the line, got %q", got)
+	}
+	got := markCursor("git commit ", "file.txt")
+	if !strings.HasPrefix(got, "git commit "+cursorMarker+"file.txt\n\n") || !strings.Contains(got, "inserted there") {
+		t.Errorf("expected the cursor to be marked and explained, got %q", got)
+	}
+}
+
+func TestMainInputCursorMidLine(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	mock := &mockProvider{response: "+-m \"fix typo\" "}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	_, out := runMain(t, "--provider", "mock", "--input", "git commit file.txt", "--input-cursor", "11", "--format", "raw")
+	if !strings.HasPrefix(mock.gotInput, "git commit "+cursorMarker+"file.txt") {
+		t.Errorf("expected the cursor to be marked in the prompt, got %q", mock.gotInput)
+	}
+	if out != "+-m \"fix typo\" " {
+		t.Errorf("expected only the text to insert at the cursor, got %q", out)
+	}
+
+	_, out = runMain(t, "--provider", "mock", "--input", "git commit file.txt", "--input-cursor", "11", "--format", "human")
+	if out != "git commit -m \"fix typo\" file.txt\n" {
+		t.Errorf("expected the completion inserted at the cursor, got %q", out)
+	}
+
+	exitCode, _ := runMain(t, "--provider", "mock", "--input", "ls", "--input-cursor", "5")
+	if exitCode != 1 {
+		t.Errorf("expected a cursor past the end to fail, got exit code %d", exitCode)
+	}
+}
+
+func TestSeparateFromCursorText(t *testing.T) {
+	cases := []struct {
+		before, after, completion, expected string
+	}{
+		{before: "git commit ", after: "file.txt", completion: "-m msg", expected: "-m msg "},
+		{before: "git commit ", after: "file.txt", completion: "-m msg ", expected: "-m msg "},
+		{before: "git commit ", after: " file.txt", completion: "-m msg", expected: "-m msg"},
+		{before: "git com", after: " file.txt", completion: "mit", expected: "mit"},
+		{before: "git commit ", after: "", completion: "-m msg", expected: "-m msg"},
+	}
+	for _, tc := range cases {
+		if got := separateFromCursorText(tc.before, tc.after, tc.completion); got != tc.expected {
+			t.Errorf("%q|%q with %q: expected %q, got %q", tc.before, tc.after, tc.completion, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// enabledEnv is a central kill switch: a boolean, or hostname globs such as
+// "dev-*,laptop" naming the only machines suggestions are made on.
+const enabledEnv = "SMART_SUGGESTION_ENABLED"
+
+var hostnameFunc = os.Hostname
+
+// suggestionsEnabled evaluates SMART_SUGGESTION_ENABLED. Unset means enabled.
+func suggestionsEnabled() bool {
+	value := strings.TrimSpace(os.Getenv(enabledEnv))
+	switch strings.ToLower(value) {
+	case "", "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+
+	hostname, err := hostnameFunc()
+	if err != nil {
+		debug.Log("Failed to get hostname, disabling suggestions", map[string]any{"error": err.Error()})
+		return false
+	}
+	hostname = strings.ToLower(hostname)
+	for _, pattern := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if matched, err := path.Match(pattern, hostname); err == nil && matched {
+			return true
+		}
+	}
+	debug.Log("Suggestions disabled on this host", map[string]any{
+		"hostname": hostname,
+		"patterns": value,
+	})
+	return false
+}
+
+// validateEnabled reports a SMART_SUGGESTION_ENABLED hostname glob that can
+// never match because it is malformed.
+func validateEnabled() error {
+	value := strings.TrimSpace(os.Getenv(enabledEnv))
+	switch strings.ToLower(value) {
+	case "", "1", "true", "yes", "on", "0", "false", "no", "off":
+		return nil
+	}
+	for _, pattern := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return fmt.Errorf("%s pattern %q is malformed: %w", enabledEnv, strings.TrimSpace(pattern), err)
+		}
+	}
+	return nil
+}
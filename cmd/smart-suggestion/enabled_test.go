@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+	"github.com/xyenon/smart-suggestion/internal/shellcontext"
+)
+
+func TestSuggestionsEnabled(t *testing.T) {
+	oldHostname := hostnameFunc
+	t.Cleanup(func() { hostnameFunc = oldHostname })
+	hostnameFunc = func() (string, error) { return "Dev-Box-3", nil }
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "", want: true},
+		{value: "true", want: true},
+		{value: " ON ", want: true},
+		{value: "1", want: true},
+		{value: "false", want: false},
+		{value: "No", want: false},
+		{value: "0", want: false},
+		{value: "dev-*", want: true},
+		{value: "laptop, dev-box-?", want: true},
+		{value: "laptop\ndev-*", want: true},
+		{value: "prod-*,jump-*", want: false},
+		{value: "[", want: false},
+	}
+	for _, tt := range tests {
+		t.Setenv(enabledEnv, tt.value)
+		if got := suggestionsEnabled(); got != tt.want {
+			t.Errorf("%s=%q: expected %v, got %v", enabledEnv, tt.value, tt.want, got)
+		}
+	}
+
+	hostnameFunc = func() (string, error) { return "", errors.New("no hostname") }
+	t.Setenv(enabledEnv, "dev-*")
+	if suggestionsEnabled() {
+		t.Error("expected suggestions to be disabled when the hostname is unknown")
+	}
+}
+
+func TestMainDisabled(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldBuildSystem := buildSystemContextFunc
+	oldBuildUser := buildUserContextFunc
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		buildSystemContextFunc = oldBuildSystem
+		buildUserContextFunc = oldBuildUser
+	})
+
+	touched := false
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		touched = true
+		return &mockProvider{response: "=ls"}, nil
+	}
+	buildSystemContextFunc = func(opts shellcontext.SystemContextOptions) (string, error) {
+		touched = true
+		return "", nil
+	}
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
+		touched = true
+		return "", nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	t.Setenv(enabledEnv, "false")
+
+	exitCode, out := runMain(t, "--provider", "mock", "--input", "list files", "--context")
+	if exitCode != -1 {
+		t.Errorf("expected a successful exit, got exit code %d", exitCode)
+	}
+	if out != "" {
+		t.Errorf("expected empty output, got %q", out)
+	}
+	if touched {
+		t.Error("expected neither the provider nor the context to be touched")
+	}
+
+	t.Setenv(enabledEnv, "true")
+	if _, out := runMain(t, "--provider", "mock", "--input", "list files"); out != "=ls" {
+		t.Errorf("expected a suggestion when enabled, got %q", out)
+	}
+}
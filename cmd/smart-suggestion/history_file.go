@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// loadHistoryFile reads the earlier conversation turns given by
+// --history-file: one JSON object per line with a role of user or assistant
+// and a content. Blank lines are skipped.
+func loadHistoryFile(path string) ([]provider.Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	var turns []provider.Message
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var turn provider.Message
+		if err := json.Unmarshal([]byte(text), &turn); err != nil {
+			return nil, fmt.Errorf("invalid history file %s:%d: %w", path, line, err)
+		}
+		if turn.Role != "user" && turn.Role != "assistant" {
+			return nil, fmt.Errorf("invalid history file %s:%d: role must be user or assistant, got %q", path, line, turn.Role)
+		}
+		if turn.Content == "" {
+			return nil, fmt.Errorf("invalid history file %s:%d: empty content", path, line)
+		}
+		turns = append(turns, turn)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return turns, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/prompt"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func writeHistoryFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHistoryFile(t *testing.T) {
+	path := writeHistoryFile(t, `{"role": "user", "content": "git st"}
+
+{"role": "assistant", "content": "=git status"}
+`)
+	turns, err := loadHistoryFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []provider.Message{{Role: "user", Content: "git st"}, {Role: "assistant", Content: "=git status"}}
+	if !slices.Equal(turns, expected) {
+		t.Errorf("expected %v, got %v", expected, turns)
+	}
+}
+
+func TestLoadHistoryFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"not JSON":      `{"role": "user", "content": "ls"}` + "\n" + `user: ls`,
+		"unknown role":  `{"role": "user", "content": "ls"}` + "\n" + `{"role": "system", "content": "be nice"}`,
+		"empty content": `{"role": "user", "content": "ls"}` + "\n" + `{"role": "assistant"}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadHistoryFile(writeHistoryFile(t, content))
+			if err == nil || !strings.Contains(err.Error(), ":2:") {
+				t.Errorf("expected an error naming line 2, got %v", err)
+			}
+		})
+	}
+
+	if _, err := loadHistoryFile(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestMainHistoryFile(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	mock := &mockProvider{response: "=git push"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	path := writeHistoryFile(t, `{"role": "user", "content": "git com"}`+"\n"+`{"role": "assistant", "content": "=git commit -m \"wip\""}`)
+
+	if _, out := runMain(t, "--provider", "mock", "--input", "git p", "--output", "-", "--history-file", path); out != "=git push" {
+		t.Fatalf("expected =git push, got %q", out)
+	}
+	examples := prompt.ExampleHistory()
+	if len(mock.gotHistory) != len(examples)+2 {
+		t.Fatalf("expected the examples and two turns, got %v", mock.gotHistory)
+	}
+	if !slices.Equal(mock.gotHistory[:len(examples)], examples) {
+		t.Errorf("expected the examples first, got %v", mock.gotHistory)
+	}
+	if last := mock.gotHistory[len(mock.gotHistory)-1]; last.Role != "assistant" || last.Content != `=git commit -m "wip"` {
+		t.Errorf("expected the file's turns last, got %v", last)
+	}
+
+	if exitCode, _ := runMain(t, "--provider", "mock", "--input", "git p", "--output", "-", "--history-file", filepath.Join(t.TempDir(), "missing")); exitCode != 1 {
+		t.Errorf("expected a missing history file to fail, got exit code %d", exitCode)
+	}
+}
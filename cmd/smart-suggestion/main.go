@@ -1,13 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/audit"
 	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/metrics"
 	"github.com/xyenon/smart-suggestion/internal/paths"
+	"github.com/xyenon/smart-suggestion/internal/prompt"
 	"github.com/xyenon/smart-suggestion/internal/provider"
 	"github.com/xyenon/smart-suggestion/internal/proxy"
 	"github.com/xyenon/smart-suggestion/internal/session"
@@ -16,152 +27,8 @@ import (
 	"github.com/xyenon/smart-suggestion/pkg"
 )
 
-const defaultSystemPrompt = `You are a professional SRE engineer with decades of experience, proficient in all shell commands.
-
-Your tasks:
-    - First, you must reason about the user's intent in <reasoning> tags. This reasoning will not be shown to the user.
-        Your reasoning process should follow these steps:
-        1. What is the user's real intention behind the recent input context?
-        2. Did the last few commands solve the intention? Why or why not?
-        3. Based on the latest information, how can you solve the user's intention?
-    - After reasoning, you will either complete the command or provide a new command that you think the user is trying to type.
-    - You need to predict what command the user wants to input next based on shell history and scrollback.
-
-RULES FOR FINAL OUTPUT (MANDATORY - MUST BE FOLLOWED EXACTLY):
-    - YOU MUST start your response with EITHER an equal sign (=) for new commands OR a plus sign (+) for completions. NO EXCEPTIONS!
-    - If you return a completely new command that the user didn't start typing, ALWAYS prefix with an equal sign (=). THIS IS CRUCIAL!
-    - If you return a completion for the user's partially typed command, ALWAYS prefix with a plus sign (+).
-    - MAKE SURE TO ONLY INCLUDE THE REST OF THE COMPLETION AFTER THE PLUS SIGN!
-    - NEVER include any leading or trailing characters except the required prefix and command/completion.
-    - ONLY respond with either a completion OR a new command, NOT BOTH.
-    - YOUR RESPONSE MUST START WITH EITHER = OR + AND NOTHING ELSE!
-    - NEVER start with both symbols or any other characters!
-    - NO NEWLINES ALLOWED IN YOUR RESPONSE!
-    - DO NOT ADD ANY ADDITIONAL TEXT, COMMENTS, OR EXPLANATIONS!
-    - YOUR RESPONSE WILL BE DIRECTLY EXECUTED IN THE USER'S SHELL, SO ACCURACY IS CRITICAL.
-    - FAILURE TO FOLLOW THESE FORMATTING RULES WILL RESULT IN YOUR RESPONSE BEING REJECTED.
-
-Example of your full response format:
-<reasoning>
-1. The user wants to see the logs for a pod that is in a CrashLoopBackOff state.
-2. The previous command 'kubectl get pods' listed the pods and their statuses, but did not show the logs.
-3. The next logical step is to use 'kubectl logs' on the failing pod to diagnose the issue.
-</reasoning>
-=kubectl -n my-namespace logs pod-name-aaa`
-
-// getExampleHistory returns conversation examples as message history
-func getExampleHistory() []provider.Message {
-	return []provider.Message{
-		// Example 1: New command for listing files
-		{Role: "user", Content: `# User input:
-
-list files in current directory`},
-		{Role: "assistant", Content: `<reasoning>
-1. The user wants to list files.
-2. No previous command.
-3. 'ls' is the command for listing files.
-</reasoning>
-=ls`},
-
-		// Example 2: New command after viewing log file details
-		{Role: "user", Content: `# Shell history:
-
-ls -l /tmp/smart-suggestion.log
-
-# User input:
-
-`},
-		{Role: "assistant", Content: `<reasoning>
-1. The user just listed details of a log file. A common next step is to view the content of that file.
-2. Listing the file does not show its content.
-3. The 'cat' command can be used to display the file content.
-</reasoning>
-=cat /tmp/smart-suggestion.log`},
-
-		// Example 3: New command for CrashLoopBackOff pods
-		{Role: "user", Content: `# Scrollback:
-
-# k -n my-namespace get pod
-NAME           READY   STATUS             RESTARTS         AGE
-pod-name-aaa   2/3     CrashLoopBackOff   358 (111s ago)   30h
-pod-name-bbb   2/3     CrashLoopBackOff   358 (3m8s ago)   30h
-
-# User input:
-
-`},
-		{Role: "assistant", Content: `<reasoning>
-1. The user is checking pods in a Kubernetes namespace.
-2. The pods are in 'CrashLoopBackOff', indicating a problem. The user likely wants to see the logs to debug.
-3. The command 'kubectl logs' will show the logs for 'pod-name-aaa'.
-</reasoning>
-=kubectl -n my-namespace logs pod-name-aaa`},
-
-		// Example 4: New command for pending pod
-		{Role: "user", Content: `# Scrollback:
-
-# k -n my-namespace get pod
-NAME           READY   STATUS             RESTARTS         AGE
-pod-name-aaa   3/3     Running            0                30h
-pod-name-bbb   0/3     Pending            0                30h
-
-# User input:
-
-`},
-		{Role: "assistant", Content: `<reasoning>
-1. The user is checking pods. One pod is 'Pending'.
-2. The 'get pod' command doesn't say why it's pending.
-3. 'kubectl describe pod' will give more details about why the pod is pending.
-</reasoning>
-=kubectl -n my-namespace describe pod pod-name-bbb`},
-
-		// Example 5: New command for NotReady node
-		{Role: "user", Content: `# Scrollback:
-
-# k get node
-NAME      STATUS   ROLES    AGE   VERSION
-node-aaa  Ready    <none>   3h    v1.25.3
-node-bbb  NotReady <none>   3h    v1.25.3
-
-# User input:
-
-`},
-		{Role: "assistant", Content: `<reasoning>
-1. The user is checking Kubernetes nodes. One node is 'NotReady'.
-2. 'get node' does not show the reason for the 'NotReady' status.
-3. 'kubectl describe node' will provide detailed events and information about the node's status.
-</reasoning>
-=kubectl describe node node-bbb`},
-
-		// Example 6: Completion for cd command
-		{Role: "user", Content: `# User input:
-
-cd /tm`},
-		{Role: "assistant", Content: `<reasoning>
-1. The user wants to change directory to a temporary folder.
-2. The user has typed '/tm' which is likely an abbreviation for '/tmp'.
-3. Completing with 'p' will form '/tmp'.
-</reasoning>
-+p`},
-
-		// Example 7: Completion for kubectl command
-		{Role: "user", Content: `# Scrollback:
-
-# k -n my-namespace get pod
-NAME           READY   STATUS             RESTARTS         AGE
-pod-name-aaa   3/3     Running            0                30h
-pod-name-bbb   0/3     Pending            0                30h
-
-# User input:
-
-k -n`},
-		{Role: "assistant", Content: `<reasoning>
-1. The user is checking pods. One pod is 'Pending'. They started typing a command.
-2. 'get pod' was useful but now they want to investigate 'pod-name-bbb'.
-3. I will complete the command to describe the pending pod.
-</reasoning>
-+ my-namespace describe pod pod-name-bbb`},
-	}
-}
+// fastMaxTokens caps the completion length in --fast mode.
+const fastMaxTokens = 64
 
 var (
 	Version   = "dev"
@@ -172,27 +39,71 @@ var (
 )
 
 var (
-	providerName    string
-	input           string
-	systemPrompt    string
-	dbg             bool
-	outputFile      string
-	sendContext     bool
-	proxyLogFile    string
-	sessionID       string
-	scrollbackLines int
-	scrollbackFile  string
+	providerName     string
+	input            string
+	systemPrompt     string
+	dbg              bool
+	outputFile       string
+	outputFormat     string
+	sendContext      bool
+	proxyLogFile     string
+	sessionID        string
+	scrollbackLines  int
+	scrollbackFile   string
+	fast             bool
+	reasoningAudit   string
+	detectAliases    bool
+	printPrompt      bool
+	dryContextFile   string
+	sinceLastPrompt  bool
+	prioritizeErrors bool
+	providersFile    string
+	metaFD           = -1
+	metaFile         string
+	semanticHistory  int
+	historyLines     int
+	proxyFollow      bool
+	proxyShell       string
+	proxySync        time.Duration
+	proxyNoCleanup   bool
+	proxySummary     bool
+	proxyRecordInput bool
+	scrollbackBytes  int
+	rotateSince      time.Duration
+	rotateIfLarger   string
+	pruneOlderThan   time.Duration
+	fetchTimeout     time.Duration
+	inputCursor      = -1
+	terseReasoning   bool
+	timeoutExitCode  int
+	redactPaths      bool
+	streamOutput     bool
+	recordFile       string
+	historyFile      string
+	replayRequery    bool
+	candidates       = 1
+	maxPromptBytes   int
 
 	logRotator *pkg.LogRotator
 )
 
 var exitFunc = os.Exit
+
+// defaultTimeoutExitCode is the exit status when the provider does not answer
+// within --timeout, the same as timeout(1), so the plugin can offer a retry
+// instead of showing a generic error.
+const defaultTimeoutExitCode = 124
+
+// suggestLockWait is how long a suggestion waits for an earlier one in the
+// same session to finish before giving up.
+var suggestLockWait = 2 * time.Second
 var buildSystemContextFunc = shellcontext.BuildSystemContext
 var buildUserContextFunc = shellcontext.BuildUserContext
 var runProxyFunc = proxy.RunProxy
-var checkUpdateFunc = updater.CheckUpdate
+var checkUpdateFunc = updater.CheckRelease
 var installUpdateFunc = updater.InstallUpdate
 var selectProviderFunc = selectProvider
+var newEmbedderFunc = func() (provider.Embedder, error) { return provider.NewOpenAIEmbedder() }
 
 func init() {
 	config := pkg.DefaultLogRotateConfig()
@@ -205,7 +116,10 @@ func init() {
 }
 
 func resolveSystemPrompt(sendContext bool) string {
-	basePrompt := defaultSystemPrompt
+	basePrompt := prompt.Default().String()
+	if terseReasoning {
+		basePrompt = prompt.Terse().String()
+	}
 	if systemPrompt != "" {
 		basePrompt = systemPrompt
 	}
@@ -214,7 +128,7 @@ func resolveSystemPrompt(sendContext bool) string {
 		return basePrompt
 	}
 
-	systemContext, err := buildSystemContextFunc()
+	systemContext, err := buildSystemContextFunc(shellcontext.SystemContextOptions{DetectAliases: detectAliases})
 	if err != nil {
 		debug.Log("Failed to build system context", map[string]any{
 			"error": err.Error(),
@@ -222,41 +136,264 @@ func resolveSystemPrompt(sendContext bool) string {
 		return basePrompt
 	}
 
-	return basePrompt + "\n\n" + systemContext
+	return basePrompt + "\n\n" + redactContext(systemContext)
+}
+
+// semanticHistorySelector returns a history selector that keeps the
+// --semantic-history entries closest to the input, or nil when disabled.
+func semanticHistorySelector(ctx context.Context) func([]string) ([]string, error) {
+	if semanticHistory <= 0 {
+		return nil
+	}
+	return func(entries []string) ([]string, error) {
+		embedder, err := newEmbedderFunc()
+		if err != nil {
+			return nil, err
+		}
+		return provider.SelectRelevant(ctx, embedder, input, entries, semanticHistory)
+	}
+}
+
+func buildUserInput(input string, opts shellcontext.UserContextOptions, sendContext bool) string {
+	return withUserContext(input, buildContext(opts, sendContext))
 }
 
-func buildUserInput(input string, scrollbackLines int, scrollbackFile string, sendContext bool) string {
+// buildContext returns the context sent along with the input, redacted before
+// it is trimmed to opts.MaxBytes, or "" when there is none.
+func buildContext(opts shellcontext.UserContextOptions, sendContext bool) string {
 	if !sendContext {
-		return input
+		return ""
 	}
 
-	userContext, err := buildUserContextFunc(scrollbackLines, scrollbackFile)
+	opts.Redact = redactContext
+	userContext, err := buildUserContextFunc(opts)
 	if err != nil {
 		debug.Log("Failed to build user context", map[string]any{
 			"error": err.Error(),
 		})
-		return input
+		return ""
+	}
+
+	return userContext
+}
+
+// addUserContext puts the context in front of the input, trimmed to fit
+// within limit bytes along with the rest of the request when limit is set.
+func (r *suggestRequest) addUserContext(limit int) {
+	described := describeInput(r.inputBefore, r.inputAfter)
+	opts := shellcontext.UserContextOptions{
+		ScrollbackLines:  scrollbackLines,
+		HistoryLines:     historyLines,
+		ScrollbackFile:   scrollbackFile,
+		SessionID:        sessionID,
+		SelectHistory:    semanticHistorySelector(r.ctx),
+		SinceLastPrompt:  sinceLastPrompt,
+		PrioritizeErrors: prioritizeErrors,
+		Input:            input,
 	}
+	withContext := sendContext
+	if limit > 0 {
+		opts.MaxBytes = contextByteBudget(limit, r.systemPrompt, r.history, described)
+		if withContext && opts.MaxBytes <= 0 {
+			debug.Log("No room for context within the prompt budget", map[string]any{
+				"max_prompt_bytes": limit,
+			})
+			withContext = false
+		}
+	}
+	r.userContext = buildContext(opts, withContext)
+	r.userInput = withUserContext(described, r.userContext)
+}
 
+// userInputHeading separates the context from the input in the user message.
+const userInputHeading = "\n\n# User input:\n\n"
+
+// withUserContext puts userContext in front of input, as the user message.
+func withUserContext(input string, userContext string) string {
 	if userContext == "" {
 		return input
 	}
+	return userContext + userInputHeading + input
+}
+
+// fastGenerationOptions returns the sampling parameters used by --fast.
+func fastGenerationOptions() provider.GenerationOptions {
+	temperature := 0.0
+	return provider.GenerationOptions{
+		MaxTokens:   fastMaxTokens,
+		Temperature: &temperature,
+	}
+}
+
+// resolveProviderName returns the --provider value when given. Otherwise it
+// consults SMART_SUGGESTION_PROVIDER_RULES for the current directory and falls
+// back to SMART_SUGGESTION_AI_PROVIDER, then LLM_PROVIDER.
+func resolveProviderName() (string, error) {
+	if providerName != "" {
+		return providerName, nil
+	}
+
+	rules, err := provider.ParseProviderRules(os.Getenv("SMART_SUGGESTION_PROVIDER_RULES"))
+	if err != nil {
+		return "", err
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if name := provider.MatchProviderRule(rules, cwd); name != "" {
+			debug.Log("Selected provider by directory rule", map[string]any{
+				"provider": name,
+				"cwd":      cwd,
+			})
+			return name, nil
+		}
+	}
+
+	return defaultProviderName(), nil
+}
+
+// defaultProviderName returns SMART_SUGGESTION_AI_PROVIDER, or the provider
+// named by the vendor-neutral LLM_PROVIDER when it is unset.
+func defaultProviderName() string {
+	if name := os.Getenv("SMART_SUGGESTION_AI_PROVIDER"); name != "" {
+		return name
+	}
+	return provider.UnifiedProvider()
+}
 
-	return userContext + "\n\n# User input:\n\n" + input
+// providersFilePath returns the providers file named by --providers-file or
+// SMART_SUGGESTION_PROVIDERS_FILE, falling back to providers.json beside the
+// config file when it exists.
+func providersFilePath() string {
+	if providersFile != "" {
+		return providersFile
+	}
+	if path := os.Getenv("SMART_SUGGESTION_PROVIDERS_FILE"); path != "" {
+		return path
+	}
+	if config := paths.GetConfigFile(); config != "" {
+		path := filepath.Join(filepath.Dir(config), "providers.json")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
 }
 
 func selectProvider(ctx *cobra.Command) (provider.Provider, error) {
-	switch strings.ToLower(providerName) {
+	name, err := resolveProviderName()
+	if err != nil {
+		return nil, err
+	}
+	names := providerChain(name)
+	if len(names) < 2 {
+		return newProvider(ctx.Context(), name)
+	}
+
+	// A fallback that cannot be built, say for want of a key, must not take
+	// the providers that can be down with it.
+	var built []string
+	var providers []provider.Provider
+	var errs []error
+	for _, name := range names {
+		p, err := newProvider(ctx.Context(), name)
+		if err != nil {
+			debug.Log("Skipping provider in fallback chain", map[string]any{
+				"provider": name,
+				"error":    err.Error(),
+			})
+			errs = append(errs, fmt.Errorf("provider %s: %w", name, err))
+			continue
+		}
+		built = append(built, name)
+		providers = append(providers, p)
+	}
+	switch len(providers) {
+	case 0:
+		return nil, errors.Join(errs...)
+	case 1:
+		return providers[0], nil
+	}
+	return provider.NewFallbackProvider(built, providers), nil
+}
+
+// providerChain splits a comma-separated provider list such as
+// "openai,gemini" into the providers to try in order.
+func providerChain(name string) []string {
+	var names []string
+	for _, part := range strings.Split(name, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			names = append(names, part)
+		}
+	}
+	return names
+}
+
+// newProvider returns the provider called name: an endpoint from the providers
+// file, or one of the built-in providers.
+func newProvider(ctx context.Context, name string) (provider.Provider, error) {
+	// Endpoints from the providers file take precedence over built-in names.
+	if path := providersFilePath(); path != "" {
+		endpoints, err := provider.LoadEndpoints(path)
+		if err != nil {
+			return nil, err
+		}
+		if endpoint, ok := endpoints[strings.ToLower(name)]; ok {
+			debug.Log("Using provider from providers file", map[string]any{
+				"provider": name,
+				"protocol": endpoint.Protocol,
+				"file":     path,
+			})
+			return provider.NewEndpointProvider(ctx, name, endpoint)
+		}
+	}
+
+	switch strings.ToLower(name) {
 	case "openai":
 		return provider.NewOpenAIProvider()
+	case "openai_compatible":
+		return provider.NewOpenAICompatibleProvider()
 	case "azure_openai":
 		return provider.NewAzureOpenAIProvider()
 	case "anthropic":
 		return provider.NewAnthropicProvider()
 	case "gemini":
-		return provider.NewGeminiProvider(ctx.Context())
+		return provider.NewGeminiProvider(ctx)
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s (valid: openai, azure_openai, anthropic, gemini)", providerName)
+		return nil, fmt.Errorf("unsupported provider: %s (valid: %s)", name, strings.Join(provider.BuiltinProviders, ", "))
+	}
+}
+
+// writeReasoningAudit appends the reasoning behind suggestion to the audit file
+// configured by --trim-reasoning-to-file or SMART_SUGGESTION_REASONING_AUDIT_FILE.
+func writeReasoningAudit(response string, suggestion string) error {
+	path := reasoningAudit
+	if path == "" {
+		path = os.Getenv("SMART_SUGGESTION_REASONING_AUDIT_FILE")
+	}
+	if path == "" {
+		return nil
+	}
+
+	return audit.Append(path, audit.Record{
+		Timestamp: time.Now(),
+		Command:   suggestion,
+		Reasoning: provider.ExtractReasoning(response),
+	})
+}
+
+// recordSessionMetrics adds a request that started at start to the metrics
+// file of a proxy run with --session-summary. Failing to record is only logged.
+func recordSessionMetrics(start time.Time, err error) {
+	path := os.Getenv(metrics.FileEnv)
+	if path == "" {
+		return
+	}
+	record := metrics.Record{
+		Timestamp: start,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Error:     err != nil,
+	}
+	if err := metrics.Append(path, record); err != nil {
+		debug.Log("Failed to record session metrics", map[string]any{"error": err.Error()})
 	}
 }
 
@@ -269,12 +406,74 @@ func writeSuggestion(outputFile string, suggestion string) error {
 		return nil
 	}
 
-	if err := os.WriteFile(outputFile, []byte(suggestion), 0644); err != nil {
+	if err := writeFileAtomic(outputFile, []byte(suggestion), 0644); err != nil {
 		return fmt.Errorf("failed to write suggestion to file: %w", err)
 	}
 	return nil
 }
 
+// writeFileAtomic replaces path with data through a temporary file in the same
+// directory, so readers see either the old or the new content, never a partial
+// write. Paths that are not regular files (pipes, devices) and directories
+// where no temporary file can be created are written directly instead.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		return os.WriteFile(path, data, perm)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return os.WriteFile(path, data, perm)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return os.WriteFile(path, data, perm)
+	}
+	return nil
+}
+
+// addSuggestFlags registers the flags shared by every command that queries the provider.
+func addSuggestFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&providerName, "provider", "p", "", "AI provider (openai, openai_compatible, azure_openai, anthropic, gemini, or a name from the providers file); a comma-separated list falls back to the next on errors")
+	cmd.Flags().StringVar(&providersFile, "providers-file", "", "JSON file of named provider endpoints (default $SMART_SUGGESTION_PROVIDERS_FILE or providers.json beside the config file)")
+	cmd.Flags().StringVarP(&input, "input", "i", "", "User input")
+	cmd.Flags().IntVar(&inputCursor, "input-cursor", -1, "Byte offset of the cursor in --input; + completions are inserted there (-1 = end of input)")
+	cmd.Flags().StringVarP(&systemPrompt, "system", "s", "", "System prompt (optional, uses default if not provided)")
+	cmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+	cmd.Flags().BoolVarP(&sendContext, "context", "c", false, "Include context information")
+	cmd.Flags().IntVar(&scrollbackLines, "scrollback-lines", 100, "Number of scrollback lines to send (0 = none)")
+	cmd.Flags().IntVar(&historyLines, "history-lines", 0, "Maximum number of most recent history entries to send (0 = all)")
+	cmd.Flags().StringVar(&historyFile, "history-file", "", "JSON lines file of earlier {\"role\": \"user\"|\"assistant\", \"content\": ...} turns to send before the input")
+	cmd.Flags().StringVar(&scrollbackFile, "scrollback-file", "", "Path to scrollback file (Ghostty integration)")
+	cmd.Flags().BoolVar(&sinceLastPrompt, "since-last-prompt", false, "Send only the scrollback from the last command's prompt on")
+	cmd.Flags().BoolVar(&redactPaths, "context-redact-paths", false, "Replace the home directory with ~ and the user name with $USER in the context")
+	cmd.Flags().BoolVar(&prioritizeErrors, "prioritize-errors", false, "When trimming scrollback, keep earlier error lines instead of only the most recent lines")
+	cmd.Flags().StringVar(&sessionID, "session-id", "", "Read scrollback from the proxy log of this session instead of the current one")
+	cmd.Flags().IntVar(&semanticHistory, "semantic-history", 0, "Send only the N history entries most relevant to the input, ranked by OpenAI embeddings (0 = send all)")
+	cmd.Flags().BoolVar(&detectAliases, "detect-aliases", false, "Run $SHELL -ic alias to collect aliases when SMART_SUGGESTION_ALIASES is not set")
+	cmd.Flags().BoolVar(&printPrompt, "print-prompt", false, "Print the exact request sent to the provider to stderr, with API keys redacted")
+	cmd.Flags().StringVar(&dryContextFile, "dry-context-file", "", "Write the messages sent to the provider, after redaction and trimming, to this file as JSON (for bug reports)")
+	cmd.Flags().DurationVar(&fetchTimeout, "timeout", 0, "Give up on the whole fetch, fallback providers included, after this long; SMART_SUGGESTION_TIMEOUT bounds each request (0 = no limit)")
+	cmd.Flags().IntVar(&maxPromptBytes, "max-prompt-bytes", 0, "Trim the oldest scrollback lines, then history entries, so the whole request fits in this many bytes (default $SMART_SUGGESTION_MAX_PROMPT_BYTES, 0 = no limit)")
+	cmd.Flags().BoolVar(&terseReasoning, "terse-reasoning", false, "Ask for one short sentence of reasoning instead of three steps, for lower latency and cost")
+}
+
 func buildRootCmd() *cobra.Command {
 	var rootCmd = &cobra.Command{
 		Use:   "smart-suggestion",
@@ -282,14 +481,17 @@ func buildRootCmd() *cobra.Command {
 		RunE:  runSuggest,
 	}
 
-	rootCmd.Flags().StringVarP(&providerName, "provider", "p", "", "AI provider (openai, azure_openai, anthropic, gemini)")
-	rootCmd.Flags().StringVarP(&input, "input", "i", "", "User input")
-	rootCmd.Flags().StringVarP(&systemPrompt, "system", "s", "", "System prompt (optional, uses default if not provided)")
-	rootCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+	addSuggestFlags(rootCmd)
 	rootCmd.Flags().StringVarP(&outputFile, "output", "o", "-", "Output file path")
-	rootCmd.Flags().BoolVarP(&sendContext, "context", "c", false, "Include context information")
-	rootCmd.Flags().IntVar(&scrollbackLines, "scrollback-lines", 100, "Number of scrollback lines to send")
-	rootCmd.Flags().StringVar(&scrollbackFile, "scrollback-file", "", "Path to scrollback file (Ghostty integration)")
+	rootCmd.Flags().StringVar(&outputFormat, "format", formatAuto, "Output format: auto (human when writing to a terminal), raw (=/+ prefixed, for the plugin) or human")
+	rootCmd.Flags().StringVar(&reasoningAudit, "trim-reasoning-to-file", "", "Append the model reasoning to this audit file (default $SMART_SUGGESTION_REASONING_AUDIT_FILE)")
+	rootCmd.Flags().BoolVar(&fast, "fast", false, "Quick completion: minimal prompt, no context, low max tokens")
+	rootCmd.Flags().IntVar(&metaFD, "meta-fd", -1, "Write JSON metadata (type, reasoning, latency, model) to this file descriptor")
+	rootCmd.Flags().StringVar(&metaFile, "meta", "", "Write JSON metadata (type, reasoning, latency, model) to this file (- for stdout)")
+	rootCmd.Flags().IntVar(&timeoutExitCode, "timeout-exit-code", defaultTimeoutExitCode, "Exit status when --timeout or SMART_SUGGESTION_TIMEOUT is reached")
+	rootCmd.Flags().IntVar(&candidates, "candidates", 1, "Ask for this many suggestions and write the distinct ones to --output, one per line")
+	rootCmd.Flags().BoolVar(&streamOutput, "stream", false, "Write the answer to --output as it arrives, then the final answer (see PROTOCOL.md)")
+	rootCmd.Flags().StringVar(&recordFile, "record", "", "Append each suggestion's input, context, provider, raw response and parsed command to this bundle for replay (default $SMART_SUGGESTION_RECORD_FILE)")
 
 	var proxyCmd = &cobra.Command{
 		Use:   "proxy",
@@ -300,6 +502,13 @@ func buildRootCmd() *cobra.Command {
 	proxyCmd.Flags().StringVarP(&sessionID, "session-id", "", "", "Session ID for log isolation (auto-generated if not provided)")
 	proxyCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
 	proxyCmd.Flags().IntVar(&scrollbackLines, "scrollback-lines", 100, "Number of scrollback lines to keep in log")
+	proxyCmd.Flags().IntVar(&scrollbackBytes, "scrollback-bytes", 0, "Also drop the oldest lines while the log is larger than this many bytes (0 = no limit)")
+	proxyCmd.Flags().StringVar(&proxyShell, "shell", "", "Shell to run inside the proxy (defaults to $SHELL)")
+	proxyCmd.Flags().BoolVar(&proxyFollow, "follow", false, "Re-read the config file before each command so scrollback and deny pattern changes apply without restarting")
+	proxyCmd.Flags().DurationVar(&proxySync, "sync-interval", time.Second, "Sync the log to disk at most this often (0 = after every write, negative = only on exit)")
+	proxyCmd.Flags().BoolVar(&proxyNoCleanup, "no-cleanup", false, "Keep session logs older than a day instead of deleting them at startup (also SMART_SUGGESTION_KEEP_SESSION_LOGS=true)")
+	proxyCmd.Flags().BoolVar(&proxySummary, "session-summary", false, "Print the number of suggestions, their average latency and errors when the shell exits")
+	proxyCmd.Flags().BoolVar(&proxyRecordInput, "record-input", false, "Also record each command line the shell runs, prefixed with \""+proxy.InputMarker+"\"")
 
 	var rotateCmd = &cobra.Command{
 		Use:   "rotate-logs",
@@ -308,6 +517,8 @@ func buildRootCmd() *cobra.Command {
 	}
 	rotateCmd.Flags().StringVarP(&proxyLogFile, "log-file", "l", "", "Log file path to rotate (required)")
 	rotateCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+	rotateCmd.Flags().DurationVar(&rotateSince, "since", 0, "Only rotate if the log was last modified longer ago than this (e.g. 24h)")
+	rotateCmd.Flags().StringVar(&rotateIfLarger, "if-larger", "", "Only rotate if the log is larger than this size (e.g. 10MB)")
 	rotateCmd.MarkFlagRequired("log-file")
 
 	var updateCmd = &cobra.Command{
@@ -316,6 +527,9 @@ func buildRootCmd() *cobra.Command {
 		Run:   runUpdate,
 	}
 	updateCmd.Flags().BoolP("check-only", "c", false, "Only check for updates, don't install")
+	updateCmd.Flags().BoolP("yes", "y", false, "Install without asking for confirmation")
+	updateCmd.Flags().Bool("auto", false, "Run at most once per --interval, backing off after failures (for automatic updates)")
+	updateCmd.Flags().Duration("interval", 24*time.Hour, "Minimum time between --auto runs")
 
 	var versionCmd = &cobra.Command{
 		Use:   "version",
@@ -329,12 +543,88 @@ func buildRootCmd() *cobra.Command {
 		},
 	}
 
-	rootCmd.AddCommand(proxyCmd, rotateCmd, updateCmd, versionCmd)
+	var pickCmd = &cobra.Command{
+		Use:   "pick",
+		Short: "Fetch several candidate commands and pick one interactively",
+		RunE:  runPick,
+	}
+	addSuggestFlags(pickCmd)
+	pickCmd.Flags().IntVarP(&pickCandidates, "candidates", "n", 3, "Number of candidate commands to fetch")
+
+	var captureCmd = &cobra.Command{
+		Use:   "capture",
+		Short: "Print the captured terminal scrollback and exit",
+		RunE:  runCapture,
+	}
+	captureCmd.Flags().IntVar(&scrollbackLines, "scrollback-lines", 100, "Number of scrollback lines to print (0 = all)")
+	captureCmd.Flags().StringVar(&scrollbackFile, "scrollback-file", "", "Path to scrollback file (Ghostty integration)")
+	captureCmd.Flags().StringVar(&sessionID, "session-id", "", "Read the proxy log of this session instead of the current one")
+	captureCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+
+	var pruneCmd = &cobra.Command{
+		Use:   "prune-cache",
+		Short: "Remove cache files older than a given age, keeping live sessions",
+		RunE:  runPruneCache,
+	}
+	pruneCmd.Flags().DurationVar(&pruneOlderThan, "older-than", 0, "Remove cache files last modified longer ago than this (e.g. 168h, required)")
+	pruneCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+	pruneCmd.MarkFlagRequired("older-than")
+
+	var warmupCmd = &cobra.Command{
+		Use:   "warmup",
+		Short: "Do nothing: no process outlives a suggestion to hold a warmed-up provider connection",
+		Args:  cobra.NoArgs,
+		RunE:  runWarmup,
+	}
+	warmupCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+
+	var validateConfigCmd = &cobra.Command{
+		Use:   "validate-config",
+		Short: "Check the provider configuration without contacting the provider",
+		RunE:  runValidateConfig,
+		// The problems were already listed; usage would bury them.
+		SilenceUsage: true,
+	}
+	validateConfigCmd.Flags().StringVarP(&providerName, "provider", "p", "", "Only check this provider (default: every provider SMART_SUGGESTION_AI_PROVIDER and SMART_SUGGESTION_PROVIDER_RULES can select)")
+	validateConfigCmd.Flags().StringVar(&providersFile, "providers-file", "", "JSON file of named provider endpoints (default $SMART_SUGGESTION_PROVIDERS_FILE or providers.json beside the config file)")
+	validateConfigCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+
+	var replayCmd = &cobra.Command{
+		Use:   "replay <bundle>",
+		Short: "Parse the responses recorded with --record again, and optionally ask the provider again",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runReplay,
+		// The differences were already listed; usage would bury them.
+		SilenceUsage: true,
+	}
+	replayCmd.Flags().BoolVar(&replayRequery, "requery", false, "Also send each recorded request to the provider again")
+	replayCmd.Flags().StringVarP(&providerName, "provider", "p", "", "Provider to send the requests to with --requery (default: the recorded one)")
+	replayCmd.Flags().StringVar(&providersFile, "providers-file", "", "JSON file of named provider endpoints (default $SMART_SUGGESTION_PROVIDERS_FILE or providers.json beside the config file)")
+	replayCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+
+	var providersCmd = &cobra.Command{
+		Use:   "providers",
+		Short: "List the available providers, the environment variables they read and whether they are ready",
+		Args:  cobra.NoArgs,
+		RunE:  runProviders,
+	}
+	providersCmd.Flags().StringVar(&providersFile, "providers-file", "", "JSON file of named provider endpoints (default $SMART_SUGGESTION_PROVIDERS_FILE or providers.json beside the config file)")
+	providersCmd.Flags().BoolVarP(&dbg, "debug", "d", false, "Enable debug logging")
+
+	var checkAnimationCmd = &cobra.Command{
+		Use:   "check-animation",
+		Short: "Check whether TERM and stderr support the plugin's loading animation: exit 0 if so, 1 otherwise",
+		Args:  cobra.NoArgs,
+		Run:   runCheckAnimation,
+	}
+
+	rootCmd.AddCommand(proxyCmd, rotateCmd, updateCmd, versionCmd, pickCmd, captureCmd, pruneCmd, warmupCmd, validateConfigCmd, replayCmd, providersCmd, checkAnimationCmd)
 
 	return rootCmd
 }
 
 func main() {
+	provider.Version = Version
 	rootCmd := buildRootCmd()
 
 	if err := rootCmd.Execute(); err != nil {
@@ -343,53 +633,450 @@ func main() {
 	}
 }
 
-func runSuggest(cmd *cobra.Command, args []string) error {
-	debug.Enable(dbg)
+// suggestRequest holds everything needed to ask the provider for a suggestion.
+type suggestRequest struct {
+	ctx          context.Context
+	providerName string
+	client       provider.Provider
+	systemPrompt string
+	userInput    string
+	history      []provider.Message
+	// userContext is the context appended to userInput, after redaction
+	// and trimming.
+	userContext string
+	// inputBefore and inputAfter are --input split at --input-cursor.
+	inputBefore string
+	inputAfter  string
+}
+
+// errNothingToSuggest is returned for an empty input without context: the
+// model would have nothing to go on. With context, an empty input asks for the
+// next command instead.
+var errNothingToSuggest = errors.New("nothing to suggest for an empty input without context")
 
-	if providerName == "" {
-		return fmt.Errorf("required flag \"provider\" not set")
+// prepareSuggestRequest validates the flags, assembles the prompt and selects the provider.
+func prepareSuggestRequest(cmd *cobra.Command) (*suggestRequest, error) {
+	activeProvider, err := resolveProviderName()
+	if err != nil {
+		return nil, err
+	}
+	if activeProvider == "" {
+		return nil, fmt.Errorf("required flag \"provider\" not set")
 	}
-	if input == "" {
-		return fmt.Errorf("required flag \"input\" not set")
+	if input == "" && (fast || !sendContext) {
+		return nil, errNothingToSuggest
+	}
+	if sessionID != "" {
+		if _, err := shellcontext.SessionLogFile(sessionID); err != nil {
+			return nil, err
+		}
 	}
 
-	systemPromptStr := resolveSystemPrompt(sendContext)
-	userInput := buildUserInput(input, scrollbackLines, scrollbackFile, sendContext)
-	providerClient, err := selectProviderFunc(cmd)
+	before, after, err := splitAtCursor(input, inputCursor)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := promptByteLimit()
+	if err != nil {
+		return nil, err
+	}
 
+	req := &suggestRequest{
+		ctx:          cmd.Context(),
+		providerName: activeProvider,
+		inputBefore:  before,
+		inputAfter:   after,
+	}
+	if fast {
+		req.systemPrompt = prompt.Fast().String()
+		req.userInput = describeInput(before, after)
+		req.ctx = provider.WithGenerationOptions(req.ctx, fastGenerationOptions())
+	} else {
+		req.systemPrompt = resolveSystemPrompt(sendContext)
+		req.history = prompt.ExampleHistory()
+		if terseReasoning {
+			req.history = prompt.TerseExampleHistory()
+		}
+		if historyFile != "" {
+			turns, err := loadHistoryFile(historyFile)
+			if err != nil {
+				return nil, err
+			}
+			// After the examples, so the conversation leads up to the input.
+			req.history = append(req.history, turns...)
+		}
+	}
+	if req.systemPrompt, err = applyPromptPolicy(req.systemPrompt); err != nil {
+		return nil, err
+	}
+	if !fast {
+		req.addUserContext(limit)
+	}
+	if dryContextFile != "" {
+		if err := req.writeMessages(dryContextFile); err != nil {
+			return nil, fmt.Errorf("failed to write context file: %w", err)
+		}
+	}
+	if printPrompt {
+		req.ctx = provider.WithPrintPrompt(req.ctx, os.Stderr)
+	}
+	req.logContextBudget()
+
+	req.client, err = selectProviderFunc(cmd)
 	if err != nil {
 		debug.Log("Error occurred", map[string]any{
 			"error":    err.Error(),
-			"provider": providerName,
-			"input":    userInput,
+			"provider": activeProvider,
+			"input":    req.userInput,
 		})
 
-		return fmt.Errorf("error fetching suggestions from %s API: %w", providerName, err)
+		return nil, fmt.Errorf("error fetching suggestions from %s API: %w", activeProvider, err)
 	}
+	return req, nil
+}
 
-	suggestion, err := providerClient.FetchWithHistory(cmd.Context(), userInput, systemPromptStr, getExampleHistory())
+// writeMessages writes the messages the request sends to path as a JSON
+// array: the system prompt, the history and the user message with its context.
+func (r *suggestRequest) writeMessages(path string) error {
+	messages := append([]provider.Message{{Role: "system", Content: r.systemPrompt}}, r.history...)
+	messages = append(messages, provider.Message{Role: "user", Content: r.userInput})
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The context can hold secrets from the scrollback, like the cache.
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// fetch sends the request and returns the raw provider response.
+func (r *suggestRequest) fetch() (string, error) {
+	return r.fetchWith(func(ctx context.Context) (string, error) {
+		return r.client.FetchWithHistory(ctx, r.userInput, r.systemPrompt, r.history)
+	})
+}
+
+// fetchWith runs call under the rate limit cooldown and --timeout, and wraps
+// its error for the user.
+func (r *suggestRequest) fetchWith(call func(ctx context.Context) (string, error)) (string, error) {
+	if err := provider.CheckCooldown(r.providerName); err != nil {
+		debug.Log("Skipping request during rate limit cooldown", map[string]any{
+			"error":    err.Error(),
+			"provider": r.providerName,
+		})
+		return "", err
+	}
+
+	ctx := r.ctx
+	if fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fetchTimeout)
+		defer cancel()
+	}
+
+	suggestion, err := call(ctx)
 	if err != nil {
 		debug.Log("Error occurred", map[string]any{
 			"error":    err.Error(),
-			"provider": providerName,
-			"input":    userInput,
+			"provider": r.providerName,
+			"input":    r.userInput,
+		})
+
+		// A refusal already explains itself and how to get past it.
+		var filtered *provider.ContentFilteredError
+		if errors.As(err, &filtered) {
+			return "", filtered
+		}
+		return "", fmt.Errorf("error fetching suggestions from %s API: %w", r.providerName, err)
+	}
+	return suggestion, nil
+}
+
+// timedOut returns how long the provider was waited for when err means it
+// did not answer in time: SMART_SUGGESTION_TIMEOUT for a single request, or
+// --timeout for the whole fetch.
+func timedOut(err error) (time.Duration, bool) {
+	var timeoutErr *provider.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr.Timeout, true
+	}
+	if errors.Is(err, context.DeadlineExceeded) && fetchTimeout > 0 {
+		return fetchTimeout, true
+	}
+	return 0, false
+}
+
+// inDeniedDirectory reports whether the working directory matches one of the
+// globs in SMART_SUGGESTION_DENY_DIRS. No suggestion is requested there, so
+// nothing from a sensitive directory ever reaches the provider.
+func inDeniedDirectory() bool {
+	patterns := provider.ParseDirPatterns(os.Getenv("SMART_SUGGESTION_DENY_DIRS"))
+	if len(patterns) == 0 {
+		return false
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false
+	}
+	if provider.MatchDirPatterns(patterns, cwd) {
+		debug.Log("Suggestions disabled in denied directory", map[string]any{
+			"cwd": cwd,
+		})
+		return true
+	}
+	return false
+}
+
+func runSuggest(cmd *cobra.Command, args []string) error {
+	debug.Enable(dbg)
+	if !suggestionsEnabled() {
+		if outputFile == "" {
+			return nil
+		}
+		return writeSuggestion(outputFile, "")
+	}
+	warnOutdatedPlugin(os.Stderr, time.Now())
+
+	if outputFile == "" && metaFile == "" && metaFD < 0 {
+		return fmt.Errorf("at least one of --output, --meta or --meta-fd must be specified")
+	}
+	human, err := humanOutput(outputFormat, outputFile)
+	if err != nil {
+		return err
+	}
+	if candidates < 1 {
+		return fmt.Errorf("--candidates must be at least 1")
+	}
+	if candidates > 1 && (streamOutput || metaFile != "" || metaFD >= 0) {
+		return fmt.Errorf("--candidates cannot be combined with --stream, --meta or --meta-fd")
+	}
+
+	if inDeniedDirectory() {
+		if outputFile == "" {
+			return nil
+		}
+		return writeSuggestion(outputFile, "")
+	}
+
+	unlock, err := lockSuggestSession()
+	if errors.Is(err, session.ErrLocked) {
+		debug.Log("Skipping suggestion while another one runs for this session", map[string]any{
+			"wait": suggestLockWait.String(),
 		})
+		return nil
+	}
+	if err != nil {
+		debug.Log("Failed to lock session, continuing without it", map[string]any{"error": err.Error()})
+	} else {
+		defer unlock()
+	}
+
+	req, err := prepareSuggestRequest(cmd)
+	if errors.Is(err, errNothingToSuggest) {
+		debug.Log("Skipping suggestion", map[string]any{"reason": err.Error()})
+		if outputFile == "" {
+			return nil
+		}
+		return writeSuggestion(outputFile, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	threshold, err := minConfidence()
+	if err != nil {
+		return err
+	}
+	safe, err := safeMode()
+	if err != nil {
+		return err
+	}
+	if candidates > 1 {
+		return writeCandidates(req, candidates, human, safe)
+	}
+	cacheTTL, err := suggestionCacheTTL()
+	if err != nil {
+		return err
+	}
+	var confidence *provider.Confidence
+	if threshold > 0 || metaFile != "" || metaFD >= 0 {
+		confidence = &provider.Confidence{}
+		req.ctx = provider.WithConfidence(req.ctx, confidence)
+	}
+
+	var stream *streamWriter
+	if streamOutput && outputFile != "" && !human {
+		stream = &streamWriter{path: outputFile}
+	}
 
-		return fmt.Errorf("error fetching suggestions from %s API: %w", providerName, err)
+	start := time.Now()
+	cacheKey := req.cacheKey()
+	suggestion, cached := lookupSuggestion(cacheKey, cacheTTL)
+	if cached {
+		debug.Log("Using cached suggestion", map[string]any{
+			"provider": req.providerName,
+			"ttl":      cacheTTL.String(),
+		})
+	} else if stream != nil {
+		suggestion, err = req.fetchStream(stream)
+	} else {
+		suggestion, err = req.fetch()
+	}
+	recordSessionMetrics(start, err)
+	if err != nil {
+		req.record("", "", "", err)
+	}
+	if timeout, ok := timedOut(err); ok {
+		fmt.Fprintf(os.Stderr, "Suggestion timed out after %s\n", timeout)
+		exitFunc(timeoutExitCode)
+		return nil
 	}
+	if err != nil {
+		return err
+	}
+	latency := time.Since(start)
 
-	finalSuggestion := provider.ParseAndExtractCommand(suggestion)
+	parsed, err := provider.ExtractCommand(suggestion)
+	if err != nil {
+		debug.Log("Invalid response", map[string]any{
+			"error":             err.Error(),
+			"provider":          req.providerName,
+			"original_response": suggestion,
+		})
+		req.record(suggestion, "", "", err)
+		return fmt.Errorf("invalid response from %s: %w", req.providerName, err)
+	}
+	if !cached && cacheTTL > 0 {
+		if err := storeSuggestion(cacheKey, suggestion, cacheTTL); err != nil {
+			debug.Log("Failed to cache suggestion", map[string]any{"error": err.Error()})
+		}
+	}
+	finalSuggestion := provider.TransformCommand(provider.ModelName(req.client), parsed)
+	finalSuggestion = cleanSuggestion(req.inputBefore, req.inputAfter, finalSuggestion)
+	if tooUnsure(confidence, threshold) {
+		debug.Log("Dropping low-confidence suggestion", map[string]any{
+			"provider":   req.providerName,
+			"suggestion": finalSuggestion,
+			"confidence": confidence.Value,
+			"threshold":  threshold,
+		})
+		finalSuggestion = ""
+	}
+	if safe {
+		finalSuggestion = neutralizeReplacement(req.inputBefore, req.inputAfter, finalSuggestion)
+	}
+	req.record(suggestion, parsed, finalSuggestion, nil)
 
 	debug.Log("Successfully fetched suggestion", map[string]any{
-		"provider":          providerName,
-		"input":             userInput,
+		"provider":          req.providerName,
+		"input":             req.userInput,
 		"original_response": suggestion,
 		"parsed_suggestion": finalSuggestion,
 	})
 
-	if err := writeSuggestion(outputFile, finalSuggestion); err != nil {
+	if err := writeReasoningAudit(suggestion, finalSuggestion); err != nil {
+		return fmt.Errorf("failed to write reasoning audit: %w", err)
+	}
+
+	if outputFile != "" {
+		output := finalSuggestion
+		if human && finalSuggestion != "" {
+			output = formatHumanSuggestion(req.inputBefore, req.inputAfter, suggestion, finalSuggestion)
+		}
+		if stream != nil {
+			err = stream.finish(output)
+		} else {
+			err = writeSuggestion(outputFile, output)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	meta := newSuggestionMeta(req, suggestion, finalSuggestion, latency)
+	meta.NonDestructive = safe
+	// The plugin shows what is printed to stderr next to the suggestion, so
+	// an objection reaches the user without --meta as well.
+	meta.Warning = req.review(finalSuggestion)
+	if meta.Warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", meta.Warning)
+	}
+	if confidence != nil && confidence.Known {
+		meta.Confidence = &confidence.Value
+	}
+	if metaFile != "" {
+		if err := writeMetaFile(metaFile, meta); err != nil {
+			return err
+		}
+	}
+	if metaFD >= 0 {
+		if err := writeMetaFD(metaFD, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockSuggestSession serializes suggestions within a session, so overlapping
+// invocations from rapid keypresses never interleave their writes.
+func lockSuggestSession() (func(), error) {
+	sessID := sessionID
+	if sessID == "" {
+		sessID = session.GetCurrentSessionID()
+	}
+	lockPath := session.GetSessionBasedLogFile(filepath.Join(paths.GetCacheDir(), "suggest.lock"), sessID)
+	return session.Lock(lockPath, suggestLockWait)
+}
+
+func runCapture(cmd *cobra.Command, args []string) error {
+	debug.Enable(dbg)
+
+	content, err := shellcontext.CaptureScrollback(shellcontext.UserContextOptions{
+		ScrollbackLines: scrollbackLines,
+		ScrollbackFile:  scrollbackFile,
+		SessionID:       sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to capture scrollback: %w", err)
+	}
+
+	if content != "" {
+		fmt.Println(content)
+	}
+	return nil
+}
+
+// runWarmup is a no-op and sends no request. Each suggestion runs in a process
+// of its own, so a connection opened here would close again when warmup exits,
+// and every shell start would pay for a request that no suggestion benefits
+// from. provider.Warmup is kept for a long-lived process that holds the
+// connection to call.
+func runWarmup(cmd *cobra.Command, args []string) error {
+	debug.Enable(dbg)
+	debug.Log("Skipping warmup: no long-lived process holds provider connections", map[string]any{})
+	return nil
+}
+
+func runPruneCache(cmd *cobra.Command, args []string) error {
+	debug.Enable(dbg)
+
+	cacheDir := paths.GetCacheDir()
+	pruned, err := proxy.PruneCache(cacheDir, pruneOlderThan)
+	var freed int64
+	for _, file := range pruned {
+		fmt.Printf("Removed %s\n", file.Path)
+		freed += file.Size
+	}
+	if err != nil {
 		return err
 	}
+
+	debug.Log("Pruned cache", map[string]any{
+		"cache_dir":  cacheDir,
+		"older_than": pruneOlderThan.String(),
+		"files":      len(pruned),
+		"bytes":      freed,
+	})
+	fmt.Printf("Pruned %d files (%d bytes) older than %s from %s\n", len(pruned), freed, pruneOlderThan, cacheDir)
 	return nil
 }
 
@@ -400,7 +1087,10 @@ func runProxy(cmd *cobra.Command, args []string) {
 	if sessID == "" {
 		sessID = session.GetCurrentSessionID()
 	}
-	shell := os.Getenv("SHELL")
+	shell := proxyShell
+	if shell == "" {
+		shell = os.Getenv("SHELL")
+	}
 	if shell == "" {
 		shell = "/bin/bash"
 	}
@@ -410,19 +1100,89 @@ func runProxy(cmd *cobra.Command, args []string) {
 		logFile = paths.GetDefaultProxyLogFile()
 	}
 
-	err := runProxyFunc(shell, proxy.ProxyOptions{
+	opts := proxy.ProxyOptions{
 		LogFile:         logFile,
 		SessionID:       sessID,
 		ScrollbackLines: scrollbackLines,
-	})
+		SyncInterval:    proxySync,
+		RecordInput:     proxyRecordInput,
+		ScrollbackBytes: scrollbackBytes,
+	}
+	if proxyFollow {
+		opts.ConfigFile = paths.GetConfigFile()
+	}
+	// The proxy is exec'd in place of the shell, so a bad pattern must not
+	// stop it from starting.
+	denyCommands, err := proxy.ParseCommandPatterns(os.Getenv(proxy.DenyCommandsEnvVar))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring %s: %v\n", proxy.DenyCommandsEnvVar, err)
+	}
+	opts.DenyCommands = denyCommands
+
+	opts.KeepSessionLogs = proxyNoCleanup
+	if keep := os.Getenv("SMART_SUGGESTION_KEEP_SESSION_LOGS"); keep != "" && !proxyNoCleanup {
+		if opts.KeepSessionLogs, err = strconv.ParseBool(keep); err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring SMART_SUGGESTION_KEEP_SESSION_LOGS: invalid value %q\n", keep)
+		}
+	}
+
+	if proxySummary {
+		opts.MetricsFile = session.GetSessionBasedLogFile(paths.GetDefaultMetricsFile(), sessID)
+	}
+
+	err = runProxyFunc(shell, opts)
 	if err != nil {
 		fmt.Printf("Proxy error: %v\n", err)
 	}
 }
 
+// rotateConditionsMet reports whether the log file satisfies every condition
+// given with --since and --if-larger. It returns a reason when it does not.
+func rotateConditionsMet(path string, since time.Duration, minSize int64) (bool, string, error) {
+	if since <= 0 && minSize <= 0 {
+		return true, "", nil
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, "log file does not exist", nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	if since > 0 {
+		if age := time.Since(info.ModTime()); age < since {
+			return false, fmt.Sprintf("last modified %s ago, not older than %s", age.Round(time.Second), since), nil
+		}
+	}
+	if minSize > 0 && info.Size() <= minSize {
+		return false, fmt.Sprintf("size %d bytes is not larger than %d bytes", info.Size(), minSize), nil
+	}
+	return true, "", nil
+}
+
 func runRotateLogs(cmd *cobra.Command, args []string) error {
 	debug.Enable(dbg)
 
+	var minSize int64
+	if rotateIfLarger != "" {
+		size, err := pkg.ParseSizeString(rotateIfLarger)
+		if err != nil {
+			return fmt.Errorf("invalid --if-larger: %w", err)
+		}
+		minSize = size
+	}
+
+	ok, reason, err := rotateConditionsMet(proxyLogFile, rotateSince, minSize)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Printf("Skipped rotating %s: %s\n", proxyLogFile, reason)
+		return nil
+	}
+
 	debug.Log("Rotating log file", map[string]any{
 		"log_file": proxyLogFile,
 	})
@@ -435,10 +1195,96 @@ func runRotateLogs(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// changelogExcerptLines caps how much of the release notes update shows.
+const changelogExcerptLines = 10
+
+// updateConfirmInput is where update reads the answer to its confirmation prompt.
+var updateConfirmInput io.Reader = os.Stdin
+
+// changelogExcerpt returns the first non-empty lines of the release notes,
+// marking the excerpt when lines were left out.
+func changelogExcerpt(notes string, maxLines int) string {
+	var lines []string
+	truncated := false
+	for line := range strings.SplitSeq(strings.ReplaceAll(notes, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(lines) == maxLines {
+			truncated = true
+			break
+		}
+		lines = append(lines, line)
+	}
+	if truncated {
+		lines = append(lines, "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// confirmUpdate asks whether to install the given version; anything other
+// than y or yes, including end of input, declines.
+func confirmUpdate(version string) bool {
+	fmt.Printf("Install version %s? [y/N] ", version)
+	answer, _ := bufio.NewReader(updateConfirmInput).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// autoUpdateStampFile returns the stamp that spaces out update --auto runs.
+func autoUpdateStampFile() string {
+	return filepath.Join(paths.GetCacheDir(), "auto_update.json")
+}
+
+// startAutoUpdate reports whether an update --auto run is due. When it is, the
+// run is recorded as failed up front, so one that is killed mid-install also
+// backs off, and the returned function clears the failures on success.
+func startAutoUpdate(interval time.Duration, now time.Time) (func(), bool) {
+	stampFile := autoUpdateStampFile()
+	stamp := updater.LoadStamp(stampFile)
+	if !stamp.Due(now, interval) {
+		debug.Log("Skipping automatic update check", map[string]any{
+			"next_check": stamp.NextCheck(interval).Format(time.RFC3339),
+			"failures":   stamp.Failures,
+		})
+		return nil, false
+	}
+
+	attempt := updater.CheckStamp{LastCheck: now, Failures: stamp.Failures + 1}
+	if err := attempt.Save(stampFile); err != nil {
+		debug.Log("Failed to write update stamp", map[string]any{"error": err.Error()})
+	}
+	return func() {
+		attempt.Failures = 0
+		if err := attempt.Save(stampFile); err != nil {
+			debug.Log("Failed to write update stamp", map[string]any{"error": err.Error()})
+		}
+	}, true
+}
+
 func runUpdate(cmd *cobra.Command, args []string) {
 	checkOnly, _ := cmd.Flags().GetBool("check-only")
+	assumeYes, _ := cmd.Flags().GetBool("yes")
+	auto, _ := cmd.Flags().GetBool("auto")
+	succeeded := func() {}
+	if auto {
+		interval, _ := cmd.Flags().GetDuration("interval")
+		var due bool
+		if succeeded, due = startAutoUpdate(interval, time.Now()); !due {
+			if checkOnly {
+				exitFunc(1)
+			}
+			return
+		}
+	}
 	fmt.Println("Checking for updates...")
-	latest, url, err := checkUpdateFunc(Version)
+	release, err := checkUpdateFunc(Version)
+	latest, url := release.Version, release.URL
 	if err != nil {
 		fmt.Printf("Check failed: %v\n", err)
 		if checkOnly {
@@ -447,6 +1293,7 @@ func runUpdate(cmd *cobra.Command, args []string) {
 		return
 	}
 	if url == "" {
+		succeeded()
 		fmt.Println("Smart Suggestion is already up to date!")
 		if checkOnly {
 			exitFunc(1)
@@ -454,14 +1301,26 @@ func runUpdate(cmd *cobra.Command, args []string) {
 		return
 	}
 	if checkOnly {
+		succeeded()
 		fmt.Printf("New version %s available.\n", latest)
 		exitFunc(0)
 		return
 	}
-	fmt.Printf("New version %s available. Installing...\n", latest)
+	fmt.Printf("Current version: %s\n", strings.TrimPrefix(Version, "v"))
+	fmt.Printf("Latest version:  %s\n", latest)
+	if excerpt := changelogExcerpt(release.Notes, changelogExcerptLines); excerpt != "" {
+		fmt.Printf("\nChangelog:\n%s\n\n", excerpt)
+	}
+	if !assumeYes && !confirmUpdate(latest) {
+		succeeded()
+		fmt.Println("Update cancelled.")
+		return
+	}
+	fmt.Printf("Installing %s...\n", latest)
 	if err := installUpdateFunc(url); err != nil {
 		fmt.Printf("Install failed: %v\n", err)
 	} else {
+		succeeded()
 		fmt.Println("Successfully updated!")
 	}
 }
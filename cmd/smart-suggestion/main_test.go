@@ -2,15 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/audit"
+	"github.com/xyenon/smart-suggestion/internal/metrics"
+	"github.com/xyenon/smart-suggestion/internal/prompt"
 	"github.com/xyenon/smart-suggestion/internal/provider"
 	"github.com/xyenon/smart-suggestion/internal/proxy"
+	"github.com/xyenon/smart-suggestion/internal/shellcontext"
+	"github.com/xyenon/smart-suggestion/internal/updater"
 )
 
 func TestResolveSystemPrompt(t *testing.T) {
@@ -21,12 +33,12 @@ func TestResolveSystemPrompt(t *testing.T) {
 		buildSystemContextFunc = oldBuildSystemContext
 	})
 
-	buildSystemContextFunc = func() (string, error) {
+	buildSystemContextFunc = func(opts shellcontext.SystemContextOptions) (string, error) {
 		return "", nil
 	}
 
 	systemPrompt = ""
-	if got := resolveSystemPrompt(false); got != defaultSystemPrompt {
+	if got := resolveSystemPrompt(false); got != prompt.Default().String() {
 		t.Fatalf("expected default prompt, got %q", got)
 	}
 
@@ -36,12 +48,12 @@ func TestResolveSystemPrompt(t *testing.T) {
 	}
 
 	// Test with sendContext=true to verify context concatenation
-	buildSystemContextFunc = func() (string, error) {
+	buildSystemContextFunc = func(opts shellcontext.SystemContextOptions) (string, error) {
 		return "mocked system context", nil
 	}
 	systemPrompt = ""
 	got := resolveSystemPrompt(true)
-	if got != defaultSystemPrompt+"\n\n"+"mocked system context" {
+	if got != prompt.Default().String()+"\n\n"+"mocked system context" {
 		t.Fatalf("expected prompt with context, got %q", got)
 	}
 
@@ -53,9 +65,38 @@ func TestResolveSystemPrompt(t *testing.T) {
 	}
 }
 
+func TestMainTerseReasoning(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	mock := &mockProvider{response: "<reasoning>Listing with details shows sizes.</reasoning>\n=ls -la"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	exitCode, out := runMain(t, "--provider", "mock", "--input", "list files", "--terse-reasoning")
+	if exitCode != -1 {
+		t.Fatalf("expected a successful exit, got exit code %d", exitCode)
+	}
+	if mock.gotSystemPrompt != prompt.Terse().String() {
+		t.Errorf("expected the terse prompt, got %q", mock.gotSystemPrompt)
+	}
+	if len(mock.gotHistory) < 2 || mock.gotHistory[1] != prompt.TerseExampleHistory()[1] {
+		t.Errorf("expected the terse example history, got %v", mock.gotHistory)
+	}
+	if out != "=ls -la" {
+		t.Errorf("expected the command to parse as before, got %q", out)
+	}
+
+	runMain(t, "--provider", "mock", "--input", "list files")
+	if mock.gotSystemPrompt != prompt.Default().String() {
+		t.Errorf("expected the default prompt without --terse-reasoning")
+	}
+}
+
 func TestBuildUserInputWithScrollback(t *testing.T) {
 	old := buildUserContextFunc
-	buildUserContextFunc = func(scrollbackLines int, scrollbackFile string) (string, error) {
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
 		return "", nil
 	}
 	t.Cleanup(func() { buildUserContextFunc = old })
@@ -65,17 +106,72 @@ func TestBuildUserInputWithScrollback(t *testing.T) {
 		t.Fatalf("failed to write scrollback file: %v", err)
 	}
 
-	buildUserContextFunc = func(scrollbackLines int, scrollbackFile string) (string, error) {
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
 		return "# Scrollback:\n\nsecond", nil
 	}
 
-	got := buildUserInput("test", 1, file, true)
+	got := buildUserInput("test", shellcontext.UserContextOptions{ScrollbackLines: 1, ScrollbackFile: file}, true)
 	expected := "# Scrollback:\n\nsecond\n\n# User input:\n\ntest"
 	if got != expected {
 		t.Fatalf("expected user input with scrollback content, got %q, want %q", got, expected)
 	}
 }
 
+func TestMainDryContextFile(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldBuild := buildUserContextFunc
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		buildUserContextFunc = oldBuild
+	})
+	mock := &mockProvider{response: "=make test"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
+		return "# Scrollback:\n\n$ make\nmake: *** No rule to make target 'all'.", nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	file := filepath.Join(t.TempDir(), "context.txt")
+
+	runMain(t, "--provider", "mock", "--input", "make", "--context", "--dry-context-file", file)
+
+	readMessages := func() []provider.Message {
+		t.Helper()
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read context file: %v", err)
+		}
+		var messages []provider.Message
+		if err := json.Unmarshal(data, &messages); err != nil {
+			t.Fatalf("expected the context file to hold JSON messages, got %q: %v", data, err)
+		}
+		if len(messages) < 2 {
+			t.Fatalf("expected at least the system and user messages, got %v", messages)
+		}
+		return messages
+	}
+
+	messages := readMessages()
+	if messages[0].Role != "system" || messages[0].Content != mock.gotSystemPrompt {
+		t.Errorf("expected the system prompt sent first, got %+v", messages[0])
+	}
+	last := messages[len(messages)-1]
+	if last.Role != "user" || last.Content != mock.gotInput {
+		t.Errorf("expected the user message sent last, got %+v", last)
+	}
+	if !strings.Contains(last.Content, "No rule to make target") {
+		t.Errorf("expected the user message to carry the context, got %q", last.Content)
+	}
+
+	// Without context, the user message is the input alone.
+	runMain(t, "--provider", "mock", "--input", "make", "--dry-context-file", file)
+	messages = readMessages()
+	if last := messages[len(messages)-1]; last.Content != mock.gotInput || strings.Contains(last.Content, "Scrollback") {
+		t.Errorf("expected the bare input as the user message, got %+v", last)
+	}
+}
+
 func TestRunSuggestMissingFlags(t *testing.T) {
 	oldProvider := providerName
 	oldInput := input
@@ -97,12 +193,213 @@ func TestRunSuggestMissingFlags(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for missing provider flag")
 	}
+}
 
-	providerName = "openai"
-	input = ""
-	err = runSuggest(cmd, nil)
-	if err == nil {
-		t.Fatal("expected error for missing input flag")
+// runMain runs main with args and returns the exit code passed to exitFunc
+// (-1 when it was not called) and what was written to stdout.
+func runMain(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	oldArgs := os.Args
+	oldExit := exitFunc
+	oldProvider := providerName
+	oldInput := input
+	oldOutput := outputFile
+	oldFormat := outputFormat
+	oldContext := sendContext
+	oldFast := fast
+	oldScrollback := scrollbackLines
+	oldMetaFD := metaFD
+	oldMetaFile := metaFile
+	oldTimeout := fetchTimeout
+	oldTimeoutExitCode := timeoutExitCode
+	oldTerse := terseReasoning
+	oldCursor := inputCursor
+	oldRedact := redactPaths
+	oldStream := streamOutput
+	oldDryContext := dryContextFile
+	oldRecord := recordFile
+	oldRequery := replayRequery
+	oldHistoryFile := historyFile
+	oldCandidates := candidates
+	oldMaxPromptBytes := maxPromptBytes
+	oldProvidersFile := providersFile
+	t.Cleanup(func() {
+		os.Args = oldArgs
+		exitFunc = oldExit
+		providerName = oldProvider
+		input = oldInput
+		outputFile = oldOutput
+		outputFormat = oldFormat
+		sendContext = oldContext
+		fast = oldFast
+		scrollbackLines = oldScrollback
+		metaFD = oldMetaFD
+		metaFile = oldMetaFile
+		fetchTimeout = oldTimeout
+		timeoutExitCode = oldTimeoutExitCode
+		terseReasoning = oldTerse
+		inputCursor = oldCursor
+		redactPaths = oldRedact
+		streamOutput = oldStream
+		dryContextFile = oldDryContext
+		recordFile = oldRecord
+		replayRequery = oldRequery
+		historyFile = oldHistoryFile
+		candidates = oldCandidates
+		maxPromptBytes = oldMaxPromptBytes
+		providersFile = oldProvidersFile
+	})
+
+	exitCode := -1
+	exitFunc = func(code int) { exitCode = code }
+	os.Args = append([]string{"smart-suggestion"}, args...)
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	main()
+	w.Close()
+	os.Stdout = stdout
+
+	out, _ := io.ReadAll(r)
+	return exitCode, string(out)
+}
+
+func TestMainEmptyInputWithoutContext(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	mock := &mockProvider{response: "=ls"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+
+	for _, args := range [][]string{
+		{"--provider", "mock", "--input", ""},
+		{"--provider", "mock"},
+		{"--provider", "mock", "--input", "", "--context", "--fast"},
+	} {
+		exitCode, out := runMain(t, args...)
+		if exitCode != -1 {
+			t.Errorf("%v: expected a successful exit, got exit code %d", args, exitCode)
+		}
+		if out != "" {
+			t.Errorf("%v: expected empty output, got %q", args, out)
+		}
+	}
+	if mock.gotCtx != nil {
+		t.Error("expected the provider not to be asked")
+	}
+}
+
+func TestMainEmptyInputWithContext(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldSystem := buildSystemContextFunc
+	oldUser := buildUserContextFunc
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		buildSystemContextFunc = oldSystem
+		buildUserContextFunc = oldUser
+	})
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	mock := &mockProvider{response: "=git status"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	buildSystemContextFunc = func(opts shellcontext.SystemContextOptions) (string, error) {
+		return "system context", nil
+	}
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
+		return "# Shell history:\n\ngit add .", nil
+	}
+
+	exitCode, out := runMain(t, "--provider", "mock", "--input", "", "--context")
+	if exitCode != -1 {
+		t.Fatalf("expected a successful exit, got exit code %d", exitCode)
+	}
+	if out != "=git status" {
+		t.Errorf("expected the predicted command, got %q", out)
+	}
+	if !strings.HasSuffix(mock.gotInput, "# User input:\n\n") {
+		t.Errorf("expected the context with an empty user input, got %q", mock.gotInput)
+	}
+}
+
+// deadlineProvider blocks until the request context ends.
+type deadlineProvider struct{}
+
+func (deadlineProvider) Fetch(ctx context.Context, input, systemPrompt string) (string, error) {
+	return deadlineProvider{}.FetchWithHistory(ctx, input, systemPrompt, nil)
+}
+
+func (deadlineProvider) FetchWithHistory(ctx context.Context, input, systemPrompt string, history []provider.Message) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestMainTimeoutExitCode(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return deadlineProvider{}, nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	exitCode, out := runMain(t, "--provider", "mock", "--input", "ls", "--timeout", "20ms")
+	if exitCode != defaultTimeoutExitCode {
+		t.Errorf("expected exit code %d, got %d", defaultTimeoutExitCode, exitCode)
+	}
+	if out != "" {
+		t.Errorf("expected no suggestion, got %q", out)
+	}
+
+	exitCode, _ = runMain(t, "--provider", "mock", "--input", "ls", "--timeout", "20ms", "--timeout-exit-code", "75")
+	if exitCode != 75 {
+		t.Errorf("expected exit code 75, got %d", exitCode)
+	}
+}
+
+func TestMainProviderTimeoutExitCode(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	timeoutErr := &provider.TimeoutError{Timeout: 15 * time.Second, Err: context.DeadlineExceeded}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{err: fmt.Errorf("failed to create chat completion: %w", timeoutErr)}, nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	exitCode, out := runMain(t, "--provider", "mock", "--input", "ls")
+	if exitCode != defaultTimeoutExitCode {
+		t.Errorf("expected exit code %d, got %d", defaultTimeoutExitCode, exitCode)
+	}
+	if out != "" {
+		t.Errorf("expected no suggestion, got %q", out)
+	}
+}
+
+func TestMainRecordsSessionMetrics(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	mock := &mockProvider{response: "=ls -la"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	metricsFile := filepath.Join(t.TempDir(), "metrics.jsonl")
+	t.Setenv(metrics.FileEnv, metricsFile)
+
+	runMain(t, "--provider", "mock", "--input", "ls")
+	mock.err = errors.New("boom")
+	runMain(t, "--provider", "mock", "--input", "ls")
+
+	summary, err := metrics.SummarizeFile(metricsFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary.Suggestions != 2 || summary.Errors != 1 {
+		t.Errorf("expected 2 suggestions and 1 error, got %+v", summary)
 	}
 }
 
@@ -139,7 +436,7 @@ func TestWriteSuggestion(t *testing.T) {
 }
 
 func TestGetExampleHistory(t *testing.T) {
-	if len(getExampleHistory()) == 0 {
+	if len(prompt.ExampleHistory()) == 0 {
 		t.Fatal("expected example history entries")
 	}
 }
@@ -195,6 +492,47 @@ func TestSelectProvider(t *testing.T) {
 	}
 }
 
+func TestSelectProviderFallbackChain(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(t.Context())
+	originalProvider := providerName
+	t.Cleanup(func() { providerName = originalProvider })
+	t.Setenv("SMART_SUGGESTION_PROVIDERS_FILE", "")
+	t.Setenv("SMART_SUGGESTION_CONFIG", filepath.Join(t.TempDir(), "config.zsh"))
+	t.Setenv("OPENAI_API_KEY", "fake")
+	t.Setenv("ANTHROPIC_API_KEY", "fake")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY_FILE", "")
+
+	providerName = "openai, anthropic"
+	p, err := selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fallback, ok := p.(*provider.FallbackProvider)
+	if !ok {
+		t.Fatalf("expected a FallbackProvider, got %T", p)
+	}
+	if !slices.Equal(fallback.Names, []string{"openai", "anthropic"}) {
+		t.Errorf("expected openai then anthropic, got %v", fallback.Names)
+	}
+
+	// Gemini has no key, so only OpenAI is left to try.
+	providerName = "gemini,openai"
+	p, err = selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := p.(*provider.OpenAIProvider); !ok {
+		t.Errorf("expected the OpenAI provider on its own, got %T", p)
+	}
+
+	providerName = "gemini,unknown"
+	if _, err := selectProvider(cmd); err == nil || !strings.Contains(err.Error(), "provider unknown") {
+		t.Errorf("expected an error naming every provider, got %v", err)
+	}
+}
+
 func TestRunRotateLogs(t *testing.T) {
 	file := filepath.Join(t.TempDir(), "proxy.log")
 	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
@@ -215,6 +553,68 @@ func TestRunRotateLogs(t *testing.T) {
 	}
 }
 
+func TestRunRotateLogsConditions(t *testing.T) {
+	oldLogFile := proxyLogFile
+	oldSince := rotateSince
+	oldIfLarger := rotateIfLarger
+	t.Cleanup(func() {
+		proxyLogFile = oldLogFile
+		rotateSince = oldSince
+		rotateIfLarger = oldIfLarger
+	})
+
+	tests := []struct {
+		name        string
+		age         time.Duration
+		since       time.Duration
+		ifLarger    string
+		wantRotated bool
+	}{
+		{name: "since met", age: 48 * time.Hour, since: 24 * time.Hour, wantRotated: true},
+		{name: "since not met", age: time.Hour, since: 24 * time.Hour, wantRotated: false},
+		{name: "if-larger met", ifLarger: "1KB", wantRotated: true},
+		{name: "if-larger not met", ifLarger: "1MB", wantRotated: false},
+		{name: "both met", age: 48 * time.Hour, since: 24 * time.Hour, ifLarger: "1KB", wantRotated: true},
+		{name: "only one met", age: 48 * time.Hour, since: 24 * time.Hour, ifLarger: "1MB", wantRotated: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "proxy.log")
+			if err := os.WriteFile(file, []byte(strings.Repeat("x", 2048)), 0644); err != nil {
+				t.Fatalf("failed to write log: %v", err)
+			}
+			mtime := time.Now().Add(-tt.age)
+			if err := os.Chtimes(file, mtime, mtime); err != nil {
+				t.Fatalf("failed to set mtime: %v", err)
+			}
+
+			proxyLogFile = file
+			rotateSince = tt.since
+			rotateIfLarger = tt.ifLarger
+			if err := runRotateLogs(nil, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err := os.Stat(file)
+			rotated := os.IsNotExist(err)
+			if rotated != tt.wantRotated {
+				t.Errorf("expected rotated=%v, got %v", tt.wantRotated, rotated)
+			}
+		})
+	}
+}
+
+func TestRunRotateLogsInvalidSize(t *testing.T) {
+	oldIfLarger := rotateIfLarger
+	t.Cleanup(func() { rotateIfLarger = oldIfLarger })
+
+	rotateIfLarger = "lots"
+	if err := runRotateLogs(nil, nil); err == nil {
+		t.Fatal("expected error for invalid --if-larger")
+	}
+}
+
 func TestRunRotateLogsMissingFile(t *testing.T) {
 	file := filepath.Join(t.TempDir(), "missing.log")
 	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
@@ -254,8 +654,8 @@ func TestRunUpdateCheckOnlyAlreadyLatest(t *testing.T) {
 	exitFunc = func(code int) {
 		exitCode = code
 	}
-	checkUpdateFunc = func(currentVersion string) (string, string, error) {
-		return "1.0.0", "", nil
+	checkUpdateFunc = func(currentVersion string) (updater.Release, error) {
+		return updater.Release{Version: "1.0.0"}, nil
 	}
 	installUpdateFunc = func(url string) error {
 		return nil
@@ -285,8 +685,8 @@ func TestRunUpdateCheckOnlyUpdateAvailable(t *testing.T) {
 	exitFunc = func(code int) {
 		exitCode = code
 	}
-	checkUpdateFunc = func(currentVersion string) (string, string, error) {
-		return "1.1.0", "https://example.com/update", nil
+	checkUpdateFunc = func(currentVersion string) (updater.Release, error) {
+		return updater.Release{Version: "1.1.0", URL: "https://example.com/update"}, nil
 	}
 	installCalled := false
 	installUpdateFunc = func(url string) error {
@@ -313,11 +713,11 @@ func TestBuildUserInputNoContext(t *testing.T) {
 		buildUserContextFunc = old
 	})
 
-	buildUserContextFunc = func(scrollbackLines int, scrollbackFile string) (string, error) {
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
 		return "extra context info", nil
 	}
 
-	userInput := buildUserInput("test input", 10, "", false)
+	userInput := buildUserInput("test input", shellcontext.UserContextOptions{ScrollbackLines: 10}, false)
 	if userInput != "test input" {
 		t.Fatalf("expected 'test input' when sendContext is false, got %q", userInput)
 	}
@@ -329,11 +729,11 @@ func TestBuildUserInputContextError(t *testing.T) {
 		buildUserContextFunc = old
 	})
 
-	buildUserContextFunc = func(scrollbackLines int, scrollbackFile string) (string, error) {
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
 		return "", errors.New("fail")
 	}
 
-	userInput := buildUserInput("test input", 10, "", true)
+	userInput := buildUserInput("test input", shellcontext.UserContextOptions{ScrollbackLines: 10}, true)
 	if userInput != "test input" {
 		t.Fatalf("expected 'test input' on error, got %q", userInput)
 	}
@@ -365,17 +765,25 @@ func TestRunProxy(t *testing.T) {
 	oldLogFile := proxyLogFile
 	oldSessionID := sessionID
 	oldScrollback := scrollbackLines
+	oldSync := proxySync
 	t.Cleanup(func() {
 		runProxyFunc = oldRunProxy
 		dbg = oldDebug
 		proxyLogFile = oldLogFile
 		sessionID = oldSessionID
 		scrollbackLines = oldScrollback
+		proxySync = oldSync
 	})
 
 	called := false
 	runProxyFunc = func(shell string, opts proxy.ProxyOptions) error {
 		called = true
+		if opts.ConfigFile != "" {
+			t.Errorf("expected no config file without --follow, got %q", opts.ConfigFile)
+		}
+		if opts.SyncInterval != 5*time.Second {
+			t.Errorf("expected sync interval 5s, got %s", opts.SyncInterval)
+		}
 		return nil
 	}
 
@@ -383,6 +791,7 @@ func TestRunProxy(t *testing.T) {
 	proxyLogFile = ""
 	sessionID = "test-session"
 	scrollbackLines = 50
+	proxySync = 5 * time.Second
 
 	runProxy(nil, nil)
 	if !called {
@@ -390,6 +799,95 @@ func TestRunProxy(t *testing.T) {
 	}
 }
 
+func TestRunProxyFollow(t *testing.T) {
+	oldRunProxy := runProxyFunc
+	oldFollow := proxyFollow
+	oldSessionID := sessionID
+	t.Cleanup(func() {
+		runProxyFunc = oldRunProxy
+		proxyFollow = oldFollow
+		sessionID = oldSessionID
+	})
+
+	configFile := filepath.Join(t.TempDir(), "config.zsh")
+	t.Setenv("SMART_SUGGESTION_CONFIG", configFile)
+
+	var got proxy.ProxyOptions
+	runProxyFunc = func(shell string, opts proxy.ProxyOptions) error {
+		got = opts
+		return nil
+	}
+
+	proxyFollow = true
+	sessionID = "test-session"
+	runProxy(nil, nil)
+
+	if got.ConfigFile != configFile {
+		t.Errorf("expected config file %q, got %q", configFile, got.ConfigFile)
+	}
+}
+
+func TestRunProxyShellFlag(t *testing.T) {
+	oldRunProxy := runProxyFunc
+	oldShell := proxyShell
+	oldSessionID := sessionID
+	t.Cleanup(func() {
+		runProxyFunc = oldRunProxy
+		proxyShell = oldShell
+		sessionID = oldSessionID
+	})
+
+	var gotShell string
+	runProxyFunc = func(shell string, opts proxy.ProxyOptions) error {
+		gotShell = shell
+		return nil
+	}
+	sessionID = "test-session"
+	t.Setenv("SHELL", "/bin/zsh")
+
+	proxyShell = "/usr/bin/fish"
+	runProxy(nil, nil)
+	if gotShell != "/usr/bin/fish" {
+		t.Errorf("expected --shell to be used, got %q", gotShell)
+	}
+
+	proxyShell = ""
+	runProxy(nil, nil)
+	if gotShell != "/bin/zsh" {
+		t.Errorf("expected $SHELL without --shell, got %q", gotShell)
+	}
+}
+
+func TestRunProxyDenyCommands(t *testing.T) {
+	oldRunProxy := runProxyFunc
+	oldSessionID := sessionID
+	t.Cleanup(func() {
+		runProxyFunc = oldRunProxy
+		sessionID = oldSessionID
+	})
+
+	var got proxy.ProxyOptions
+	runProxyFunc = func(shell string, opts proxy.ProxyOptions) error {
+		got = opts
+		return nil
+	}
+	sessionID = "test-session"
+
+	t.Setenv("SMART_SUGGESTION_PROXY_DENY_COMMANDS", "^vault read\n^op ")
+	runProxy(nil, nil)
+	if len(got.DenyCommands) != 2 {
+		t.Fatalf("expected 2 deny patterns, got %d", len(got.DenyCommands))
+	}
+
+	// An invalid pattern is reported but must not keep the shell from starting.
+	got = proxy.ProxyOptions{}
+	t.Setenv("SMART_SUGGESTION_PROXY_DENY_COMMANDS", "vault (read")
+	runProxy(nil, nil)
+	if got.SessionID != "test-session" || got.DenyCommands != nil {
+		t.Fatalf("expected proxy to start without deny patterns, got %+v", got)
+	}
+}
+
 func TestRunProxyError(t *testing.T) {
 	oldRunProxy := runProxyFunc
 	oldDebug := dbg
@@ -445,8 +943,8 @@ func TestRunUpdateCheckError(t *testing.T) {
 	oldCheck := checkUpdateFunc
 	t.Cleanup(func() { checkUpdateFunc = oldCheck })
 
-	checkUpdateFunc = func(currentVersion string) (string, string, error) {
-		return "", "", errors.New("network error")
+	checkUpdateFunc = func(currentVersion string) (updater.Release, error) {
+		return updater.Release{}, errors.New("network error")
 	}
 
 	cmd := &cobra.Command{}
@@ -467,8 +965,8 @@ func TestRunUpdateCheckOnlyCheckError(t *testing.T) {
 	exitFunc = func(code int) {
 		exitCode = code
 	}
-	checkUpdateFunc = func(currentVersion string) (string, string, error) {
-		return "", "", errors.New("network error")
+	checkUpdateFunc = func(currentVersion string) (updater.Release, error) {
+		return updater.Release{}, errors.New("network error")
 	}
 
 	cmd := &cobra.Command{}
@@ -489,8 +987,8 @@ func TestRunUpdateInstallError(t *testing.T) {
 		installUpdateFunc = oldInstall
 	})
 
-	checkUpdateFunc = func(currentVersion string) (string, string, error) {
-		return "2.0.0", "https://example.com/update", nil
+	checkUpdateFunc = func(currentVersion string) (updater.Release, error) {
+		return updater.Release{Version: "2.0.0", URL: "https://example.com/update"}, nil
 	}
 	installUpdateFunc = func(url string) error {
 		return errors.New("install failed")
@@ -498,6 +996,7 @@ func TestRunUpdateInstallError(t *testing.T) {
 
 	cmd := &cobra.Command{}
 	cmd.Flags().Bool("check-only", false, "")
+	cmd.Flags().Bool("yes", true, "")
 
 	runUpdate(cmd, nil)
 }
@@ -510,8 +1009,8 @@ func TestRunUpdateInstallSuccess(t *testing.T) {
 		installUpdateFunc = oldInstall
 	})
 
-	checkUpdateFunc = func(currentVersion string) (string, string, error) {
-		return "2.0.0", "https://example.com/update", nil
+	checkUpdateFunc = func(currentVersion string) (updater.Release, error) {
+		return updater.Release{Version: "2.0.0", URL: "https://example.com/update"}, nil
 	}
 	installCalled := false
 	installUpdateFunc = func(url string) error {
@@ -521,6 +1020,7 @@ func TestRunUpdateInstallSuccess(t *testing.T) {
 
 	cmd := &cobra.Command{}
 	cmd.Flags().Bool("check-only", false, "")
+	cmd.Flags().Bool("yes", true, "")
 
 	runUpdate(cmd, nil)
 	if !installCalled {
@@ -528,6 +1028,141 @@ func TestRunUpdateInstallSuccess(t *testing.T) {
 	}
 }
 
+func TestRunUpdateAutoStamp(t *testing.T) {
+	oldExit := exitFunc
+	oldCheck := checkUpdateFunc
+	oldInstall := installUpdateFunc
+	t.Cleanup(func() {
+		exitFunc = oldExit
+		checkUpdateFunc = oldCheck
+		installUpdateFunc = oldInstall
+	})
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	exitFunc = func(code int) {}
+	checks := 0
+	checkUpdateFunc = func(currentVersion string) (updater.Release, error) {
+		checks++
+		return updater.Release{Version: "2.0.0", URL: "https://example.com/update"}, nil
+	}
+	installUpdateFunc = func(url string) error {
+		return errors.New("install failed")
+	}
+
+	newCmd := func() *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("check-only", false, "")
+		cmd.Flags().Bool("yes", true, "")
+		cmd.Flags().Bool("auto", true, "")
+		cmd.Flags().Duration("interval", 24*time.Hour, "")
+		return cmd
+	}
+
+	runUpdate(newCmd(), nil)
+	runUpdate(newCmd(), nil)
+	if checks != 1 {
+		t.Fatalf("expected the stamp to suppress the second check, got %d checks", checks)
+	}
+	if stamp := updater.LoadStamp(autoUpdateStampFile()); stamp.Failures != 1 {
+		t.Errorf("expected the failed install to be recorded, got %+v", stamp)
+	}
+
+	// Once the backoff has passed, a successful install clears the failures.
+	stale := updater.CheckStamp{LastCheck: time.Now().Add(-48 * time.Hour), Failures: 1}
+	if err := stale.Save(autoUpdateStampFile()); err != nil {
+		t.Fatal(err)
+	}
+	installUpdateFunc = func(url string) error { return nil }
+	runUpdate(newCmd(), nil)
+	if checks != 2 {
+		t.Fatalf("expected a check once the backoff passed, got %d checks", checks)
+	}
+	if stamp := updater.LoadStamp(autoUpdateStampFile()); stamp.Failures != 0 {
+		t.Errorf("expected a successful update to clear the failures, got %+v", stamp)
+	}
+}
+
+func TestRunUpdateShowsChangelogAndConfirms(t *testing.T) {
+	oldCheck := checkUpdateFunc
+	oldInstall := installUpdateFunc
+	oldInput := updateConfirmInput
+	oldVersion := Version
+	t.Cleanup(func() {
+		checkUpdateFunc = oldCheck
+		installUpdateFunc = oldInstall
+		updateConfirmInput = oldInput
+		Version = oldVersion
+	})
+
+	Version = "1.0.0"
+	checkUpdateFunc = func(currentVersion string) (updater.Release, error) {
+		return updater.Release{
+			Version: "2.0.0",
+			URL:     "https://example.com/update",
+			Notes:   "## What's Changed\r\n\r\n- Add pick subcommand\r\n- Strip ANSI from tmux scrollback\r\n",
+		}, nil
+	}
+
+	for _, tt := range []struct {
+		answer      string
+		wantInstall bool
+	}{
+		{answer: "y\n", wantInstall: true},
+		{answer: "\n", wantInstall: false},
+		{answer: "", wantInstall: false},
+	} {
+		installCalled := false
+		installUpdateFunc = func(url string) error {
+			installCalled = true
+			return nil
+		}
+		updateConfirmInput = strings.NewReader(tt.answer)
+
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("check-only", false, "")
+		cmd.Flags().Bool("yes", false, "")
+
+		stdout := os.Stdout
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("failed to create pipe: %v", err)
+		}
+		os.Stdout = w
+		runUpdate(cmd, nil)
+		_ = w.Close()
+		os.Stdout = stdout
+		data, _ := io.ReadAll(r)
+		output := string(data)
+
+		for _, want := range []string{
+			"Current version: 1.0.0",
+			"Latest version:  2.0.0",
+			"## What's Changed\n- Add pick subcommand\n- Strip ANSI from tmux scrollback",
+			"Install version 2.0.0? [y/N]",
+		} {
+			if !strings.Contains(output, want) {
+				t.Errorf("answer %q: expected output to contain %q, got %q", tt.answer, want, output)
+			}
+		}
+		if installCalled != tt.wantInstall {
+			t.Errorf("answer %q: expected install called=%v, got %v", tt.answer, tt.wantInstall, installCalled)
+		}
+	}
+}
+
+func TestChangelogExcerpt(t *testing.T) {
+	notes := "line1\n\nline2\nline3\nline4"
+	if got := changelogExcerpt(notes, 2); got != "line1\nline2\n..." {
+		t.Errorf("expected truncated excerpt, got %q", got)
+	}
+	if got := changelogExcerpt(notes, 10); got != "line1\nline2\nline3\nline4" {
+		t.Errorf("expected full excerpt, got %q", got)
+	}
+	if got := changelogExcerpt("  \n", 10); got != "" {
+		t.Errorf("expected empty excerpt, got %q", got)
+	}
+}
+
 func TestRunRotateLogsForceRotateError(t *testing.T) {
 	dir := t.TempDir()
 	file := filepath.Join(dir, "readonly.log")
@@ -577,7 +1212,7 @@ func TestBuildRootCmd(t *testing.T) {
 		names[sub.Use] = true
 	}
 
-	expected := []string{"proxy", "rotate-logs", "update", "version"}
+	expected := []string{"proxy", "rotate-logs", "update", "version", "pick", "capture"}
 	for _, name := range expected {
 		if !names[name] {
 			t.Fatalf("expected subcommand %q", name)
@@ -604,17 +1239,181 @@ func TestBuildRootCmdVersionSubcommand(t *testing.T) {
 type mockProvider struct {
 	response string
 	err      error
+
+	gotCtx          context.Context
+	gotInput        string
+	gotSystemPrompt string
+	gotHistory      []provider.Message
 }
 
 func (m *mockProvider) Fetch(ctx context.Context, input, systemPrompt string) (string, error) {
-	return m.response, m.err
+	return m.FetchWithHistory(ctx, input, systemPrompt, nil)
 }
 
 func (m *mockProvider) FetchWithHistory(ctx context.Context, input, systemPrompt string, history []provider.Message) (string, error) {
+	m.gotCtx = ctx
+	m.gotInput = input
+	m.gotSystemPrompt = systemPrompt
+	m.gotHistory = history
 	return m.response, m.err
 }
 
-func TestRunSuggestSuccess(t *testing.T) {
+func TestRunSuggestRateLimitCooldown(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+	})
+
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	if err := provider.RecordCooldown("mock", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RecordCooldown failed: %v", err)
+	}
+
+	mock := &mockProvider{response: "=ls"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "list files"
+	providerName = "mock"
+	sendContext = false
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	err := runSuggest(cmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "mock rate limited, retry in") {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if mock.gotInput != "" {
+		t.Fatal("expected provider not to be called during cooldown")
+	}
+}
+
+// blockingProvider answers only once release is closed.
+type blockingProvider struct {
+	response string
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (b *blockingProvider) Fetch(ctx context.Context, input, systemPrompt string) (string, error) {
+	return b.FetchWithHistory(ctx, input, systemPrompt, nil)
+}
+
+func (b *blockingProvider) FetchWithHistory(ctx context.Context, input, systemPrompt string, history []provider.Message) (string, error) {
+	close(b.started)
+	<-b.release
+	return b.response, nil
+}
+
+func TestRunSuggestSessionLock(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	oldWait := suggestLockWait
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+		suggestLockWait = oldWait
+	})
+
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	t.Setenv("SMART_SUGGESTION_SESSION_ID", "lock-test")
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "list files"
+	providerName = "mock"
+	sendContext = false
+
+	first := &blockingProvider{response: "=ls -la", started: make(chan struct{}), release: make(chan struct{})}
+	second := &mockProvider{response: "=pwd"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		select {
+		case <-first.started:
+			return second, nil
+		default:
+			return first, nil
+		}
+	}
+
+	runOnce := func() error {
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+		return runSuggest(cmd, nil)
+	}
+
+	firstErr := make(chan error, 1)
+	go func() { firstErr <- runOnce() }()
+	<-first.started
+
+	t.Run("gives up while the session is busy", func(t *testing.T) {
+		suggestLockWait = 50 * time.Millisecond
+		if err := runOnce(); err != nil {
+			t.Fatalf("expected a clean exit, got %v", err)
+		}
+		if second.gotInput != "" {
+			t.Fatal("expected the provider not to be called while another suggestion runs")
+		}
+		if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+			t.Fatalf("expected no output to be written yet, got %v", err)
+		}
+	})
+
+	t.Run("waits for the running suggestion", func(t *testing.T) {
+		suggestLockWait = 5 * time.Second
+		time.AfterFunc(50*time.Millisecond, func() { close(first.release) })
+		if err := runOnce(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := <-firstErr; err != nil {
+			t.Fatalf("unexpected error from first suggestion: %v", err)
+		}
+		content, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		if string(content) != "=pwd" {
+			t.Errorf("expected the later suggestion to be written last, got %q", content)
+		}
+	})
+
+	t.Run("other sessions are not blocked", func(t *testing.T) {
+		blocker := &blockingProvider{response: "=ls", started: make(chan struct{}), release: make(chan struct{})}
+		selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) { return blocker, nil }
+		done := make(chan error, 1)
+		go func() { done <- runOnce() }()
+		<-blocker.started
+		defer func() {
+			close(blocker.release)
+			<-done
+		}()
+
+		t.Setenv("SMART_SUGGESTION_SESSION_ID", "other-session")
+		other := &mockProvider{response: "=whoami"}
+		selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) { return other, nil }
+		suggestLockWait = 0
+		if err := runOnce(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if other.gotInput == "" {
+			t.Error("expected a different session to fetch without waiting")
+		}
+	})
+}
+
+func TestRunSuggestSuccess(t *testing.T) {
 	oldSelect := selectProviderFunc
 	oldOutput := outputFile
 	oldInput := input
@@ -655,6 +1454,39 @@ func TestRunSuggestSuccess(t *testing.T) {
 	}
 }
 
+func TestRunSuggestUnclosedReasoning(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+	})
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "<reasoning>\n1. The user wants to list files.\n2. The"}, nil
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "list files"
+	providerName = "mock"
+	sendContext = false
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	err := runSuggest(cmd, nil)
+	if !errors.Is(err, provider.ErrUnclosedReasoning) {
+		t.Fatalf("expected ErrUnclosedReasoning, got %v", err)
+	}
+	if _, statErr := os.Stat(outputFile); !os.IsNotExist(statErr) {
+		t.Errorf("expected no suggestion to be written, got %v", statErr)
+	}
+}
+
 func TestRunSuggestProviderError(t *testing.T) {
 	oldSelect := selectProviderFunc
 	oldProvider := providerName
@@ -714,6 +1546,38 @@ func TestRunSuggestFetchError(t *testing.T) {
 	}
 }
 
+func TestRunSuggestContentFiltered(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldProvider := providerName
+	oldInput := input
+	oldContext := sendContext
+	oldOutput := outputFile
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		providerName = oldProvider
+		input = oldInput
+		sendContext = oldContext
+		outputFile = oldOutput
+	})
+
+	refusal := &provider.ContentFilteredError{Provider: "Gemini", Reason: "SAFETY"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{err: fmt.Errorf("failed to send message: %w", refusal)}, nil
+	}
+	providerName = "mock"
+	input = "kill -9 the stuck process"
+	sendContext = false
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	err := runSuggest(cmd, nil)
+	if err == nil || err.Error() != refusal.Error() {
+		t.Errorf("expected only the refusal's own message, got %v", err)
+	}
+}
+
 func TestRunSuggestWriteError(t *testing.T) {
 	oldSelect := selectProviderFunc
 	oldOutput := outputFile
@@ -747,3 +1611,812 @@ func TestRunSuggestWriteError(t *testing.T) {
 		t.Fatal("expected error for write failure")
 	}
 }
+
+func TestRunSuggestFast(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldDebug := dbg
+	oldContext := sendContext
+	oldFast := fast
+	oldBuildUserContext := buildUserContextFunc
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		dbg = oldDebug
+		sendContext = oldContext
+		fast = oldFast
+		buildUserContextFunc = oldBuildUserContext
+	})
+
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
+		return "# Shell history:\n\nls", nil
+	}
+	mock := &mockProvider{response: "+la"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "ls -"
+	providerName = "mock"
+	dbg = false
+	sendContext = true
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	fast = false
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defaultOpts := provider.GenerationOptionsFromContext(mock.gotCtx)
+	if defaultOpts.MaxTokens != 0 || defaultOpts.Temperature != nil {
+		t.Fatalf("expected provider defaults without --fast, got %+v", defaultOpts)
+	}
+	if len(mock.gotHistory) == 0 {
+		t.Fatal("expected example history without --fast")
+	}
+	if mock.gotInput == input {
+		t.Fatal("expected context in user input without --fast")
+	}
+
+	fast = true
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.gotSystemPrompt != prompt.Fast().String() {
+		t.Fatalf("expected fast system prompt, got %q", mock.gotSystemPrompt)
+	}
+	if mock.gotInput != input {
+		t.Fatalf("expected raw input without context, got %q", mock.gotInput)
+	}
+	if len(mock.gotHistory) != 0 {
+		t.Fatalf("expected no example history, got %d messages", len(mock.gotHistory))
+	}
+	fastOpts := provider.GenerationOptionsFromContext(mock.gotCtx)
+	if fastOpts.MaxTokens != fastMaxTokens {
+		t.Fatalf("expected max tokens %d, got %d", fastMaxTokens, fastOpts.MaxTokens)
+	}
+	if fastOpts.Temperature == nil || *fastOpts.Temperature != 0 {
+		t.Fatalf("expected temperature 0, got %v", fastOpts.Temperature)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(content) != "+la" {
+		t.Fatalf("expected '+la', got %q", string(content))
+	}
+}
+
+func TestSelectProviderFromProvidersFile(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	originalProvider := providerName
+	originalFile := providersFile
+	t.Cleanup(func() {
+		providerName = originalProvider
+		providersFile = originalFile
+	})
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("SMART_SUGGESTION_CONFIG", "")
+	t.Setenv("SMART_SUGGESTION_PROVIDERS_FILE", "")
+
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"=kubectl get pods"}}]}`)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "providers.json")
+	spec := fmt.Sprintf(`{"team-llm": {"protocol": "openai", "base_url": %q, "api_key_env": "TEAM_LLM_KEY", "model": "team-model"}}`, server.URL+"/gateway/v1")
+	if err := os.WriteFile(file, []byte(spec), 0600); err != nil {
+		t.Fatalf("failed to write providers file: %v", err)
+	}
+	t.Setenv("TEAM_LLM_KEY", "team-secret")
+
+	providerName = "team-llm"
+	providersFile = file
+	p, err := selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("expected provider from providers file, got %v", err)
+	}
+	if provider.ModelName(p) != "team-model" {
+		t.Errorf("expected team-model, got %q", provider.ModelName(p))
+	}
+	got, err := p.Fetch(context.Background(), "pods", "system")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "=kubectl get pods" || gotPath != "/gateway/v1/chat/completions" {
+		t.Errorf("unexpected response %q from path %q", got, gotPath)
+	}
+
+	// Built-in names keep working alongside the file.
+	t.Setenv("OPENAI_API_KEY", "fake")
+	providerName = "openai"
+	if _, err := selectProvider(cmd); err != nil {
+		t.Fatalf("expected built-in provider, got %v", err)
+	}
+
+	// The environment variable is used when the flag is not given.
+	providersFile = ""
+	t.Setenv("SMART_SUGGESTION_PROVIDERS_FILE", file)
+	providerName = "TEAM-LLM"
+	if _, err := selectProvider(cmd); err != nil {
+		t.Fatalf("expected provider from SMART_SUGGESTION_PROVIDERS_FILE, got %v", err)
+	}
+
+	t.Setenv("SMART_SUGGESTION_PROVIDERS_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := selectProvider(cmd); err == nil || !strings.Contains(err.Error(), "failed to read providers file") {
+		t.Fatalf("expected error for a missing providers file, got %v", err)
+	}
+}
+
+func TestSelectProviderFromUnifiedEnv(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(t.Context())
+	originalProvider := providerName
+	t.Cleanup(func() { providerName = originalProvider })
+	providerName = ""
+	t.Setenv("SMART_SUGGESTION_PROVIDER_RULES", "")
+	t.Setenv("SMART_SUGGESTION_PROVIDERS_FILE", "")
+	t.Setenv("SMART_SUGGESTION_CONFIG", filepath.Join(t.TempDir(), "config.zsh"))
+	t.Setenv("SMART_SUGGESTION_AI_PROVIDER", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY_FILE", "")
+	t.Setenv("LLM_PROVIDER", "claude")
+	t.Setenv("LLM_API_KEY", "fake")
+
+	p, err := selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := p.(*provider.AnthropicProvider); !ok {
+		t.Fatalf("expected the provider LLM_PROVIDER names, got %T", p)
+	}
+
+	// SMART_SUGGESTION_AI_PROVIDER takes precedence over LLM_PROVIDER.
+	t.Setenv("SMART_SUGGESTION_AI_PROVIDER", "openai")
+	t.Setenv("OPENAI_API_KEY", "fake")
+	p, err = selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := p.(*provider.OpenAIProvider); !ok {
+		t.Fatalf("expected SMART_SUGGESTION_AI_PROVIDER to win, got %T", p)
+	}
+}
+
+func TestSelectProviderByDirectoryRule(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	originalProvider := providerName
+	t.Cleanup(func() { providerName = originalProvider })
+
+	home := t.TempDir()
+	workDir := filepath.Join(home, "work", "api")
+	personalDir := filepath.Join(home, "personal")
+	otherDir := filepath.Join(home, "other")
+	for _, dir := range []string{workDir, personalDir, otherDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+
+	t.Setenv("HOME", home)
+	t.Setenv("SMART_SUGGESTION_PROVIDER_RULES", "work/* -> azure_openai; personal -> openai")
+	t.Setenv("SMART_SUGGESTION_AI_PROVIDER", "anthropic")
+	t.Setenv("OPENAI_API_KEY", "fake")
+	t.Setenv("ANTHROPIC_API_KEY", "fake")
+	t.Setenv("AZURE_OPENAI_API_KEY", "fake")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT_NAME", "deploy")
+	t.Setenv("AZURE_OPENAI_RESOURCE_NAME", "resource")
+
+	providerName = ""
+
+	t.Chdir(workDir)
+	p, err := selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := p.(*provider.AzureOpenAIProvider); !ok {
+		t.Fatalf("expected azure provider under ~/work, got %T", p)
+	}
+
+	t.Chdir(personalDir)
+	p, err = selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := p.(*provider.OpenAIProvider); !ok {
+		t.Fatalf("expected openai provider under ~/personal, got %T", p)
+	}
+
+	t.Chdir(otherDir)
+	p, err = selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := p.(*provider.AnthropicProvider); !ok {
+		t.Fatalf("expected fallback anthropic provider, got %T", p)
+	}
+
+	// An explicit --provider wins over the rules.
+	t.Chdir(workDir)
+	providerName = "openai"
+	p, err = selectProvider(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := p.(*provider.OpenAIProvider); !ok {
+		t.Fatalf("expected explicit openai provider, got %T", p)
+	}
+
+	providerName = ""
+	t.Setenv("SMART_SUGGESTION_PROVIDER_RULES", "broken rule")
+	if _, err := selectProvider(cmd); err == nil {
+		t.Fatal("expected error for malformed rules")
+	}
+}
+
+func TestRunSuggestReasoningAudit(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldDebug := dbg
+	oldContext := sendContext
+	oldAudit := reasoningAudit
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		dbg = oldDebug
+		sendContext = oldContext
+		reasoningAudit = oldAudit
+	})
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "<reasoning>list the files</reasoning>=ls -la"}, nil
+	}
+	dir := t.TempDir()
+	outputFile = filepath.Join(dir, "output.txt")
+	input = "list files"
+	providerName = "mock"
+	dbg = false
+	sendContext = false
+	reasoningAudit = ""
+
+	auditFile := filepath.Join(dir, "audit.log")
+	t.Setenv("SMART_SUGGESTION_REASONING_AUDIT_FILE", auditFile)
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	for range 2 {
+		if err := runSuggest(cmd, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatalf("failed to read audit file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit records, got %d: %q", len(lines), string(data))
+	}
+	var record audit.Record
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("failed to parse audit record: %v", err)
+	}
+	if record.Command != "=ls -la" || record.Reasoning != "list the files" || record.Timestamp.IsZero() {
+		t.Fatalf("unexpected audit record: %+v", record)
+	}
+
+	// The flag takes precedence over the environment variable.
+	reasoningAudit = filepath.Join(dir, "flag-audit.log")
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(reasoningAudit); err != nil {
+		t.Fatalf("expected audit file from flag: %v", err)
+	}
+
+	reasoningAudit = filepath.Join(outputFile, "audit.log")
+	if err := runSuggest(cmd, nil); err == nil {
+		t.Fatal("expected error for unwritable audit file")
+	}
+}
+
+func TestRunSuggestSessionID(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldDebug := dbg
+	oldContext := sendContext
+	oldSessionID := sessionID
+	oldBuildUserContext := buildUserContextFunc
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		dbg = oldDebug
+		sendContext = oldContext
+		sessionID = oldSessionID
+		buildUserContextFunc = oldBuildUserContext
+	})
+
+	cacheDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", cacheDir)
+	if err := os.WriteFile(filepath.Join(cacheDir, "proxy.pane-a.log"), []byte("go test ./...\nFAIL\n"), 0644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	var gotOpts shellcontext.UserContextOptions
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
+		gotOpts = opts
+		return "", nil
+	}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "=go test ./..."}, nil
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "rerun"
+	providerName = "mock"
+	dbg = false
+	sendContext = true
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	sessionID = "pane-a"
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotOpts.SessionID != "pane-a" {
+		t.Fatalf("expected session ID to be passed to context, got %q", gotOpts.SessionID)
+	}
+
+	sessionID = "pane-missing"
+	if err := runSuggest(cmd, nil); err == nil {
+		t.Fatal("expected error for unknown session")
+	}
+}
+
+func TestRunSuggestDeniedDirectory(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldDebug := dbg
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		dbg = oldDebug
+		sendContext = oldContext
+	})
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SMART_SUGGESTION_DENY_DIRS", "~/.ssh; /nonexistent/vault/**")
+	secrets := filepath.Join(home, ".ssh", "keys")
+	projects := filepath.Join(home, "projects")
+	for _, dir := range []string{secrets, projects} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+
+	mock := &mockProvider{response: "=ls -la"}
+	calls := 0
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		calls++
+		return mock, nil
+	}
+	input = "ls"
+	providerName = "mock"
+	dbg = false
+	sendContext = false
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	t.Chdir(secrets)
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected provider not to be called in denied directory, got %d calls", calls)
+	}
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("expected empty output in denied directory, got %q", data)
+	}
+
+	t.Chdir(projects)
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected provider to be called outside denied directories, got %d calls", calls)
+	}
+	data, err = os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(data) != "=ls -la" {
+		t.Fatalf("expected suggestion outside denied directories, got %q", data)
+	}
+}
+
+type mockEmbedder struct{}
+
+// Embed scores texts by whether they mention git, so "git" queries prefer git entries.
+func (mockEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "git") {
+			vectors[i] = []float64{1, 0}
+		} else {
+			vectors[i] = []float64{0, 1}
+		}
+	}
+	return vectors, nil
+}
+
+func TestSemanticHistorySelector(t *testing.T) {
+	oldSemanticHistory := semanticHistory
+	oldInput := input
+	oldNewEmbedder := newEmbedderFunc
+	t.Cleanup(func() {
+		semanticHistory = oldSemanticHistory
+		input = oldInput
+		newEmbedderFunc = oldNewEmbedder
+	})
+
+	semanticHistory = 0
+	if semanticHistorySelector(context.Background()) != nil {
+		t.Fatal("expected no selector when disabled")
+	}
+
+	semanticHistory = 1
+	input = "git push"
+	newEmbedderFunc = func() (provider.Embedder, error) { return mockEmbedder{}, nil }
+	selector := semanticHistorySelector(context.Background())
+	if selector == nil {
+		t.Fatal("expected selector when enabled")
+	}
+	selected, err := selector([]string{"ls -la", "git status", "make"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(selected) != 1 || selected[0] != "git status" {
+		t.Fatalf("expected the git entry, got %q", selected)
+	}
+
+	newEmbedderFunc = func() (provider.Embedder, error) { return nil, errors.New("no key") }
+	if _, err := semanticHistorySelector(context.Background())([]string{"a", "b"}); err == nil {
+		t.Fatal("expected embedder construction error")
+	}
+}
+
+func TestWriteSuggestionAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output.txt")
+	if err := os.WriteFile(path, []byte("=a much longer previous suggestion"), 0644); err != nil {
+		t.Fatalf("failed to seed output: %v", err)
+	}
+
+	if err := writeSuggestion(path, "=ls -la"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(data) != "=ls -la" {
+		t.Fatalf("expected complete suggestion, got %q", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat output: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0644 {
+		t.Fatalf("expected permissions 0644, got %o", perm)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "output.txt" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Fatalf("expected no temp files left behind, got %v", names)
+	}
+}
+
+func TestWriteSuggestionAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatalf("failed to seed target: %v", err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	if err := writeSuggestion(link, "+la"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("failed to stat link: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("expected the symlink to be kept")
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read target: %v", err)
+	}
+	if string(data) != "+la" {
+		t.Fatalf("expected suggestion written through the symlink, got %q", data)
+	}
+}
+
+func TestRunCapture(t *testing.T) {
+	oldLines := scrollbackLines
+	oldFile := scrollbackFile
+	oldSession := sessionID
+	oldDebug := dbg
+	t.Cleanup(func() {
+		scrollbackLines = oldLines
+		scrollbackFile = oldFile
+		sessionID = oldSession
+		dbg = oldDebug
+	})
+
+	cacheDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", cacheDir)
+	t.Setenv("SMART_SUGGESTION_SESSION_ID", "")
+	t.Setenv("TMUX", "")
+	t.Setenv("KITTY_LISTEN_ON", "")
+	logFile := filepath.Join(cacheDir, "proxy.pts_3.log")
+	if err := os.WriteFile(logFile, []byte("$ make\ncc -o app main.c\nmain.c:3: error: expected ';'\n"), 0644); err != nil {
+		t.Fatalf("failed to write proxy log: %v", err)
+	}
+
+	scrollbackLines = 2
+	scrollbackFile = ""
+	sessionID = "pts_3"
+	dbg = false
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	runErr := runCapture(&cobra.Command{}, nil)
+	w.Close()
+	os.Stdout = stdout
+
+	out, _ := io.ReadAll(r)
+	if runErr != nil {
+		t.Fatalf("unexpected error: %v", runErr)
+	}
+	if string(out) != "cc -o app main.c\nmain.c:3: error: expected ';'\n" {
+		t.Fatalf("expected captured proxy log tail, got %q", out)
+	}
+
+	sessionID = "missing"
+	if err := runCapture(&cobra.Command{}, nil); err == nil {
+		t.Fatal("expected error for unknown session")
+	}
+}
+
+func TestRunPruneCache(t *testing.T) {
+	oldOlderThan := pruneOlderThan
+	oldDebug := dbg
+	t.Cleanup(func() {
+		pruneOlderThan = oldOlderThan
+		dbg = oldDebug
+	})
+
+	cacheDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", cacheDir)
+	stale := filepath.Join(cacheDir, "ratelimit.json")
+	fresh := filepath.Join(cacheDir, "proxy.log")
+	for _, file := range []string{stale, fresh} {
+		if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("failed to age file: %v", err)
+	}
+
+	pruneOlderThan = 24 * time.Hour
+	dbg = false
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	runErr := runPruneCache(&cobra.Command{}, nil)
+	w.Close()
+	os.Stdout = stdout
+
+	out, _ := io.ReadAll(r)
+	if runErr != nil {
+		t.Fatalf("unexpected error: %v", runErr)
+	}
+	if !strings.Contains(string(out), "Removed "+stale) || !strings.Contains(string(out), "Pruned 1 files (2 bytes)") {
+		t.Errorf("unexpected output %q", out)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("expected the stale file to be removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("expected the fresh file to be kept: %v", err)
+	}
+
+	pruneOlderThan = 0
+	if err := runPruneCache(&cobra.Command{}, nil); err == nil {
+		t.Error("expected an error for a zero age")
+	}
+}
+
+func TestMainWarmup(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"gpt-4o-mini","object":"model"}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "sk-test-key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	// No process would keep the connection, so nothing is sent.
+	exitCode, out := runMain(t, "warmup")
+	if exitCode != -1 {
+		t.Errorf("expected a successful exit, got exit code %d", exitCode)
+	}
+	if out != "" {
+		t.Errorf("expected no output, got %q", out)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no request, got %v", requests)
+	}
+}
+
+func TestRunProxySessionSummary(t *testing.T) {
+	oldRunProxy := runProxyFunc
+	oldSummary := proxySummary
+	oldSessionID := sessionID
+	t.Cleanup(func() {
+		runProxyFunc = oldRunProxy
+		proxySummary = oldSummary
+		sessionID = oldSessionID
+	})
+
+	var got proxy.ProxyOptions
+	runProxyFunc = func(shell string, opts proxy.ProxyOptions) error {
+		got = opts
+		return nil
+	}
+	cacheDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", cacheDir)
+	sessionID = "test-session"
+
+	proxySummary = false
+	runProxy(nil, nil)
+	if got.MetricsFile != "" {
+		t.Errorf("expected no metrics file without --session-summary, got %q", got.MetricsFile)
+	}
+
+	proxySummary = true
+	runProxy(nil, nil)
+	if want := filepath.Join(cacheDir, "metrics.test-session.jsonl"); got.MetricsFile != want {
+		t.Errorf("expected metrics file %q, got %q", want, got.MetricsFile)
+	}
+}
+
+func TestRunProxyScrollbackBytes(t *testing.T) {
+	oldRunProxy := runProxyFunc
+	oldBytes := scrollbackBytes
+	oldSessionID := sessionID
+	t.Cleanup(func() {
+		runProxyFunc = oldRunProxy
+		scrollbackBytes = oldBytes
+		sessionID = oldSessionID
+	})
+
+	var got proxy.ProxyOptions
+	runProxyFunc = func(shell string, opts proxy.ProxyOptions) error {
+		got = opts
+		return nil
+	}
+	sessionID = "test-session"
+
+	scrollbackBytes = 65536
+	runProxy(nil, nil)
+	if got.ScrollbackBytes != 65536 {
+		t.Errorf("expected a byte limit of 65536, got %d", got.ScrollbackBytes)
+	}
+}
+
+func TestRunProxyKeepSessionLogs(t *testing.T) {
+	oldRunProxy := runProxyFunc
+	oldNoCleanup := proxyNoCleanup
+	oldSessionID := sessionID
+	t.Cleanup(func() {
+		runProxyFunc = oldRunProxy
+		proxyNoCleanup = oldNoCleanup
+		sessionID = oldSessionID
+	})
+
+	var got proxy.ProxyOptions
+	runProxyFunc = func(shell string, opts proxy.ProxyOptions) error {
+		got = opts
+		return nil
+	}
+	sessionID = "test-session"
+
+	tests := []struct {
+		env       string
+		noCleanup bool
+		want      bool
+	}{
+		{env: "", want: false},
+		{env: "true", want: true},
+		{env: "false", want: false},
+		{env: "maybe", want: false},
+		{env: "", noCleanup: true, want: true},
+		{env: "false", noCleanup: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("SMART_SUGGESTION_KEEP_SESSION_LOGS", tt.env)
+		proxyNoCleanup = tt.noCleanup
+		runProxy(nil, nil)
+		if got.KeepSessionLogs != tt.want {
+			t.Errorf("env %q, --no-cleanup %v: expected KeepSessionLogs %v, got %v", tt.env, tt.noCleanup, tt.want, got.KeepSessionLogs)
+		}
+	}
+}
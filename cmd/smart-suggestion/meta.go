@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// suggestionMeta describes a suggestion for integrations that should not have
+// to parse the command output.
+type suggestionMeta struct {
+	// Type is "command" for a replacement (=), "completion" for a suffix (+),
+	// "display" for a command that is only shown (~) and "" when no suggestion
+	// was made.
+	Type      string `json:"type"`
+	Command   string `json:"command"`
+	Reasoning string `json:"reasoning,omitempty"`
+	Provider  string `json:"provider"`
+	Model     string `json:"model,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	// Confidence is between 0 and 1, for providers exposing log probabilities.
+	Confidence *float64 `json:"confidence,omitempty"`
+	// NonDestructive is set in safe mode, where the suggestion never replaces
+	// the input.
+	NonDestructive bool `json:"non_destructive,omitempty"`
+	// Warning is the objection of the reviewer in SMART_SUGGESTION_REVIEW_MODEL.
+	Warning string `json:"warning,omitempty"`
+}
+
+func newSuggestionMeta(req *suggestRequest, response string, suggestion string, latency time.Duration) suggestionMeta {
+	meta := suggestionMeta{
+		Command:   suggestion,
+		Reasoning: provider.ExtractReasoning(response),
+		Provider:  req.providerName,
+		Model:     provider.ModelName(req.client),
+		LatencyMS: latency.Milliseconds(),
+	}
+	switch {
+	case strings.HasPrefix(suggestion, "="):
+		meta.Type = "command"
+		meta.Command = suggestion[1:]
+	case strings.HasPrefix(suggestion, "+"):
+		meta.Type = "completion"
+		meta.Command = suggestion[1:]
+	case strings.HasPrefix(suggestion, displayPrefix):
+		meta.Type = "display"
+		meta.Command = suggestion[1:]
+	}
+	return meta
+}
+
+func marshalMeta(meta suggestionMeta) ([]byte, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func writeMeta(w io.Writer, meta suggestionMeta) error {
+	data, err := marshalMeta(meta)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return nil
+}
+
+// writeMetaFile writes meta to path the same way writeSuggestion writes the
+// command, so both can be read back from files after a single invocation.
+func writeMetaFile(path string, meta suggestionMeta) error {
+	if path == "-" || path == "/dev/stdout" {
+		return writeMeta(os.Stdout, meta)
+	}
+
+	data, err := marshalMeta(meta)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata to file: %w", err)
+	}
+	return nil
+}
+
+// writeMetaFD writes meta to the inherited file descriptor fd. The descriptor
+// is duplicated first so the caller's copy stays open.
+func writeMetaFD(fd int, meta suggestionMeta) error {
+	dup, err := syscall.Dup(fd)
+	if err != nil {
+		return fmt.Errorf("invalid --meta-fd %d: %w", fd, err)
+	}
+	f := os.NewFile(uintptr(dup), "meta")
+	defer f.Close()
+
+	return writeMeta(f, meta)
+}
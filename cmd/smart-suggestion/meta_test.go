@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestNewSuggestionMeta(t *testing.T) {
+	req := &suggestRequest{providerName: "openai", client: &provider.OpenAIProvider{Model: "gpt-4o-mini"}}
+
+	meta := newSuggestionMeta(req, "<reasoning>list files</reasoning>=ls -la", "=ls -la", 1500*time.Millisecond)
+	expected := suggestionMeta{
+		Type:      "command",
+		Command:   "ls -la",
+		Reasoning: "list files",
+		Provider:  "openai",
+		Model:     "gpt-4o-mini",
+		LatencyMS: 1500,
+	}
+	if meta != expected {
+		t.Fatalf("expected %+v, got %+v", expected, meta)
+	}
+
+	if meta := newSuggestionMeta(req, "+la", "+la", 0); meta.Type != "completion" || meta.Command != "la" {
+		t.Fatalf("expected completion metadata, got %+v", meta)
+	}
+	if meta := newSuggestionMeta(req, "", "", 0); meta.Type != "" || meta.Command != "" {
+		t.Fatalf("expected empty metadata, got %+v", meta)
+	}
+}
+
+func TestRunSuggestMetaFD(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldDebug := dbg
+	oldContext := sendContext
+	oldMetaFD := metaFD
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		dbg = oldDebug
+		sendContext = oldContext
+		metaFD = oldMetaFD
+	})
+
+	mock := &mockProvider{response: "<reasoning>complete the flags</reasoning>+la"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "ls -"
+	providerName = "mock"
+	dbg = false
+	sendContext = false
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	defer r.Close()
+	metaFD = int(w.Fd())
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	runErr := runSuggest(cmd, nil)
+	w.Close()
+	if runErr != nil {
+		t.Fatalf("unexpected error: %v", runErr)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read meta pipe: %v", err)
+	}
+	var meta suggestionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatalf("expected JSON on meta fd, got %q: %v", data, err)
+	}
+	if meta.Type != "completion" || meta.Command != "la" || meta.Reasoning != "complete the flags" || meta.Provider != "mock" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+
+	output, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(output) != "+la" {
+		t.Fatalf("expected plain command in output, got %q", output)
+	}
+}
+
+func TestRunSuggestOutputAndMetaFiles(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldMetaFile := metaFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		metaFile = oldMetaFile
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+	})
+
+	mock := &mockProvider{response: "<reasoning>show hidden files too</reasoning>=ls -la"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	dir := t.TempDir()
+	outputFile = filepath.Join(dir, "cmd.txt")
+	metaFile = filepath.Join(dir, "meta.json")
+	input = "list files"
+	providerName = "mock"
+	sendContext = false
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	output, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(output) != "=ls -la" {
+		t.Fatalf("expected plain command in output, got %q", output)
+	}
+
+	data, err := os.ReadFile(metaFile)
+	if err != nil {
+		t.Fatalf("failed to read meta file: %v", err)
+	}
+	var meta suggestionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatalf("expected JSON in meta file, got %q: %v", data, err)
+	}
+	if meta.Type != "command" || meta.Command != "ls -la" || meta.Reasoning != "show hidden files too" || meta.Provider != "mock" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+
+	// Metadata alone is enough when no plain output is wanted.
+	outputFile = ""
+	if err := os.Remove(metaFile); err != nil {
+		t.Fatalf("failed to remove meta file: %v", err)
+	}
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(metaFile); err != nil {
+		t.Fatalf("expected meta file to be written: %v", err)
+	}
+}
+
+func TestRunSuggestRequiresOutput(t *testing.T) {
+	oldOutput := outputFile
+	oldMetaFile := metaFile
+	oldMetaFD := metaFD
+	t.Cleanup(func() {
+		outputFile = oldOutput
+		metaFile = oldMetaFile
+		metaFD = oldMetaFD
+	})
+
+	outputFile = ""
+	metaFile = ""
+	metaFD = -1
+	if err := runSuggest(&cobra.Command{}, nil); err == nil {
+		t.Fatal("expected error when no output is specified")
+	}
+}
+
+func TestWriteMetaFDInvalid(t *testing.T) {
+	if err := writeMetaFD(987654, suggestionMeta{}); err == nil {
+		t.Fatal("expected error for invalid file descriptor")
+	}
+}
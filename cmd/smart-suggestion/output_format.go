@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// Output formats accepted by --format.
+const (
+	formatAuto  = "auto"
+	formatRaw   = "raw"
+	formatHuman = "human"
+)
+
+// humanOutput reports whether the suggestion should be written for a person
+// rather than for the plugin. In auto mode that is the case when it goes to
+// stdout and stdout is a terminal.
+func humanOutput(format, outputFile string) (bool, error) {
+	switch format {
+	case "", formatAuto:
+		toStdout := outputFile == "-" || outputFile == "/dev/stdout"
+		return toStdout && isTerminalFunc(int(os.Stdout.Fd())), nil
+	case formatRaw:
+		return false, nil
+	case formatHuman:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid --format %q: must be %s, %s or %s", format, formatAuto, formatRaw, formatHuman)
+	}
+}
+
+// formatHumanSuggestion renders suggestion as the full command line without
+// its =/+ prefix, preceded by the model's reasoning as shell comments so the
+// whole output can still be pasted into a shell. before and after are the
+// input on either side of the cursor.
+func formatHumanSuggestion(before, after, response, suggestion string) string {
+	var builder strings.Builder
+	if reasoning := provider.ExtractReasoning(response); reasoning != "" {
+		for line := range strings.Lines(reasoning) {
+			builder.WriteString(strings.TrimRight("# "+strings.TrimRight(line, "\n"), " ") + "\n")
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString(candidateCommand(before, after, suggestion) + "\n")
+	return builder.String()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestHumanOutput(t *testing.T) {
+	oldIsTerminal := isTerminalFunc
+	t.Cleanup(func() { isTerminalFunc = oldIsTerminal })
+
+	cases := []struct {
+		format   string
+		output   string
+		terminal bool
+		want     bool
+	}{
+		{format: formatAuto, output: "-", terminal: true, want: true},
+		{format: formatAuto, output: "/dev/stdout", terminal: true, want: true},
+		{format: formatAuto, output: "-", terminal: false, want: false},
+		{format: formatAuto, output: "suggestion.txt", terminal: true, want: false},
+		{format: "", output: "-", terminal: true, want: true},
+		{format: formatRaw, output: "-", terminal: true, want: false},
+		{format: formatHuman, output: "-", terminal: false, want: true},
+	}
+	for _, tc := range cases {
+		isTerminalFunc = func(fd int) bool { return tc.terminal }
+		got, err := humanOutput(tc.format, tc.output)
+		if err != nil {
+			t.Fatalf("%+v: unexpected error: %v", tc, err)
+		}
+		if got != tc.want {
+			t.Errorf("%+v: expected %v, got %v", tc, tc.want, got)
+		}
+	}
+
+	if _, err := humanOutput("json", "-"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestFormatHumanSuggestion(t *testing.T) {
+	response := "<reasoning>\n1. The user lists files.\n\n2. Add details.\n</reasoning>\n+la"
+	want := "# 1. The user lists files.\n#\n# 2. Add details.\n\nls -la\n"
+	if got := formatHumanSuggestion("ls -", "", response, "+la"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := formatHumanSuggestion("", "", "=ls", "=ls"); got != "ls\n" {
+		t.Errorf("expected the bare command without reasoning, got %q", got)
+	}
+}
+
+func runSuggestToStdout(t *testing.T, terminal bool) string {
+	t.Helper()
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldFormat := outputFormat
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	oldIsTerminal := isTerminalFunc
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		outputFormat = oldFormat
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+		isTerminalFunc = oldIsTerminal
+	})
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "<reasoning>\nThe user wants details.\n</reasoning>\n+la"}, nil
+	}
+	isTerminalFunc = func(fd int) bool { return terminal }
+	outputFile = "-"
+	outputFormat = formatAuto
+	input = "ls -"
+	providerName = "mock"
+	sendContext = false
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	err = runSuggest(cmd, nil)
+	w.Close()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestRunSuggestTerminalOutput(t *testing.T) {
+	if got := runSuggestToStdout(t, true); got != "# The user wants details.\n\nls -la\n" {
+		t.Errorf("unexpected terminal output %q", got)
+	}
+}
+
+func TestRunSuggestPipeOutput(t *testing.T) {
+	if got := runSuggestToStdout(t, false); got != "+la" {
+		t.Errorf("unexpected pipe output %q", got)
+	}
+}
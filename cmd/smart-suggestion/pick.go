@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/picker"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+var (
+	pickCandidates int
+
+	isTerminalFunc = term.IsTerminal
+)
+
+// fetchCandidates asks the provider for n responses, in one request when it
+// supports that, and returns the distinct parsed suggestions in the order they
+// arrived. It only fails when no request succeeds.
+func fetchCandidates(req *suggestRequest, n int) ([]string, error) {
+	var responses []string
+	_, err := req.fetchWith(func(ctx context.Context) (string, error) {
+		var err error
+		responses, err = provider.FetchCandidates(ctx, req.client, req.userInput, req.systemPrompt, req.history, n)
+		return "", err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, response := range responses {
+		suggestion := provider.TransformCommand(provider.ModelName(req.client), provider.ParseAndExtractCommand(response))
+		if suggestion == "" || seen[suggestion] {
+			continue
+		}
+		seen[suggestion] = true
+		candidates = append(candidates, suggestion)
+	}
+	return candidates, nil
+}
+
+// candidateCommand turns a parsed suggestion into the full command line it
+// stands for, given the user's input before and after the cursor. Completions
+// are inserted at the cursor.
+func candidateCommand(before, after, suggestion string) string {
+	switch {
+	case strings.HasPrefix(suggestion, "="), strings.HasPrefix(suggestion, displayPrefix):
+		return suggestion[1:]
+	case strings.HasPrefix(suggestion, "+"):
+		return before + cleanSuggestion(before, after, suggestion)[1:] + after
+	default:
+		return suggestion
+	}
+}
+
+func runPick(cmd *cobra.Command, args []string) error {
+	debug.Enable(dbg)
+
+	if pickCandidates < 1 {
+		return fmt.Errorf("--candidates must be at least 1")
+	}
+	if !suggestionsEnabled() || inDeniedDirectory() {
+		return nil
+	}
+
+	req, err := prepareSuggestRequest(cmd)
+	if errors.Is(err, errNothingToSuggest) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	suggestions, err := fetchCandidates(req, pickCandidates)
+	if err != nil {
+		return err
+	}
+	if len(suggestions) == 0 {
+		return fmt.Errorf("no candidates returned by %s", req.providerName)
+	}
+
+	commands := make([]string, len(suggestions))
+	for i, suggestion := range suggestions {
+		commands[i] = candidateCommand(req.inputBefore, req.inputAfter, suggestion)
+	}
+
+	debug.Log("Fetched candidates", map[string]any{
+		"provider":   req.providerName,
+		"input":      req.userInput,
+		"candidates": commands,
+	})
+
+	stdinFd := int(os.Stdin.Fd())
+	if !isTerminalFunc(stdinFd) || !isTerminalFunc(int(os.Stderr.Fd())) {
+		for _, command := range commands {
+			fmt.Println(command)
+		}
+		return nil
+	}
+
+	oldState, err := term.MakeRaw(stdinFd)
+	if err != nil {
+		return fmt.Errorf("failed to set raw mode: %w", err)
+	}
+	index, err := picker.Pick(os.Stdin, os.Stderr, commands)
+	_ = term.Restore(stdinFd, oldState)
+	if errors.Is(err, picker.ErrCancelled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(commands[index])
+	return nil
+}
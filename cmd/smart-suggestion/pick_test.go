@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// sequenceProvider returns its responses in turn, one per request.
+type sequenceProvider struct {
+	responses []string
+	errs      []error
+	calls     int
+}
+
+func (s *sequenceProvider) Fetch(ctx context.Context, input, systemPrompt string) (string, error) {
+	return s.FetchWithHistory(ctx, input, systemPrompt, nil)
+}
+
+func (s *sequenceProvider) FetchWithHistory(ctx context.Context, input, systemPrompt string, history []provider.Message) (string, error) {
+	i := s.calls
+	s.calls++
+	var err error
+	if i < len(s.errs) {
+		err = s.errs[i]
+	}
+	if i < len(s.responses) {
+		return s.responses[i], err
+	}
+	return "", err
+}
+
+func TestCandidateCommand(t *testing.T) {
+	cases := []struct {
+		suggestion string
+		expected   string
+	}{
+		{suggestion: "=ls -la", expected: "ls -la"},
+		{suggestion: "+la", expected: "ls -la"},
+		{suggestion: "ls -la", expected: "ls -la"},
+	}
+	for _, tc := range cases {
+		if got := candidateCommand("ls -", "", tc.suggestion); got != tc.expected {
+			t.Fatalf("%q: expected %q, got %q", tc.suggestion, tc.expected, got)
+		}
+	}
+}
+
+func TestFetchCandidates(t *testing.T) {
+	seq := &sequenceProvider{
+		responses: []string{"=ls -la", "", "=ls -la", "+lh", "=ls -1"},
+		errs:      []error{nil, errors.New("boom")},
+	}
+	req := &suggestRequest{ctx: context.Background(), providerName: "mock", client: seq}
+
+	candidates, err := fetchCandidates(req, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"=ls -la", "+lh", "=ls -1"}
+	if strings.Join(candidates, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %q, got %q", expected, candidates)
+	}
+	if seq.calls != 5 {
+		t.Fatalf("expected 5 requests, got %d", seq.calls)
+	}
+
+	failing := &suggestRequest{ctx: context.Background(), providerName: "mock", client: &mockProvider{err: errors.New("boom")}}
+	if _, err := fetchCandidates(failing, 2); err == nil {
+		t.Fatal("expected error when every request fails")
+	}
+}
+
+func TestRunPickNonTTY(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldInput := input
+	oldProvider := providerName
+	oldDebug := dbg
+	oldContext := sendContext
+	oldCandidates := pickCandidates
+	oldIsTerminal := isTerminalFunc
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		input = oldInput
+		providerName = oldProvider
+		dbg = oldDebug
+		sendContext = oldContext
+		pickCandidates = oldCandidates
+		isTerminalFunc = oldIsTerminal
+	})
+
+	seq := &sequenceProvider{responses: []string{"<reasoning>r</reasoning>=ls -la", "+lh", "=ls -la"}}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return seq, nil
+	}
+	isTerminalFunc = func(fd int) bool { return false }
+	input = "ls -"
+	providerName = "mock"
+	dbg = false
+	sendContext = false
+	pickCandidates = 3
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	runErr := runPick(cmd, nil)
+	w.Close()
+	os.Stdout = stdout
+
+	out, _ := io.ReadAll(r)
+	if runErr != nil {
+		t.Fatalf("unexpected error: %v", runErr)
+	}
+	if string(out) != "ls -la\nls -lh\n" {
+		t.Fatalf("expected all candidates on stdout, got %q", out)
+	}
+}
+
+func TestRunPickInvalidCandidates(t *testing.T) {
+	oldCandidates := pickCandidates
+	t.Cleanup(func() { pickCandidates = oldCandidates })
+
+	pickCandidates = 0
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err := runPick(cmd, nil); err == nil {
+		t.Fatal("expected error for zero candidates")
+	}
+}
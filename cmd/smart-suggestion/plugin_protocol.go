@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/paths"
+)
+
+// pluginProtocolVersion is bumped whenever the binary starts relying on
+// something the zsh plugin has to send or handle. The plugin reports the
+// version it speaks in pluginProtocolEnv; keep both in sync.
+const pluginProtocolVersion = 2
+
+const (
+	pluginProtocolEnv = "SMART_SUGGESTION_PLUGIN_PROTOCOL"
+	// pluginWarningInterval keeps the outdated plugin warning to once a day.
+	pluginWarningInterval = 24 * time.Hour
+)
+
+// pluginOutdated parses the protocol version reported by the plugin and tells
+// whether it is older than the binary's. An empty value means the binary was
+// not called by the plugin and is never outdated.
+func pluginOutdated(reported string) (int, bool, error) {
+	reported = strings.TrimSpace(reported)
+	if reported == "" {
+		return 0, false, nil
+	}
+	version, err := strconv.Atoi(reported)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid plugin protocol version %q", reported)
+	}
+	return version, version < pluginProtocolVersion, nil
+}
+
+func pluginWarningFile() string {
+	return filepath.Join(paths.GetCacheDir(), "plugin_protocol_warning")
+}
+
+// warnOutdatedPlugin writes a warning to w when the sourced plugin speaks an
+// older protocol than this binary, at most once per pluginWarningInterval.
+func warnOutdatedPlugin(w io.Writer, now time.Time) {
+	version, outdated, err := pluginOutdated(os.Getenv(pluginProtocolEnv))
+	if err != nil {
+		debug.Log("Ignoring plugin protocol version", map[string]any{"error": err.Error()})
+		return
+	}
+	if !outdated {
+		return
+	}
+
+	stampFile := pluginWarningFile()
+	if data, err := os.ReadFile(stampFile); err == nil {
+		if last, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil &&
+			now.Sub(time.Unix(last, 0)) < pluginWarningInterval {
+			return
+		}
+	}
+
+	fmt.Fprintf(w, "smart-suggestion: the zsh plugin is outdated (protocol %d, binary expects %d); update the plugin or restart your shell to load the new one\n",
+		version, pluginProtocolVersion)
+
+	err = os.MkdirAll(filepath.Dir(stampFile), 0755)
+	if err == nil {
+		err = os.WriteFile(stampFile, []byte(strconv.FormatInt(now.Unix(), 10)), 0644)
+	}
+	if err != nil {
+		debug.Log("Failed to record plugin protocol warning", map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPluginOutdated(t *testing.T) {
+	cases := []struct {
+		reported string
+		version  int
+		outdated bool
+		wantErr  bool
+	}{
+		{reported: ""},
+		{reported: "0", version: 0, outdated: true},
+		{reported: " 0 ", version: 0, outdated: true},
+		{reported: "1", version: 1, outdated: true},
+		{reported: "2", version: 2},
+		{reported: "3", version: 3},
+		{reported: "v1", wantErr: true},
+	}
+	for _, tc := range cases {
+		version, outdated, err := pluginOutdated(tc.reported)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%q: unexpected error %v", tc.reported, err)
+		}
+		if version != tc.version || outdated != tc.outdated {
+			t.Errorf("%q: expected (%d, %v), got (%d, %v)", tc.reported, tc.version, tc.outdated, version, outdated)
+		}
+	}
+}
+
+func TestWarnOutdatedPluginOncePerDay(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	t.Setenv(pluginProtocolEnv, "0")
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var out strings.Builder
+	warnOutdatedPlugin(&out, now)
+	if !strings.Contains(out.String(), "zsh plugin is outdated (protocol 0, binary expects 2)") {
+		t.Fatalf("expected a warning, got %q", out.String())
+	}
+
+	out.Reset()
+	warnOutdatedPlugin(&out, now.Add(23*time.Hour))
+	if out.Len() != 0 {
+		t.Errorf("expected no second warning within a day, got %q", out.String())
+	}
+
+	warnOutdatedPlugin(&out, now.Add(25*time.Hour))
+	if out.Len() == 0 {
+		t.Error("expected the warning again after a day")
+	}
+}
+
+func TestWarnOutdatedPluginCurrent(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	for _, reported := range []string{"", "2", "garbage"} {
+		t.Setenv(pluginProtocolEnv, reported)
+		var out strings.Builder
+		warnOutdatedPlugin(&out, time.Now())
+		if out.Len() != 0 {
+			t.Errorf("%q: expected no warning, got %q", reported, out.String())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+const maxPromptBytesEnv = "SMART_SUGGESTION_MAX_PROMPT_BYTES"
+
+// promptByteLimit returns --max-prompt-bytes, or SMART_SUGGESTION_MAX_PROMPT_BYTES
+// when the flag is not set. 0 means no limit.
+func promptByteLimit() (int, error) {
+	if maxPromptBytes < 0 {
+		return 0, fmt.Errorf("--max-prompt-bytes must not be negative")
+	}
+	if maxPromptBytes > 0 {
+		return maxPromptBytes, nil
+	}
+	v := strings.TrimSpace(os.Getenv(maxPromptBytesEnv))
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a number of bytes, or 0 for no limit", maxPromptBytesEnv, v)
+	}
+	return limit, nil
+}
+
+// contextByteBudget returns how many bytes of context fit within limit next to
+// the system prompt, the conversation history and the described input, which
+// are never trimmed. It is at most 0 when nothing fits.
+func contextByteBudget(limit int, systemPrompt string, history []provider.Message, describedInput string) int {
+	budget := limit - len(systemPrompt) - len(userInputHeading) - len(describedInput)
+	for _, msg := range history {
+		budget -= len(msg.Content)
+	}
+	return budget
+}
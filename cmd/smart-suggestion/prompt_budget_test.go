@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+	"github.com/xyenon/smart-suggestion/internal/shellcontext"
+)
+
+func TestPromptByteLimit(t *testing.T) {
+	oldMax := maxPromptBytes
+	t.Cleanup(func() { maxPromptBytes = oldMax })
+
+	cases := []struct {
+		flag     int
+		env      string
+		expected int
+		wantErr  bool
+	}{
+		{flag: 0, env: "", expected: 0},
+		{flag: 0, env: "32000", expected: 32000},
+		{flag: 8000, env: "32000", expected: 8000},
+		{flag: 0, env: "lots", wantErr: true},
+		{flag: 0, env: "-5", wantErr: true},
+		{flag: -1, env: "", wantErr: true},
+	}
+	for _, tc := range cases {
+		maxPromptBytes = tc.flag
+		t.Setenv(maxPromptBytesEnv, tc.env)
+		got, err := promptByteLimit()
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("flag %d, env %q: expected an error", tc.flag, tc.env)
+			}
+			continue
+		}
+		if err != nil || got != tc.expected {
+			t.Errorf("flag %d, env %q: expected %d, got %d (%v)", tc.flag, tc.env, tc.expected, got, err)
+		}
+	}
+}
+
+func TestContextByteBudget(t *testing.T) {
+	history := []provider.Message{{Role: "user", Content: "12345"}, {Role: "assistant", Content: "123"}}
+	got := contextByteBudget(1000, "system", history, "ls -")
+	if expected := 1000 - len("system") - 8 - len(userInputHeading) - len("ls -"); got != expected {
+		t.Errorf("expected %d, got %d", expected, got)
+	}
+	if got := contextByteBudget(10, "a long system prompt", nil, "ls"); got > 0 {
+		t.Errorf("expected no room for context, got %d", got)
+	}
+}
+
+func TestPrepareSuggestRequestMaxPromptBytes(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldSystem := buildSystemContextFunc
+	oldUser := buildUserContextFunc
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	oldMax := maxPromptBytes
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		buildSystemContextFunc = oldSystem
+		buildUserContextFunc = oldUser
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+		maxPromptBytes = oldMax
+	})
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "=ls"}, nil
+	}
+	buildSystemContextFunc = func(opts shellcontext.SystemContextOptions) (string, error) {
+		return "", nil
+	}
+	calls := 0
+	var gotMax int
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
+		calls++
+		gotMax = opts.MaxBytes
+		return "# Scrollback:\n\nline one", nil
+	}
+	t.Setenv(maxPromptBytesEnv, "")
+	input = "list"
+	providerName = "mock"
+	sendContext = true
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	maxPromptBytes = 0
+	req, err := prepareSuggestRequest(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotMax != 0 {
+		t.Errorf("expected no context limit by default, got %d", gotMax)
+	}
+
+	maxPromptBytes = 100000
+	if req, err = prepareSuggestRequest(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := contextByteBudget(100000, req.systemPrompt, req.history, "list"); gotMax != expected {
+		t.Errorf("expected a context limit of %d, got %d", expected, gotMax)
+	}
+
+	// Without room for any context, it is not gathered at all.
+	calls = 0
+	maxPromptBytes = 10
+	if req, err = prepareSuggestRequest(cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 0 || req.userInput != "list" {
+		t.Errorf("expected only the input without context, got %q after %d calls", req.userInput, calls)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/prompt"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// applyPromptPolicy wraps text with SMART_SUGGESTION_PROMPT_PREFIX and
+// SMART_SUGGESTION_PROMPT_SUFFIX. The suffix goes right before the output
+// rules, so the rules still come last and policy text cannot override them.
+func applyPromptPolicy(text string) (string, error) {
+	prefix, err := provider.EnvOrFile("SMART_SUGGESTION_PROMPT_PREFIX")
+	if err != nil {
+		return "", err
+	}
+	suffix, err := provider.EnvOrFile("SMART_SUGGESTION_PROMPT_SUFFIX")
+	if err != nil {
+		return "", err
+	}
+
+	if suffix != "" {
+		if idx := strings.Index(text, prompt.RulesHeading); idx >= 0 {
+			text = text[:idx] + suffix + "\n\n" + text[idx:]
+		} else {
+			text = text + "\n\n" + suffix
+		}
+	}
+	if prefix != "" {
+		text = prefix + "\n\n" + text
+	}
+	return text, nil
+}
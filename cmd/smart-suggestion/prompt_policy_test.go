@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/prompt"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestApplyPromptPolicy(t *testing.T) {
+	const prefix = "Company policy: never suggest commands that disable auditing."
+	const suffix = "Prefer commands from the internal toolbox."
+	t.Setenv("SMART_SUGGESTION_PROMPT_PREFIX", prefix)
+	t.Setenv("SMART_SUGGESTION_PROMPT_SUFFIX", suffix)
+
+	for _, base := range []string{prompt.Default().String(), prompt.Fast().String()} {
+		got, err := applyPromptPolicy(base)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(got, prefix+"\n\n") {
+			t.Errorf("expected prompt to start with the prefix, got %q", got[:min(len(got), 80)])
+		}
+		suffixAt := strings.Index(got, suffix)
+		rulesAt := strings.Index(got, prompt.RulesHeading)
+		if suffixAt < 0 || rulesAt < 0 || suffixAt > rulesAt {
+			t.Errorf("expected suffix (at %d) before the output rules (at %d)", suffixAt, rulesAt)
+		}
+		if !strings.HasSuffix(got, base[strings.Index(base, prompt.RulesHeading):]) {
+			t.Error("expected the output rules to remain at the end")
+		}
+	}
+
+	got, err := applyPromptPolicy("Custom prompt.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := prefix + "\n\nCustom prompt.\n\n" + suffix; got != want {
+		t.Errorf("expected %q for a prompt without output rules, got %q", want, got)
+	}
+}
+
+func TestApplyPromptPolicyUnset(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_PROMPT_PREFIX", "")
+	t.Setenv("SMART_SUGGESTION_PROMPT_SUFFIX", "")
+
+	got, err := applyPromptPolicy(prompt.Default().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != prompt.Default().String() {
+		t.Error("expected prompt to be unchanged without a prefix or suffix")
+	}
+}
+
+func TestApplyPromptPolicyFiles(t *testing.T) {
+	dir := t.TempDir()
+	prefixFile := filepath.Join(dir, "prefix.txt")
+	if err := os.WriteFile(prefixFile, []byte("From file.\n"), 0644); err != nil {
+		t.Fatalf("failed to write prefix file: %v", err)
+	}
+	t.Setenv("SMART_SUGGESTION_PROMPT_PREFIX", "From env.")
+	t.Setenv("SMART_SUGGESTION_PROMPT_PREFIX_FILE", prefixFile)
+	t.Setenv("SMART_SUGGESTION_PROMPT_SUFFIX", "")
+
+	got, err := applyPromptPolicy("Custom prompt.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "From env.\n\nCustom prompt." {
+		t.Errorf("expected the variable to take precedence, got %q", got)
+	}
+
+	t.Setenv("SMART_SUGGESTION_PROMPT_PREFIX", "")
+	if got, err = applyPromptPolicy("Custom prompt."); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "From file.\n\nCustom prompt." {
+		t.Errorf("expected the file when the variable is empty, got %q", got)
+	}
+
+	t.Setenv("SMART_SUGGESTION_PROMPT_SUFFIX_FILE", filepath.Join(dir, "missing.txt"))
+	if _, err := applyPromptPolicy("Custom prompt."); err == nil {
+		t.Error("expected error for a missing suffix file")
+	}
+}
+
+func TestRunSuggestPromptPolicy(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	oldSystem := systemPrompt
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+		systemPrompt = oldSystem
+	})
+
+	t.Setenv("SMART_SUGGESTION_PROMPT_PREFIX", "POLICY")
+	mock := &mockProvider{response: "=ls"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	input = "list files"
+	providerName = "mock"
+	sendContext = false
+	systemPrompt = ""
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err := runSuggest(cmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(mock.gotSystemPrompt, "POLICY\n\n") {
+		t.Fatalf("expected the policy prefix in the system prompt, got %q", mock.gotSystemPrompt[:min(len(mock.gotSystemPrompt), 80)])
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func runProviders(cmd *cobra.Command, args []string) error {
+	debug.Enable(dbg)
+	return listProviders(os.Stdout)
+}
+
+// listProviders writes every provider that --provider accepts to w: the
+// built-in ones, then those from the providers file. Each gets its status and
+// the environment variables it reads. The status comes from the environment
+// alone: no provider is built, and no "cmd:" value run.
+func listProviders(w io.Writer) error {
+	var endpoints map[string]provider.Endpoint
+	if path := providersFilePath(); path != "" {
+		var err error
+		if endpoints, err = provider.LoadEndpoints(path); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range provider.BuiltinProviders {
+		if _, ok := endpoints[name]; ok {
+			// The providers file takes its place; it is listed below.
+			continue
+		}
+		desc, _ := provider.Describe(name)
+		model, err := provider.CheckConfig(name)
+		writeProviderStatus(w, name, desc, model, err)
+	}
+
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		endpoint := endpoints[name]
+		label := fmt.Sprintf("%s (%s, from the providers file)", name, endpoint.Protocol)
+		model, err := provider.CheckEndpoint(name, endpoint)
+		writeProviderStatus(w, label, provider.DescribeEndpoint(endpoint), model, err)
+	}
+	return nil
+}
+
+// writeProviderStatus writes label and the status of a provider, given its
+// description and what checking its configuration found, then the variables it
+// reads, one line each.
+func writeProviderStatus(w io.Writer, label string, desc provider.Description, model string, err error) {
+	status := "ready"
+	if len(desc.Missing) > 0 {
+		status = "missing: " + strings.Join(desc.Missing, ", ")
+	} else if err != nil {
+		status = "error: " + err.Error()
+	} else if model != "" {
+		status += ", model=" + model
+	}
+	fmt.Fprintf(w, "%s: %s\n", label, status)
+
+	vars := make([]string, len(desc.Env))
+	for i, env := range desc.Env {
+		switch env.Source {
+		case "":
+			vars[i] = env.Name + " [unset]"
+		case env.Name:
+			vars[i] = env.Name + " [set]"
+		default:
+			vars[i] = fmt.Sprintf("%s [set via %s]", env.Name, env.Source)
+		}
+	}
+	fmt.Fprintf(w, "  %s\n", strings.Join(vars, ", "))
+}
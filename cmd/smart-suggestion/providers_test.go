@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMainProviders(t *testing.T) {
+	setValidConfig(t)
+	for _, name := range []string{
+		"LLM_PROVIDER", "LLM_API_KEY", "LLM_MODEL", "LLM_BASE_URL",
+		"OPENAI_MODEL", "OPENAI_COMPATIBLE_API_KEY", "OPENAI_COMPATIBLE_API_KEY_FILE",
+		"AZURE_OPENAI_API_KEY", "AZURE_OPENAI_DEPLOYMENT_NAME", "AZURE_OPENAI_RESOURCE_NAME", "AZURE_OPENAI_BASE_URL",
+		"ANTHROPIC_API_KEY", "ANTHROPIC_API_KEY_FILE", "GEMINI_API_KEY", "GEMINI_API_KEY_FILE",
+	} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("OPENAI_COMPATIBLE_BASE_URL", "https://gateway.example.com/v1")
+	t.Setenv("OPENAI_COMPATIBLE_MODEL", "llama-3.3-70b")
+	t.Setenv("WORK_KEY", "")
+
+	providersFile := filepath.Join(t.TempDir(), "providers.json")
+	if err := os.WriteFile(providersFile, []byte(`{"work": {"protocol": "anthropic", "api_key_env": "WORK_KEY"}}`), 0644); err != nil {
+		t.Fatalf("failed to write providers file: %v", err)
+	}
+
+	exitCode, out := runMain(t, "providers", "--providers-file", providersFile)
+	if exitCode != -1 {
+		t.Errorf("expected a successful exit, got exit code %d", exitCode)
+	}
+	for _, want := range []string{
+		"openai: ready, model=gpt-4o-mini\n  OPENAI_API_KEY [set], OPENAI_API_KEYS [unset],",
+		"openai_compatible: missing: OPENAI_COMPATIBLE_API_KEY\n",
+		"OPENAI_COMPATIBLE_BASE_URL [set]",
+		"azure_openai: missing: AZURE_OPENAI_API_KEY, AZURE_OPENAI_DEPLOYMENT_NAME, AZURE_OPENAI_RESOURCE_NAME\n",
+		"gemini: missing: GEMINI_API_KEY\n",
+		"work (anthropic, from the providers file): missing: WORK_KEY\n  WORK_KEY [unset]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in the output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestListProvidersBuildError(t *testing.T) {
+	setValidConfig(t)
+	t.Setenv("OPENAI_BASE_URL", "ftp://example.com")
+
+	var out strings.Builder
+	if err := listProviders(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "openai: error: OPENAI_BASE_URL") {
+		t.Errorf("expected the build error of openai, got:\n%s", out.String())
+	}
+}
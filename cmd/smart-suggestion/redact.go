@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Placeholders --context-redact-paths puts in place of the home directory and
+// the user name. Both are valid shell, so a command the model builds from
+// them still works when run.
+const (
+	homePlaceholder = "~"
+	userPlaceholder = "$USER"
+)
+
+// redactContext replaces the home directory in context with ~ and the user
+// name with $USER, when --context-redact-paths is set.
+func redactContext(context string) string {
+	if !redactPaths {
+		return context
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = ""
+	}
+	return redactHome(context, filepath.Clean(home), os.Getenv("USER"))
+}
+
+// userSegments are the places the user name is masked in, with %s standing
+// for it: home directories other than home, such as those of a remote host, a
+// user@host prompt or ssh target, and the "You are user" of the context header.
+// Elsewhere the name is left alone, as it may just as well be a word or part
+// of a command.
+var userSegments = []string{"/home/%s", "/Users/%s", "%s@", "You are user %s"}
+
+// redactHome replaces home wherever it starts a path, and then user wherever
+// it is a whole component of one of userSegments. A home of / or a relative
+// one is left alone, as masking it would mangle every path.
+func redactHome(text, home, user string) string {
+	notInPath := func(b byte) bool { return b != '/' && !isNameByte(b) }
+	if filepath.IsAbs(home) && home != "/" {
+		text = replaceWord(text, home, homePlaceholder, notInPath, func(b byte) bool { return b == '/' || notInPath(b) })
+	}
+	if user == "" {
+		return text
+	}
+	notInName := func(b byte) bool { return !isNameByte(b) }
+	for _, segment := range userSegments {
+		before, after := notInName, func(b byte) bool { return b == '/' || notInPath(b) }
+		if strings.HasPrefix(segment, "/") {
+			// Also under a mount point, such as /mnt/Users/<user>.
+			before = func(byte) bool { return true }
+		}
+		if strings.HasSuffix(segment, "@") {
+			// Only a user@host, not a word that merely ends in @.
+			after = isNameByte
+		}
+		text = replaceWord(text, fmt.Sprintf(segment, user), fmt.Sprintf(segment, userPlaceholder), before, after)
+	}
+	return text
+}
+
+// replaceWord replaces the occurrences of old whose preceding byte satisfies
+// before and whose following byte satisfies after. The start and end of text
+// satisfy both.
+func replaceWord(text, old, replacement string, before, after func(byte) bool) string {
+	var builder strings.Builder
+	last := 0
+	for pos := 0; ; {
+		i := strings.Index(text[pos:], old)
+		if i < 0 {
+			break
+		}
+		start, end := pos+i, pos+i+len(old)
+		if (start == 0 || before(text[start-1])) && (end == len(text) || after(text[end])) {
+			builder.WriteString(text[last:start])
+			builder.WriteString(replacement)
+			last = end
+		}
+		pos = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// isNameByte reports whether b can be part of a user name or file name.
+func isNameByte(b byte) bool {
+	return b == '_' || b == '-' || b == '.' ||
+		'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xyenon/smart-suggestion/internal/shellcontext"
+)
+
+func TestRedactHome(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "cwd", text: "in directory /Users/alice/src/app.", want: "in directory ~/src/app."},
+		{name: "home itself", text: "cd /Users/alice", want: "cd ~"},
+		{name: "user name", text: "You are user alice with id 501", want: "You are user $USER with id 501"},
+		{name: "user in other paths", text: "ls /var/mail/alice /tmp/alice-build", want: "ls /var/mail/alice /tmp/alice-build"},
+		{name: "other homes", text: "scp host:/home/alice/a /Users/alice/b", want: "scp host:/home/$USER/a ~/b"},
+		{name: "user as a word", text: "git log --author alice && echo alice", want: "git log --author alice && echo alice"},
+		{name: "not a prompt", text: "alice@ and @alice", want: "alice@ and @alice"},
+		{name: "prompt", text: "alice@mbp ~/src % make", want: "$USER@mbp ~/src % make"},
+		{name: "similar home", text: "/Users/alicex/notes /mnt/Users/alice/x", want: "/Users/alicex/notes /mnt/Users/$USER/x"},
+		{name: "longer word", text: "malice alicealice", want: "malice alicealice"},
+		{name: "several", text: "/Users/alice/a:/Users/alice/b", want: "~/a:~/b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactHome(tt.text, "/Users/alice", "alice"); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := redactHome("cd /etc && whoami", "/", ""); got != "cd /etc && whoami" {
+		t.Errorf("expected a root home to be left alone, got %q", got)
+	}
+}
+
+func TestRedactContextFlag(t *testing.T) {
+	oldSystem := buildSystemContextFunc
+	oldUser := buildUserContextFunc
+	oldRedact := redactPaths
+	oldPrompt := systemPrompt
+	t.Cleanup(func() {
+		buildSystemContextFunc = oldSystem
+		buildUserContextFunc = oldUser
+		redactPaths = oldRedact
+		systemPrompt = oldPrompt
+	})
+	t.Setenv("HOME", "/home/alice")
+	t.Setenv("USER", "alice")
+	systemPrompt = "prompt"
+	buildSystemContextFunc = func(shellcontext.SystemContextOptions) (string, error) {
+		return "You are user alice in directory /home/alice/src.", nil
+	}
+	buildUserContextFunc = func(opts shellcontext.UserContextOptions) (string, error) {
+		return opts.Redact("# Shell history:\n\ncd /home/alice/src\nssh alice@server"), nil
+	}
+
+	redactPaths = false
+	if got := resolveSystemPrompt(true); !strings.Contains(got, "/home/alice/src") {
+		t.Fatalf("expected the context unchanged without the flag, got %q", got)
+	}
+
+	redactPaths = true
+	system := resolveSystemPrompt(true)
+	if system != "prompt\n\nYou are user $USER in directory ~/src." {
+		t.Errorf("expected a redacted system context, got %q", system)
+	}
+	user := buildUserInput("cat /home/alice/notes", shellcontext.UserContextOptions{}, true)
+	want := "# Shell history:\n\ncd ~/src\nssh $USER@server\n\n# User input:\n\ncat /home/alice/notes"
+	if user != want {
+		t.Errorf("expected the context, not the input, to be redacted, got %q", user)
+	}
+}
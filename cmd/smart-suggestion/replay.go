@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+	"github.com/xyenon/smart-suggestion/internal/recording"
+)
+
+// record appends the request and its outcome to the bundle configured by
+// --record or SMART_SUGGESTION_RECORD_FILE, for `smart-suggestion replay`.
+// Failing to record is only logged.
+func (r *suggestRequest) record(response string, parsed string, suggestion string, err error) {
+	path := recordFile
+	if path == "" {
+		path = os.Getenv(recording.FileEnv)
+	}
+	if path == "" {
+		return
+	}
+
+	entry := recording.Entry{
+		Timestamp:    time.Now(),
+		Provider:     r.providerName,
+		Model:        provider.ModelName(r.client),
+		Input:        input,
+		Context:      r.userContext,
+		SystemPrompt: r.systemPrompt,
+		History:      r.history,
+		UserInput:    r.userInput,
+		Response:     response,
+		Parsed:       parsed,
+		Suggestion:   suggestion,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := recording.Append(path, entry); err != nil {
+		debug.Log("Failed to record suggestion", map[string]any{"error": err.Error()})
+	}
+}
+
+// runReplay parses every recorded response again, so a change to the parser
+// can be checked against real answers, and with --requery sends the recorded
+// requests again to see what the provider answers now.
+func runReplay(cmd *cobra.Command, args []string) error {
+	debug.Enable(dbg)
+
+	entries, err := recording.Read(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	changed := 0
+	for i, entry := range entries {
+		label := entry.Provider
+		if entry.Model != "" {
+			label += "/" + entry.Model
+		}
+		fmt.Fprintf(out, "#%d %s %s %q\n", i+1, entry.Timestamp.Local().Format(time.DateTime), label, entry.Input)
+
+		if entry.Response == "" {
+			fmt.Fprintf(out, "  error:     %s\n", entry.Error)
+		} else {
+			replayed := provider.ParseAndExtractCommand(entry.Response)
+			fmt.Fprintf(out, "  recorded:  %s\n", entry.Parsed)
+			fmt.Fprintf(out, "  replayed:  %s\n", replayed)
+			if replayed != entry.Parsed {
+				changed++
+				fmt.Fprintln(out, "  (the recorded response parses differently now)")
+			}
+		}
+
+		if replayRequery {
+			requeryEntry(cmd, out, entry)
+		}
+	}
+
+	if changed > 0 {
+		return fmt.Errorf("%d of %d recorded responses parse differently now", changed, len(entries))
+	}
+	return nil
+}
+
+// requeryEntry sends the recorded request to the provider given by --provider,
+// or the recorded one, and prints the command it answers with.
+func requeryEntry(cmd *cobra.Command, out io.Writer, entry recording.Entry) {
+	flagProvider := providerName
+	defer func() { providerName = flagProvider }()
+	if providerName == "" {
+		providerName = entry.Provider
+	}
+
+	client, err := selectProviderFunc(cmd)
+	if err != nil {
+		fmt.Fprintf(out, "  requery failed: %v\n", err)
+		return
+	}
+	response, err := client.FetchWithHistory(cmd.Context(), entry.UserInput, entry.SystemPrompt, entry.History)
+	if err != nil {
+		fmt.Fprintf(out, "  requery failed: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "  requeried: %s\n", provider.ParseAndExtractCommand(response))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+	"github.com/xyenon/smart-suggestion/internal/recording"
+)
+
+func TestMainRecordAndReplay(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	mock := &mockProvider{response: "<reasoning>List the files.</reasoning>=ls -la"}
+	var selected []string
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		selected = append(selected, providerName)
+		return mock, nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	bundle := filepath.Join(t.TempDir(), "session.jsonl")
+
+	if _, out := runMain(t, "--provider", "mock", "--input", "ls", "--output", "-", "--record", bundle); out != "=ls -la" {
+		t.Fatalf("expected =ls -la, got %q", out)
+	}
+	mock.err = errors.New("service unavailable")
+	t.Setenv(recording.FileEnv, bundle)
+	if exitCode, _ := runMain(t, "--provider", "mock", "--input", "git", "--output", "-"); exitCode != 1 {
+		t.Fatalf("expected the failed suggestion to exit 1, got %d", exitCode)
+	}
+
+	entries, err := recording.Read(bundle)
+	if err != nil {
+		t.Fatalf("failed to read bundle: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected two recorded suggestions, got %+v", entries)
+	}
+	first := entries[0]
+	if first.Provider != "mock" || first.Input != "ls" || first.Response != mock.response || first.Parsed != "=ls -la" || first.Suggestion != "=ls -la" {
+		t.Errorf("unexpected first entry: %+v", first)
+	}
+	if first.SystemPrompt == "" || first.UserInput == "" || len(first.History) == 0 {
+		t.Errorf("expected the request to be recorded, got %+v", first)
+	}
+	if entries[1].Input != "git" || !strings.Contains(entries[1].Error, "service unavailable") {
+		t.Errorf("expected the error to be recorded, got %+v", entries[1])
+	}
+
+	exitCode, out := runMain(t, "replay", bundle)
+	if exitCode != -1 {
+		t.Fatalf("expected replay to succeed, got exit code %d: %s", exitCode, out)
+	}
+	for _, want := range []string{`mock "ls"`, "recorded:  =ls -la", "replayed:  =ls -la", "error:     ", "service unavailable"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected replay output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	mock.err = nil
+	mock.response = "=ls -l"
+	selected = nil
+	_, out = runMain(t, "replay", "--requery", bundle)
+	if !strings.Contains(out, "requeried: =ls -l\n") {
+		t.Errorf("expected the requeried command, got:\n%s", out)
+	}
+	if mock.gotSystemPrompt != first.SystemPrompt || mock.gotInput != entries[1].UserInput {
+		t.Errorf("expected the recorded request to be sent again, got %q", mock.gotInput)
+	}
+	if len(selected) != 2 || selected[0] != "mock" {
+		t.Errorf("expected the recorded provider to be requeried, got %v", selected)
+	}
+}
+
+func TestReplayReportsChangedParse(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "session.jsonl")
+	for _, entry := range []recording.Entry{
+		{Timestamp: time.Now(), Provider: "openai", Input: "ls", Response: "=ls -la", Parsed: "=ls -la"},
+		{Timestamp: time.Now(), Provider: "openai", Input: "cd", Response: "=cd ~", Parsed: "=cd ~/src"},
+	} {
+		if err := recording.Append(bundle, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exitCode, out := runMain(t, "replay", bundle)
+	if exitCode != 1 {
+		t.Errorf("expected exit code 1 for a changed parse, got %d", exitCode)
+	}
+	if strings.Count(out, "parses differently now") != 1 {
+		t.Errorf("expected one changed response, got:\n%s", out)
+	}
+}
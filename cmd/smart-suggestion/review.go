@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/prompt"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// reviewModelEnv names a second, usually cheaper, model of the same provider
+// that checks each suggestion before it is returned. Unset skips the review.
+const reviewModelEnv = "SMART_SUGGESTION_REVIEW_MODEL"
+
+// reviewObjectionPrefix starts the reviewer's answer when it objects.
+const reviewObjectionPrefix = "OBJECTION:"
+
+var withModelFunc = provider.WithModel
+
+// review asks the model in SMART_SUGGESTION_REVIEW_MODEL whether suggestion is
+// safe and correct for the request, and returns its objection, or "" when it
+// has none. A review that cannot be done is logged and counts as no objection,
+// so it never costs the user the suggestion.
+func (r *suggestRequest) review(suggestion string) string {
+	model := strings.TrimSpace(os.Getenv(reviewModelEnv))
+	if model == "" || suggestion == "" {
+		return ""
+	}
+	reviewer, ok := withModelFunc(r.client, model)
+	if !ok {
+		debug.Log("Provider does not support a review model", map[string]any{
+			"provider": r.providerName,
+		})
+		return ""
+	}
+
+	command := candidateCommand(r.inputBefore, r.inputAfter, suggestion)
+	input := "# Context and input the command was suggested for:\n\n" + r.userInput +
+		"\n\n# Suggested command:\n\n" + command
+	response, err := r.fetchWith(func(ctx context.Context) (string, error) {
+		return reviewer.FetchWithHistory(ctx, input, prompt.Review().String(), nil)
+	})
+	if err != nil {
+		debug.Log("Review failed", map[string]any{
+			"error":        err.Error(),
+			"review_model": model,
+		})
+		return ""
+	}
+
+	objection := parseReview(response)
+	debug.Log("Reviewed suggestion", map[string]any{
+		"command":      command,
+		"review_model": model,
+		"objection":    objection,
+	})
+	return objection
+}
+
+// parseReview returns the objection in a reviewer's response, or "" for OK.
+// Any other answer is taken as an objection in its own words, since the
+// reviewer did not clearly approve.
+func parseReview(response string) string {
+	answer := provider.ParseAndExtractCommand(response)
+	if strings.EqualFold(strings.TrimRight(answer, "."), "OK") {
+		return ""
+	}
+	if objection, ok := strings.CutPrefix(answer, reviewObjectionPrefix); ok {
+		return strings.TrimSpace(objection)
+	}
+	return answer
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestParseReview(t *testing.T) {
+	tests := map[string]string{
+		"OK":  "",
+		"ok.": "",
+		"OBJECTION: This deletes the whole home directory.":                         "This deletes the whole home directory.",
+		"<reasoning>rm -rf on ~ is destructive.</reasoning>\nOBJECTION: Deletes ~.": "Deletes ~.",
+		"<reasoning>Listing is harmless.</reasoning>OK":                             "",
+		"Looks risky to me": "Looks risky to me",
+	}
+	for response, want := range tests {
+		if got := parseReview(response); got != want {
+			t.Errorf("parseReview(%q) = %q, want %q", response, got, want)
+		}
+	}
+}
+
+func TestRunSuggestReview(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldWithModel := withModelFunc
+	oldOutput := outputFile
+	oldMeta := metaFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		withModelFunc = oldWithModel
+		outputFile = oldOutput
+		metaFile = oldMeta
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+	})
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "<reasoning>Clean up.</reasoning>=rm -rf ~"}, nil
+	}
+	dir := t.TempDir()
+	outputFile = filepath.Join(dir, "output.txt")
+	metaFile = filepath.Join(dir, "meta.json")
+	input = "clean up"
+	providerName = "mock"
+	sendContext = false
+
+	tests := []struct {
+		name        string
+		reviewModel string
+		reviewer    *mockProvider
+		supported   bool
+		wantWarning string
+	}{
+		{
+			name:        "objection",
+			reviewModel: "gpt-4o-mini",
+			reviewer:    &mockProvider{response: "OBJECTION: This deletes the home directory."},
+			supported:   true,
+			wantWarning: "This deletes the home directory.",
+		},
+		{
+			name:        "approval",
+			reviewModel: "gpt-4o-mini",
+			reviewer:    &mockProvider{response: "OK"},
+			supported:   true,
+		},
+		{
+			name:        "review failure",
+			reviewModel: "gpt-4o-mini",
+			reviewer:    &mockProvider{err: errors.New("connection reset")},
+			supported:   true,
+		},
+		{
+			name:        "provider without models",
+			reviewModel: "gpt-4o-mini",
+			reviewer:    &mockProvider{response: "OBJECTION: unused"},
+		},
+		{
+			name:     "no review model",
+			reviewer: &mockProvider{response: "OBJECTION: unused"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(reviewModelEnv, tt.reviewModel)
+			var gotModel string
+			withModelFunc = func(p provider.Provider, model string) (provider.Provider, bool) {
+				gotModel = model
+				return tt.reviewer, tt.supported
+			}
+
+			cmd := &cobra.Command{}
+			cmd.SetContext(context.Background())
+			if err := runSuggest(cmd, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			output, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != "=rm -rf ~" {
+				t.Errorf("expected the suggestion to be kept, got %q", output)
+			}
+			data, err := os.ReadFile(metaFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var meta suggestionMeta
+			if err := json.Unmarshal(data, &meta); err != nil {
+				t.Fatal(err)
+			}
+			if meta.Warning != tt.wantWarning {
+				t.Errorf("expected warning %q, got %q", tt.wantWarning, meta.Warning)
+			}
+
+			if tt.reviewModel == "" {
+				if tt.reviewer.gotCtx != nil {
+					t.Error("expected no review without a review model")
+				}
+				return
+			}
+			if gotModel != tt.reviewModel {
+				t.Errorf("expected the review model %q, got %q", tt.reviewModel, gotModel)
+			}
+			if tt.supported && !strings.HasSuffix(tt.reviewer.gotInput, "# Suggested command:\n\nrm -rf ~") {
+				t.Errorf("expected the reviewer to get the command, got %q", tt.reviewer.gotInput)
+			}
+		})
+	}
+}
+
+func TestRunSuggestReviewWithoutMeta(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldWithModel := withModelFunc
+	oldOutput := outputFile
+	oldMeta := metaFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	oldStderr := os.Stderr
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		withModelFunc = oldWithModel
+		outputFile = oldOutput
+		metaFile = oldMeta
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+		os.Stderr = oldStderr
+	})
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	t.Setenv(reviewModelEnv, "gpt-4o-mini")
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "=rm -rf ~"}, nil
+	}
+	reviewer := &mockProvider{response: "OBJECTION: This deletes the home directory."}
+	withModelFunc = func(p provider.Provider, model string) (provider.Provider, bool) {
+		return reviewer, true
+	}
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	metaFile = ""
+	input = "clean up"
+	providerName = "mock"
+	sendContext = false
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = w
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	err = runSuggest(cmd, nil)
+	w.Close()
+	os.Stderr = oldStderr
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stderr, _ := io.ReadAll(r)
+	if reviewer.gotCtx == nil {
+		t.Fatal("expected the suggestion to be reviewed without --meta")
+	}
+	if string(stderr) != "Warning: This deletes the home directory.\n" {
+		t.Errorf("expected the objection on stderr, got %q", stderr)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// safeModeEnv makes every suggestion non-destructive: the plugin never
+// replaces what was typed, it only shows ghost text or a hint.
+const safeModeEnv = "SMART_SUGGESTION_SAFE_MODE"
+
+// displayPrefix marks a suggestion that is only shown to the user, leaving the
+// buffer alone. Safe mode turns "=" replacements into it when they cannot be
+// expressed as a completion of the input.
+const displayPrefix = "~"
+
+// safeMode reports whether SMART_SUGGESTION_SAFE_MODE is enabled.
+func safeMode() (bool, error) {
+	v := strings.TrimSpace(os.Getenv(safeModeEnv))
+	if v == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be true or false", safeModeEnv, v)
+	}
+	return enabled, nil
+}
+
+// neutralizeReplacement rewrites a "=" suggestion so applying it cannot
+// clobber the input. A new command that keeps the text on both sides of the
+// cursor becomes a "+" completion of the missing part; any other becomes a
+// display-only "~" suggestion, and an empty one is dropped. Completions are
+// returned unchanged.
+func neutralizeReplacement(before, after, suggestion string) string {
+	command, ok := strings.CutPrefix(suggestion, "=")
+	if !ok {
+		return suggestion
+	}
+	if command == "" {
+		return ""
+	}
+	if len(command) > len(before)+len(after) && strings.HasPrefix(command, before) && strings.HasSuffix(command, after) {
+		return "+" + command[len(before):len(command)-len(after)]
+	}
+	return displayPrefix + command
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestSafeMode(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "true": true, " 1 ": true, "false": false} {
+		t.Setenv(safeModeEnv, value)
+		got, err := safeMode()
+		if err != nil || got != want {
+			t.Errorf("%q: expected %v, got %v, %v", value, want, got, err)
+		}
+	}
+
+	t.Setenv(safeModeEnv, "sometimes")
+	if _, err := safeMode(); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestNeutralizeReplacement(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     string
+		after      string
+		suggestion string
+		want       string
+	}{
+		{name: "unrelated command", before: "list files", suggestion: "=ls -la", want: "~ls -la"},
+		{name: "command extending input", before: "git com", suggestion: "=git commit -m", want: "+mit -m"},
+		{name: "command keeping text after cursor", before: "git ", after: " --amend", suggestion: "=git commit --amend", want: "+commit"},
+		{name: "command dropping text after cursor", before: "git ", after: " --amend", suggestion: "=git commit", want: "~git commit"},
+		{name: "same command", before: "ls", suggestion: "=ls", want: "~ls"},
+		{name: "empty input", suggestion: "=ls", want: "+ls"},
+		{name: "empty replacement", before: "rm -rf build", suggestion: "=", want: ""},
+		{name: "completion", before: "git sta", suggestion: "+tus", want: "+tus"},
+		{name: "no suggestion", before: "ls", suggestion: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := neutralizeReplacement(tt.before, tt.after, tt.suggestion); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunSuggestSafeMode(t *testing.T) {
+	oldSelect := selectProviderFunc
+	oldOutput := outputFile
+	oldMeta := metaFile
+	oldInput := input
+	oldProvider := providerName
+	oldContext := sendContext
+	t.Cleanup(func() {
+		selectProviderFunc = oldSelect
+		outputFile = oldOutput
+		metaFile = oldMeta
+		input = oldInput
+		providerName = oldProvider
+		sendContext = oldContext
+	})
+
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &mockProvider{response: "=rm -rf build"}, nil
+	}
+	dir := t.TempDir()
+	outputFile = filepath.Join(dir, "output.txt")
+	metaFile = filepath.Join(dir, "meta.json")
+	input = "clean up"
+	providerName = "mock"
+	sendContext = false
+
+	for _, tt := range []struct {
+		safe     string
+		want     string
+		wantType string
+	}{
+		{safe: "true", want: "~rm -rf build", wantType: "display"},
+		{safe: "false", want: "=rm -rf build", wantType: "command"},
+	} {
+		t.Setenv(safeModeEnv, tt.safe)
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+		if err := runSuggest(cmd, nil); err != nil {
+			t.Fatalf("safe mode %s: unexpected error: %v", tt.safe, err)
+		}
+
+		output, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != tt.want {
+			t.Errorf("safe mode %s: expected output %q, got %q", tt.safe, tt.want, output)
+		}
+
+		data, err := os.ReadFile(metaFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var meta suggestionMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			t.Fatal(err)
+		}
+		if meta.Type != tt.wantType || meta.Command != "rm -rf build" || meta.NonDestructive != (tt.safe == "true") {
+			t.Errorf("safe mode %s: unexpected metadata %+v", tt.safe, meta)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import "strings"
+
+// controlOperatorHints describe the command the cursor is in by the control
+// operator that started it.
+var controlOperatorHints = map[string]string{
+	"|":  "in a command that reads the previous command's output through `|`",
+	"|&": "in a command that reads the previous command's output and errors through `|&`",
+	"&&": "in a command that runs only if the previous one succeeds (`&&`)",
+	"||": "in a command that runs only if the previous one fails (`||`)",
+}
+
+// shellLine is what a lightweight scan of the input up to the cursor tells
+// about where the cursor is. It does not parse the shell grammar: command
+// substitutions, here-documents and the like are scanned as plain text.
+type shellLine struct {
+	// quote is ' or " when the cursor is inside a quoted string, 0 otherwise.
+	quote byte
+	// control is the last control operator (|, |&, &&, ||, ; or &) before
+	// the cursor, or "".
+	control string
+	// redirect is the redirection operator (>, >>, <, &>, ...) the cursor
+	// directly follows, waiting for its file name, or "".
+	redirect string
+}
+
+// scanShellLine scans before, the input up to the cursor.
+func scanShellLine(before string) shellLine {
+	var line shellLine
+	inWord := false
+	for i := 0; i < len(before); i++ {
+		c := before[i]
+		switch line.quote {
+		case '\'':
+			if c == '\'' {
+				line.quote = 0
+			}
+			continue
+		case '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				line.quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\\':
+			i++
+			inWord = true
+		case '\'', '"':
+			line.quote = c
+			inWord = true
+		case ' ', '\t', '\n':
+			if inWord {
+				// A finished word after a redirection is its file name.
+				line.redirect = ""
+			}
+			inWord = false
+		case '|', '&', ';':
+			op := string(c)
+			if i+1 < len(before) && (c == '|' && (before[i+1] == '|' || before[i+1] == '&') || c == '&' && before[i+1] == '&') {
+				op += string(before[i+1])
+				i++
+			}
+			if op == "&" && i+1 < len(before) && before[i+1] == '>' {
+				// &> and &>> redirect both output streams.
+				line.redirect, i = redirectAt(before, i+1, "&")
+				inWord = false
+				continue
+			}
+			line.control, line.redirect = op, ""
+			inWord = false
+		case '<', '>':
+			// A file descriptor number directly before belongs to the operator.
+			line.redirect, i = redirectAt(before, i, "")
+			inWord = false
+		default:
+			inWord = true
+		}
+	}
+	return line
+}
+
+// redirectAt returns the redirection operator starting at before[i] (after
+// prefix), and the index of its last byte.
+func redirectAt(before string, i int, prefix string) (string, int) {
+	op := prefix + string(before[i])
+	if i+1 < len(before) && (before[i+1] == before[i] || before[i+1] == '&') {
+		op += string(before[i+1])
+		i++
+	}
+	return op, i
+}
+
+// shellLineNote describes the shell state at the cursor for the model, or
+// returns "" when there is nothing worth saying.
+func shellLineNote(before string) string {
+	line := scanShellLine(before)
+
+	var hints []string
+	switch line.quote {
+	case '\'':
+		hints = append(hints, "inside a single-quoted string")
+	case '"':
+		hints = append(hints, "inside a double-quoted string")
+	}
+	if line.redirect != "" && line.quote == 0 {
+		hints = append(hints, "at the file name of the redirection `"+line.redirect+"`")
+	}
+	if hint, ok := controlOperatorHints[line.control]; ok {
+		hints = append(hints, hint)
+	}
+	if len(hints) == 0 {
+		return ""
+	}
+	return "\n\n(The cursor is " + strings.Join(hints, ", and ") + ".)"
+}
+
+// describeInput returns the input as the model sees it: with the cursor
+// marked when it is not at the end, and the shell state at the cursor noted.
+func describeInput(before, after string) string {
+	return markCursor(before, after) + shellLineNote(before)
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestScanShellLine(t *testing.T) {
+	tests := []struct {
+		before string
+		want   shellLine
+	}{
+		{before: "", want: shellLine{}},
+		{before: "git commit -m 'fix the", want: shellLine{quote: '\''}},
+		{before: "git commit -m 'fix' && git push", want: shellLine{control: "&&"}},
+		{before: `echo "it's \"quoted`, want: shellLine{quote: '"'}},
+		{before: `echo it\'s `, want: shellLine{}},
+		{before: "ps aux | gr", want: shellLine{control: "|"}},
+		{before: "make 2>&1 |& tee", want: shellLine{control: "|&"}},
+		{before: "test -f x || ", want: shellLine{control: "||"}},
+		{before: "echo 'a | b' ", want: shellLine{}},
+		{before: "cd src; ls", want: shellLine{control: ";"}},
+		{before: "sort data > ", want: shellLine{redirect: ">"}},
+		{before: "sort data >> out", want: shellLine{redirect: ">>"}},
+		{before: "sort data > out ", want: shellLine{}},
+		{before: "make &> ", want: shellLine{redirect: "&>"}},
+		{before: "cat < in | wc ", want: shellLine{control: "|"}},
+		{before: "grep x file | sort > ", want: shellLine{control: "|", redirect: ">"}},
+	}
+	for _, tt := range tests {
+		if got := scanShellLine(tt.before); got != tt.want {
+			t.Errorf("%q: expected %+v, got %+v", tt.before, tt.want, got)
+		}
+	}
+}
+
+func TestShellLineNote(t *testing.T) {
+	tests := []struct {
+		before string
+		want   string
+	}{
+		{before: "ls -la", want: ""},
+		{before: "git commit -m 'fix the", want: "\n\n(The cursor is inside a single-quoted string.)"},
+		{before: `grep "TODO`, want: "\n\n(The cursor is inside a double-quoted string.)"},
+		{before: "ps aux | gr", want: "\n\n(The cursor is in a command that reads the previous command's output through `|`.)"},
+		{before: "make && ", want: "\n\n(The cursor is in a command that runs only if the previous one succeeds (`&&`).)"},
+		{before: "ps aux | grep 'ssh", want: "\n\n(The cursor is inside a single-quoted string, and in a command that reads the previous command's output through `|`.)"},
+		{before: "curl -s example.com > ", want: "\n\n(The cursor is at the file name of the redirection `>`.)"},
+		{before: "cd src; ls", want: ""},
+	}
+	for _, tt := range tests {
+		if got := shellLineNote(tt.before); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.before, tt.want, got)
+		}
+	}
+}
+
+func TestDescribeInput(t *testing.T) {
+	got := describeInput("echo '", "' | wc -c")
+	want := "echo '" + cursorMarker + "' | wc -c" + cursorNote + "\n\n(The cursor is inside a single-quoted string.)"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
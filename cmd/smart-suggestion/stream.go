@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// streamWriter writes the answer to --output as it streams in, for
+// integrations that show partial text. A file is rewritten with everything
+// received so far; stdout, which cannot be rewritten, gets each new piece.
+type streamWriter struct {
+	path    string
+	partial strings.Builder
+	err     error
+}
+
+// write passes on text, a new piece of the answer. After a failed write the
+// rest of the stream is dropped and finish reports the failure.
+func (w *streamWriter) write(text string) {
+	if w.err != nil {
+		return
+	}
+	w.partial.WriteString(text)
+	if w.path == "-" || w.path == "/dev/stdout" {
+		_, w.err = fmt.Fprint(os.Stdout, text)
+		return
+	}
+	w.err = writeFileAtomic(w.path, []byte(w.partial.String()), 0644)
+}
+
+// finish writes the final answer: on a line of its own after the partial
+// text on stdout, and in place of the partial text in a file.
+func (w *streamWriter) finish(suggestion string) error {
+	if w.err != nil {
+		return fmt.Errorf("failed to stream suggestion: %w", w.err)
+	}
+	if (w.path == "-" || w.path == "/dev/stdout") && w.partial.Len() > 0 {
+		suggestion = "\n" + suggestion
+	}
+	return writeSuggestion(w.path, suggestion)
+}
+
+// fetchStream is fetch for --stream: the command in the response is passed to
+// w as it arrives, before the whole response is returned. Providers that
+// cannot stream answer in one piece.
+func (r *suggestRequest) fetchStream(w *streamWriter) (string, error) {
+	return r.fetchWith(func(ctx context.Context) (string, error) {
+		chunks, err := provider.FetchStream(ctx, r.client, r.userInput, r.systemPrompt, r.history)
+		if err != nil {
+			return "", err
+		}
+		var parser provider.StreamParser
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				return "", chunk.Err
+			}
+			if text := parser.Write(chunk.Text); text != "" {
+				w.write(text)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return parser.Response(), nil
+	})
+}
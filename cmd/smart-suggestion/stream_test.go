@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// streamingProvider streams its chunks in order, ending with err if set.
+type streamingProvider struct {
+	mockProvider
+	chunks []string
+	err    error
+}
+
+func (p *streamingProvider) FetchStream(ctx context.Context, input, systemPrompt string, history []provider.Message) (<-chan provider.StreamChunk, error) {
+	chunks := make(chan provider.StreamChunk, len(p.chunks)+1)
+	for _, text := range p.chunks {
+		chunks <- provider.StreamChunk{Text: text}
+	}
+	if p.err != nil {
+		chunks <- provider.StreamChunk{Err: p.err}
+	}
+	close(chunks)
+	return chunks, nil
+}
+
+func TestMainStream(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+
+	tests := []struct {
+		name     string
+		provider provider.Provider
+		want     string
+	}{
+		{
+			name:     "streaming provider",
+			provider: &streamingProvider{chunks: []string{"<reasoning>Check.</reas", "oning>=git ", "status"}},
+			want:     "=git status\n=git status",
+		},
+		{
+			name:     "non-streaming provider",
+			provider: &mockProvider{response: "<reasoning>List.</reasoning>=ls"},
+			want:     "=ls\n=ls",
+		},
+		{
+			name:     "no suggestion",
+			provider: &streamingProvider{chunks: []string{"<reasoning>Nothing to do.</reasoning>"}},
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+				return tt.provider, nil
+			}
+			exitCode, out := runMain(t, "--provider", "mock", "--input", "git st", "--format", "raw", "--stream")
+			if exitCode != -1 {
+				t.Fatalf("expected a successful exit, got exit code %d", exitCode)
+			}
+			if out != tt.want {
+				t.Errorf("expected output %q, got %q", tt.want, out)
+			}
+		})
+	}
+}
+
+func TestMainStreamError(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return &streamingProvider{chunks: []string{"=git"}, err: errors.New("connection reset")}, nil
+	}
+
+	exitCode, _ := runMain(t, "--provider", "mock", "--input", "git st", "--format", "raw", "--stream")
+	if exitCode != 1 {
+		t.Errorf("expected exit code 1, got %d", exitCode)
+	}
+}
+
+func TestStreamWriterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.txt")
+	w := &streamWriter{path: path}
+
+	for _, step := range []struct{ text, want string }{
+		{text: "=git", want: "=git"},
+		{text: " status", want: "=git status"},
+	} {
+		w.write(step.text)
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != step.want {
+			t.Errorf("after %q: expected %q, got %q", step.text, step.want, got)
+		}
+	}
+
+	if err := w.finish("=git status -s"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "=git status -s" {
+		t.Errorf("expected the final answer alone, got %q", got)
+	}
+}
+
+func TestStreamWriterWriteError(t *testing.T) {
+	w := &streamWriter{path: filepath.Join(t.TempDir(), "missing", "output.txt")}
+	w.write("=ls")
+	if err := w.finish("=ls"); err == nil || !strings.Contains(err.Error(), "failed to stream suggestion") {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/paths"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// cacheTTLEnv sets how long a suggestion is reused for the same request, so
+// asking twice for the same buffer costs one API call. 0 disables the cache.
+const cacheTTLEnv = "SMART_SUGGESTION_CACHE_TTL"
+
+// cachedSuggestion is a provider response kept in the suggestion cache.
+type cachedSuggestion struct {
+	Response string    `json:"response"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// suggestionCacheTTL returns SMART_SUGGESTION_CACHE_TTL, or 0 when unset.
+func suggestionCacheTTL() (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(cacheTTLEnv))
+	if v == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a duration such as 30s, or 0 to disable", cacheTTLEnv, v)
+	}
+	return ttl, nil
+}
+
+func suggestionCacheFile() string {
+	return filepath.Join(paths.GetCacheDir(), "suggestions.cache")
+}
+
+// cacheKey identifies the request by everything that decides its answer.
+func (r *suggestRequest) cacheKey() string {
+	history, _ := json.Marshal(r.history)
+	hash := sha256.New()
+	for _, part := range []string{
+		strings.ToLower(r.providerName),
+		provider.ModelName(r.client),
+		r.systemPrompt,
+		string(history),
+		r.userInput,
+	} {
+		// Length-prefixed, so no two different requests hash the same parts.
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// readSuggestionCache returns the cached responses. A missing or corrupt
+// cache is empty; the next store overwrites it.
+func readSuggestionCache() map[string]cachedSuggestion {
+	cache := make(map[string]cachedSuggestion)
+	data, err := os.ReadFile(suggestionCacheFile())
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		debug.Log("Ignoring invalid suggestion cache", map[string]any{"error": err.Error()})
+		return make(map[string]cachedSuggestion)
+	}
+	return cache
+}
+
+// lookupSuggestion returns the response cached for key within ttl.
+func lookupSuggestion(key string, ttl time.Duration) (string, bool) {
+	if ttl <= 0 {
+		return "", false
+	}
+	entry, ok := readSuggestionCache()[key]
+	if !ok || time.Since(entry.StoredAt) >= ttl {
+		return "", false
+	}
+	return entry.Response, true
+}
+
+// storeSuggestion caches response under key and drops entries older than
+// ttl, so the file only holds suggestions that can still be used.
+func storeSuggestion(key string, response string, ttl time.Duration) error {
+	cache := readSuggestionCache()
+	for k, entry := range cache {
+		if time.Since(entry.StoredAt) >= ttl {
+			delete(cache, k)
+		}
+	}
+	cache[key] = cachedSuggestion{Response: response, StoredAt: time.Now()}
+
+	path := suggestionCacheFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	// The responses are built from the user's context, so keep them private.
+	return writeFileAtomic(path, data, 0600)
+}
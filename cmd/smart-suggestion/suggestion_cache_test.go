@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestSuggestionCacheTTL(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "0", want: 0},
+		{value: " 30s ", want: 30 * time.Second},
+		{value: "-1m", wantErr: true},
+		{value: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv(cacheTTLEnv, tt.value)
+		got, err := suggestionCacheTTL()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error %v", tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestSuggestionCacheLookup(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	if _, ok := lookupSuggestion("key", time.Minute); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	if err := storeSuggestion("key", "=ls", time.Minute); err != nil {
+		t.Fatalf("storeSuggestion failed: %v", err)
+	}
+	if got, ok := lookupSuggestion("key", time.Minute); !ok || got != "=ls" {
+		t.Errorf("expected a hit with =ls, got %q, %v", got, ok)
+	}
+	if _, ok := lookupSuggestion("key", 0); ok {
+		t.Error("expected no hit with the cache disabled")
+	}
+	if _, ok := lookupSuggestion("key", time.Nanosecond); ok {
+		t.Error("expected an expired entry to miss")
+	}
+
+	info, err := os.Stat(suggestionCacheFile())
+	if err != nil {
+		t.Fatalf("failed to stat cache: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected the cache to be private, got %v", perm)
+	}
+}
+
+func TestSuggestionCacheCorruptFile(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	if err := os.WriteFile(suggestionCacheFile(), []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := lookupSuggestion("key", time.Minute); ok {
+		t.Fatal("expected a corrupt cache to miss")
+	}
+	if err := storeSuggestion("key", "=ls", time.Minute); err != nil {
+		t.Fatalf("expected a corrupt cache to be overwritten, got %v", err)
+	}
+	if got, ok := lookupSuggestion("key", time.Minute); !ok || got != "=ls" {
+		t.Errorf("expected a hit after overwriting, got %q, %v", got, ok)
+	}
+}
+
+func TestMainUsesSuggestionCache(t *testing.T) {
+	oldSelect := selectProviderFunc
+	t.Cleanup(func() { selectProviderFunc = oldSelect })
+	mock := &mockProvider{response: "=ls -la"}
+	selectProviderFunc = func(cmd *cobra.Command) (provider.Provider, error) {
+		return mock, nil
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	t.Setenv(cacheTTLEnv, "1m")
+
+	if _, out := runMain(t, "--provider", "mock", "--input", "ls", "--output", "-"); out != "=ls -la" {
+		t.Fatalf("expected =ls -la, got %q", out)
+	}
+
+	// The same request is answered from the cache, without the provider.
+	mock.response = "=pwd"
+	if _, out := runMain(t, "--provider", "mock", "--input", "ls", "--output", "-"); out != "=ls -la" {
+		t.Errorf("expected the cached =ls -la, got %q", out)
+	}
+
+	if _, out := runMain(t, "--provider", "mock", "--input", "cd", "--output", "-"); out != "=pwd" {
+		t.Errorf("expected a new input to reach the provider, got %q", out)
+	}
+
+	t.Setenv(cacheTTLEnv, "0")
+	if _, out := runMain(t, "--provider", "mock", "--input", "ls", "--output", "-"); out != "=pwd" {
+		t.Errorf("expected the provider with the cache disabled, got %q", out)
+	}
+}
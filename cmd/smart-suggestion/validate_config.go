@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+	"github.com/xyenon/smart-suggestion/internal/shellcontext"
+)
+
+// configProblems checks the configuration a suggestion would read, without
+// contacting or even building a provider: it checks that every provider the
+// rules can select has its variables set and valid, and parses the settings
+// that are otherwise only read mid-request. "cmd:" values are not run.
+func configProblems() []string {
+	var problems []string
+	report := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	names := []string{providerName}
+	if providerName == "" {
+		names = []string{defaultProviderName()}
+		rules, err := provider.ParseProviderRules(os.Getenv("SMART_SUGGESTION_PROVIDER_RULES"))
+		report(err)
+		for _, rule := range rules {
+			names = append(names, rule.Provider)
+		}
+	}
+	// Every provider in a fallback chain is checked on its own.
+	var chained []string
+	for _, name := range names {
+		chained = append(chained, providerChain(name)...)
+	}
+	names = chained
+	if len(names) == 0 {
+		report(fmt.Errorf("no provider configured; set SMART_SUGGESTION_AI_PROVIDER (or LLM_PROVIDER) or pass --provider"))
+	}
+
+	// Every provider would fail the same way on a broken providers file.
+	var endpoints map[string]provider.Endpoint
+	if path := providersFilePath(); path != "" {
+		var err error
+		if endpoints, err = provider.LoadEndpoints(path); err != nil {
+			report(err)
+			names = nil
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		if err := checkProviderConfig(name, endpoints); err != nil {
+			report(fmt.Errorf("provider %s: %w", name, err))
+		}
+	}
+
+	for _, err := range provider.ValidateSettings() {
+		report(err)
+	}
+	_, err := minConfidence()
+	report(err)
+	_, err = applyPromptPolicy("")
+	report(err)
+	report(validateEnabled())
+	_, err = safeMode()
+	report(err)
+	_, err = suggestionCacheTTL()
+	report(err)
+	_, err = promptByteLimit()
+	report(err)
+	_, err = shellcontext.ParseContextRules(os.Getenv(shellcontext.ContextRulesEnvVar))
+	report(err)
+	return problems
+}
+
+// checkProviderConfig checks the configuration of the provider called name,
+// from the providers file when it is listed there, as newProvider would read
+// it.
+func checkProviderConfig(name string, endpoints map[string]provider.Endpoint) error {
+	if endpoint, ok := endpoints[strings.ToLower(name)]; ok {
+		_, err := provider.CheckEndpoint(name, endpoint)
+		return err
+	}
+	if _, ok := provider.Describe(name); !ok {
+		return fmt.Errorf("unsupported provider: %s (valid: %s)", name, strings.Join(provider.BuiltinProviders, ", "))
+	}
+	_, err := provider.CheckConfig(name)
+	return err
+}
+
+func runValidateConfig(cmd *cobra.Command, args []string) error {
+	debug.Enable(dbg)
+
+	problems := configProblems()
+	if len(problems) == 0 {
+		fmt.Println("Configuration is valid.")
+		return nil
+	}
+	fmt.Println("Configuration problems:")
+	for _, problem := range problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+	return fmt.Errorf("found %d configuration problem(s)", len(problems))
+}
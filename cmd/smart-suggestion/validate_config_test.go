@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xyenon/smart-suggestion/internal/shellcontext"
+)
+
+// setValidConfig sets up a complete OpenAI configuration in a clean environment.
+func setValidConfig(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"SMART_SUGGESTION_PROVIDER_RULES", "SMART_SUGGESTION_PROVIDERS_FILE", "SMART_SUGGESTION_MAX_TOKENS",
+		"SMART_SUGGESTION_TEMPERATURE", "SMART_SUGGESTION_SYSTEM_ROLE", "SMART_SUGGESTION_PROMPT_PREFIX_FILE", "SMART_SUGGESTION_PROMPT_SUFFIX_FILE",
+		"OPENAI_API_KEYS", "OPENAI_API_KEY_FILE", "OPENAI_BASE_URL", "AZURE_OPENAI_API_KEY_FILE",
+		minConfidenceEnv, enabledEnv, safeModeEnv, cacheTTLEnv, maxPromptBytesEnv, shellcontext.ContextRulesEnvVar,
+	} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("SMART_SUGGESTION_CONFIG", filepath.Join(t.TempDir(), "config.zsh"))
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	t.Setenv("SMART_SUGGESTION_AI_PROVIDER", "openai")
+	t.Setenv("OPENAI_API_KEY", "sk-test-key")
+}
+
+func TestMainValidateConfigValid(t *testing.T) {
+	setValidConfig(t)
+
+	exitCode, out := runMain(t, "validate-config")
+	if exitCode != -1 {
+		t.Errorf("expected a successful exit, got exit code %d", exitCode)
+	}
+	if out != "Configuration is valid.\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestMainValidateConfigInvalid(t *testing.T) {
+	setValidConfig(t)
+	t.Setenv("OPENAI_BASE_URL", "ftp://example.com")
+	t.Setenv("SMART_SUGGESTION_PROVIDER_RULES", "~/work -> azure_openai")
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure-key")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT_NAME", "gpt-4o")
+	t.Setenv("AZURE_OPENAI_RESOURCE_NAME", "")
+	t.Setenv("AZURE_OPENAI_BASE_URL", "")
+	t.Setenv(minConfidenceEnv, "2")
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "many")
+	t.Setenv(maxPromptBytesEnv, "-1")
+	t.Setenv(shellcontext.ContextRulesEnvVar, "terraform -> cloud")
+
+	exitCode, out := runMain(t, "validate-config")
+	if exitCode != 1 {
+		t.Errorf("expected exit code 1, got %d", exitCode)
+	}
+	for _, want := range []string{
+		"provider openai: OPENAI_BASE_URL",
+		"provider azure_openai: AZURE_OPENAI_RESOURCE_NAME",
+		minConfidenceEnv,
+		"SMART_SUGGESTION_MAX_TOKENS",
+		maxPromptBytesEnv,
+		`unknown section "cloud"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected a problem mentioning %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigProblemsFallbackChain(t *testing.T) {
+	setValidConfig(t)
+	t.Setenv("SMART_SUGGESTION_AI_PROVIDER", "openai, gemini")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY_FILE", "")
+
+	problems := configProblems()
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "provider gemini: ") {
+		t.Errorf("expected only the gemini problem, got %v", problems)
+	}
+}
+
+func TestConfigProblemsNoProvider(t *testing.T) {
+	setValidConfig(t)
+	t.Setenv("SMART_SUGGESTION_AI_PROVIDER", "")
+
+	problems := configProblems()
+	if len(problems) != 1 || !strings.Contains(problems[0], "no provider configured") {
+		t.Errorf("expected only the missing provider, got %v", problems)
+	}
+
+	// A broken providers file is reported once, not once per provider.
+	file := filepath.Join(t.TempDir(), "providers.json")
+	if err := os.WriteFile(file, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SMART_SUGGESTION_PROVIDERS_FILE", file)
+	t.Setenv("SMART_SUGGESTION_PROVIDER_RULES", "~/a -> openai; ~/b -> gemini")
+	if problems := configProblems(); len(problems) != 1 {
+		t.Errorf("expected the providers file problem once, got %v", problems)
+	}
+}
+
+func TestConfigProblemsRunsNothing(t *testing.T) {
+	setValidConfig(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	t.Setenv("OPENAI_API_KEYS", "sk-one,sk-two")
+	t.Setenv("OPENAI_BASE_URL", "cmd:touch "+marker+"; echo https://api.example.com")
+
+	if problems := configProblems(); len(problems) != 0 {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("expected no cmd: value to be run")
+	}
+	if entries, _ := os.ReadDir(os.Getenv("SMART_SUGGESTION_CACHE_DIR")); len(entries) != 0 {
+		t.Errorf("expected nothing written to the cache directory, got %v", entries)
+	}
+}
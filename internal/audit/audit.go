@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/jsonl"
+)
+
+// Record is a single audit entry describing why a command was suggested.
+type Record struct {
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command"`
+	Reasoning string    `json:"reasoning"`
+}
+
+// Append writes record as a JSON line to the audit file at path. The file is
+// only ever appended to and is created readable by the owner only.
+func Append(path string, record Record) error {
+	return jsonl.Append(path, record)
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "audit.log")
+
+	first := Record{Timestamp: time.Unix(1700000000, 0).UTC(), Command: "=ls", Reasoning: "list files"}
+	second := Record{Timestamp: time.Unix(1700000060, 0).UTC(), Command: "+la", Reasoning: "complete flags"}
+	if err := Append(path, first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Append(path, second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat audit file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("expected permissions 0600, got %o", perm)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit file: %v", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("failed to parse audit record: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0] != first || records[1] != second {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestAppendError(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := Append(filepath.Join(blocker, "audit.log"), Record{}); err == nil {
+		t.Fatal("expected error when parent is a file")
+	}
+}
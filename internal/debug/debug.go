@@ -6,10 +6,21 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/xyenon/smart-suggestion/internal/paths"
+	"github.com/xyenon/smart-suggestion/pkg"
+)
+
+// Log formats selected with SMART_SUGGESTION_LOG_FORMAT.
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
 )
 
 var (
@@ -17,8 +28,14 @@ var (
 	mu        sync.RWMutex
 	logger    *log.Logger
 	logFile   *os.File
+	logFormat = FormatJSON
 	initOnce  sync.Once
 	initError error
+
+	// maxLogSize is the size past which debug.log is rotated when logging
+	// starts. Rotated backups are gzip-compressed, since users who leave debug
+	// on would otherwise collect megabytes of JSON.
+	maxLogSize int64 = 5 * 1024 * 1024
 )
 
 func Enable(e bool) {
@@ -42,6 +59,9 @@ func initLogger() {
 		initError = fmt.Errorf("failed to create cache directory: %w", err)
 		return
 	}
+	if err := rotateLog(logFilePath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to rotate debug log: %v\n", err)
+	}
 
 	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -51,6 +71,21 @@ func initLogger() {
 
 	logFile = f
 	logger = log.New(f, "", 0)
+	logFormat = FormatJSON
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("SMART_SUGGESTION_LOG_FORMAT")), FormatLogfmt) {
+		logFormat = FormatLogfmt
+	}
+}
+
+// rotateLog rotates the debug log at path once it exceeds maxLogSize,
+// compressing the backup and keeping the three most recent ones for a week.
+func rotateLog(path string) error {
+	config := pkg.DefaultLogRotateConfig()
+	config.MaxSize = maxLogSize
+	config.MaxBackups = 3
+	config.MaxAge = 7
+	config.Compress = true
+	return pkg.NewLogRotator(config).CheckAndRotate(path)
 }
 
 func Log(message string, data map[string]any) {
@@ -68,6 +103,7 @@ func Log(message string, data map[string]any) {
 
 	mu.RLock()
 	l := logger
+	format := logFormat
 	mu.RUnlock()
 
 	if l == nil {
@@ -82,6 +118,11 @@ func Log(message string, data map[string]any) {
 		logEntry[k] = v
 	}
 
+	if format == FormatLogfmt {
+		l.Println(encodeLogfmt(logEntry))
+		return
+	}
+
 	jsonData, err := json.Marshal(logEntry)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to marshal debug log: %v\n", err)
@@ -91,6 +132,49 @@ func Log(message string, data map[string]any) {
 	l.Println(string(jsonData))
 }
 
+// encodeLogfmt renders entry as one logfmt line: date and log first, then the
+// other keys sorted, so lines for the same event always line up.
+func encodeLogfmt(entry map[string]any) string {
+	keys := make([]string, 0, len(entry))
+	for k := range entry {
+		if k != "date" && k != "log" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	keys = append([]string{"date", "log"}, keys...)
+
+	var builder strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(k)
+		builder.WriteByte('=')
+		builder.WriteString(logfmtValue(entry[k]))
+	}
+	return builder.String()
+}
+
+// logfmtValue formats v the way JSON would, except that strings are written
+// bare unless they need quoting.
+func logfmtValue(v any) string {
+	s, ok := v.(string)
+	if !ok {
+		data, err := json.Marshal(v)
+		if err != nil {
+			data = []byte(fmt.Sprint(v))
+		}
+		if err := json.Unmarshal(data, &s); err != nil {
+			s = string(data)
+		}
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\\") || strings.ContainsFunc(s, unicode.IsControl) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
 func Close() {
 	mu.Lock()
 	defer mu.Unlock()
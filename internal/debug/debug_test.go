@@ -1,16 +1,23 @@
 package debug
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestLog(t *testing.T) {
 	// Create a temp dir for cache
 	tempDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", "")
 	t.Setenv("XDG_CACHE_HOME", tempDir)
 
 	// Reset state
@@ -79,6 +86,7 @@ func TestEnableFalse(t *testing.T) {
 
 func TestInitError(t *testing.T) {
 	tempDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", "")
 	t.Setenv("XDG_CACHE_HOME", tempDir)
 
 	// Create a file where the directory should be
@@ -102,3 +110,170 @@ func TestInitError(t *testing.T) {
 		t.Error("expected debug to be disabled after init error")
 	}
 }
+
+// parseLogfmt splits a logfmt line into its keys and values.
+func parseLogfmt(t *testing.T, line string) ([]string, map[string]string) {
+	t.Helper()
+	var keys []string
+	values := make(map[string]string)
+	for line != "" {
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok || key == "" || strings.ContainsAny(key, " \"") {
+			t.Fatalf("malformed logfmt near %q", line)
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				t.Fatalf("malformed quoted value near %q: %v", rest, err)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+			rest = " " + rest
+		}
+		keys = append(keys, key)
+		values[key] = value
+		line = strings.TrimPrefix(rest, " ")
+	}
+	return keys, values
+}
+
+func TestLogFormats(t *testing.T) {
+	data := map[string]any{
+		"provider": "openai",
+		"input":    `echo "hi there"`,
+		"empty":    "",
+		"lines":    3,
+		"ok":       true,
+		"nested":   map[string]any{"a": 1},
+		"error":    "line one\nline two",
+	}
+	want := map[string]string{
+		"log":      "Fetched suggestion",
+		"provider": "openai",
+		"input":    `echo "hi there"`,
+		"empty":    "",
+		"lines":    "3",
+		"ok":       "true",
+		"nested":   `{"a":1}`,
+		"error":    "line one\nline two",
+	}
+
+	read := func(format string) string {
+		tempDir := t.TempDir()
+		t.Setenv("SMART_SUGGESTION_CACHE_DIR", tempDir)
+		t.Setenv("SMART_SUGGESTION_LOG_FORMAT", format)
+		mu.Lock()
+		enabled = false
+		logFile = nil
+		logger = nil
+		initOnce = *new(sync.Once)
+		initError = nil
+		mu.Unlock()
+
+		Enable(true)
+		Log("Fetched suggestion", data)
+		Close()
+		content, err := os.ReadFile(filepath.Join(tempDir, "debug.log"))
+		if err != nil {
+			t.Fatalf("failed to read log file: %v", err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("expected one line, got %q", content)
+		}
+		return lines[0]
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(read("")), &entry); err != nil {
+		t.Fatalf("expected JSON by default: %v", err)
+	}
+	if entry["provider"] != "openai" || entry["lines"] != float64(3) {
+		t.Errorf("unexpected JSON entry %v", entry)
+	}
+
+	keys, values := parseLogfmt(t, read("logfmt"))
+	wantKeys := []string{"date", "log", "empty", "error", "input", "lines", "nested", "ok", "provider"}
+	if !slices.Equal(keys, wantKeys) {
+		t.Errorf("expected keys %v, got %v", wantKeys, keys)
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, values[key])
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, values["date"]); err != nil {
+		t.Errorf("expected an RFC 3339 date, got %q", values["date"])
+	}
+
+	// Both formats carry the same fields.
+	for key := range entry {
+		if _, ok := values[key]; !ok {
+			t.Errorf("logfmt is missing %s", key)
+		}
+	}
+}
+
+func TestEnableRotatesLargeLogCompressed(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", tempDir)
+	oldMax := maxLogSize
+	t.Cleanup(func() {
+		maxLogSize = oldMax
+		Close()
+	})
+	maxLogSize = 16
+
+	logPath := filepath.Join(tempDir, "debug.log")
+	old := strings.Repeat(`{"log":"old entry"}`+"\n", 4)
+	if err := os.WriteFile(logPath, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	enabled = false
+	logFile = nil
+	logger = nil
+	initOnce = *new(sync.Once)
+	initError = nil
+	mu.Unlock()
+
+	Enable(true)
+	Log("new entry", nil)
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if strings.Contains(string(content), "old entry") || !strings.Contains(string(content), "new entry") {
+		t.Fatalf("expected a fresh log after rotation, got %q", content)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(tempDir, "debug-*.log*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz") {
+		t.Fatalf("expected one gzip backup, got %v", backups)
+	}
+
+	f, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("expected a gzip-compressed backup: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to decompress backup: %v", err)
+	}
+	if string(data) != old {
+		t.Errorf("expected the backup to hold the old log, got %q", data)
+	}
+}
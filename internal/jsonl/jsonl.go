@@ -0,0 +1,36 @@
+// Package jsonl appends records to JSON lines files, such as the reasoning
+// audit, the session metrics and the suggestion recordings.
+package jsonl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Append writes v as a JSON line to the file at path, creating it and its
+// directory when missing. The file is only ever appended to and is created
+// readable by the owner only, as it may hold the user's commands and context.
+func Append(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record for %s: %w", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// One write per record, so concurrent appenders never interleave lines.
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package jsonl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "records.jsonl")
+
+	type record struct {
+		Command string `json:"command"`
+	}
+	for _, command := range []string{"=ls", "+la"} {
+		if err := Append(path, record{Command: command}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if want := "{\"command\":\"=ls\"}\n{\"command\":\"+la\"}\n"; string(data) != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected permissions 0600, got %o", perm)
+	}
+
+	if err := Append(path, func() {}); err == nil || !strings.Contains(err.Error(), "marshal") {
+		t.Errorf("expected a marshal error, got %v", err)
+	}
+}
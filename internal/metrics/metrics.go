@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/jsonl"
+)
+
+// FileEnv names the metrics file that suggestions are recorded in. The proxy
+// sets it for the shell it wraps when --session-summary is given.
+const FileEnv = "SMART_SUGGESTION_METRICS_FILE"
+
+// Record describes a single suggestion request.
+type Record struct {
+	Timestamp time.Time `json:"timestamp"`
+	LatencyMS int64     `json:"latency_ms"`
+	Error     bool      `json:"error,omitempty"`
+}
+
+// Summary totals the records of a session.
+type Summary struct {
+	Suggestions int
+	Errors      int
+	// TotalLatency adds up the latency of every suggestion, failed ones
+	// included, since the user waited for those too.
+	TotalLatency time.Duration
+}
+
+// Append writes record as a JSON line to the metrics file at path.
+func Append(path string, record Record) error {
+	return jsonl.Append(path, record)
+}
+
+// Summarize totals the records in r. Lines that are not records, such as one
+// cut short by a suggestion still being written, are skipped.
+func Summarize(r io.Reader) (Summary, error) {
+	var summary Summary
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		summary.Suggestions++
+		summary.TotalLatency += time.Duration(record.LatencyMS) * time.Millisecond
+		if record.Error {
+			summary.Errors++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Summary{}, fmt.Errorf("failed to read metrics: %w", err)
+	}
+	return summary, nil
+}
+
+// SummarizeFile totals the records in the metrics file at path. A missing
+// file is an empty session.
+func SummarizeFile(path string) (Summary, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Summary{}, nil
+	}
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to open metrics file: %w", err)
+	}
+	defer f.Close()
+	return Summarize(f)
+}
+
+// AverageLatency returns the mean latency, or 0 for an empty session.
+func (s Summary) AverageLatency() time.Duration {
+	if s.Suggestions == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Suggestions)
+}
+
+// String formats the summary as a single line, such as
+// "this session: 14 suggestions, avg 420ms, 2 errors".
+func (s Summary) String() string {
+	return fmt.Sprintf("this session: %s, avg %s, %s",
+		plural(s.Suggestions, "suggestion"),
+		s.AverageLatency().Round(time.Millisecond),
+		plural(s.Errors, "error"))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	input := strings.Join([]string{
+		`{"timestamp":"2025-01-01T00:00:00Z","latency_ms":300}`,
+		`{"timestamp":"2025-01-01T00:00:05Z","latency_ms":500,"error":true}`,
+		`not a record`,
+		`{"timestamp":"2025-01-01T00:00:09Z","latency_ms":460}`,
+		`{"timestamp":"2025-01-01T00:00:12Z","lat`,
+	}, "\n")
+
+	summary, err := Summarize(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary.Suggestions != 3 || summary.Errors != 1 {
+		t.Errorf("expected 3 suggestions and 1 error, got %+v", summary)
+	}
+	if got, want := summary.String(), "this session: 3 suggestions, avg 420ms, 1 error"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	tests := []struct {
+		summary Summary
+		want    string
+	}{
+		{summary: Summary{}, want: "this session: 0 suggestions, avg 0s, 0 errors"},
+		{summary: Summary{Suggestions: 1, TotalLatency: 1500 * time.Millisecond}, want: "this session: 1 suggestion, avg 1.5s, 0 errors"},
+		{summary: Summary{Suggestions: 14, Errors: 2, TotalLatency: 5880 * time.Millisecond}, want: "this session: 14 suggestions, avg 420ms, 2 errors"},
+	}
+	for _, tt := range tests {
+		if got := tt.summary.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestAppendAndSummarizeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics", "metrics.pts_1.jsonl")
+
+	summary, err := SummarizeFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error for a missing file: %v", err)
+	}
+	if summary.Suggestions != 0 {
+		t.Errorf("expected an empty summary, got %+v", summary)
+	}
+
+	now := time.Now()
+	for _, record := range []Record{
+		{Timestamp: now, LatencyMS: 200},
+		{Timestamp: now, LatencyMS: 400, Error: true},
+	} {
+		if err := Append(path, record); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	summary, err = SummarizeFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := summary.String(), "this session: 2 suggestions, avg 300ms, 1 error"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
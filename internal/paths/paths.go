@@ -7,7 +7,17 @@ import (
 
 const ProxyLogFilename = "proxy.log"
 
+// MetricsFilename is the base name of the per-session metrics files that
+// proxy --session-summary reads.
+const MetricsFilename = "metrics.jsonl"
+
+// GetCacheDir returns the directory for smart-suggestion's state. SMART_SUGGESTION_CACHE_DIR
+// is used verbatim when set, so state can be isolated without changing XDG_CACHE_HOME.
 func GetCacheDir() string {
+	if dir := os.Getenv("SMART_SUGGESTION_CACHE_DIR"); dir != "" {
+		return dir
+	}
+
 	cacheDir := os.Getenv("XDG_CACHE_HOME")
 	if cacheDir == "" {
 		homeDir, err := os.UserHomeDir()
@@ -22,3 +32,25 @@ func GetCacheDir() string {
 func GetDefaultProxyLogFile() string {
 	return filepath.Join(GetCacheDir(), ProxyLogFilename)
 }
+
+func GetDefaultMetricsFile() string {
+	return filepath.Join(GetCacheDir(), MetricsFilename)
+}
+
+// GetConfigFile returns the plugin's config file, matching the plugin's default
+// of $XDG_CONFIG_HOME/smart-suggestion/config.zsh unless SMART_SUGGESTION_CONFIG is set.
+func GetConfigFile() string {
+	if file := os.Getenv("SMART_SUGGESTION_CONFIG"); file != "" {
+		return file
+	}
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configDir, "smart-suggestion", "config.zsh")
+}
@@ -6,8 +6,23 @@ import (
 )
 
 func TestGetCacheDir(t *testing.T) {
+	t.Run("SMART_SUGGESTION_CACHE_DIR set", func(t *testing.T) {
+		overrideDir := filepath.Join(t.TempDir(), "isolated")
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+		t.Setenv("SMART_SUGGESTION_CACHE_DIR", overrideDir)
+
+		if got := GetCacheDir(); got != overrideDir {
+			t.Errorf("expected %q, got %q", overrideDir, got)
+		}
+		expectedLog := filepath.Join(overrideDir, ProxyLogFilename)
+		if got := GetDefaultProxyLogFile(); got != expectedLog {
+			t.Errorf("expected %q, got %q", expectedLog, got)
+		}
+	})
+
 	t.Run("XDG_CACHE_HOME set", func(t *testing.T) {
 		tempDir := t.TempDir()
+		t.Setenv("SMART_SUGGESTION_CACHE_DIR", "")
 		t.Setenv("XDG_CACHE_HOME", tempDir)
 
 		expected := filepath.Join(tempDir, "smart-suggestion")
@@ -17,6 +32,7 @@ func TestGetCacheDir(t *testing.T) {
 	})
 
 	t.Run("XDG_CACHE_HOME unset", func(t *testing.T) {
+		t.Setenv("SMART_SUGGESTION_CACHE_DIR", "")
 		t.Setenv("XDG_CACHE_HOME", "")
 		// We can't easily mock UserHomeDir without refactoring, so we'll check if it ends with .cache/smart-suggestion
 		// or if it falls back to TempDir
@@ -30,6 +46,7 @@ func TestGetCacheDir(t *testing.T) {
 
 func TestGetDefaultProxyLogFile(t *testing.T) {
 	tempDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", "")
 	t.Setenv("XDG_CACHE_HOME", tempDir)
 
 	expected := filepath.Join(tempDir, "smart-suggestion", ProxyLogFilename)
@@ -37,3 +54,35 @@ func TestGetDefaultProxyLogFile(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, got)
 	}
 }
+
+func TestGetConfigFile(t *testing.T) {
+	t.Run("SMART_SUGGESTION_CONFIG set", func(t *testing.T) {
+		t.Setenv("SMART_SUGGESTION_CONFIG", "/etc/smart-suggestion.zsh")
+		if got := GetConfigFile(); got != "/etc/smart-suggestion.zsh" {
+			t.Errorf("expected explicit config file, got %q", got)
+		}
+	})
+
+	t.Run("XDG_CONFIG_HOME set", func(t *testing.T) {
+		configHome := t.TempDir()
+		t.Setenv("SMART_SUGGESTION_CONFIG", "")
+		t.Setenv("XDG_CONFIG_HOME", configHome)
+
+		expected := filepath.Join(configHome, "smart-suggestion", "config.zsh")
+		if got := GetConfigFile(); got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("XDG_CONFIG_HOME unset", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("SMART_SUGGESTION_CONFIG", "")
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("HOME", home)
+
+		expected := filepath.Join(home, ".config", "smart-suggestion", "config.zsh")
+		if got := GetConfigFile(); got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package picker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrCancelled is returned when the user aborts the selection.
+var ErrCancelled = errors.New("selection cancelled")
+
+const (
+	keyCtrlC = 0x03
+	keyEsc   = 0x1b
+)
+
+// Pick draws items on out and lets the user move the highlight with the arrow
+// keys (or j/k) read from in. Enter selects the highlighted item and returns its
+// index; q or Ctrl-C returns ErrCancelled. The terminal backing in is expected
+// to be in raw mode already.
+func Pick(in io.Reader, out io.Writer, items []string) (int, error) {
+	if len(items) == 0 {
+		return -1, errors.New("no items to pick from")
+	}
+
+	reader := bufio.NewReader(in)
+	selected := 0
+	render(out, items, selected, false)
+
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return -1, ErrCancelled
+			}
+			return -1, fmt.Errorf("failed to read key: %w", err)
+		}
+
+		switch b {
+		case '\r', '\n':
+			return selected, nil
+		case 'q', keyCtrlC:
+			return -1, ErrCancelled
+		case 'k':
+			selected = moveUp(selected)
+		case 'j':
+			selected = moveDown(selected, len(items))
+		case keyEsc:
+			seq, err := readEscapeSequence(reader)
+			if err != nil {
+				return -1, ErrCancelled
+			}
+			switch seq {
+			case 'A':
+				selected = moveUp(selected)
+			case 'B':
+				selected = moveDown(selected, len(items))
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+		render(out, items, selected, true)
+	}
+}
+
+// readEscapeSequence reads the rest of a CSI sequence such as "[A" and returns
+// its final byte.
+func readEscapeSequence(reader *bufio.Reader) (byte, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != '[' && b != 'O' {
+		return 0, nil
+	}
+	return reader.ReadByte()
+}
+
+func moveUp(selected int) int {
+	if selected > 0 {
+		return selected - 1
+	}
+	return selected
+}
+
+func moveDown(selected, count int) int {
+	if selected < count-1 {
+		return selected + 1
+	}
+	return selected
+}
+
+// render writes one line per item, marking the selected one. When redraw is
+// set, the cursor first moves back up over the previously drawn list.
+func render(out io.Writer, items []string, selected int, redraw bool) {
+	if redraw {
+		fmt.Fprintf(out, "\x1b[%dA", len(items))
+	}
+	for i, item := range items {
+		marker := "  "
+		if i == selected {
+			marker = "> "
+		}
+		fmt.Fprintf(out, "\r\x1b[2K%s%s\r\n", marker, item)
+	}
+}
@@ -0,0 +1,70 @@
+package picker
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPick(t *testing.T) {
+	items := []string{"ls -la", "ls -lh", "ls -1"}
+
+	cases := []struct {
+		name     string
+		keys     string
+		expected int
+	}{
+		{name: "enter selects first", keys: "\r", expected: 0},
+		{name: "arrow down", keys: "\x1b[B\r", expected: 1},
+		{name: "arrow down then up", keys: "\x1b[B\x1b[B\x1b[A\r", expected: 1},
+		{name: "stops at last", keys: "jjjjj\n", expected: 2},
+		{name: "stops at first", keys: "kk\r", expected: 0},
+		{name: "application cursor keys", keys: "\x1bOB\r", expected: 1},
+		{name: "ignores other keys", keys: "xj\x1b[C\r", expected: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := Pick(strings.NewReader(tc.keys), &out, items)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Fatalf("expected %d, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPickCancel(t *testing.T) {
+	items := []string{"ls"}
+
+	for _, keys := range []string{"q", "\x03", ""} {
+		if _, err := Pick(strings.NewReader(keys), &bytes.Buffer{}, items); !errors.Is(err, ErrCancelled) {
+			t.Fatalf("keys %q: expected ErrCancelled, got %v", keys, err)
+		}
+	}
+}
+
+func TestPickRender(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := Pick(strings.NewReader("j\r"), &out, []string{"one", "two"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rendered := out.String()
+	if !strings.Contains(rendered, "> one") || !strings.Contains(rendered, "> two") {
+		t.Fatalf("expected both selection states to be drawn, got %q", rendered)
+	}
+	if !strings.Contains(rendered, "\x1b[2A") {
+		t.Fatalf("expected redraw to move the cursor up, got %q", rendered)
+	}
+}
+
+func TestPickNoItems(t *testing.T) {
+	if _, err := Pick(strings.NewReader("\r"), &bytes.Buffer{}, nil); err == nil {
+		t.Fatal("expected error for empty item list")
+	}
+}
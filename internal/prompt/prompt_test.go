@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// The assembled prompts must stay byte-identical to the prompts that were
+// sent before they were split into sections.
+func TestPromptsMatchGolden(t *testing.T) {
+	cases := map[string]Prompt{
+		"default.txt": Default(),
+		"fast.txt":    Fast(),
+		"terse.txt":   Terse(),
+		"review.txt":  Review(),
+	}
+	for file, p := range cases {
+		want, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("failed to read golden file: %v", err)
+		}
+		if got := p.String(); got != string(want) {
+			t.Errorf("%s: assembled prompt differs from golden file:\n%s", file, got)
+		}
+	}
+}
+
+func TestPromptString(t *testing.T) {
+	p := Prompt{Role: "role", Rules: "rules"}
+	if got := p.String(); got != "role\n\nrules" {
+		t.Errorf("expected empty sections to be skipped, got %q", got)
+	}
+
+	p = Default()
+	p.Examples = ""
+	if got := p.String(); !strings.HasSuffix(got, Default().Rules) {
+		t.Errorf("expected the rules to end the prompt without examples, got %q", got)
+	}
+}
+
+func TestRulesHeading(t *testing.T) {
+	for _, p := range []Prompt{Default(), Fast(), Terse(), Review()} {
+		if !strings.HasPrefix(p.Rules, RulesHeading) {
+			t.Errorf("expected rules to start with %q, got %q", RulesHeading, p.Rules)
+		}
+	}
+}
+
+func TestExampleHistory(t *testing.T) {
+	history := ExampleHistory()
+	if len(history) == 0 || len(history)%2 != 0 {
+		t.Fatalf("expected user/assistant pairs, got %d messages", len(history))
+	}
+	for i, msg := range history {
+		want := "user"
+		if i%2 == 1 {
+			want = "assistant"
+		}
+		if msg.Role != want {
+			t.Errorf("message %d: expected role %q, got %q", i, want, msg.Role)
+		}
+	}
+}
+
+func TestTerseExampleHistory(t *testing.T) {
+	full := ExampleHistory()
+	terse := TerseExampleHistory()
+	if len(terse) != len(full) {
+		t.Fatalf("expected %d messages, got %d", len(full), len(terse))
+	}
+	for i, msg := range terse {
+		if msg.Role == "user" {
+			if msg != full[i] {
+				t.Errorf("message %d: expected user input to be unchanged", i)
+			}
+			continue
+		}
+		reasoning := provider.ExtractReasoning(msg.Content)
+		if reasoning == "" || strings.Contains(reasoning, "\n") {
+			t.Errorf("message %d: expected a single reasoning sentence, got %q", i, reasoning)
+		}
+		// The command still parses exactly as before.
+		if got, want := provider.ParseAndExtractCommand(msg.Content), provider.ParseAndExtractCommand(full[i].Content); got != want {
+			t.Errorf("message %d: expected command %q, got %q", i, want, got)
+		}
+	}
+}
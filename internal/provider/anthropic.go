@@ -3,40 +3,61 @@ package provider
 import (
 	"context"
 	"fmt"
-	"os"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/xyenon/smart-suggestion/internal/debug"
 )
 
+// anthropicDefaultMaxTokens is the completion-token limit sent when none is
+// configured; the Messages API requires one.
+const anthropicDefaultMaxTokens = 1000
+
 type AnthropicProvider struct {
 	Model  string
 	Client *anthropic.Client
+
+	timeout time.Duration
 }
 
 func NewAnthropicProvider() (*AnthropicProvider, error) {
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable is not set")
+	cfg, err := loadProviderConfig("anthropic", true)
+	if err != nil {
+		return nil, err
 	}
 
+	timeout := requestTimeout()
+	client := newAnthropicClient("anthropic", cfg["ANTHROPIC_API_KEY"], cfg["ANTHROPIC_BASE_URL"], nil, timeout)
+
+	return &AnthropicProvider{
+		Model:   cfg["ANTHROPIC_MODEL"],
+		Client:  &client,
+		timeout: timeout,
+	}, nil
+}
+
+// newAnthropicClient returns a client for the Anthropic API. name keys the
+// rate limit cooldown, headers are sent with every request and each request
+// gives up after timeout, unless it is 0.
+func newAnthropicClient(name, apiKey, baseURL string, headers map[string]string, timeout time.Duration) anthropic.Client {
 	options := []option.RequestOption{
 		option.WithAPIKey(apiKey),
+		option.WithHTTPClient(newProviderHTTPClient(name)),
+		option.WithHeader("User-Agent", UserAgent()),
+		option.WithRequestTimeout(timeout),
+		// sendWithRetries retries instead, the same way for every provider.
+		option.WithMaxRetries(0),
 	}
 
-	if baseURL := normalizeBaseURL(os.Getenv("ANTHROPIC_BASE_URL")); baseURL != "" {
+	if baseURL := normalizeBaseURL(baseURL); baseURL != "" {
 		options = append(options, option.WithBaseURL(baseURL))
 	}
+	for key, value := range headers {
+		options = append(options, option.WithHeader(key, value))
+	}
 
-	model := envOrDefault(os.Getenv("ANTHROPIC_MODEL"), "claude-3-5-sonnet-20241022")
-
-	client := anthropic.NewClient(options...)
-
-	return &AnthropicProvider{
-		Model:  model,
-		Client: &client,
-	}, nil
+	return anthropic.NewClient(options...)
 }
 
 func (p *AnthropicProvider) Fetch(ctx context.Context, input string, systemPrompt string) (string, error) {
@@ -46,6 +67,30 @@ func (p *AnthropicProvider) Fetch(ctx context.Context, input string, systemPromp
 func (p *AnthropicProvider) FetchWithHistory(ctx context.Context, input string, systemPrompt string, history []Message) (string, error) {
 	logProviderRequest("anthropic", p.Model, systemPrompt, history, input)
 
+	return retryTruncated(ctx, "anthropic", p.Model, anthropicDefaultMaxTokens, func(ctx context.Context) (string, bool, error) {
+		params := p.messageParams(ctx, input, systemPrompt, history)
+		resp, err := p.Client.Messages.New(ctx, params)
+		debug.Log("Received Anthropic response", map[string]any{
+			"response": resp,
+		})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to create message: %w", timeoutError(ctx, p.timeout, err))
+		}
+		if err := anthropicRefusal(resp); err != nil {
+			return "", false, err
+		}
+
+		truncated := resp.StopReason == anthropic.StopReasonMaxTokens
+		if len(resp.Content) == 0 {
+			return "", truncated, fmt.Errorf("no content returned from Anthropic API")
+		}
+
+		return resp.Content[0].Text, truncated, nil
+	})
+}
+
+// messageParams builds the request for input after history.
+func (p *AnthropicProvider) messageParams(ctx context.Context, input string, systemPrompt string, history []Message) anthropic.MessageNewParams {
 	messages := []anthropic.MessageParam{}
 	for _, msg := range history {
 		switch msg.Role {
@@ -58,25 +103,69 @@ func (p *AnthropicProvider) FetchWithHistory(ctx context.Context, input string,
 
 	messages = append(messages, anthropic.NewUserMessage(anthropic.NewTextBlock(input)))
 
-	resp, err := p.Client.Messages.New(
-		ctx,
-		anthropic.MessageNewParams{
-			Model:     anthropic.Model(p.Model),
-			MaxTokens: 1000,
-			System:    []anthropic.TextBlockParam{{Text: systemPrompt}},
-			Messages:  messages,
-		},
-	)
-	debug.Log("Received Anthropic response", map[string]any{
-		"response": resp,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to create message: %w", err)
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.Model(p.Model),
+		MaxTokens: anthropicDefaultMaxTokens,
+		System:    []anthropic.TextBlockParam{{Text: systemPrompt}},
+		Messages:  messages,
+	}
+	if maxTokens := maxTokensFor(ctx, p.Model); maxTokens > 0 {
+		params.MaxTokens = maxTokens
 	}
+	if temperature := temperatureFor(ctx); temperature != nil {
+		// Anthropic accepts temperatures up to 1 only.
+		params.Temperature = anthropic.Float(min(*temperature, 1))
+	}
+	return params
+}
+
+// FetchStream is FetchWithHistory for a streamed response.
+func (p *AnthropicProvider) FetchStream(ctx context.Context, input string, systemPrompt string, history []Message) (<-chan StreamChunk, error) {
+	logProviderRequest("anthropic", p.Model, systemPrompt, history, input)
 
-	if len(resp.Content) == 0 {
-		return "", fmt.Errorf("no content returned from Anthropic API")
+	stream := p.Client.Messages.NewStreaming(ctx, p.messageParams(ctx, input, systemPrompt, history))
+	ok := stream.Next()
+	if !ok && stream.Err() != nil {
+		err := stream.Err()
+		stream.Close()
+		return nil, fmt.Errorf("failed to create message: %w", timeoutError(ctx, p.timeout, err))
 	}
 
-	return resp.Content[0].Text, nil
+	chunks := make(chan StreamChunk)
+	go func() {
+		defer close(chunks)
+		defer stream.Close()
+
+		var message anthropic.Message
+		for ; ok; ok = stream.Next() {
+			event := stream.Current()
+			if err := message.Accumulate(event); err != nil {
+				sendChunk(ctx, chunks, StreamChunk{Err: fmt.Errorf("failed to read message stream: %w", err)})
+				return
+			}
+			if event.Type != "content_block_delta" || event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				continue
+			}
+			if !sendChunk(ctx, chunks, StreamChunk{Text: event.Delta.Text}) {
+				return
+			}
+		}
+		debug.Log("Received Anthropic response", map[string]any{
+			"response": message,
+		})
+
+		var err error
+		switch {
+		case stream.Err() != nil:
+			err = fmt.Errorf("failed to stream message: %w", timeoutError(ctx, p.timeout, stream.Err()))
+		case anthropicRefusal(&message) != nil:
+			err = anthropicRefusal(&message)
+		case len(message.Content) == 0:
+			err = fmt.Errorf("no content returned from Anthropic API")
+		}
+		if err != nil {
+			sendChunk(ctx, chunks, StreamChunk{Err: err})
+		}
+	}()
+	return chunks, nil
 }
@@ -78,6 +78,21 @@ func TestAnthropicProvider_Fetch(t *testing.T) {
 			}`,
 			ExpectedError: "no content returned from Anthropic API",
 		},
+		{
+			Name:         "refusal",
+			Input:        "kill -9 everything",
+			SystemPrompt: "test",
+			MockStatus:   http.StatusOK,
+			MockResponse: `{
+				"id": "msg_789",
+				"type": "message",
+				"role": "assistant",
+				"model": "claude-3-5-sonnet-20241022",
+				"content": [],
+				"stop_reason": "refusal"
+			}`,
+			ExpectedError: "Anthropic declined to answer under its content policy (refusal)",
+		},
 	}
 
 	for _, tc := range cases {
@@ -121,3 +136,19 @@ func TestAnthropicProvider_Fetch(t *testing.T) {
 		})
 	}
 }
+
+func TestAnthropicProvider_TemperatureCapped(t *testing.T) {
+	p := &AnthropicProvider{Model: "claude-3-5-sonnet-20241022"}
+
+	t.Setenv("SMART_SUGGESTION_TEMPERATURE", "1.5")
+	params := p.messageParams(t.Context(), "ls", "prompt", nil)
+	if got := params.Temperature.Value; got != 1 {
+		t.Errorf("expected temperature capped at 1, got %v", got)
+	}
+
+	t.Setenv("SMART_SUGGESTION_TEMPERATURE", "0.2")
+	params = p.messageParams(t.Context(), "ls", "prompt", nil)
+	if got := params.Temperature.Value; got != 0.2 {
+		t.Errorf("expected temperature 0.2, got %v", got)
+	}
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/openai/openai-go"
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/paths"
+)
+
+// openAIAPIKeys returns the keys listed in OPENAI_API_KEYS (comma-separated),
+// or the single key from OPENAI_API_KEY / OPENAI_API_KEY_FILE, as
+// loadProviderConfig has checked them.
+func openAIAPIKeys(cfg providerConfig) []string {
+	if list := cfg["OPENAI_API_KEYS"]; list != "" {
+		keys, _ := parseKeyList("OPENAI_API_KEYS", list)
+		return keys
+	}
+	return []string{cfg["OPENAI_API_KEY"]}
+}
+
+func keyRotationFile() string {
+	return filepath.Join(paths.GetCacheDir(), "keyrotation.json")
+}
+
+func readKeyRotation() map[string]int {
+	rotation := make(map[string]int)
+	data, err := os.ReadFile(keyRotationFile())
+	if err != nil {
+		return rotation
+	}
+	if err := json.Unmarshal(data, &rotation); err != nil {
+		debug.Log("Ignoring invalid key rotation file", map[string]any{"error": err.Error()})
+		return make(map[string]int)
+	}
+	return rotation
+}
+
+// keySent records that a request for providerName went out with the key at
+// index of n, so that the next invocation starts with the key after it. Only
+// sending advances the rotation; building a provider does not.
+func keySent(providerName string, index, n int) {
+	if n > 1 {
+		storeNextKey(providerName, (index+1)%n)
+	}
+}
+
+// storeNextKey records that the next invocation for providerName should start
+// with the key at index next.
+func storeNextKey(providerName string, next int) {
+	rotation := readKeyRotation()
+	rotation[providerName] = next
+	path := keyRotationFile()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(rotation); err == nil {
+			err = os.WriteFile(path, data, 0644)
+		}
+	}
+	if err != nil {
+		debug.Log("Failed to update key rotation file", map[string]any{"error": err.Error()})
+	}
+}
+
+// keyOrder returns the indexes of n keys in the order this invocation tries
+// them: starting after the key used last time, with keys still cooling down
+// after a rate limit moved to the end.
+func keyOrder(providerName string, n int) []int {
+	start := readKeyRotation()[providerName] % n
+	if start < 0 {
+		start = 0
+	}
+	var ready, cooling []int
+	for i := range n {
+		index := (start + i) % n
+		if CheckCooldown(keyProviderName(providerName, index, n)) != nil {
+			cooling = append(cooling, index)
+		} else {
+			ready = append(ready, index)
+		}
+	}
+	return append(ready, cooling...)
+}
+
+// keyProviderName names the rate limit cooldown of one key, so that a key
+// reaching its quota does not block the others.
+func keyProviderName(providerName string, index, n int) string {
+	if n == 1 {
+		return providerName
+	}
+	return fmt.Sprintf("%s#%d", providerName, index+1)
+}
+
+// isKeyError reports whether err means the key itself was rejected or ran out
+// of quota, so that another key may still succeed.
+func isKeyError(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
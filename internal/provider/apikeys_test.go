@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keyServer answers chat completions with the key that was used, rejecting
+// the keys listed in rejected with a 401.
+func keyServer(t *testing.T, rejected ...string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var used []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		used = append(used, key)
+		w.Header().Set("Content-Type", "application/json")
+		for _, k := range rejected {
+			if k == key {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error":{"message":"invalid key","type":"invalid_request_error"}}`)
+				return
+			}
+		}
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"=%s"}}]}`, key)
+	}))
+	t.Cleanup(server.Close)
+	return server, &used
+}
+
+func TestOpenAIAPIKeys(t *testing.T) {
+	openAIAPIKeys := func() ([]string, error) {
+		cfg, err := loadProviderConfig("openai", true)
+		if err != nil {
+			return nil, err
+		}
+		return openAIAPIKeys(cfg), nil
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-single")
+	t.Setenv("OPENAI_API_KEYS", "")
+	keys, err := openAIAPIKeys()
+	if err != nil || len(keys) != 1 || keys[0] != "sk-single" {
+		t.Fatalf("expected the single key, got %v, %v", keys, err)
+	}
+
+	t.Setenv("OPENAI_API_KEYS", " sk-a, sk-b,,sk-c ")
+	keys, err = openAIAPIKeys()
+	if err != nil || strings.Join(keys, ",") != "sk-a,sk-b,sk-c" {
+		t.Fatalf("expected the listed keys, got %v, %v", keys, err)
+	}
+
+	t.Setenv("OPENAI_API_KEYS", ", ,")
+	if _, err := openAIAPIKeys(); err == nil {
+		t.Error("expected an error for a list without keys")
+	}
+
+	t.Setenv("OPENAI_API_KEYS", `sk-a,"sk-b"`)
+	if _, err := openAIAPIKeys(); err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEYS") {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+func TestOpenAIProviderKeyRotation(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	server, used := keyServer(t)
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("OPENAI_API_KEYS", "sk-a,sk-b,sk-c")
+
+	for range 4 {
+		p, err := NewOpenAIProvider()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := p.Fetch(t.Context(), "list", "system"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := strings.Join(*used, ","); got != "sk-a,sk-b,sk-c,sk-a" {
+		t.Errorf("expected keys in turn, got %s", got)
+	}
+}
+
+func TestNewOpenAIProviderDoesNotAdvanceRotation(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	server, used := keyServer(t)
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("OPENAI_API_KEYS", "sk-a,sk-b,sk-c")
+
+	// Providers built without sending anything, as validate-config does,
+	// leave the rotation where it was.
+	for range 2 {
+		if _, err := NewOpenAIProvider(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	p, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.Fetch(t.Context(), "list", "system"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(*used, ","); got != "sk-a" {
+		t.Errorf("expected the first key, got %s", got)
+	}
+}
+
+func TestOpenAIEmbedderKeyRotation(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	var used []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		used = append(used, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"object":"embedding","index":0,"embedding":[1]}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("OPENAI_API_KEYS", "sk-a,sk-b")
+
+	for range 3 {
+		e, err := NewOpenAIEmbedder()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := e.Embed(t.Context(), []string{"git status"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := strings.Join(used, ","); got != "sk-a,sk-b,sk-a" {
+		t.Errorf("expected keys in turn, got %s", got)
+	}
+}
+
+func TestOpenAIProviderKeyErrorAdvances(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	server, used := keyServer(t, "sk-a")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("OPENAI_API_KEYS", "sk-a,sk-b,sk-c")
+
+	p, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := p.Fetch(t.Context(), "list", "system")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "=sk-b" {
+		t.Errorf("expected the second key to answer, got %q", got)
+	}
+
+	// The next invocation continues after the key that worked.
+	p, err = NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.Fetch(t.Context(), "list", "system"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(*used, ","); got != "sk-a,sk-b,sk-c" {
+		t.Errorf("unexpected key order %s", got)
+	}
+}
+
+func TestOpenAIProviderAllKeysRejected(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	server, used := keyServer(t, "sk-a", "sk-b")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("OPENAI_API_KEYS", "sk-a,sk-b")
+
+	p, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.Fetch(t.Context(), "list", "system"); err == nil {
+		t.Fatal("expected an error when every key is rejected")
+	}
+	if len(*used) != 2 {
+		t.Errorf("expected each key to be tried once, got %v", *used)
+	}
+}
+
+func TestKeyOrderSkipsCoolingKeys(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	if err := RecordCooldown(keyProviderName("openai", 0, 3), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RecordCooldown failed: %v", err)
+	}
+	if got := fmt.Sprint(keyOrder("openai", 3)); got != "[1 2 0]" {
+		t.Errorf("expected the cooling key last, got %s", got)
+	}
+	if got := fmt.Sprint(keyOrder("openai", 1)); got != "[0]" {
+		t.Errorf("expected a single key to be used regardless, got %s", got)
+	}
+}
@@ -3,56 +3,92 @@ package provider
 import (
 	"context"
 	"fmt"
-	"os"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/azure"
+	"github.com/openai/openai-go/option"
 	"github.com/xyenon/smart-suggestion/internal/debug"
 )
 
 type AzureOpenAIProvider struct {
 	DeploymentName string
 	Client         *openai.Client
+
+	timeout time.Duration
 }
 
 func NewAzureOpenAIProvider() (*AzureOpenAIProvider, error) {
-	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("AZURE_OPENAI_API_KEY environment variable is not set")
-	}
-
-	deploymentName := os.Getenv("AZURE_OPENAI_DEPLOYMENT_NAME")
-	if deploymentName == "" {
-		return nil, fmt.Errorf("AZURE_OPENAI_DEPLOYMENT_NAME environment variable is not set")
+	cfg, err := loadProviderConfig("azure_openai", true)
+	if err != nil {
+		return nil, err
 	}
-
-	baseURL := os.Getenv("AZURE_OPENAI_BASE_URL")
-	resourceName := os.Getenv("AZURE_OPENAI_RESOURCE_NAME")
-
-	if baseURL == "" && resourceName == "" {
-		return nil, fmt.Errorf("AZURE_OPENAI_RESOURCE_NAME environment variable is not set")
+	deploymentName := cfg["AZURE_OPENAI_DEPLOYMENT_NAME"]
+	apiVersion := cfg["AZURE_OPENAI_API_VERSION"]
+
+	endpoint, verbatim := azureEndpoint(cfg["AZURE_OPENAI_BASE_URL"], cfg["AZURE_OPENAI_RESOURCE_NAME"])
+	timeout := requestTimeout()
+
+	options := []option.RequestOption{
+		azure.WithAPIKey(cfg["AZURE_OPENAI_API_KEY"]),
+		option.WithHTTPClient(newProviderHTTPClient("azure_openai")),
+		option.WithHeader("User-Agent", UserAgent()),
+		option.WithRequestTimeout(timeout),
+		option.WithMaxRetries(0),
 	}
-
-	apiVersion := envOrDefault(os.Getenv("AZURE_OPENAI_API_VERSION"), "2024-10-21")
-
-	var endpoint string
-	if baseURL != "" {
-		endpoint = normalizeBaseURL(baseURL)
+	if verbatim {
+		fullURL, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AZURE_OPENAI_BASE_URL: %w", err)
+		}
+		options = append(options, withFullEndpoint(fullURL)...)
 	} else {
-		endpoint = fmt.Sprintf("https://%s.openai.azure.com", resourceName)
+		options = append(options, azure.WithEndpoint(endpoint, apiVersion))
 	}
 
-	client := openai.NewClient(
-		azure.WithEndpoint(endpoint, apiVersion),
-		azure.WithAPIKey(apiKey),
-	)
+	client := openai.NewClient(options...)
 
 	return &AzureOpenAIProvider{
 		DeploymentName: deploymentName,
 		Client:         &client,
+		timeout:        timeout,
 	}, nil
 }
 
+// azureEndpoint returns the endpoint to send requests to. When baseURL already
+// points at a chat completions path, as some gateways expose it, it is returned
+// with verbatim set and must be used as is. Otherwise the endpoint is the
+// resource root, which the Azure client extends with the deployment path and
+// api-version.
+func azureEndpoint(baseURL string, resourceName string) (string, bool) {
+	if baseURL == "" {
+		return fmt.Sprintf("https://%s.openai.azure.com", resourceName), false
+	}
+
+	endpoint := normalizeBaseURL(baseURL)
+	if u, err := url.Parse(endpoint); err == nil && strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), "/chat/completions") {
+		return endpoint, true
+	}
+	return endpoint, false
+}
+
+// withFullEndpoint sends every request to endpoint, including its path and
+// query, instead of the URL derived from the base URL and deployment.
+func withFullEndpoint(endpoint *url.URL) []option.RequestOption {
+	return []option.RequestOption{
+		option.WithBaseURL(endpoint.Scheme + "://" + endpoint.Host + "/"),
+		option.WithMiddleware(func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			target := *endpoint
+			r.URL = &target
+			r.Host = target.Host
+			return next(r)
+		}),
+	}
+}
+
 func (p *AzureOpenAIProvider) Fetch(ctx context.Context, input string, systemPrompt string) (string, error) {
 	return p.FetchWithHistory(ctx, input, systemPrompt, nil)
 }
@@ -60,25 +96,25 @@ func (p *AzureOpenAIProvider) Fetch(ctx context.Context, input string, systemPro
 func (p *AzureOpenAIProvider) FetchWithHistory(ctx context.Context, input string, systemPrompt string, history []Message) (string, error) {
 	logProviderRequest("azure_openai", p.DeploymentName, systemPrompt, history, input)
 
-	messages := buildOpenAIChatMessages(systemPrompt, input, history)
-
-	resp, err := p.Client.Chat.Completions.New(
-		ctx,
-		openai.ChatCompletionNewParams{
-			Model:    openai.ChatModel(p.DeploymentName),
-			Messages: messages,
-		},
-	)
-	debug.Log("Received Azure OpenAI response", map[string]any{
-		"response": resp,
+	messages := buildOpenAIChatMessages(p.DeploymentName, systemPrompt, input, history)
+
+	return retryTruncated(ctx, "azure_openai", p.DeploymentName, 0, func(ctx context.Context) (string, bool, error) {
+		resp, err := p.Client.Chat.Completions.New(ctx, newOpenAIChatParams(ctx, p.DeploymentName, messages))
+		debug.Log("Received Azure OpenAI response", map[string]any{
+			"response": resp,
+		})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to create chat completion: %w", timeoutError(ctx, p.timeout, openAIFilterError("Azure OpenAI", err)))
+		}
+
+		if len(resp.Choices) == 0 {
+			return "", false, fmt.Errorf("no choices returned from Azure OpenAI API")
+		}
+		if err := openAIRefusal("Azure OpenAI", resp.Choices[0]); err != nil {
+			return "", false, err
+		}
+
+		recordOpenAIConfidence(ctx, resp.Choices[0])
+		return resp.Choices[0].Message.Content, resp.Choices[0].FinishReason == "length", nil
 	})
-	if err != nil {
-		return "", fmt.Errorf("failed to create chat completion: %w", err)
-	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned from Azure OpenAI API")
-	}
-
-	return resp.Choices[0].Message.Content, nil
 }
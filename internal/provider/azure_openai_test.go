@@ -98,6 +98,22 @@ func TestAzureOpenAIProvider_Fetch(t *testing.T) {
 			MockResponse:  `{"choices": []}`,
 			ExpectedError: "no choices returned from Azure OpenAI API",
 		},
+		{
+			Name:          "prompt filtered",
+			Input:         "kill -9 everything",
+			SystemPrompt:  "test",
+			MockStatus:    http.StatusBadRequest,
+			MockResponse:  `{"error": {"code": "content_filter", "message": "The response was filtered due to the prompt triggering Azure OpenAI's content management policy."}}`,
+			ExpectedError: "Azure OpenAI declined to answer under its content policy",
+		},
+		{
+			Name:          "completion filtered",
+			Input:         "kill -9 everything",
+			SystemPrompt:  "test",
+			MockStatus:    http.StatusOK,
+			MockResponse:  `{"choices": [{"message": {"role": "assistant", "content": ""}, "finish_reason": "content_filter"}]}`,
+			ExpectedError: "Azure OpenAI declined to answer under its content policy (content_filter)",
+		},
 	}
 
 	for _, tc := range cases {
@@ -141,3 +157,93 @@ func TestAzureOpenAIProvider_Fetch(t *testing.T) {
 		})
 	}
 }
+
+func TestAzureEndpoint(t *testing.T) {
+	cases := []struct {
+		name         string
+		baseURL      string
+		resourceName string
+		expected     string
+		verbatim     bool
+	}{
+		{name: "resource name", resourceName: "awesome-corp", expected: "https://awesome-corp.openai.azure.com"},
+		{name: "base url", baseURL: "https://gateway.example.com/", expected: "https://gateway.example.com"},
+		{name: "base url with prefix", baseURL: "https://gateway.example.com/azure", expected: "https://gateway.example.com/azure"},
+		{
+			name:     "full url",
+			baseURL:  "https://gateway.example.com/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21",
+			expected: "https://gateway.example.com/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21",
+			verbatim: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint, verbatim := azureEndpoint(tc.baseURL, tc.resourceName)
+			if endpoint != tc.expected || verbatim != tc.verbatim {
+				t.Fatalf("expected (%q, %v), got (%q, %v)", tc.expected, tc.verbatim, endpoint, verbatim)
+			}
+		})
+	}
+}
+
+func TestNewAzureOpenAIProvider_Endpoints(t *testing.T) {
+	const response = `{"choices": [{"message": {"role": "assistant", "content": "=ls"}}]}`
+
+	cases := []struct {
+		name          string
+		path          string
+		expectedPath  string
+		expectedQuery string
+	}{
+		{
+			name:          "base url",
+			path:          "",
+			expectedPath:  "/openai/deployments/test-deployment/chat/completions",
+			expectedQuery: "api-version=2024-10-21",
+		},
+		{
+			name:          "full url",
+			path:          "/gateway/deployments/gpt-4o/chat/completions?api-version=2025-01-01-preview",
+			expectedPath:  "/gateway/deployments/gpt-4o/chat/completions",
+			expectedQuery: "api-version=2025-01-01-preview",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotPath, gotQuery, gotKey string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotQuery = r.URL.RawQuery
+				gotKey = r.Header.Get("Api-Key")
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, response)
+			}))
+			defer server.Close()
+
+			t.Setenv("AZURE_OPENAI_API_KEY", "test-key")
+			t.Setenv("AZURE_OPENAI_DEPLOYMENT_NAME", "test-deployment")
+			t.Setenv("AZURE_OPENAI_RESOURCE_NAME", "")
+			t.Setenv("AZURE_OPENAI_API_VERSION", "")
+			t.Setenv("AZURE_OPENAI_BASE_URL", server.URL+tc.path)
+
+			p, err := NewAzureOpenAIProvider()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := p.Fetch(t.Context(), "ls", "prompt"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotPath != tc.expectedPath {
+				t.Fatalf("expected path %q, got %q", tc.expectedPath, gotPath)
+			}
+			if gotQuery != tc.expectedQuery {
+				t.Fatalf("expected query %q, got %q", tc.expectedQuery, gotQuery)
+			}
+			if gotKey != "test-key" {
+				t.Fatalf("expected api key header, got %q", gotKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// CandidateProvider is implemented by providers that can return several
+// responses to one request, so n candidates cost a single round trip.
+type CandidateProvider interface {
+	Provider
+	// FetchCandidates sends the request once and returns up to n responses.
+	FetchCandidates(ctx context.Context, input string, systemPrompt string, history []Message, n int) ([]string, error)
+}
+
+// FetchCandidates returns n responses from p: from one request when p is a
+// CandidateProvider, otherwise from n requests in turn. Failed requests are
+// skipped; it only fails when none succeeds.
+func FetchCandidates(ctx context.Context, p Provider, input string, systemPrompt string, history []Message, n int) ([]string, error) {
+	if multi, ok := p.(CandidateProvider); ok && n > 1 {
+		return multi.FetchCandidates(ctx, input, systemPrompt, history, n)
+	}
+
+	var responses []string
+	var lastErr error
+	for range n {
+		response, err := p.FetchWithHistory(ctx, input, systemPrompt, history)
+		if err != nil {
+			debug.Log("Candidate request failed", map[string]any{"error": err.Error()})
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		responses = append(responses, response)
+	}
+	if len(responses) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return responses, nil
+}
+
+// FetchCandidates asks for n choices in one chat completion. Refused choices
+// are left out.
+func (p *OpenAIProvider) FetchCandidates(ctx context.Context, input string, systemPrompt string, history []Message, n int) ([]string, error) {
+	logProviderRequest("openai", p.Model, systemPrompt, history, input)
+
+	params := newOpenAIChatParams(ctx, p.Model, buildOpenAIChatMessages(p.Model, systemPrompt, input, history))
+	params.N = openai.Int(int64(n))
+	resp, err := p.createChatCompletion(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices returned from OpenAI API")
+	}
+
+	var responses []string
+	var refusal error
+	for _, choice := range resp.Choices {
+		if err := openAIRefusal("OpenAI", choice); err != nil {
+			refusal = err
+			continue
+		}
+		responses = append(responses, choice.Message.Content)
+	}
+	if len(responses) == 0 {
+		return nil, refusal
+	}
+	return responses, nil
+}
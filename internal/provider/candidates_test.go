@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestFetchCandidates_SequentialRequests(t *testing.T) {
+	p := &countingProvider{staticProvider: staticProvider{response: "=ls"}}
+
+	got, err := FetchCandidates(t.Context(), p, "list", "prompt", nil, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, ",") != "=ls,=ls,=ls" {
+		t.Errorf("expected three responses, got %q", got)
+	}
+	if p.calls != 3 {
+		t.Errorf("expected 3 requests, got %d", p.calls)
+	}
+
+	failing := &countingProvider{staticProvider: staticProvider{err: errors.New("boom")}}
+	if _, err := FetchCandidates(t.Context(), failing, "list", "prompt", nil, 2); err == nil {
+		t.Error("expected an error when every request fails")
+	}
+}
+
+func TestOpenAIProvider_FetchCandidates(t *testing.T) {
+	requests := 0
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [
+			{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "=ls -la"}},
+			{"index": 1, "finish_reason": "content_filter", "message": {"role": "assistant", "content": ""}},
+			{"index": 2, "finish_reason": "stop", "message": {"role": "assistant", "content": "+lh"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(server.URL),
+	)
+	p := &OpenAIProvider{Model: "gpt-4o-mini", Client: &client}
+
+	got, err := FetchCandidates(t.Context(), p, "ls -", "prompt", nil, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, ",") != "=ls -la,+lh" {
+		t.Errorf("expected the unfiltered choices, got %q", got)
+	}
+	if requests != 1 {
+		t.Errorf("expected a single request, got %d", requests)
+	}
+	if body["n"] != float64(3) {
+		t.Errorf("expected n=3, got %v", body["n"])
+	}
+}
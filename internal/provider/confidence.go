@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// Confidence receives how sure the model was of the command it suggested, as
+// the geometric mean of its token probabilities between 0 and 1. Known stays
+// false for providers that do not expose log probabilities.
+type Confidence struct {
+	Value float64
+	Known bool
+}
+
+type confidenceKey struct{}
+
+// WithConfidence returns a copy of ctx asking providers to request log
+// probabilities and record the resulting confidence in c.
+func WithConfidence(ctx context.Context, c *Confidence) context.Context {
+	return context.WithValue(ctx, confidenceKey{}, c)
+}
+
+// ConfidenceFromContext returns the recorder attached to ctx, if any.
+func ConfidenceFromContext(ctx context.Context) *Confidence {
+	c, _ := ctx.Value(confidenceKey{}).(*Confidence)
+	return c
+}
+
+// recordOpenAIConfidence stores the confidence of choice in the recorder on
+// ctx, if there is one and the response carries log probabilities.
+func recordOpenAIConfidence(ctx context.Context, choice openai.ChatCompletionChoice) {
+	c := ConfidenceFromContext(ctx)
+	if c == nil {
+		return
+	}
+	if value, ok := commandConfidence(choice.Logprobs.Content); ok {
+		*c = Confidence{Value: value, Known: true}
+	}
+}
+
+// commandConfidence averages the log probabilities of the tokens after the
+// last </reasoning>, so a long and hesitant explanation does not drag down
+// the confidence of a command the model was sure about.
+func commandConfidence(tokens []openai.ChatCompletionTokenLogprob) (float64, bool) {
+	var text strings.Builder
+	for _, token := range tokens {
+		text.WriteString(token.Token)
+	}
+	start := 0
+	if i := strings.LastIndex(text.String(), "</reasoning>"); i >= 0 {
+		start = i + len("</reasoning>")
+	}
+
+	var sum float64
+	var count, offset int
+	for _, token := range tokens {
+		offset += len(token.Token)
+		if offset <= start || strings.TrimSpace(token.Token) == "" {
+			continue
+		}
+		sum += token.Logprob
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return math.Exp(sum / float64(count)), true
+}
@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func logprobTokens(pairs ...any) []openai.ChatCompletionTokenLogprob {
+	var tokens []openai.ChatCompletionTokenLogprob
+	for i := 0; i < len(pairs); i += 2 {
+		tokens = append(tokens, openai.ChatCompletionTokenLogprob{
+			Token:   pairs[i].(string),
+			Logprob: pairs[i+1].(float64),
+		})
+	}
+	return tokens
+}
+
+func TestCommandConfidence(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []openai.ChatCompletionTokenLogprob
+		want   float64
+		known  bool
+	}{
+		{name: "no tokens"},
+		{name: "command only", tokens: logprobTokens("=", 0.0, "ls", math.Log(0.25)), want: 0.5, known: true},
+		{
+			name:   "reasoning ignored",
+			tokens: logprobTokens("<reasoning>", -5.0, "unsure", -9.0, "</reasoning>", -1.0, "\n", -3.0, "=ls", 0.0),
+			want:   1,
+			known:  true,
+		},
+		{name: "only reasoning", tokens: logprobTokens("<reasoning>", -1.0, "</reasoning>", -1.0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, known := commandConfidence(tt.tokens)
+			if known != tt.known {
+				t.Fatalf("expected known=%v, got %v", tt.known, known)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("expected confidence %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenAIProvider_Confidence(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	var requested bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Logprobs bool `json:"logprobs"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		requested = body.Logprobs
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"=ls"},"logprobs":{"content":[{"token":"=","logprob":0},{"token":"ls","logprob":%v}]}}]}`, math.Log(0.04))
+	}))
+	defer server.Close()
+
+	t.Setenv("OPENAI_API_KEY", "sk-test-key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	p, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.Fetch(t.Context(), "list", "system"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requested {
+		t.Error("expected no logprobs to be requested without a recorder")
+	}
+
+	var c Confidence
+	if _, err := p.Fetch(WithConfidence(t.Context(), &c), "list", "system"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !requested {
+		t.Error("expected logprobs to be requested")
+	}
+	if !c.Known || math.Abs(c.Value-0.2) > 1e-9 {
+		t.Errorf("expected confidence 0.2, got %+v", c)
+	}
+}
+
+func TestOpenAIChatParamsLogprobs(t *testing.T) {
+	var c Confidence
+	ctx := WithConfidence(t.Context(), &c)
+	for model, want := range map[string]bool{"gpt-4o-mini": true, "o4-mini": false, "gpt-5-mini": false} {
+		params := newOpenAIChatParams(ctx, model, nil)
+		if got := params.Logprobs.Valid(); got != want {
+			t.Errorf("%s: expected logprobs requested %v, got %v", model, want, got)
+		}
+	}
+}
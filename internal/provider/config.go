@@ -1,11 +1,154 @@
 package provider
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/xyenon/smart-suggestion/internal/debug"
 )
 
+// Version is the build version reported to providers in the User-Agent header.
+// The binary sets it from its own version at startup.
+var Version = "dev"
+
+// UserAgent returns the User-Agent sent with every provider request:
+// SMART_SUGGESTION_USER_AGENT when set, smart-suggestion/<version> otherwise.
+func UserAgent() string {
+	if ua := strings.TrimSpace(os.Getenv("SMART_SUGGESTION_USER_AGENT")); ua != "" {
+		return ua
+	}
+	return "smart-suggestion/" + strings.TrimPrefix(Version, "v")
+}
+
+// ProxyURLEnv names a proxy for provider requests that takes precedence over
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+const ProxyURLEnv = "SMART_SUGGESTION_PROXY_URL"
+
+// parseProxyURL parses a SMART_SUGGESTION_PROXY_URL value.
+func parseProxyURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%q is not a valid URL; expected something like http://proxy.example.com:8080", value)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return u, nil
+	}
+	return nil, fmt.Errorf("%q must use http, https or socks5, not %s", value, u.Scheme)
+}
+
+// newProviderTransport returns the transport every provider sends requests
+// through. It goes through SMART_SUGGESTION_PROXY_URL when set and otherwise
+// honors HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+func newProviderTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxy, ok := envSetting(ProxyURLEnv, parseProxyURL); ok {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport
+}
+
+// RetriesEnv sets how often a provider request is sent again after a
+// transient error: a 408, 429, 500, 502, 503 or 504 response.
+const RetriesEnv = "SMART_SUGGESTION_RETRIES"
+
+const defaultRetries = 2
+
+// maxRetryDelay is the longest wait before a retry. A provider that asks for
+// a longer one gets no retry: nobody waits that long for a suggestion, and
+// the rate limit cooldown takes over.
+const maxRetryDelay = 10 * time.Second
+
+// retryBaseDelay is the wait before the first retry when the provider does
+// not say how long to wait. It doubles with every further retry.
+var retryBaseDelay = 500 * time.Millisecond
+
+// parseRetries parses a SMART_SUGGESTION_RETRIES value.
+func parseRetries(value string) (int, error) {
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 || retries > 10 {
+		return 0, fmt.Errorf("%q is not a whole number between 0 and 10", value)
+	}
+	return retries, nil
+}
+
+// retries returns SMART_SUGGESTION_RETRIES, or defaultRetries when it is
+// unset or invalid.
+func retries() int {
+	if retries, ok := envSetting(RetriesEnv, parseRetries); ok {
+		return retries
+	}
+	return defaultRetries
+}
+
+// retryableStatus reports whether a response with status code is worth
+// sending the request again for.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// sendWithRetries sends req with send and, while the response has a
+// retryable status, sends it again up to retries() times. Each retry waits
+// for as long as Retry-After asks, or with exponential backoff when it is
+// absent. The SDKs' own retries are turned off, so this is the only place
+// requests are retried, for every provider alike.
+func sendWithRetries(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	limit := retries()
+	for attempt := 0; ; attempt++ {
+		resp, err := send(req)
+		if err != nil || attempt >= limit || !retryableStatus(resp.StatusCode) {
+			return resp, err
+		}
+
+		wait, ok := retryAfter(resp.Header, time.Now())
+		if !ok {
+			wait = retryBaseDelay << attempt
+		}
+		if wait > maxRetryDelay {
+			return resp, nil
+		}
+		next := req.Clone(req.Context())
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			if next.Body, err = req.GetBody(); err != nil {
+				return resp, nil
+			}
+		}
+
+		debug.Log("Retrying provider request", map[string]any{
+			"status":  resp.StatusCode,
+			"attempt": attempt + 1,
+			"wait":    wait.String(),
+		})
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		timer := time.NewTimer(max(wait, 0))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		req = next
+	}
+}
+
 func envOrDefault(value string, fallback string) string {
 	if value == "" {
 		return fallback
@@ -13,6 +156,121 @@ func envOrDefault(value string, fallback string) string {
 	return value
 }
 
+// envSetting parses the environment variable name with parse. It reports false
+// when the variable is unset or invalid, so the caller keeps its default; an
+// invalid value is only logged.
+func envSetting[T any](name string, parse func(string) (T, error)) (T, bool) {
+	var zero T
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return zero, false
+	}
+	parsed, err := parse(value)
+	if err != nil {
+		debug.Log("Ignoring invalid "+name, map[string]any{
+			"value": value,
+			"error": err.Error(),
+		})
+		return zero, false
+	}
+	return parsed, true
+}
+
+// parseMaxTokens parses a SMART_SUGGESTION_MAX_TOKENS value.
+func parseMaxTokens(value string) (int64, error) {
+	tokens, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || tokens <= 0 {
+		return 0, fmt.Errorf("%q is not a positive integer", value)
+	}
+	return tokens, nil
+}
+
+// parseTemperature parses a SMART_SUGGESTION_TEMPERATURE value. The range is
+// the widest any provider accepts.
+func parseTemperature(value string) (float64, error) {
+	temperature, err := strconv.ParseFloat(value, 64)
+	if err != nil || temperature < 0 || temperature > 2 {
+		return 0, fmt.Errorf("%q is not a number between 0 and 2", value)
+	}
+	return temperature, nil
+}
+
+// temperatureFor resolves the sampling temperature for a request. Per-request
+// options win, then SMART_SUGGESTION_TEMPERATURE. It returns nil when the
+// provider default should be kept.
+func temperatureFor(ctx context.Context) *float64 {
+	if opts := GenerationOptionsFromContext(ctx); opts.Temperature != nil {
+		return opts.Temperature
+	}
+	if temperature, ok := envSetting("SMART_SUGGESTION_TEMPERATURE", parseTemperature); ok {
+		return &temperature
+	}
+	return nil
+}
+
+// secretNotSetError is returned by resolveSecret when neither the variable
+// nor its _FILE counterpart is set.
+type secretNotSetError struct {
+	name string
+}
+
+func (e *secretNotSetError) Error() string {
+	return fmt.Sprintf("neither %s nor %s_FILE is set", e.name, e.name)
+}
+
+// resolveSecret returns the secret, such as an API key, that the environment
+// variable name holds, run first when it is a "cmd:" value. Only when name is
+// empty is name+"_FILE" read instead, and the trimmed first line of the file
+// it names is used (as mounted Docker or Kubernetes secrets are). It fails
+// when neither is set.
+func resolveSecret(name string) (string, error) {
+	secret, err := envOrFile(name, true)
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", &secretNotSetError{name: name}
+	}
+	return secret, nil
+}
+
+// EnvOrFile returns the value of the environment variable name the way
+// resolveSecret does, except that all of the file's trimmed content is used,
+// and "" is returned when neither name nor name+"_FILE" is set.
+func EnvOrFile(name string) (string, error) {
+	return envOrFile(name, false)
+}
+
+// envOrFile returns the value of name, or when that is empty the content of
+// the file name+"_FILE" names: its trimmed first line if firstLine is set, or
+// all of it. The variable wins when both are set. This reverses the first
+// *_API_KEY_FILE support, where the file took precedence, so the prompt
+// policy files could share the helper; a stale inline key set next to a
+// mounted one is now used instead of the file and has to be unset.
+func envOrFile(name string, firstLine bool) (string, error) {
+	value, err := envValue(name)
+	if err != nil || value != "" {
+		return value, err
+	}
+
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	if !firstLine {
+		return strings.TrimSpace(string(data)), nil
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	if value = strings.TrimSpace(line); value == "" {
+		return "", fmt.Errorf("%s_FILE %s has nothing on its first line", name, path)
+	}
+	return value, nil
+}
+
 func normalizeBaseURL(baseURL string) string {
 	if baseURL == "" {
 		return ""
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// ContentFilteredError is returned when a provider declines to answer under its
+// content policy. Unlike network or auth errors, retrying the same request does
+// not help, so its message tells the user what to change instead.
+type ContentFilteredError struct {
+	Provider string
+	Reason   string
+}
+
+func (e *ContentFilteredError) Error() string {
+	reason := ""
+	if e.Reason != "" {
+		reason = " (" + e.Reason + ")"
+	}
+	return fmt.Sprintf("%s declined to answer under its content policy%s. Try rephrasing the input, or send less context (fewer scrollback lines, or none)", e.Provider, reason)
+}
+
+// geminiFilterReasons are the finish reasons Gemini stops a response with when
+// it is blocked by a safety or policy filter.
+var geminiFilterReasons = map[genai.FinishReason]bool{
+	genai.FinishReasonSafety:            true,
+	genai.FinishReasonBlocklist:         true,
+	genai.FinishReasonProhibitedContent: true,
+	genai.FinishReasonSPII:              true,
+}
+
+// openAIRefusal returns a *ContentFilteredError when choice was cut off by the
+// content filter or is a refusal, and nil otherwise.
+func openAIRefusal(providerName string, choice openai.ChatCompletionChoice) error {
+	if choice.FinishReason == "content_filter" {
+		return &ContentFilteredError{Provider: providerName, Reason: "content_filter"}
+	}
+	if choice.Message.Refusal != "" {
+		return &ContentFilteredError{Provider: providerName, Reason: choice.Message.Refusal}
+	}
+	return nil
+}
+
+// openAIFilterError turns the error Azure OpenAI answers a filtered prompt with
+// into a *ContentFilteredError and returns other errors unchanged.
+func openAIFilterError(providerName string, err error) error {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.Code == "content_filter" {
+		return &ContentFilteredError{Provider: providerName, Reason: "content_filter"}
+	}
+	return err
+}
+
+// anthropicRefusal returns a *ContentFilteredError when Claude stopped because
+// it refused the request, and nil otherwise.
+func anthropicRefusal(resp *anthropic.Message) error {
+	if resp.StopReason == anthropic.StopReasonRefusal {
+		return &ContentFilteredError{Provider: "Anthropic", Reason: string(resp.StopReason)}
+	}
+	return nil
+}
+
+// geminiRefusal returns a *ContentFilteredError when Gemini blocked the prompt
+// or the response, and nil otherwise.
+func geminiRefusal(resp *genai.GenerateContentResponse) error {
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return &ContentFilteredError{Provider: "Gemini", Reason: string(resp.PromptFeedback.BlockReason)}
+	}
+	if len(resp.Candidates) > 0 && geminiFilterReasons[resp.Candidates[0].FinishReason] {
+		return &ContentFilteredError{Provider: "Gemini", Reason: string(resp.Candidates[0].FinishReason)}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestContentFilteredError(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &ContentFilteredError{Provider: "Gemini", Reason: "SAFETY"})
+
+	var filtered *ContentFilteredError
+	if !errors.As(err, &filtered) {
+		t.Fatal("expected the refusal to be recognizable through wrapping")
+	}
+	msg := filtered.Error()
+	if !strings.HasPrefix(msg, "Gemini declined to answer under its content policy (SAFETY).") || !strings.Contains(msg, "rephrasing") {
+		t.Errorf("expected a message suggesting a rephrase, got %q", msg)
+	}
+	if msg := (&ContentFilteredError{Provider: "OpenAI"}).Error(); strings.Contains(msg, "()") {
+		t.Errorf("expected no empty reason, got %q", msg)
+	}
+}
+
+func TestOpenAIFilterErrorKeepsOtherErrors(t *testing.T) {
+	other := errors.New("connection refused")
+	if err := openAIFilterError("OpenAI", other); err != other {
+		t.Errorf("expected other errors unchanged, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// contractCase is a raw model response and the exact line the binary hands
+// the plugin for it. See PROTOCOL.md for the contract these files pin down.
+type contractCase struct {
+	model    string
+	response string
+	output   string
+}
+
+// parseContractCase reads a case file: "#" comment lines, a "model:" line,
+// then the raw response and the expected output, each introduced by a
+// "-- response --" or "-- output --" line. The newline ending each section
+// belongs to the file, not to the section.
+func parseContractCase(data string) (contractCase, bool) {
+	header, rest, ok := strings.Cut(data, "-- response --\n")
+	if !ok {
+		return contractCase{}, false
+	}
+	response, output, ok := strings.Cut(rest, "\n-- output --\n")
+	if !ok {
+		return contractCase{}, false
+	}
+
+	var c contractCase
+	for line := range strings.Lines(header) {
+		if model, found := strings.CutPrefix(strings.TrimSpace(line), "model:"); found {
+			c.model = strings.TrimSpace(model)
+		}
+	}
+	c.response = response
+	c.output = strings.TrimSuffix(output, "\n")
+	return c, true
+}
+
+// parseResponse is what the binary does with a response before it reaches
+// the plugin, independently of the input: extract the answer, then fix the
+// model's known quirks.
+func parseResponse(model, response string) string {
+	command, err := ExtractCommand(response)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return TransformCommand(model, command)
+}
+
+func TestResponseContract(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "contract", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no contract cases found")
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".txt"), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read contract case: %v", err)
+			}
+			c, ok := parseContractCase(string(data))
+			if !ok {
+				t.Fatal("malformed contract case: expected -- response -- and -- output -- sections")
+			}
+			if c.model == "" {
+				t.Fatal("malformed contract case: missing model: line")
+			}
+			if got := parseResponse(c.model, c.response); got != c.output {
+				t.Errorf("response %q parsed to %q, want %q", c.response, got, c.output)
+			}
+		})
+	}
+}
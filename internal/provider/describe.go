@@ -0,0 +1,327 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BuiltinProviders lists the providers that need no providers file.
+var BuiltinProviders = []string{"openai", "openai_compatible", "azure_openai", "anthropic", "gemini"}
+
+// varKind says how the value of a providerVar is read and checked.
+type varKind int
+
+const (
+	// varSetting is a plain setting, used as it is.
+	varSetting varKind = iota
+	// varSecret is a key: it can be a "cmd:" value or be read from the file
+	// <name>_FILE names, and is checked with validateAPIKey.
+	varSecret
+	// varKeyList is a comma-separated list of keys.
+	varKeyList
+	// varURL is a base URL: it can be a "cmd:" value and is checked with
+	// validateBaseURL.
+	varURL
+)
+
+// providerVar is an environment variable a built-in provider reads.
+type providerVar struct {
+	name string
+	kind varKind
+	// required variables must be set, unless one of their alternatives is,
+	// which is then used in their place.
+	required     bool
+	alternatives []string
+	// hint is added to the error for a required variable that is not set.
+	hint string
+	// fallback is used when the variable is not set.
+	fallback string
+	// model marks the variable naming the model.
+	model bool
+}
+
+// providerVars lists the environment variables of each built-in provider.
+// The constructors read their configuration through loadProviderConfig, so
+// this is all they read, and what Describe and CheckConfig report on.
+var providerVars = map[string][]providerVar{
+	"openai": {
+		{name: "OPENAI_API_KEY", kind: varSecret, required: true, alternatives: []string{"OPENAI_API_KEYS"}},
+		{name: "OPENAI_API_KEYS", kind: varKeyList},
+		{name: "OPENAI_MODEL", fallback: "gpt-4o-mini", model: true},
+		{name: "OPENAI_BASE_URL", kind: varURL},
+		{name: "OPENAI_ORG_ID"},
+		{name: "OPENAI_PROJECT_ID"},
+		{name: "OPENAI_EMBEDDING_MODEL", fallback: "text-embedding-3-small"},
+	},
+	"openai_compatible": {
+		{name: "OPENAI_COMPATIBLE_API_KEY", kind: varSecret, required: true, hint: "use any placeholder for a server without keys"},
+		{name: "OPENAI_COMPATIBLE_BASE_URL", kind: varURL, required: true},
+		{name: "OPENAI_COMPATIBLE_MODEL", required: true, model: true},
+	},
+	"azure_openai": {
+		{name: "AZURE_OPENAI_API_KEY", kind: varSecret, required: true},
+		{name: "AZURE_OPENAI_DEPLOYMENT_NAME", required: true, model: true},
+		{name: "AZURE_OPENAI_RESOURCE_NAME", required: true, alternatives: []string{"AZURE_OPENAI_BASE_URL"}},
+		{name: "AZURE_OPENAI_BASE_URL", kind: varURL},
+		{name: "AZURE_OPENAI_API_VERSION", fallback: "2024-10-21"},
+	},
+	"anthropic": {
+		{name: "ANTHROPIC_API_KEY", kind: varSecret, required: true},
+		{name: "ANTHROPIC_MODEL", fallback: "claude-3-5-sonnet-20241022", model: true},
+		{name: "ANTHROPIC_BASE_URL", kind: varURL},
+	},
+	"gemini": {
+		{name: "GEMINI_API_KEY", kind: varSecret, required: true},
+		{name: "GEMINI_MODEL", fallback: "gemini-2.5-flash", model: true},
+		{name: "GEMINI_BASE_URL", kind: varURL},
+	},
+}
+
+// providerChecks check settings of a built-in provider against each other,
+// once each variable has passed on its own.
+var providerChecks = map[string]func(providerConfig) error{
+	"azure_openai": func(cfg providerConfig) error {
+		return validateAzureConfig(cfg["AZURE_OPENAI_RESOURCE_NAME"], cfg["AZURE_OPENAI_DEPLOYMENT_NAME"], cfg["AZURE_OPENAI_API_VERSION"])
+	},
+}
+
+// providerConfig maps the variables of a built-in provider to their values,
+// or their fallbacks when not set.
+type providerConfig map[string]string
+
+// varSource returns the variable the value of v comes from: v.name itself,
+// the LLM_* variable standing in for it or, for a secret that has neither,
+// v.name+"_FILE". It is "" when none is set.
+func varSource(v providerVar) string {
+	if value, from := providerEnv(v.name); value != "" {
+		return from
+	}
+	if v.kind == varSecret && os.Getenv(v.name+"_FILE") != "" {
+		return v.name + "_FILE"
+	}
+	return ""
+}
+
+// loadProviderConfig reads and checks the variables of the built-in provider
+// called name. With resolve, "cmd:" values are run and _FILE variables read,
+// as building the provider needs. Without, neither happens: such values only
+// count as set, and the rest are checked as they are.
+func loadProviderConfig(name string, resolve bool) (providerConfig, error) {
+	name = strings.ToLower(name)
+	vars, ok := providerVars[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+
+	sources := make(map[string]string, len(vars))
+	for _, v := range vars {
+		sources[v.name] = varSource(v)
+	}
+
+	cfg := make(providerConfig, len(vars))
+	for _, v := range vars {
+		replaced := false
+		for _, alternative := range v.alternatives {
+			if sources[alternative] != "" {
+				replaced = true
+			}
+		}
+		if replaced {
+			continue
+		}
+
+		value, err := readProviderVar(v, resolve)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" && sources[v.name] == "" {
+			if v.required {
+				return nil, notSetError(v)
+			}
+			value = v.fallback
+		}
+		cfg[v.name] = value
+	}
+
+	if check := providerChecks[name]; check != nil {
+		if err := check(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// readProviderVar returns the checked value of v. Without resolve, it returns
+// "" for a value that only running a command or reading a file would tell.
+func readProviderVar(v providerVar, resolve bool) (string, error) {
+	value := providerSetting(v.name)
+	switch v.kind {
+	case varSecret:
+		if resolve {
+			var err error
+			if value, err = envOrFile(v.name, true); err != nil {
+				return "", err
+			}
+		} else if strings.HasPrefix(value, secretCommandPrefix) {
+			return "", nil
+		}
+		if value == "" {
+			return "", nil
+		}
+		return value, validateAPIKey(v.name, value)
+	case varKeyList:
+		if value == "" {
+			return "", nil
+		}
+		_, err := parseKeyList(v.name, value)
+		return value, err
+	case varURL:
+		if resolve {
+			var err error
+			if value, err = envValue(v.name); err != nil {
+				return "", err
+			}
+		} else if strings.HasPrefix(value, secretCommandPrefix) {
+			return "", nil
+		}
+		return value, validateBaseURL(v.name, value)
+	}
+	return value, nil
+}
+
+// notSetError is the error for the required variable v that is not set.
+func notSetError(v providerVar) error {
+	var err error = fmt.Errorf("%s environment variable is not set", v.name)
+	if v.kind == varSecret {
+		err = &secretNotSetError{name: v.name}
+	}
+	if v.hint != "" {
+		err = fmt.Errorf("%w (%s)", err, v.hint)
+	}
+	return err
+}
+
+// parseKeyList splits the comma-separated keys that the variable name holds,
+// skipping empty entries.
+func parseKeyList(name, list string) ([]string, error) {
+	var keys []string
+	for key := range strings.SplitSeq(list, ",") {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		if err := validateAPIKey(name, key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s does not contain any key", name)
+	}
+	return keys, nil
+}
+
+// CheckConfig checks the configuration of the built-in provider called name
+// as building it would, but without running "cmd:" values or reading _FILE
+// variables, and returns the model it would use.
+func CheckConfig(name string) (string, error) {
+	cfg, err := loadProviderConfig(name, false)
+	if err != nil {
+		return "", err
+	}
+	return cfg.model(name), nil
+}
+
+// model returns the model the built-in provider called name uses with cfg.
+func (cfg providerConfig) model(name string) string {
+	for _, v := range providerVars[strings.ToLower(name)] {
+		if v.model {
+			return cfg[v.name]
+		}
+	}
+	return ""
+}
+
+// CheckEndpoint checks endpoint the way CheckConfig checks a built-in
+// provider, and returns the model it would use.
+func CheckEndpoint(name string, endpoint Endpoint) (string, error) {
+	key := providerVar{name: endpoint.APIKeyEnv, kind: varSecret, required: true}
+	value, err := readProviderVar(key, false)
+	if err != nil {
+		return "", err
+	}
+	if value == "" && varSource(key) == "" {
+		return "", fmt.Errorf("%w (needed by provider %q)", notSetError(key), name)
+	}
+	if !strings.HasPrefix(endpoint.BaseURL, secretCommandPrefix) {
+		if err := validateBaseURL(fmt.Sprintf("base_url of provider %q", name), endpoint.BaseURL); err != nil {
+			return "", err
+		}
+	}
+	return envOrDefault(endpoint.Model, endpointDefaultModels[endpoint.Protocol]), nil
+}
+
+// EnvStatus is an environment variable a provider reads.
+type EnvStatus struct {
+	Name string
+	// Source is the variable the value comes from: Name itself, the LLM_*
+	// variable standing in for it or, when neither is set, Name_FILE. It is
+	// "" when none is set.
+	Source string
+}
+
+// Description is the configuration of a provider as far as the environment
+// tells, without building it: the variables it reads and the required ones
+// that are not set.
+type Description struct {
+	Env     []EnvStatus
+	Missing []string
+}
+
+// Describe describes the built-in provider called name, and reports false
+// when there is none.
+func Describe(name string) (Description, bool) {
+	vars, ok := providerVars[strings.ToLower(name)]
+	if !ok {
+		return Description{}, false
+	}
+
+	var desc Description
+	sources := make(map[string]string, len(vars))
+	for _, v := range vars {
+		source := varSource(v)
+		sources[v.name] = source
+		desc.Env = append(desc.Env, EnvStatus{Name: v.name, Source: source})
+	}
+	for _, v := range vars {
+		if !v.required || sources[v.name] != "" {
+			continue
+		}
+		satisfied := false
+		for _, alternative := range v.alternatives {
+			if sources[alternative] != "" {
+				satisfied = true
+			}
+		}
+		if !satisfied {
+			desc.Missing = append(desc.Missing, v.name)
+		}
+	}
+	return desc, true
+}
+
+// DescribeEndpoint describes endpoint, whose only variable is its API key.
+func DescribeEndpoint(endpoint Endpoint) Description {
+	status := EnvStatus{Name: endpoint.APIKeyEnv}
+	if os.Getenv(endpoint.APIKeyEnv) != "" {
+		status.Source = endpoint.APIKeyEnv
+	} else if os.Getenv(endpoint.APIKeyEnv+"_FILE") != "" {
+		status.Source = endpoint.APIKeyEnv + "_FILE"
+	}
+
+	desc := Description{Env: []EnvStatus{status}}
+	if status.Source == "" {
+		desc.Missing = []string{endpoint.APIKeyEnv}
+	}
+	return desc
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearProviderVars unsets every variable Describe looks at.
+func clearProviderVars(t *testing.T) {
+	t.Helper()
+	t.Setenv(UnifiedProviderEnv, "")
+	t.Setenv("LLM_API_KEY", "")
+	for _, vars := range providerVars {
+		for _, v := range vars {
+			t.Setenv(v.name, "")
+			t.Setenv(v.name+"_FILE", "")
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	clearProviderVars(t)
+	t.Setenv("AZURE_OPENAI_API_KEY_FILE", "/run/secrets/azure")
+	t.Setenv("AZURE_OPENAI_BASE_URL", "https://gateway.example.com")
+	t.Setenv(UnifiedProviderEnv, "claude")
+	t.Setenv("LLM_API_KEY", "sk-ant-test")
+
+	cases := []struct {
+		name    string
+		missing []string
+		sources map[string]string
+	}{
+		{
+			name:    "openai",
+			missing: []string{"OPENAI_API_KEY"},
+		},
+		{
+			name:    "azure_openai",
+			missing: []string{"AZURE_OPENAI_DEPLOYMENT_NAME"},
+			sources: map[string]string{
+				"AZURE_OPENAI_API_KEY":  "AZURE_OPENAI_API_KEY_FILE",
+				"AZURE_OPENAI_BASE_URL": "AZURE_OPENAI_BASE_URL",
+			},
+		},
+		{
+			name:    "anthropic",
+			sources: map[string]string{"ANTHROPIC_API_KEY": "LLM_API_KEY"},
+		},
+	}
+	for _, tc := range cases {
+		desc, ok := Describe(tc.name)
+		if !ok {
+			t.Fatalf("%s: expected a description", tc.name)
+		}
+		if !reflect.DeepEqual(desc.Missing, tc.missing) {
+			t.Errorf("%s: expected missing %v, got %v", tc.name, tc.missing, desc.Missing)
+		}
+		for _, env := range desc.Env {
+			if env.Source != tc.sources[env.Name] {
+				t.Errorf("%s: expected %s from %q, got %q", tc.name, env.Name, tc.sources[env.Name], env.Source)
+			}
+		}
+	}
+
+	// OPENAI_API_KEYS stands in for OPENAI_API_KEY.
+	t.Setenv("OPENAI_API_KEYS", "sk-one,sk-two")
+	if desc, _ := Describe("openai"); len(desc.Missing) != 0 {
+		t.Errorf("expected nothing missing with OPENAI_API_KEYS, got %v", desc.Missing)
+	}
+
+	if _, ok := Describe("deepseek"); ok {
+		t.Error("expected no description for an unknown provider")
+	}
+}
+
+func TestDescribeEndpoint(t *testing.T) {
+	t.Setenv("WORK_KEY", "")
+	t.Setenv("WORK_KEY_FILE", "")
+	endpoint := Endpoint{Protocol: "openai", APIKeyEnv: "WORK_KEY"}
+
+	if desc := DescribeEndpoint(endpoint); !reflect.DeepEqual(desc.Missing, []string{"WORK_KEY"}) {
+		t.Errorf("expected WORK_KEY to be missing, got %v", desc.Missing)
+	}
+	t.Setenv("WORK_KEY", "sk-work")
+	if desc := DescribeEndpoint(endpoint); len(desc.Missing) != 0 || desc.Env[0].Source != "WORK_KEY" {
+		t.Errorf("expected WORK_KEY to be set, got %+v", desc)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	clearProviderVars(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	t.Setenv("ANTHROPIC_API_KEY", "cmd:touch "+marker+"; echo sk-ant")
+	t.Setenv("ANTHROPIC_MODEL", "claude-sonnet-4-5")
+
+	model, err := CheckConfig("anthropic")
+	if err != nil || model != "claude-sonnet-4-5" {
+		t.Errorf("expected claude-sonnet-4-5, got %q, %v", model, err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("expected the cmd: value not to be run")
+	}
+
+	t.Setenv("GEMINI_API_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+	if model, err := CheckConfig("gemini"); err != nil || model != "gemini-2.5-flash" {
+		t.Errorf("expected the default model without reading the file, got %q, %v", model, err)
+	}
+
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure-key")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT_NAME", "gpt-4o")
+	t.Setenv("AZURE_OPENAI_RESOURCE_NAME", "https://corp.openai.azure.com")
+	if _, err := CheckConfig("azure_openai"); err == nil || !strings.Contains(err.Error(), "AZURE_OPENAI_RESOURCE_NAME should be just the resource name") {
+		t.Errorf("expected the resource name error, got %v", err)
+	}
+
+	if _, err := CheckConfig("openai_compatible"); err == nil || !strings.Contains(err.Error(), "placeholder") {
+		t.Errorf("expected the missing key with its hint, got %v", err)
+	}
+}
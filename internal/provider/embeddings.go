@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/openai/openai-go"
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// Embedder turns texts into embedding vectors, one per text and in the same order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+type OpenAIEmbedder struct {
+	Model  string
+	Client *openai.Client
+
+	// fallbacks, keyIndex and keyCount rotate through OPENAI_API_KEYS as
+	// OpenAIProvider does.
+	fallbacks []keyedOpenAIClient
+	keyIndex  int
+	keyCount  int
+}
+
+func NewOpenAIEmbedder() (*OpenAIEmbedder, error) {
+	cfg, err := loadProviderConfig("openai", true)
+	if err != nil {
+		return nil, err
+	}
+	keys := openAIAPIKeys(cfg)
+	model := cfg["OPENAI_EMBEDDING_MODEL"]
+
+	// The same clients as for suggestions, so the proxy, cooldowns, retries
+	// and key rotation apply to embedding requests too.
+	clients := newOpenAIKeyClients(keys, cfg, requestTimeout())
+
+	return &OpenAIEmbedder{
+		Model:     model,
+		Client:    clients[0].client,
+		fallbacks: clients[1:],
+		keyIndex:  clients[0].index,
+		keyCount:  len(keys),
+	}, nil
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	params := openai.EmbeddingNewParams{
+		Model: openai.EmbeddingModel(e.Model),
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+	}
+	resp, err := e.Client.Embeddings.New(ctx, params)
+	keySent("openai", e.keyIndex, e.keyCount)
+	for _, fallback := range e.fallbacks {
+		if err == nil || !isKeyError(err) {
+			break
+		}
+		debug.Log("OpenAI key rejected, trying the next one", map[string]any{
+			"error": err.Error(),
+			"key":   fallback.index + 1,
+		})
+		resp, err = fallback.client.Embeddings.New(ctx, params)
+		keySent("openai", fallback.index, e.keyCount)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings from OpenAI API, got %d", len(texts), len(resp.Data))
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || int(item.Index) >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// SelectRelevant returns the k entries most similar to query by cosine
+// similarity of their embeddings. The selected entries keep their original
+// order so that history still reads chronologically.
+func SelectRelevant(ctx context.Context, embedder Embedder, query string, entries []string, k int) ([]string, error) {
+	if k <= 0 || len(entries) <= k {
+		return entries, nil
+	}
+
+	vectors, err := embedder.Embed(ctx, append([]string{query}, entries...))
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(entries)+1 {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(entries)+1, len(vectors))
+	}
+
+	queryVector := vectors[0]
+	scores := make([]float64, len(entries))
+	indexes := make([]int, len(entries))
+	for i := range entries {
+		scores[i] = cosineSimilarity(queryVector, vectors[i+1])
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return scores[indexes[a]] > scores[indexes[b]]
+	})
+
+	selected := indexes[:k]
+	sort.Ints(selected)
+	relevant := make([]string, 0, k)
+	for _, i := range selected {
+		relevant = append(relevant, entries[i])
+	}
+
+	debug.Log("Selected relevant history", map[string]any{
+		"query":    query,
+		"selected": relevant,
+	})
+	return relevant, nil
+}
+
+func cosineSimilarity(a []float64, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
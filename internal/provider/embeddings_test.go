@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// keywordVectors embeds each text as counts of a few keywords, which is enough
+// to make similarity meaningful for the small corpus below.
+func keywordVectors(texts []string) [][]float64 {
+	keywords := []string{"git", "docker", "ls", "make"}
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vector := make([]float64, len(keywords))
+		for j, keyword := range keywords {
+			vector[j] = float64(strings.Count(text, keyword))
+		}
+		vectors[i] = vector
+	}
+	return vectors
+}
+
+func newMockEmbeddingsServer(t *testing.T, requests *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		*requests++
+
+		var body struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("failed to parse request: %v", err)
+		}
+
+		type item struct {
+			Object    string    `json:"object"`
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		}
+		var items []item
+		for i, vector := range keywordVectors(body.Input) {
+			items = append(items, item{Object: "embedding", Index: i, Embedding: vector})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"object": "list",
+			"model":  body.Model,
+			"data":   items,
+			"usage":  map[string]int{"prompt_tokens": 1, "total_tokens": 1},
+		})
+	}))
+}
+
+func TestOpenAIEmbedder_Embed(t *testing.T) {
+	requests := 0
+	server := newMockEmbeddingsServer(t, &requests)
+	defer server.Close()
+
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(server.URL),
+	)
+	e := &OpenAIEmbedder{Model: "text-embedding-3-small", Client: &client}
+
+	vectors, err := e.Embed(t.Context(), []string{"git status", "docker ps"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Fatalf("unexpected vectors: %v", vectors)
+	}
+
+	if vectors, err := e.Embed(t.Context(), nil); err != nil || vectors != nil {
+		t.Fatalf("expected no request for empty input, got %v, %v", vectors, err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestNewOpenAIEmbedder(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	if _, err := NewOpenAIEmbedder(); err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEY") {
+		t.Fatalf("expected api key error, got %v", err)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_EMBEDDING_MODEL", "")
+	e, err := NewOpenAIEmbedder()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Model != "text-embedding-3-small" {
+		t.Fatalf("expected default embedding model, got %q", e.Model)
+	}
+}
+
+func TestNewOpenAIEmbedderUsesProxy(t *testing.T) {
+	requests := 0
+	proxy := newMockEmbeddingsServer(t, &requests)
+	defer proxy.Close()
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_API_KEYS", "")
+	t.Setenv("OPENAI_BASE_URL", "http://embeddings.invalid")
+	t.Setenv(ProxyURLEnv, proxy.URL)
+
+	e, err := NewOpenAIEmbedder()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := e.Embed(t.Context(), []string{"git status"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected the request to go through the proxy, got %d", requests)
+	}
+}
+
+func TestSelectRelevant(t *testing.T) {
+	requests := 0
+	server := newMockEmbeddingsServer(t, &requests)
+	defer server.Close()
+
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(server.URL),
+	)
+	e := &OpenAIEmbedder{Model: "text-embedding-3-small", Client: &client}
+
+	history := []string{
+		"git checkout -b feature",
+		"docker compose up -d",
+		"ls -la",
+		"make test",
+		"git commit -m wip",
+		"docker ps",
+	}
+
+	relevant, err := SelectRelevant(t.Context(), e, "git push", history, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"git checkout -b feature", "git commit -m wip"}
+	if strings.Join(relevant, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %q, got %q", expected, relevant)
+	}
+
+	all, err := SelectRelevant(t.Context(), e, "git push", history[:2], 5)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("expected all entries when k exceeds history, got %v, %v", all, err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected a single embeddings request, got %d", requests)
+	}
+}
+
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	return nil, errors.New("boom")
+}
+
+func TestSelectRelevantError(t *testing.T) {
+	if _, err := SelectRelevant(t.Context(), failingEmbedder{}, "q", []string{"a", "b"}, 1); err == nil {
+		t.Fatal("expected embedder error")
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got := cosineSimilarity([]float64{1, 0}, []float64{1, 0}); got != 1 {
+		t.Fatalf("expected 1, got %v", got)
+	}
+	if got := cosineSimilarity([]float64{1, 0}, []float64{0, 1}); got != 0 {
+		t.Fatalf("expected 0, got %v", got)
+	}
+	if got := cosineSimilarity([]float64{0, 0}, []float64{1, 1}); got != 0 {
+		t.Fatalf("expected 0 for zero vector, got %v", got)
+	}
+	if got := cosineSimilarity([]float64{1}, []float64{1, 1}); got != 0 {
+		t.Fatalf("expected 0 for mismatched lengths, got %s", fmt.Sprint(got))
+	}
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Endpoint is a named provider from a providers file, so teams can define many
+// endpoints in one place and switch between them with --provider <name>.
+type Endpoint struct {
+	// Protocol is the API the endpoint speaks: openai (including any
+	// OpenAI-compatible server), anthropic or gemini.
+	Protocol string `json:"protocol"`
+	BaseURL  string `json:"base_url"`
+	// APIKeyEnv names the environment variable holding the key. As for the
+	// built-in providers, <APIKeyEnv>_FILE is read instead when set.
+	APIKeyEnv string            `json:"api_key_env"`
+	Model     string            `json:"model"`
+	Headers   map[string]string `json:"headers"`
+}
+
+// endpointDefaultModels are used when an endpoint does not name a model.
+var endpointDefaultModels = map[string]string{
+	"openai":    "gpt-4o-mini",
+	"anthropic": "claude-3-5-sonnet-20241022",
+	"gemini":    "gemini-2.5-flash",
+}
+
+// LoadEndpoints reads a providers file: a JSON object mapping endpoint names
+// to their specs. Names are matched case-insensitively.
+func LoadEndpoints(path string) (map[string]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read providers file: %w", err)
+	}
+
+	var raw map[string]Endpoint
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid providers file %s: %w", path, err)
+	}
+
+	endpoints := make(map[string]Endpoint, len(raw))
+	for name, endpoint := range raw {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if key == "" {
+			return nil, fmt.Errorf("invalid providers file %s: empty provider name", path)
+		}
+		endpoint.Protocol = strings.ToLower(strings.TrimSpace(endpoint.Protocol))
+		if _, ok := endpointDefaultModels[endpoint.Protocol]; !ok {
+			return nil, fmt.Errorf("invalid providers file %s: provider %q has unsupported protocol %q (valid: %s)",
+				path, name, endpoint.Protocol, strings.Join(EndpointProtocols(), ", "))
+		}
+		if endpoint.APIKeyEnv == "" {
+			return nil, fmt.Errorf("invalid providers file %s: provider %q has no api_key_env", path, name)
+		}
+		endpoints[key] = endpoint
+	}
+	return endpoints, nil
+}
+
+// EndpointProtocols lists the protocols an Endpoint can use.
+func EndpointProtocols() []string {
+	protocols := make([]string, 0, len(endpointDefaultModels))
+	for protocol := range endpointDefaultModels {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+// NewEndpointProvider returns a provider for the endpoint called name.
+func NewEndpointProvider(ctx context.Context, name string, endpoint Endpoint) (Provider, error) {
+	apiKey, err := resolveSecret(endpoint.APIKeyEnv)
+	if err != nil {
+		return nil, fmt.Errorf("%w (needed by provider %q)", err, name)
+	}
+	if err := validateAPIKey(endpoint.APIKeyEnv, apiKey); err != nil {
+		return nil, err
+	}
+	baseURLName := fmt.Sprintf("base_url of provider %q", name)
+	if endpoint.BaseURL, err = resolveValue(baseURLName, endpoint.BaseURL); err != nil {
+		return nil, err
+	}
+	if err := validateBaseURL(baseURLName, endpoint.BaseURL); err != nil {
+		return nil, err
+	}
+
+	model := envOrDefault(endpoint.Model, endpointDefaultModels[endpoint.Protocol])
+	name = strings.ToLower(name)
+	timeout := requestTimeout()
+
+	switch endpoint.Protocol {
+	case "openai":
+		client := newOpenAIClient(name, apiKey, endpoint.BaseURL, endpoint.Headers, timeout)
+		return &OpenAIProvider{Model: model, Client: &client, timeout: timeout}, nil
+	case "anthropic":
+		client := newAnthropicClient(name, apiKey, endpoint.BaseURL, endpoint.Headers, timeout)
+		return &AnthropicProvider{Model: model, Client: &client, timeout: timeout}, nil
+	case "gemini":
+		client, err := newGeminiClient(ctx, name, apiKey, endpoint.BaseURL, endpoint.Headers, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return &GeminiProvider{Model: model, Client: client, timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("provider %q has unsupported protocol %q", name, endpoint.Protocol)
+	}
+}
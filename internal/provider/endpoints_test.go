@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProvidersFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "providers.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write providers file: %v", err)
+	}
+	return path
+}
+
+func TestLoadEndpoints(t *testing.T) {
+	path := writeProvidersFile(t, `{
+		"Internal-Llama": {"protocol": "OpenAI", "base_url": "https://llm.internal/v1", "api_key_env": "INTERNAL_KEY", "model": "llama-3-70b", "headers": {"X-Team": "sre"}},
+		"claude-gw": {"protocol": "anthropic", "base_url": "https://gw.internal", "api_key_env": "GW_KEY"}
+	}`)
+
+	endpoints, err := LoadEndpoints(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	llama, ok := endpoints["internal-llama"]
+	if !ok {
+		t.Fatalf("expected names to be matched case-insensitively, got %v", endpoints)
+	}
+	if llama.Protocol != "openai" || llama.Model != "llama-3-70b" || llama.Headers["X-Team"] != "sre" {
+		t.Errorf("unexpected endpoint: %+v", llama)
+	}
+	if endpoints["claude-gw"].APIKeyEnv != "GW_KEY" {
+		t.Errorf("unexpected endpoint: %+v", endpoints["claude-gw"])
+	}
+}
+
+func TestLoadEndpoints_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "invalid json", content: `{"a": `, want: "invalid providers file"},
+		{name: "unknown protocol", content: `{"a": {"protocol": "cohere", "api_key_env": "K"}}`, want: `unsupported protocol "cohere"`},
+		{name: "missing key env", content: `{"a": {"protocol": "openai"}}`, want: "has no api_key_env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadEndpoints(writeProvidersFile(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := LoadEndpoints(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+}
+
+func TestNewEndpointProvider(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	var gotPath, gotAuth, gotTeam, gotModel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		gotTeam = r.Header.Get("X-Team")
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(body, &req)
+		gotModel = req.Model
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"=ls"}}]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("INTERNAL_KEY", "internal-secret")
+	p, err := NewEndpointProvider(t.Context(), "internal-llama", Endpoint{
+		Protocol:  "openai",
+		BaseURL:   server.URL + "/v1",
+		APIKeyEnv: "INTERNAL_KEY",
+		Model:     "llama-3-70b",
+		Headers:   map[string]string{"X-Team": "sre"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ModelName(p) != "llama-3-70b" {
+		t.Errorf("expected model llama-3-70b, got %q", ModelName(p))
+	}
+
+	got, err := p.Fetch(t.Context(), "list", "system")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "=ls" {
+		t.Errorf("expected =ls, got %q", got)
+	}
+	if gotPath != "/v1/chat/completions" || gotAuth != "Bearer internal-secret" || gotTeam != "sre" || gotModel != "llama-3-70b" {
+		t.Errorf("unexpected request: path=%q auth=%q team=%q model=%q", gotPath, gotAuth, gotTeam, gotModel)
+	}
+}
+
+func TestNewEndpointProvider_Errors(t *testing.T) {
+	t.Setenv("MISSING_KEY", "")
+	_, err := NewEndpointProvider(t.Context(), "gw", Endpoint{Protocol: "anthropic", APIKeyEnv: "MISSING_KEY"})
+	if err == nil || !strings.Contains(err.Error(), `neither MISSING_KEY nor MISSING_KEY_FILE is set (needed by provider "gw")`) {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+
+	t.Setenv("GW_KEY", "secret")
+	_, err = NewEndpointProvider(t.Context(), "gw", Endpoint{Protocol: "gemini", APIKeyEnv: "GW_KEY", BaseURL: "ftp://gw"})
+	if err == nil || !strings.Contains(err.Error(), `base_url of provider "gw"`) {
+		t.Fatalf("expected base URL error, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// FallbackProvider asks each of its providers in turn until one answers. It
+// is built from a comma-separated provider list such as "openai,gemini".
+type FallbackProvider struct {
+	// Names labels Providers in logs and errors, and is the key their rate
+	// limit cooldowns are recorded under.
+	Names     []string
+	Providers []Provider
+}
+
+// NewFallbackProvider returns a provider that tries providers in order;
+// names[i] is the name providers[i] was selected by.
+func NewFallbackProvider(names []string, providers []Provider) *FallbackProvider {
+	return &FallbackProvider{Names: names, Providers: providers}
+}
+
+func (p *FallbackProvider) Fetch(ctx context.Context, input string, systemPrompt string) (string, error) {
+	return p.FetchWithHistory(ctx, input, systemPrompt, nil)
+}
+
+// FetchWithHistory returns the first response that is not an error. Providers
+// still in a rate limit cooldown are skipped. Only when every provider fails
+// does it return an error, which joins all of theirs. It stops as soon as ctx
+// is done.
+func (p *FallbackProvider) FetchWithHistory(ctx context.Context, input string, systemPrompt string, history []Message) (string, error) {
+	var errs []error
+	for i, candidate := range p.Providers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		name := p.Names[i]
+		if i > 0 {
+			debug.Log("Falling back to next provider", map[string]any{
+				"provider":       name,
+				"previous_error": errs[len(errs)-1].Error(),
+			})
+		}
+
+		if err := CheckCooldown(name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		response, err := candidate.FetchWithHistory(ctx, input, systemPrompt, history)
+		if err == nil {
+			return response, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	return "", errors.Join(errs...)
+}
+
+// Warmup warms up the first provider, which answers unless it fails.
+func (p *FallbackProvider) Warmup(ctx context.Context) error {
+	if len(p.Providers) == 0 {
+		return nil
+	}
+	return Warmup(ctx, p.Providers[0])
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingProvider records how often it was asked before answering like p.
+type countingProvider struct {
+	staticProvider
+	calls int
+}
+
+func (p *countingProvider) FetchWithHistory(ctx context.Context, input, systemPrompt string, history []Message) (string, error) {
+	p.calls++
+	return p.staticProvider.FetchWithHistory(ctx, input, systemPrompt, history)
+}
+
+func TestFallbackProvider_FirstSuccessWins(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	primary := &countingProvider{staticProvider: staticProvider{err: errors.New("rate limited")}}
+	secondary := &countingProvider{staticProvider: staticProvider{response: "=ls"}}
+	last := &countingProvider{staticProvider: staticProvider{response: "=pwd"}}
+	p := NewFallbackProvider([]string{"openai", "gemini", "anthropic"}, []Provider{primary, secondary, last})
+
+	got, err := p.Fetch(t.Context(), "list", "prompt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "=ls" {
+		t.Errorf("expected the secondary's response, got %q", got)
+	}
+	if primary.calls != 1 || secondary.calls != 1 || last.calls != 0 {
+		t.Errorf("expected calls 1, 1, 0, got %d, %d, %d", primary.calls, secondary.calls, last.calls)
+	}
+}
+
+func TestFallbackProvider_AllFail(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	timeoutErr := &TimeoutError{Timeout: time.Second, Err: context.DeadlineExceeded}
+	p := NewFallbackProvider([]string{"openai", "gemini"}, []Provider{
+		staticProvider{err: errors.New("invalid api key")},
+		staticProvider{err: timeoutErr},
+	})
+
+	_, err := p.Fetch(t.Context(), "list", "prompt")
+	if err == nil {
+		t.Fatal("expected an error when every provider fails")
+	}
+	for _, want := range []string{"openai: invalid api key", "gemini: "} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Errorf("expected the joined error to keep the TimeoutError, got %v", err)
+	}
+}
+
+func TestFallbackProvider_SkipsCooldown(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	if err := RecordCooldown("openai", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RecordCooldown failed: %v", err)
+	}
+	primary := &countingProvider{staticProvider: staticProvider{response: "=ls"}}
+	p := NewFallbackProvider([]string{"openai", "gemini"}, []Provider{primary, staticProvider{response: "=pwd"}})
+
+	got, err := p.Fetch(t.Context(), "list", "prompt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "=pwd" || primary.calls != 0 {
+		t.Errorf("expected the provider in cooldown to be skipped, got %q after %d calls", got, primary.calls)
+	}
+}
+
+func TestFallbackProvider_StopsWhenCancelled(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	ctx, cancel := context.WithCancel(t.Context())
+	secondary := &countingProvider{staticProvider: staticProvider{response: "=ls"}}
+	p := NewFallbackProvider([]string{"openai", "gemini"}, []Provider{
+		staticProvider{err: context.Canceled},
+		secondary,
+	})
+
+	cancel()
+	if _, err := p.Fetch(ctx, "list", "prompt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Errorf("expected no fallback after cancellation, got %d calls", secondary.calls)
+	}
+}
+
+func TestFallbackProvider_ModelName(t *testing.T) {
+	p := NewFallbackProvider([]string{"openai", "anthropic"}, []Provider{
+		&OpenAIProvider{Model: "gpt-4o-mini"},
+		&AnthropicProvider{Model: "claude-3-5-sonnet-20241022"},
+	})
+	if got := ModelName(p); got != "gpt-4o-mini" {
+		t.Errorf("expected the primary's model, got %q", got)
+	}
+}
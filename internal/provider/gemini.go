@@ -3,7 +3,8 @@ package provider
 import (
 	"context"
 	"fmt"
-	"os"
+	"net/http"
+	"time"
 
 	"github.com/xyenon/smart-suggestion/internal/debug"
 	"google.golang.org/genai"
@@ -12,32 +13,50 @@ import (
 type GeminiProvider struct {
 	Model  string
 	Client *genai.Client
+
+	timeout time.Duration
 }
 
 func NewGeminiProvider(ctx context.Context) (*GeminiProvider, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY environment variable is not set")
+	cfg, err := loadProviderConfig("gemini", true)
+	if err != nil {
+		return nil, err
 	}
 
-	config := &genai.ClientConfig{APIKey: apiKey}
+	timeout := requestTimeout()
+	client, err := newGeminiClient(ctx, "gemini", cfg["GEMINI_API_KEY"], cfg["GEMINI_BASE_URL"], nil, timeout)
+	if err != nil {
+		return nil, err
+	}
 
-	baseURL := os.Getenv("GEMINI_BASE_URL")
+	return &GeminiProvider{
+		Model:   cfg["GEMINI_MODEL"],
+		Client:  client,
+		timeout: timeout,
+	}, nil
+}
+
+// newGeminiClient returns a client for the Gemini API. name keys the rate
+// limit cooldown, headers are sent with every request and each request gives
+// up after timeout, unless it is 0.
+func newGeminiClient(ctx context.Context, name, apiKey, baseURL string, headers map[string]string, timeout time.Duration) (*genai.Client, error) {
+	config := &genai.ClientConfig{APIKey: apiKey, HTTPClient: newProviderHTTPClient(name)}
+	config.HTTPOptions.Headers = http.Header{"User-Agent": []string{UserAgent()}}
+	for key, value := range headers {
+		config.HTTPOptions.Headers.Set(key, value)
+	}
 	if baseURL != "" {
 		config.HTTPOptions.BaseURL = baseURL
 	}
+	if timeout > 0 {
+		config.HTTPOptions.Timeout = &timeout
+	}
 
 	client, err := genai.NewClient(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
 	}
-
-	model := envOrDefault(os.Getenv("GEMINI_MODEL"), "gemini-2.5-flash")
-
-	return &GeminiProvider{
-		Model:  model,
-		Client: client,
-	}, nil
+	return client, nil
 }
 
 func (p *GeminiProvider) Fetch(ctx context.Context, input string, systemPrompt string) (string, error) {
@@ -47,8 +66,6 @@ func (p *GeminiProvider) Fetch(ctx context.Context, input string, systemPrompt s
 func (p *GeminiProvider) FetchWithHistory(ctx context.Context, input string, systemPrompt string, history []Message) (string, error) {
 	logProviderRequest("gemini", p.Model, systemPrompt, history, input)
 
-	config := &genai.GenerateContentConfig{SystemInstruction: genai.NewContentFromText(systemPrompt, genai.RoleUser)}
-
 	var chatHistory []*genai.Content
 	for _, msg := range history {
 		var role genai.Role
@@ -63,30 +80,45 @@ func (p *GeminiProvider) FetchWithHistory(ctx context.Context, input string, sys
 		chatHistory = append(chatHistory, genai.NewContentFromText(msg.Content, role))
 	}
 
-	chat, err := p.Client.Chats.Create(ctx, p.Model, config, chatHistory)
-	if err != nil {
-		return "", fmt.Errorf("failed to create chat: %w", err)
-	}
+	return retryTruncated(ctx, "gemini", p.Model, 0, func(ctx context.Context) (string, bool, error) {
+		config := &genai.GenerateContentConfig{SystemInstruction: genai.NewContentFromText(systemPrompt, genai.RoleUser)}
+		if maxTokens := maxTokensFor(ctx, p.Model); maxTokens > 0 {
+			config.MaxOutputTokens = int32(maxTokens)
+		}
+		if temperature := temperatureFor(ctx); temperature != nil {
+			config.Temperature = genai.Ptr(float32(*temperature))
+		}
 
-	resp, err := chat.SendMessage(ctx, genai.Part{Text: input})
-	debug.Log("Received Gemini response", map[string]any{
-		"response": resp,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to send message: %w", err)
-	}
+		chat, err := p.Client.Chats.Create(ctx, p.Model, config, chatHistory)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to create chat: %w", err)
+		}
 
-	if len(resp.Candidates) == 0 {
-		return "", fmt.Errorf("no candidates returned from Gemini API")
-	}
-	if resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content parts returned from Gemini API")
-	}
+		resp, err := chat.SendMessage(ctx, genai.Part{Text: input})
+		debug.Log("Received Gemini response", map[string]any{
+			"response": resp,
+		})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to send message: %w", timeoutError(ctx, p.timeout, err))
+		}
+		if err := geminiRefusal(resp); err != nil {
+			return "", false, err
+		}
 
-	part := resp.Candidates[0].Content.Parts[0]
-	if part.Text != "" {
-		return part.Text, nil
-	}
+		if len(resp.Candidates) == 0 {
+			return "", false, fmt.Errorf("no candidates returned from Gemini API")
+		}
+		// Thinking models can spend the whole limit before writing any part.
+		truncated := resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens
+		if resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+			return "", truncated, fmt.Errorf("no content parts returned from Gemini API")
+		}
+
+		part := resp.Candidates[0].Content.Parts[0]
+		if part.Text != "" {
+			return part.Text, truncated, nil
+		}
 
-	return "", fmt.Errorf("unexpected part type from Gemini API")
+		return "", false, fmt.Errorf("unexpected part type from Gemini API")
+	})
 }
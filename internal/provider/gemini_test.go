@@ -236,6 +236,26 @@ func TestGeminiProvider_FetchWithHistory_MockedResponses(t *testing.T) {
 			expectError:   true,
 			errorContains: "failed to send message",
 		},
+		{
+			name: "prompt_blocked",
+			responseBody: `{
+				"promptFeedback": {"blockReason": "SAFETY"}
+			}`,
+			statusCode:    200,
+			expectError:   true,
+			errorContains: "Gemini declined to answer under its content policy (SAFETY)",
+		},
+		{
+			name: "response_blocked",
+			responseBody: `{
+				"candidates": [
+					{"finishReason": "PROHIBITED_CONTENT"}
+				]
+			}`,
+			statusCode:    200,
+			expectError:   true,
+			errorContains: "Gemini declined to answer under its content policy (PROHIBITED_CONTENT)",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"context"
+	"strings"
+)
+
+// modelMaxTokens maps model name prefixes to completion-token defaults. More
+// specific prefixes come first. Models whose thinking tokens count against the
+// output limit get more room so the answer is not cut off.
+var modelMaxTokens = []struct {
+	prefix string
+	tokens int64
+}{
+	{prefix: "gpt-3.5-turbo", tokens: 1000},
+	{prefix: "gpt-4o-mini", tokens: 1000},
+	{prefix: "gpt-4o", tokens: 1000},
+	{prefix: "gpt-4.1", tokens: 1000},
+	{prefix: "claude-3-haiku", tokens: 1000},
+	{prefix: "claude-3-5", tokens: 1000},
+	{prefix: "claude-3-7", tokens: 2000},
+	{prefix: "claude-sonnet-4", tokens: 2000},
+	{prefix: "claude-opus-4", tokens: 2000},
+	{prefix: "gemini-1.5", tokens: 1000},
+	{prefix: "gemini-2.0", tokens: 1000},
+	{prefix: "gemini-2.5", tokens: 8192},
+}
+
+// DefaultMaxTokens returns the completion-token default for model, or 0 when
+// the model is not in the table.
+func DefaultMaxTokens(model string) int64 {
+	model = strings.ToLower(model)
+	for _, entry := range modelMaxTokens {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.tokens
+		}
+	}
+	return 0
+}
+
+// maxTokensFor resolves the completion-token limit for a request to model.
+// Per-request options win, then SMART_SUGGESTION_MAX_TOKENS, then the model
+// table. It returns 0 when the provider default should be kept.
+func maxTokensFor(ctx context.Context, model string) int64 {
+	if opts := GenerationOptionsFromContext(ctx); opts.MaxTokens > 0 {
+		return opts.MaxTokens
+	}
+	if tokens, ok := envSetting("SMART_SUGGESTION_MAX_TOKENS", parseMaxTokens); ok {
+		return tokens
+	}
+	return DefaultMaxTokens(model)
+}
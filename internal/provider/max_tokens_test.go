@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestDefaultMaxTokens(t *testing.T) {
+	cases := []struct {
+		model    string
+		expected int64
+	}{
+		{model: "gpt-4o-mini", expected: 1000},
+		{model: "gpt-4o-2024-08-06", expected: 1000},
+		{model: "Claude-Sonnet-4-20250514", expected: 2000},
+		{model: "gemini-2.5-flash", expected: 8192},
+		{model: "my-finetune", expected: 0},
+	}
+	for _, tc := range cases {
+		if got := DefaultMaxTokens(tc.model); got != tc.expected {
+			t.Fatalf("%s: expected %d, got %d", tc.model, tc.expected, got)
+		}
+	}
+}
+
+func TestMaxTokensFor(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "")
+	if got := maxTokensFor(t.Context(), "gemini-2.5-flash"); got != 8192 {
+		t.Fatalf("expected table value 8192, got %d", got)
+	}
+	if got := maxTokensFor(t.Context(), "unknown"); got != 0 {
+		t.Fatalf("expected 0 for unknown model, got %d", got)
+	}
+
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "300")
+	if got := maxTokensFor(t.Context(), "gemini-2.5-flash"); got != 300 {
+		t.Fatalf("expected env override 300, got %d", got)
+	}
+
+	ctx := WithGenerationOptions(t.Context(), GenerationOptions{MaxTokens: 64})
+	if got := maxTokensFor(ctx, "gemini-2.5-flash"); got != 64 {
+		t.Fatalf("expected request option 64, got %d", got)
+	}
+
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "lots")
+	if got := maxTokensFor(t.Context(), "gpt-4o"); got != 1000 {
+		t.Fatalf("expected table value for invalid override, got %d", got)
+	}
+}
+
+func TestOpenAIProvider_FetchUsesModelMaxTokens(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"index": 0, "message": {"role": "assistant", "content": "+la"}}]}`)
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(server.URL),
+	)
+	p := &OpenAIProvider{Model: "gpt-4o-mini", Client: &client}
+
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "")
+	if _, err := p.Fetch(t.Context(), "ls -", "prompt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["max_completion_tokens"] != float64(1000) {
+		t.Fatalf("expected max_completion_tokens from model table, got %v", body["max_completion_tokens"])
+	}
+
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "256")
+	if _, err := p.Fetch(t.Context(), "ls -", "prompt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["max_completion_tokens"] != float64(256) {
+		t.Fatalf("expected max_completion_tokens override 256, got %v", body["max_completion_tokens"])
+	}
+}
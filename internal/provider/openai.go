@@ -3,7 +3,8 @@ package provider
 import (
 	"context"
 	"fmt"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -13,30 +14,94 @@ import (
 type OpenAIProvider struct {
 	Model  string
 	Client *openai.Client
+
+	// fallbacks hold clients for the other OPENAI_API_KEYS, tried in turn when
+	// the key of Client, the one at keyIndex, is rejected or out of quota.
+	fallbacks []keyedOpenAIClient
+	keyIndex  int
+	keyCount  int
+	timeout   time.Duration
+}
+
+type keyedOpenAIClient struct {
+	index  int
+	client *openai.Client
 }
 
 func NewOpenAIProvider() (*OpenAIProvider, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable is not set")
+	cfg, err := loadProviderConfig("openai", true)
+	if err != nil {
+		return nil, err
 	}
+	keys := openAIAPIKeys(cfg)
+	model := cfg["OPENAI_MODEL"]
+	timeout := requestTimeout()
+
+	clients := newOpenAIKeyClients(keys, cfg, timeout)
+
+	return &OpenAIProvider{
+		Model:     model,
+		Client:    clients[0].client,
+		fallbacks: clients[1:],
+		keyIndex:  clients[0].index,
+		keyCount:  len(keys),
+		timeout:   timeout,
+	}, nil
+}
 
+// newOpenAIKeyClients returns a client for each of keys, in the order this
+// invocation tries them: each invocation starts with the key after the one
+// last sent with, spreading requests across them.
+func newOpenAIKeyClients(keys []string, cfg providerConfig, timeout time.Duration) []keyedOpenAIClient {
+	order := keyOrder("openai", len(keys))
+	clients := make([]keyedOpenAIClient, len(order))
+	for i, index := range order {
+		client := newOpenAIClient(keyProviderName("openai", index, len(keys)), keys[index], cfg["OPENAI_BASE_URL"], openAIAccountHeaders(cfg), timeout)
+		clients[i] = keyedOpenAIClient{index: index, client: &client}
+	}
+	return clients
+}
+
+// openAIAccountHeaders returns the OpenAI-Organization and OpenAI-Project
+// headers from OPENAI_ORG_ID and OPENAI_PROJECT_ID, for accounts in several
+// organizations or projects.
+func openAIAccountHeaders(cfg providerConfig) map[string]string {
+	headers := make(map[string]string)
+	if org := strings.TrimSpace(cfg["OPENAI_ORG_ID"]); org != "" {
+		headers["OpenAI-Organization"] = org
+	}
+	if project := strings.TrimSpace(cfg["OPENAI_PROJECT_ID"]); project != "" {
+		headers["OpenAI-Project"] = project
+	}
+	return headers
+}
+
+// newOpenAIClient returns a client for an OpenAI-compatible API. name keys the
+// rate limit cooldown, headers are sent with every request and each request
+// gives up after timeout, unless it is 0.
+func newOpenAIClient(name, apiKey, baseURL string, headers map[string]string, timeout time.Duration) openai.Client {
 	options := []option.RequestOption{
 		option.WithAPIKey(apiKey),
+		option.WithHTTPClient(newProviderHTTPClient(name)),
+		option.WithHeader("User-Agent", UserAgent()),
+		option.WithRequestTimeout(timeout),
+		// sendWithRetries retries instead, the same way for every provider.
+		option.WithMaxRetries(0),
+		// The SDK adds these from OPENAI_ORG_ID and OPENAI_PROJECT_ID on its
+		// own; only the openai provider passes them on, through headers, so
+		// other servers never see the OpenAI account.
+		option.WithHeaderDel("OpenAI-Organization"),
+		option.WithHeaderDel("OpenAI-Project"),
 	}
 
-	if baseURL := normalizeBaseURL(os.Getenv("OPENAI_BASE_URL")); baseURL != "" {
+	if baseURL := normalizeBaseURL(baseURL); baseURL != "" {
 		options = append(options, option.WithBaseURL(baseURL))
 	}
+	for key, value := range headers {
+		options = append(options, option.WithHeader(key, value))
+	}
 
-	model := envOrDefault(os.Getenv("OPENAI_MODEL"), "gpt-4o-mini")
-
-	client := openai.NewClient(options...)
-
-	return &OpenAIProvider{
-		Model:  model,
-		Client: &client,
-	}, nil
+	return openai.NewClient(options...)
 }
 
 func (p *OpenAIProvider) Fetch(ctx context.Context, input string, systemPrompt string) (string, error) {
@@ -46,25 +111,115 @@ func (p *OpenAIProvider) Fetch(ctx context.Context, input string, systemPrompt s
 func (p *OpenAIProvider) FetchWithHistory(ctx context.Context, input string, systemPrompt string, history []Message) (string, error) {
 	logProviderRequest("openai", p.Model, systemPrompt, history, input)
 
-	messages := buildOpenAIChatMessages(systemPrompt, input, history)
+	messages := buildOpenAIChatMessages(p.Model, systemPrompt, input, history)
+
+	return retryTruncated(ctx, "openai", p.Model, 0, func(ctx context.Context) (string, bool, error) {
+		resp, err := p.createChatCompletion(ctx, newOpenAIChatParams(ctx, p.Model, messages))
+		if err != nil {
+			return "", false, err
+		}
+
+		if len(resp.Choices) == 0 {
+			return "", false, fmt.Errorf("no choices returned from OpenAI API")
+		}
+		if err := openAIRefusal("OpenAI", resp.Choices[0]); err != nil {
+			return "", false, err
+		}
+
+		recordOpenAIConfidence(ctx, resp.Choices[0])
+		return resp.Choices[0].Message.Content, resp.Choices[0].FinishReason == "length", nil
+	})
+}
 
-	resp, err := p.Client.Chat.Completions.New(
-		ctx,
-		openai.ChatCompletionNewParams{
-			Model:    openai.ChatModel(p.Model),
-			Messages: messages,
-		},
-	)
+// createChatCompletion sends params, moving on to the next API key while the
+// current one is rejected.
+func (p *OpenAIProvider) createChatCompletion(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	resp, err := p.Client.Chat.Completions.New(ctx, params)
+	keySent("openai", p.keyIndex, p.keyCount)
+	for _, fallback := range p.fallbacks {
+		if err == nil || !isKeyError(err) {
+			break
+		}
+		debug.Log("OpenAI key rejected, trying the next one", map[string]any{
+			"error": err.Error(),
+			"key":   fallback.index + 1,
+		})
+		resp, err = fallback.client.Chat.Completions.New(ctx, params)
+		keySent("openai", fallback.index, p.keyCount)
+	}
 	debug.Log("Received OpenAI response", map[string]any{
 		"response": resp,
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create chat completion: %w", err)
+		return nil, fmt.Errorf("failed to create chat completion: %w", timeoutError(ctx, p.timeout, openAIFilterError("OpenAI", err)))
 	}
+	return resp, nil
+}
+
+// FetchStream is FetchWithHistory for a streamed response. The rejected-key
+// fallback, refusal checks and confidence apply as they do there.
+func (p *OpenAIProvider) FetchStream(ctx context.Context, input string, systemPrompt string, history []Message) (<-chan StreamChunk, error) {
+	logProviderRequest("openai", p.Model, systemPrompt, history, input)
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned from OpenAI API")
+	messages := buildOpenAIChatMessages(p.Model, systemPrompt, input, history)
+
+	params := newOpenAIChatParams(ctx, p.Model, messages)
+	stream := p.Client.Chat.Completions.NewStreaming(ctx, params)
+	ok := stream.Next()
+	keySent("openai", p.keyIndex, p.keyCount)
+	for _, fallback := range p.fallbacks {
+		if ok || !isKeyError(stream.Err()) {
+			break
+		}
+		debug.Log("OpenAI key rejected, trying the next one", map[string]any{
+			"error": stream.Err().Error(),
+			"key":   fallback.index + 1,
+		})
+		stream.Close()
+		stream = fallback.client.Chat.Completions.NewStreaming(ctx, params)
+		ok = stream.Next()
+		keySent("openai", fallback.index, p.keyCount)
+	}
+	if !ok && stream.Err() != nil {
+		err := stream.Err()
+		stream.Close()
+		return nil, fmt.Errorf("failed to create chat completion: %w", timeoutError(ctx, p.timeout, openAIFilterError("OpenAI", err)))
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	chunks := make(chan StreamChunk)
+	go func() {
+		defer close(chunks)
+		defer stream.Close()
+
+		var acc openai.ChatCompletionAccumulator
+		for ; ok; ok = stream.Next() {
+			chunk := stream.Current()
+			acc.AddChunk(chunk)
+			if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+				continue
+			}
+			if !sendChunk(ctx, chunks, StreamChunk{Text: chunk.Choices[0].Delta.Content}) {
+				return
+			}
+		}
+		debug.Log("Received OpenAI response", map[string]any{
+			"response": acc.ChatCompletion,
+		})
+
+		var err error
+		switch {
+		case stream.Err() != nil:
+			err = fmt.Errorf("failed to stream chat completion: %w", timeoutError(ctx, p.timeout, openAIFilterError("OpenAI", stream.Err())))
+		case len(acc.Choices) == 0:
+			err = fmt.Errorf("no choices returned from OpenAI API")
+		default:
+			err = openAIRefusal("OpenAI", acc.Choices[0])
+		}
+		if err != nil {
+			sendChunk(ctx, chunks, StreamChunk{Err: err})
+			return
+		}
+		recordOpenAIConfidence(ctx, acc.Choices[0])
+	}()
+	return chunks, nil
 }
@@ -0,0 +1,22 @@
+package provider
+
+// NewOpenAICompatibleProvider returns a provider for a third-party server
+// that speaks the OpenAI chat API, such as OpenRouter, Together, Groq or
+// LocalAI. It is configured by its own OPENAI_COMPATIBLE_* variables, so it
+// can be used alongside the openai provider. There is no default server or
+// model to fall back on, so both must be set.
+func NewOpenAICompatibleProvider() (*OpenAIProvider, error) {
+	cfg, err := loadProviderConfig("openai_compatible", true)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := requestTimeout()
+	client := newOpenAIClient("openai_compatible", cfg["OPENAI_COMPATIBLE_API_KEY"], cfg["OPENAI_COMPATIBLE_BASE_URL"], nil, timeout)
+
+	return &OpenAIProvider{
+		Model:   cfg["OPENAI_COMPATIBLE_MODEL"],
+		Client:  &client,
+		timeout: timeout,
+	}, nil
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func setOpenAICompatibleEnv(t *testing.T, baseURL string) {
+	t.Helper()
+	clearProviderEnv(t)
+	t.Setenv("OPENAI_COMPATIBLE_API_KEY", "sk-gateway")
+	t.Setenv("OPENAI_COMPATIBLE_BASE_URL", baseURL)
+	t.Setenv("OPENAI_COMPATIBLE_MODEL", "meta-llama/llama-3.3-70b-instruct")
+}
+
+func TestNewOpenAICompatibleProvider_Errors(t *testing.T) {
+	for _, missing := range []string{"OPENAI_COMPATIBLE_API_KEY", "OPENAI_COMPATIBLE_BASE_URL", "OPENAI_COMPATIBLE_MODEL"} {
+		t.Run(missing, func(t *testing.T) {
+			setOpenAICompatibleEnv(t, "https://gateway.example.com/v1")
+			t.Setenv(missing, "")
+			if _, err := NewOpenAICompatibleProvider(); err == nil || !strings.Contains(err.Error(), missing) {
+				t.Errorf("expected an error naming %s, got %v", missing, err)
+			}
+		})
+	}
+
+	setOpenAICompatibleEnv(t, "ftp://gateway.example.com")
+	if _, err := NewOpenAICompatibleProvider(); err == nil || !strings.Contains(err.Error(), "OPENAI_COMPATIBLE_BASE_URL") {
+		t.Errorf("expected an invalid base URL error, got %v", err)
+	}
+}
+
+func TestOpenAICompatibleProvider_IndependentOfOpenAI(t *testing.T) {
+	var got *http.Request
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "=ls -la"}}]}`)
+	}))
+	defer server.Close()
+
+	setOpenAICompatibleEnv(t, server.URL+"/api/v1/")
+	// The openai provider's settings must not leak to the gateway.
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+	t.Setenv("OPENAI_BASE_URL", "https://api.openai.com/v1")
+	t.Setenv("OPENAI_MODEL", "gpt-4o")
+	t.Setenv("OPENAI_ORG_ID", "org-123")
+	t.Setenv("OPENAI_PROJECT_ID", "proj-456")
+
+	p, err := NewOpenAICompatibleProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response, err := p.Fetch(t.Context(), "ls", "prompt")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if response != "=ls -la" {
+		t.Errorf("expected =ls -la, got %q", response)
+	}
+	if got.URL.Path != "/api/v1/chat/completions" {
+		t.Errorf("expected the gateway's chat completions path, got %s", got.URL.Path)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "Bearer sk-gateway" {
+		t.Errorf("expected the gateway key, got %q", auth)
+	}
+	for _, header := range []string{"OpenAI-Organization", "OpenAI-Project"} {
+		if value := got.Header.Get(header); value != "" {
+			t.Errorf("expected no %s header, got %q", header, value)
+		}
+	}
+	if body["model"] != "meta-llama/llama-3.3-70b-instruct" {
+		t.Errorf("expected the gateway model, got %v", body["model"])
+	}
+}
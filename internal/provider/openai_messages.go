@@ -1,11 +1,22 @@
 package provider
 
-import "github.com/openai/openai-go"
+import (
+	"context"
 
-func buildOpenAIChatMessages(systemPrompt string, input string, history []Message) []openai.ChatCompletionMessageParamUnion {
+	"github.com/openai/openai-go"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// buildOpenAIChatMessages sends the system prompt with the role model expects
+// (see SystemRole), followed by history and input.
+func buildOpenAIChatMessages(model, systemPrompt, input string, history []Message) []openai.ChatCompletionMessageParamUnion {
 	messages := []openai.ChatCompletionMessageParamUnion{
 		openai.SystemMessage(systemPrompt),
 	}
+	if SystemRole(model) == RoleDeveloper {
+		messages[0] = openai.DeveloperMessage(systemPrompt)
+	}
 
 	for _, msg := range history {
 		switch msg.Role {
@@ -19,3 +30,29 @@ func buildOpenAIChatMessages(systemPrompt string, input string, history []Messag
 	messages = append(messages, openai.UserMessage(input))
 	return messages
 }
+
+func newOpenAIChatParams(ctx context.Context, model string, messages []openai.ChatCompletionMessageParamUnion) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:    openai.ChatModel(model),
+		Messages: messages,
+	}
+
+	if maxTokens := maxTokensFor(ctx, model); maxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(maxTokens)
+	}
+	if temperature := temperatureFor(ctx); temperature != nil {
+		if isReasoningModel(model) {
+			// Reasoning models reject any temperature but the default.
+			debug.Log("Ignoring temperature for reasoning model", map[string]any{
+				"model": model,
+			})
+		} else {
+			params.Temperature = openai.Float(*temperature)
+		}
+	}
+	// Reasoning models reject logprobs, so their confidence stays unknown.
+	if ConfidenceFromContext(ctx) != nil && !isReasoningModel(model) {
+		params.Logprobs = openai.Bool(true)
+	}
+	return params
+}
@@ -114,6 +114,30 @@ func TestOpenAIProvider_Fetch(t *testing.T) {
 			}`,
 			ExpectedError: "no choices returned from OpenAI API",
 		},
+		{
+			Name:         "content filter",
+			Input:        "kill -9 everything",
+			SystemPrompt: "test",
+			MockStatus:   http.StatusOK,
+			MockResponse: `{
+				"choices": [
+					{"index": 0, "message": {"role": "assistant", "content": ""}, "finish_reason": "content_filter"}
+				]
+			}`,
+			ExpectedError: "OpenAI declined to answer under its content policy (content_filter)",
+		},
+		{
+			Name:         "refusal",
+			Input:        "kill -9 everything",
+			SystemPrompt: "test",
+			MockStatus:   http.StatusOK,
+			MockResponse: `{
+				"choices": [
+					{"index": 0, "message": {"role": "assistant", "content": null, "refusal": "I can't help with that."}, "finish_reason": "stop"}
+				]
+			}`,
+			ExpectedError: "OpenAI declined to answer under its content policy (I can't help with that.)",
+		},
 	}
 
 	for _, tc := range cases {
@@ -157,3 +181,44 @@ func TestOpenAIProvider_Fetch(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenAIProvider_AccountHeaders(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"=ls"}}]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("OPENAI_API_KEY", "sk-test-key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("OPENAI_ORG_ID", " org-123 ")
+	t.Setenv("OPENAI_PROJECT_ID", "proj_456")
+
+	p, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.Fetch(t.Context(), "list", "system"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if org := got.Get("OpenAI-Organization"); org != "org-123" {
+		t.Errorf("expected OpenAI-Organization org-123, got %q", org)
+	}
+	if project := got.Get("OpenAI-Project"); project != "proj_456" {
+		t.Errorf("expected OpenAI-Project proj_456, got %q", project)
+	}
+
+	// The embedder bills to the same organization and project.
+	e, err := NewOpenAIEmbedder()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.Embed(t.Context(), []string{"ls"})
+	if org := got.Get("OpenAI-Organization"); org != "org-123" {
+		t.Errorf("expected the embedder to send OpenAI-Organization org-123, got %q", org)
+	}
+}
@@ -0,0 +1,23 @@
+package provider
+
+import "context"
+
+// GenerationOptions overrides sampling parameters for a single request.
+// Zero values leave the provider defaults untouched.
+type GenerationOptions struct {
+	MaxTokens   int64
+	Temperature *float64
+}
+
+type generationOptionsKey struct{}
+
+// WithGenerationOptions returns a copy of ctx carrying opts for the provider to apply.
+func WithGenerationOptions(ctx context.Context, opts GenerationOptions) context.Context {
+	return context.WithValue(ctx, generationOptionsKey{}, opts)
+}
+
+// GenerationOptionsFromContext returns the options attached to ctx, if any.
+func GenerationOptionsFromContext(ctx context.Context) GenerationOptions {
+	opts, _ := ctx.Value(generationOptionsKey{}).(GenerationOptions)
+	return opts
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestGenerationOptionsFromContext(t *testing.T) {
+	if got := GenerationOptionsFromContext(t.Context()); got.MaxTokens != 0 || got.Temperature != nil {
+		t.Fatalf("expected zero options, got %+v", got)
+	}
+
+	temperature := 0.0
+	ctx := WithGenerationOptions(t.Context(), GenerationOptions{MaxTokens: 64, Temperature: &temperature})
+	got := GenerationOptionsFromContext(ctx)
+	if got.MaxTokens != 64 {
+		t.Fatalf("expected max tokens 64, got %d", got.MaxTokens)
+	}
+	if got.Temperature == nil || *got.Temperature != 0 {
+		t.Fatalf("expected temperature 0, got %v", got.Temperature)
+	}
+}
+
+func TestOpenAIProvider_FetchAppliesGenerationOptions(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"index": 0, "message": {"role": "assistant", "content": "+la"}}]}`)
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(server.URL),
+	)
+	p := &OpenAIProvider{Model: "custom-model", Client: &client}
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "")
+	t.Setenv("SMART_SUGGESTION_TEMPERATURE", "")
+
+	if _, err := p.Fetch(t.Context(), "ls -", "prompt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := body["max_completion_tokens"]; ok {
+		t.Fatalf("expected no max_completion_tokens without options, got %v", body["max_completion_tokens"])
+	}
+	if _, ok := body["temperature"]; ok {
+		t.Fatalf("expected no temperature without options, got %v", body["temperature"])
+	}
+
+	temperature := 0.0
+	ctx := WithGenerationOptions(t.Context(), GenerationOptions{MaxTokens: 64, Temperature: &temperature})
+	if _, err := p.Fetch(ctx, "ls -", "prompt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["max_completion_tokens"] != float64(64) {
+		t.Fatalf("expected max_completion_tokens 64, got %v", body["max_completion_tokens"])
+	}
+	if body["temperature"] != float64(0) {
+		t.Fatalf("expected temperature 0, got %v", body["temperature"])
+	}
+
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "128")
+	t.Setenv("SMART_SUGGESTION_TEMPERATURE", "0.2")
+	if _, err := p.Fetch(t.Context(), "ls -", "prompt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["max_completion_tokens"] != float64(128) {
+		t.Fatalf("expected max_completion_tokens 128, got %v", body["max_completion_tokens"])
+	}
+	if body["temperature"] != 0.2 {
+		t.Fatalf("expected temperature 0.2, got %v", body["temperature"])
+	}
+}
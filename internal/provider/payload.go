@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders carry credentials and are never printed.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":  true,
+	"Api-Key":        true,
+	"X-Api-Key":      true,
+	"X-Goog-Api-Key": true,
+}
+
+type printPromptKey struct{}
+
+// WithPrintPrompt returns a copy of ctx that makes providers write each request
+// they send to w, exactly as it goes over the wire but with credentials redacted.
+func WithPrintPrompt(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, printPromptKey{}, w)
+}
+
+func printPromptWriter(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(printPromptKey{}).(io.Writer)
+	return w
+}
+
+// printPayload writes req as indented JSON: method, URL, headers and body.
+func printPayload(w io.Writer, req *http.Request) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	headers := make(map[string]string, len(req.Header))
+	for name, values := range req.Header {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			headers[name] = redacted
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	payload := struct {
+		Method  string            `json:"method"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Body    any               `json:"body,omitempty"`
+	}{
+		Method:  req.Method,
+		URL:     redactURL(req.URL),
+		Headers: headers,
+	}
+	if json.Valid(body) {
+		payload.Body = json.RawMessage(body)
+	} else if len(body) > 0 {
+		payload.Body = string(body)
+	}
+
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal request payload: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// requestBody returns the body of req without consuming it.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	redactedAny := false
+	for _, name := range []string{"key", "api-key", "api_key"} {
+		if query.Has(name) {
+			query.Set(name, redacted)
+			redactedAny = true
+		}
+	}
+	if !redactedAny {
+		return u.String()
+	}
+	clone := *u
+	clone.RawQuery = query.Encode()
+	return clone.String()
+}
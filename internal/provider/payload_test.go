@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPrintPrompt(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"=ls"}}]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("OPENAI_API_KEY", "sk-test-key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	p, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	ctx := WithPrintPrompt(t.Context(), &buf)
+	if _, err := p.Fetch(ctx, "list files in /tmp", "You are a shell assistant."); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"You are a shell assistant.", "list files in /tmp", `"method": "POST"`, redacted} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected printed payload to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "sk-test-key") {
+		t.Errorf("expected API key to be redacted, got:\n%s", out)
+	}
+
+	var payload struct {
+		Body struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &payload); err != nil {
+		t.Fatalf("expected JSON output, got %v", err)
+	}
+	if len(payload.Body.Messages) != 2 || payload.Body.Messages[0].Role != "system" {
+		t.Errorf("unexpected messages: %+v", payload.Body.Messages)
+	}
+
+	// Without the option nothing is printed.
+	buf.Reset()
+	if _, err := p.Fetch(t.Context(), "list", "system"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	u, _ := url.Parse("https://example.com/v1/models?alt=sse&key=secret")
+	got := redactURL(u)
+	if strings.Contains(got, "secret") || !strings.Contains(got, "alt=sse") {
+		t.Errorf("unexpected URL: %s", got)
+	}
+
+	u, _ = url.Parse("https://example.com/v1/chat")
+	if got := redactURL(u); got != "https://example.com/v1/chat" {
+		t.Errorf("expected URL unchanged, got %s", got)
+	}
+}
@@ -2,12 +2,17 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
 	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
 )
 
 type Message struct {
-	Role    string // "user" or "assistant"
-	Content string
+	Role    string `json:"role"` // "user" or "assistant"
+	Content string `json:"content"`
 }
 
 type Provider interface {
@@ -15,11 +20,199 @@ type Provider interface {
 	FetchWithHistory(ctx context.Context, input string, systemPrompt string, history []Message) (string, error)
 }
 
+// ErrUnclosedReasoning is returned when a response opens a <reasoning> block
+// but never closes it, usually because the output was cut off.
+var ErrUnclosedReasoning = errors.New("response ended inside an unclosed <reasoning> block")
+
+// ExtractCommand returns the command that follows the reasoning block. A
+// response whose last <reasoning> is never closed yields ErrUnclosedReasoning
+// rather than passing the reasoning off as the command, and a command wrapped
+// in a markdown code fence or a JSON object is unwrapped. Only the first line
+// of a multi-line command is kept; see SanitizeCommand.
+func ExtractCommand(response string) (string, error) {
+	const openingTag, closingTag = "<reasoning>", "</reasoning>"
+	end := strings.LastIndex(response, closingTag)
+	if strings.LastIndex(response, openingTag) > end {
+		return "", ErrUnclosedReasoning
+	}
+	if end != -1 {
+		response = response[end+len(closingTag):]
+	}
+	return SanitizeCommand(unwrapJSONCommand(stripCodeFence(strings.TrimSpace(response)))), nil
+}
+
+// SanitizeCommand strips trailing line breaks from command and cuts it at the
+// first remaining one. The system prompt asks for a single line, and the
+// shell would run every line after the first as soon as the first is
+// accepted, so a multi-line answer is never passed on whole.
+func SanitizeCommand(command string) string {
+	command = strings.TrimRight(command, "\r\n")
+	first, _, found := strings.Cut(command, "\n")
+	if !found {
+		return command
+	}
+	first = strings.TrimRight(first, "\r")
+	debug.Log("Keeping only the first line of a multi-line command", map[string]any{
+		"command": command,
+		"kept":    first,
+	})
+	return first
+}
+
+// fenceLanguagePattern matches the language tag of an opening fence line,
+// such as bash in ```bash.
+var fenceLanguagePattern = regexp.MustCompile(`^[A-Za-z][\w+.-]*$`)
+
+// stripCodeFence removes the markdown code fence some models wrap the answer
+// in, before or after the = or + prefix: =```bash\nls -la\n``` becomes
+// =ls -la. An opening fence line only holding a language tag is dropped, and
+// so is a lone closing fence line. Answers without a fence are returned
+// unchanged.
+func stripCodeFence(answer string) string {
+	prefix, body := "", answer
+	if strings.HasPrefix(body, "=") || strings.HasPrefix(body, "+") {
+		prefix, body = body[:1], strings.TrimSpace(body[1:])
+	}
+
+	lines := strings.Split(body, "\n")
+	fenced := false
+	if len(lines) == 1 {
+		if len(body) > 6 && strings.HasPrefix(body, "```") && strings.HasSuffix(body, "```") {
+			lines[0], fenced = body[3:len(body)-3], true
+		}
+	} else {
+		if strings.TrimSpace(lines[len(lines)-1]) == "```" {
+			lines, fenced = lines[:len(lines)-1], true
+		}
+		if opening, ok := strings.CutPrefix(lines[0], "```"); ok {
+			opening = strings.TrimSpace(opening)
+			fenced = true
+			if (opening == "" || fenceLanguagePattern.MatchString(opening)) && len(lines) > 1 {
+				lines = lines[1:]
+			} else {
+				lines[0] = opening
+			}
+		}
+	}
+	if !fenced {
+		return answer
+	}
+
+	body = strings.TrimRight(strings.Trim(strings.Join(lines, "\n"), "\r\n"), " \t")
+	if prefix != "+" {
+		body = strings.TrimSpace(body)
+	}
+	if strings.HasPrefix(body, "=") || strings.HasPrefix(body, "+") {
+		// The prefix came inside the fence, or twice.
+		return body
+	}
+	return prefix + body
+}
+
+// unwrapJSONCommand returns the command of an answer that is nothing but a
+// JSON object with a "command" or "cmd" string, such as {"command": "ls"},
+// which some models send when the context shows JSON. The command is a new
+// one (=) unless it carries its own prefix. Anything else is returned
+// unchanged: real answers start with = or +, so a command that prints or
+// takes JSON is never mistaken for a wrapper.
+func unwrapJSONCommand(answer string) string {
+	if !strings.HasPrefix(answer, "{") || !strings.HasSuffix(answer, "}") {
+		return answer
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(answer), &fields); err != nil {
+		return answer
+	}
+	for _, value := range fields {
+		switch value.(type) {
+		case map[string]any, []any:
+			// A nested structure is data, not a wrapper around one command.
+			return answer
+		}
+	}
+
+	value, ok := fields["command"]
+	if !ok {
+		value = fields["cmd"]
+	}
+	command, _ := value.(string)
+	command = strings.TrimSpace(command)
+	if command == "" || strings.Contains(command, "\n") {
+		return answer
+	}
+	if !strings.HasPrefix(command, "=") && !strings.HasPrefix(command, "+") {
+		command = "=" + command
+	}
+	return command
+}
+
+// ParseAndExtractCommand is ExtractCommand for callers that only need the
+// command: a response with unclosed reasoning yields "".
 func ParseAndExtractCommand(response string) string {
-	closingTag := "</reasoning>"
-	if pos := strings.LastIndex(response, closingTag); pos != -1 {
-		commandPart := response[pos+len(closingTag):]
-		return strings.TrimSpace(commandPart)
+	command, _ := ExtractCommand(response)
+	return command
+}
+
+// ExtractReasoning returns the content of the <reasoning> block, or "" if the
+// response has no complete block.
+func ExtractReasoning(response string) string {
+	const openingTag, closingTag = "<reasoning>", "</reasoning>"
+	start := strings.Index(response, openingTag)
+	if start == -1 {
+		return ""
+	}
+	end := strings.LastIndex(response, closingTag)
+	if end < start {
+		return ""
+	}
+	return strings.TrimSpace(response[start+len(openingTag) : end])
+}
+
+// ModelName returns the model (or Azure deployment) p sends requests to, or ""
+// for providers that do not expose one.
+func ModelName(p Provider) string {
+	switch p := p.(type) {
+	case *OpenAIProvider:
+		return p.Model
+	case *AzureOpenAIProvider:
+		return p.DeploymentName
+	case *AnthropicProvider:
+		return p.Model
+	case *GeminiProvider:
+		return p.Model
+	case *FallbackProvider:
+		// Named after the provider that is tried first.
+		if len(p.Providers) == 0 {
+			return ""
+		}
+		return ModelName(p.Providers[0])
+	default:
+		return ""
+	}
+}
+
+// WithModel returns a copy of p that sends requests to model (an Azure
+// deployment for Azure OpenAI) with the same credentials and endpoint, and
+// false for providers that do not expose one.
+func WithModel(p Provider, model string) (Provider, bool) {
+	switch p := p.(type) {
+	case *OpenAIProvider:
+		copied := *p
+		copied.Model = model
+		return &copied, true
+	case *AzureOpenAIProvider:
+		copied := *p
+		copied.DeploymentName = model
+		return &copied, true
+	case *AnthropicProvider:
+		copied := *p
+		copied.Model = model
+		return &copied, true
+	case *GeminiProvider:
+		copied := *p
+		copied.Model = model
+		return &copied, true
+	default:
+		return nil, false
 	}
-	return strings.TrimSpace(response)
 }
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -71,6 +72,11 @@ func TestParseAndExtractCommand(t *testing.T) {
 			input:    "<reasoning>\nthinking\nmore\n</reasoning>\nls -la",
 			expected: "ls -la",
 		},
+		{
+			name:     "truncated reasoning",
+			input:    "<reasoning>\n1. The user wants to list files.\n2. ls -la shows",
+			expected: "",
+		},
 	}
 
 	for _, tt := range tests {
@@ -82,3 +88,184 @@ func TestParseAndExtractCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "complete", input: "<reasoning>list</reasoning>=ls", expected: "=ls"},
+		{name: "no reasoning", input: "=ls", expected: "=ls"},
+		{name: "truncated before closing tag", input: "<reasoning>\n1. The user wants to", wantErr: true},
+		{name: "truncated inside closing tag", input: "<reasoning>thinking</reas", wantErr: true},
+		{name: "only an opening tag", input: "<reasoning>", wantErr: true},
+		{name: "second block left open", input: "<reasoning>a</reasoning>=ls\n<reasoning>more", wantErr: true},
+		{name: "closed block mentioning the tag", input: "<reasoning>I will not use <reasoning> again</reasoning>+ -la", expected: "+ -la"},
+		{name: "JSON command", input: `{"command": "ls -la"}`, expected: "=ls -la"},
+		{name: "JSON cmd after reasoning", input: "<reasoning>list</reasoning>\n{\"cmd\": \"git status\"}", expected: "=git status"},
+		{name: "JSON command with prefix", input: `{"command": "+ -la", "explanation": "long listing"}`, expected: "+ -la"},
+		{name: "JSON with nested data", input: `{"command": "ls", "args": ["-la"]}`, expected: `{"command": "ls", "args": ["-la"]}`},
+		{name: "JSON without command", input: `{"name": "ls"}`, expected: `{"name": "ls"}`},
+		{name: "JSON with multi-line command", input: `{"command": "cd /\nrm -rf *"}`, expected: `{"command": "cd /\nrm -rf *"}`},
+		{name: "command taking JSON", input: `=curl -d '{"command": "ls"}' localhost`, expected: `=curl -d '{"command": "ls"}' localhost`},
+		{name: "invalid JSON", input: `{"command": "ls"`, expected: `{"command": "ls"`},
+		{name: "JSON in a fence", input: "```json\n{\"command\": \"ls\"}\n```", expected: "=ls"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractCommand(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnclosedReasoning) {
+					t.Fatalf("expected ErrUnclosedReasoning, got %v (command %q)", err, got)
+				}
+				if got != "" {
+					t.Errorf("expected no command, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExtractCommandCodeFence(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "fence after prefix", input: "=```bash\nls -la\n```", expected: "=ls -la"},
+		{name: "prefix inside fence", input: "```bash\n=ls -la\n```", expected: "=ls -la"},
+		{name: "fence without language", input: "=```\nls -la\n```", expected: "=ls -la"},
+		{name: "prefix on both sides", input: "=```sh\n=ls -la\n```", expected: "=ls -la"},
+		{name: "single line", input: "=```ls -la```", expected: "=ls -la"},
+		{name: "single line with space after prefix", input: "= ```git status```", expected: "=git status"},
+		{name: "after reasoning", input: "<reasoning>list</reasoning>\n```zsh\n=ls -la\n```", expected: "=ls -la"},
+		{name: "completion keeps its leading space", input: "+```\n -la\n```", expected: "+ -la"},
+		{name: "command on the opening line", input: "```=ls -la\n```", expected: "=ls -la"},
+		{name: "missing closing fence", input: "=```bash\nls -la", expected: "=ls -la"},
+		{name: "trailing fence line only", input: "=ls -la\n```", expected: "=ls -la"},
+		{name: "multi-line keeps the first line", input: "=```bash\nfor f in *; do\n  echo $f\ndone\n```", expected: "=for f in *; do"},
+		{name: "multi-line with CRLF", input: "```bash\r\n=ls -la\r\n```", expected: "=ls -la"},
+		{name: "backticks in the command", input: "=echo ```", expected: "=echo ```"},
+		{name: "command substitution", input: "=echo `date`", expected: "=echo `date`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractCommand(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("ExtractCommand(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSanitizeCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "single line", input: "=ls -la", expected: "=ls -la"},
+		{name: "trailing newlines", input: "=ls -la\n\n", expected: "=ls -la"},
+		{name: "trailing CRLF", input: "+ -la\r\n", expected: "+ -la"},
+		{name: "second command", input: "=cd /\nrm -rf *", expected: "=cd /"},
+		{name: "CRLF between lines", input: "=git add .\r\ngit commit", expected: "=git add ."},
+		{name: "completion keeps its leading space", input: "+ -la\nrm -rf ~", expected: "+ -la"},
+		{name: "empty", input: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeCommand(tt.input); got != tt.expected {
+				t.Errorf("SanitizeCommand(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractCommandKeepsFirstLine(t *testing.T) {
+	got, err := ExtractCommand("<reasoning>clean up</reasoning>=cd /tmp\nrm -rf *")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "=cd /tmp" {
+		t.Errorf("expected only the first line, got %q", got)
+	}
+}
+
+func TestExtractReasoning(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "no reasoning", input: "=ls -la", expected: ""},
+		{name: "with reasoning", input: "<reasoning>thinking...</reasoning>=ls -la", expected: "thinking..."},
+		{name: "multiline reasoning", input: "<reasoning>\n1. a\n2. b\n</reasoning>\n=ls", expected: "1. a\n2. b"},
+		{name: "unclosed reasoning", input: "<reasoning>thinking", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractReasoning(tt.input); got != tt.expected {
+				t.Errorf("ExtractReasoning(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestModelName(t *testing.T) {
+	cases := []struct {
+		provider Provider
+		expected string
+	}{
+		{provider: &OpenAIProvider{Model: "gpt-4o-mini"}, expected: "gpt-4o-mini"},
+		{provider: &AzureOpenAIProvider{DeploymentName: "prod-gpt"}, expected: "prod-gpt"},
+		{provider: &AnthropicProvider{Model: "claude-sonnet-4"}, expected: "claude-sonnet-4"},
+		{provider: &GeminiProvider{Model: "gemini-2.5-flash"}, expected: "gemini-2.5-flash"},
+		{provider: nil, expected: ""},
+	}
+	for _, tc := range cases {
+		if got := ModelName(tc.provider); got != tc.expected {
+			t.Fatalf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestWithModel(t *testing.T) {
+	for _, original := range []Provider{
+		&OpenAIProvider{Model: "gpt-4o"},
+		&AzureOpenAIProvider{DeploymentName: "prod-gpt"},
+		&AnthropicProvider{Model: "claude-sonnet-4"},
+		&GeminiProvider{Model: "gemini-2.5-pro"},
+	} {
+		before := ModelName(original)
+		reviewer, ok := WithModel(original, "cheap-model")
+		if !ok {
+			t.Fatalf("%T: expected a model override to be supported", original)
+		}
+		if got := ModelName(reviewer); got != "cheap-model" {
+			t.Errorf("%T: expected the copy to use cheap-model, got %q", original, got)
+		}
+		if got := ModelName(original); got != before {
+			t.Errorf("%T: expected the original to keep %q, got %q", original, before, got)
+		}
+	}
+
+	if _, ok := WithModel(nil, "cheap-model"); ok {
+		t.Error("expected no override for an unknown provider")
+	}
+}
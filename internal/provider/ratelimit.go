@@ -0,0 +1,210 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/paths"
+)
+
+const (
+	// defaultRateLimitWindow is assumed when a provider reports no remaining
+	// requests without saying when the limit resets.
+	defaultRateLimitWindow = time.Minute
+	// maxCooldown bounds how long a single response can block suggestions.
+	maxCooldown = 10 * time.Minute
+)
+
+// rateLimitHeaders pairs "remaining" headers with the header telling when that
+// limit resets, for the conventions used by the supported providers.
+var rateLimitHeaders = []struct {
+	remaining string
+	reset     string
+}{
+	{"x-ratelimit-remaining", "x-ratelimit-reset"},
+	{"x-ratelimit-remaining-requests", "x-ratelimit-reset-requests"},
+	{"x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens"},
+	{"anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset"},
+	{"anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset"},
+}
+
+// RateLimitedError is returned while a provider is cooling down after it
+// reported that its rate limit was reached.
+type RateLimitedError struct {
+	Provider   string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	return fmt.Sprintf("%s rate limited, retry in %ds", e.Provider, seconds)
+}
+
+func rateLimitFile() string {
+	return filepath.Join(paths.GetCacheDir(), "ratelimit.json")
+}
+
+func readCooldowns() map[string]time.Time {
+	cooldowns := make(map[string]time.Time)
+	data, err := os.ReadFile(rateLimitFile())
+	if err != nil {
+		return cooldowns
+	}
+	if err := json.Unmarshal(data, &cooldowns); err != nil {
+		debug.Log("Ignoring invalid rate limit file", map[string]any{"error": err.Error()})
+		return make(map[string]time.Time)
+	}
+	return cooldowns
+}
+
+func writeCooldowns(cooldowns map[string]time.Time) error {
+	path := rateLimitFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cooldowns)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// CheckCooldown returns a *RateLimitedError when providerName is still within
+// a cooldown recorded from an earlier response.
+func CheckCooldown(providerName string) error {
+	providerName = strings.ToLower(providerName)
+	until, ok := readCooldowns()[providerName]
+	if !ok {
+		return nil
+	}
+	if wait := time.Until(until); wait > 0 {
+		return &RateLimitedError{Provider: providerName, RetryAfter: wait}
+	}
+	return nil
+}
+
+// RecordCooldown blocks requests to providerName until the given time. A zero
+// time clears the cooldown.
+func RecordCooldown(providerName string, until time.Time) error {
+	providerName = strings.ToLower(providerName)
+	cooldowns := readCooldowns()
+	if until.IsZero() {
+		if _, ok := cooldowns[providerName]; !ok {
+			return nil
+		}
+		delete(cooldowns, providerName)
+	} else {
+		cooldowns[providerName] = until
+	}
+	return writeCooldowns(cooldowns)
+}
+
+// cooldownFromHeaders works out until when no further request should be sent,
+// from retry-after or from a rate limit with nothing remaining.
+func cooldownFromHeaders(h http.Header, now time.Time) (time.Time, bool) {
+	wait, _ := retryAfter(h, now)
+	for _, header := range rateLimitHeaders {
+		if strings.TrimSpace(h.Get(header.remaining)) != "0" {
+			continue
+		}
+		reset, ok := parseRateLimitReset(h.Get(header.reset), now)
+		if !ok {
+			reset = defaultRateLimitWindow
+		}
+		wait = max(wait, reset)
+	}
+
+	if wait <= 0 {
+		return time.Time{}, false
+	}
+	return now.Add(min(wait, maxCooldown)), true
+}
+
+// retryAfter returns how long retry-after-ms or Retry-After (in seconds or as
+// an HTTP date) asks to wait, and false when neither is set.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	if v := h.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	} else if v := h.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), true
+		} else if t, err := http.ParseTime(v); err == nil {
+			return t.Sub(now), true
+		}
+	}
+	return 0, false
+}
+
+// parseRateLimitReset understands durations ("6m0s", as OpenAI sends them),
+// seconds, Unix timestamps and RFC 3339 times (as Anthropic sends them).
+func parseRateLimitReset(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, true
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		if n > 1e9 {
+			return time.Unix(int64(n), 0).Sub(now), true
+		}
+		return time.Duration(n * float64(time.Second)), true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// providerTransport prints requests when asked to through WithPrintPrompt,
+// records a cooldown for its provider whenever a response says the rate limit
+// was reached, and clears it after a successful response.
+type providerTransport struct {
+	provider string
+	base     http.RoundTripper
+}
+
+func (t *providerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if w := printPromptWriter(req.Context()); w != nil {
+		if err := printPayload(w, req); err != nil {
+			debug.Log("Failed to print request payload", map[string]any{"error": err.Error()})
+		}
+	}
+
+	resp, err := sendWithRetries(req, t.base.RoundTrip)
+	if err != nil {
+		return resp, err
+	}
+
+	until, limited := cooldownFromHeaders(resp.Header, time.Now())
+	switch {
+	case limited:
+		debug.Log("Provider rate limit reached", map[string]any{
+			"provider": t.provider,
+			"until":    until,
+		})
+		err = RecordCooldown(t.provider, until)
+	case resp.StatusCode < 300:
+		err = RecordCooldown(t.provider, time.Time{})
+	}
+	if err != nil {
+		debug.Log("Failed to update rate limit file", map[string]any{"error": err.Error()})
+	}
+	return resp, nil
+}
+
+// newProviderHTTPClient returns the HTTP client providers send requests with.
+func newProviderHTTPClient(providerName string) *http.Client {
+	return &http.Client{Transport: &providerTransport{provider: providerName, base: newProviderTransport()}}
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCooldownFromHeaders(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+		limited bool
+	}{
+		{name: "no headers"},
+		{name: "requests remaining", headers: map[string]string{"x-ratelimit-remaining-requests": "12", "x-ratelimit-reset-requests": "1s"}},
+		{name: "retry-after seconds", headers: map[string]string{"Retry-After": "30"}, want: 30 * time.Second, limited: true},
+		{name: "retry-after date", headers: map[string]string{"Retry-After": now.Add(45 * time.Second).Format(http.TimeFormat)}, want: 45 * time.Second, limited: true},
+		{name: "retry-after-ms", headers: map[string]string{"retry-after-ms": "1500", "Retry-After": "9"}, want: 1500 * time.Millisecond, limited: true},
+		{name: "openai requests exhausted", headers: map[string]string{"x-ratelimit-remaining-requests": "0", "x-ratelimit-reset-requests": "6m0s"}, want: 6 * time.Minute, limited: true},
+		{name: "generic exhausted with seconds", headers: map[string]string{"x-ratelimit-remaining": "0", "x-ratelimit-reset": "20"}, want: 20 * time.Second, limited: true},
+		{name: "generic exhausted with timestamp", headers: map[string]string{"x-ratelimit-remaining": "0", "x-ratelimit-reset": fmt.Sprint(now.Add(2 * time.Minute).Unix())}, want: 2 * time.Minute, limited: true},
+		{name: "anthropic exhausted", headers: map[string]string{"anthropic-ratelimit-requests-remaining": "0", "anthropic-ratelimit-requests-reset": now.Add(40 * time.Second).Format(time.RFC3339)}, want: 40 * time.Second, limited: true},
+		{name: "exhausted without reset", headers: map[string]string{"x-ratelimit-remaining": "0"}, want: defaultRateLimitWindow, limited: true},
+		{name: "longest wait wins", headers: map[string]string{"Retry-After": "5", "x-ratelimit-remaining-tokens": "0", "x-ratelimit-reset-tokens": "50s"}, want: 50 * time.Second, limited: true},
+		{name: "capped", headers: map[string]string{"Retry-After": "86400"}, want: maxCooldown, limited: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			until, limited := cooldownFromHeaders(h, now)
+			if limited != tt.limited {
+				t.Fatalf("expected limited=%v, got %v", tt.limited, limited)
+			}
+			if limited && until.Sub(now) != tt.want {
+				t.Errorf("expected cooldown %s, got %s", tt.want, until.Sub(now))
+			}
+		})
+	}
+}
+
+func TestCheckCooldown(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	if err := CheckCooldown("openai"); err != nil {
+		t.Fatalf("expected no cooldown, got %v", err)
+	}
+
+	if err := RecordCooldown("openai", time.Now().Add(30*time.Second)); err != nil {
+		t.Fatalf("RecordCooldown failed: %v", err)
+	}
+	err := CheckCooldown("OpenAI")
+	var limited *RateLimitedError
+	if !errors.As(err, &limited) {
+		t.Fatalf("expected RateLimitedError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "rate limited, retry in 30s") {
+		t.Errorf("unexpected message: %q", err.Error())
+	}
+	if err := CheckCooldown("anthropic"); err != nil {
+		t.Errorf("expected other providers to be unaffected, got %v", err)
+	}
+
+	if err := RecordCooldown("openai", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("RecordCooldown failed: %v", err)
+	}
+	if err := CheckCooldown("openai"); err != nil {
+		t.Errorf("expected expired cooldown to be ignored, got %v", err)
+	}
+}
+
+func TestOpenAIProvider_RateLimitHeaders(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	exhausted := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exhausted {
+			w.Header().Set("x-ratelimit-remaining-requests", "0")
+			w.Header().Set("x-ratelimit-reset-requests", "20s")
+		} else {
+			w.Header().Set("x-ratelimit-remaining-requests", "99")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"=ls"}}]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("OPENAI_API_KEY", "sk-test-key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	p, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.Fetch(t.Context(), "list", "system"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = CheckCooldown("openai")
+	if err == nil || !strings.Contains(err.Error(), "openai rate limited, retry in 20s") {
+		t.Fatalf("expected cooldown after exhausted limit, got %v", err)
+	}
+
+	// A later successful response without an exhausted limit lifts the cooldown.
+	exhausted = false
+	if _, err := p.Fetch(t.Context(), "list", "system"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := CheckCooldown("openai"); err != nil {
+		t.Errorf("expected cooldown to be cleared, got %v", err)
+	}
+}
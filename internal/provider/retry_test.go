@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newFlakyServer fails the first failures requests with status and headers,
+// then answers like OpenAI or Gemini. It returns the number of requests.
+func newFlakyServer(t *testing.T, failures int, status int, headers map[string]string) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			for key, value := range headers {
+				w.Header().Set(key, value)
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"error": {"message": "try again"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"index": 0, "message": {"role": "assistant", "content": "=ls"}}], "candidates": [{"content": {"role": "model", "parts": [{"text": "=ls"}]}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newRetryTestProvider(t *testing.T, baseURL string) *OpenAIProvider {
+	t.Helper()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	oldDelay := retryBaseDelay
+	t.Cleanup(func() { retryBaseDelay = oldDelay })
+	retryBaseDelay = time.Millisecond
+
+	client := newOpenAIClient("openai", "test-key", baseURL, nil, 0)
+	return &OpenAIProvider{Model: "gpt-4o-mini", Client: &client}
+}
+
+func TestRetriesTransientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			server, requests := newFlakyServer(t, 2, status, nil)
+			p := newRetryTestProvider(t, server.URL)
+			t.Setenv(RetriesEnv, "")
+
+			if _, err := p.Fetch(t.Context(), "ls", "prompt"); err != nil {
+				t.Fatalf("expected the second retry to succeed, got %v", err)
+			}
+			if *requests != 3 {
+				t.Errorf("expected 3 requests, got %d", *requests)
+			}
+		})
+	}
+}
+
+func TestRetriesLimit(t *testing.T) {
+	server, requests := newFlakyServer(t, 5, http.StatusBadGateway, nil)
+	p := newRetryTestProvider(t, server.URL)
+
+	t.Setenv(RetriesEnv, "1")
+	if _, err := p.Fetch(t.Context(), "ls", "prompt"); err == nil {
+		t.Fatal("expected an error once the retries are used up")
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests, got %d", *requests)
+	}
+
+	// The SDK's own retries are off too, so 0 means a single request.
+	*requests = 0
+	t.Setenv(RetriesEnv, "0")
+	if _, err := p.Fetch(t.Context(), "ls", "prompt"); err == nil {
+		t.Fatal("expected an error without retries")
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+}
+
+func TestRetriesSkipPermanentErrors(t *testing.T) {
+	server, requests := newFlakyServer(t, 1, http.StatusBadRequest, nil)
+	p := newRetryTestProvider(t, server.URL)
+
+	if _, err := p.Fetch(t.Context(), "ls", "prompt"); err == nil {
+		t.Fatal("expected the bad request to fail")
+	}
+	if *requests != 1 {
+		t.Errorf("expected no retry, got %d requests", *requests)
+	}
+}
+
+func TestRetriesRespectRetryAfter(t *testing.T) {
+	server, requests := newFlakyServer(t, 1, http.StatusTooManyRequests, map[string]string{"retry-after-ms": "50"})
+	p := newRetryTestProvider(t, server.URL)
+
+	start := time.Now()
+	if _, err := p.Fetch(t.Context(), "ls", "prompt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected the retry to wait 50ms, waited %s", elapsed)
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests, got %d", *requests)
+	}
+
+	// A wait longer than maxRetryDelay is left to the rate limit cooldown.
+	server, requests = newFlakyServer(t, 1, http.StatusTooManyRequests, map[string]string{"Retry-After": "60"})
+	p = newRetryTestProvider(t, server.URL)
+	if _, err := p.Fetch(t.Context(), "ls", "prompt"); err == nil {
+		t.Fatal("expected the rate limit error")
+	}
+	if *requests != 1 {
+		t.Errorf("expected no retry, got %d requests", *requests)
+	}
+	if err := CheckCooldown("openai"); err == nil {
+		t.Error("expected a cooldown after the unretried rate limit")
+	}
+}
+
+func TestRetriesStopWithContext(t *testing.T) {
+	server, requests := newFlakyServer(t, 5, http.StatusServiceUnavailable, map[string]string{"Retry-After": "5"})
+	p := newRetryTestProvider(t, server.URL)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.Fetch(ctx, "ls", "prompt"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the wait to end with the context, took %s", elapsed)
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+}
+
+func TestGeminiRetriesTransientErrors(t *testing.T) {
+	server, requests := newFlakyServer(t, 1, http.StatusServiceUnavailable, nil)
+	newRetryTestProvider(t, server.URL)
+	client, err := newGeminiClient(t.Context(), "gemini", "test-key", server.URL, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &GeminiProvider{Model: "gemini-2.5-flash", Client: client}
+
+	if _, err := p.Fetch(t.Context(), "ls", "prompt"); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests, got %d", *requests)
+	}
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProviderRule selects a provider when the working directory matches Pattern.
+type ProviderRule struct {
+	Pattern  string
+	Provider string
+}
+
+// ParseProviderRules parses rules of the form "pattern -> provider", separated
+// by newlines or semicolons. Blank entries and lines starting with # are ignored.
+func ParseProviderRules(spec string) ([]ProviderRule, error) {
+	var rules []ProviderRule
+	entries := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == '\n' || r == ';'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		pattern, name, ok := strings.Cut(entry, "->")
+		pattern = strings.TrimSpace(pattern)
+		name = strings.TrimSpace(name)
+		if !ok || pattern == "" || name == "" {
+			return nil, fmt.Errorf("invalid provider rule %q (expected \"pattern -> provider\")", entry)
+		}
+		rules = append(rules, ProviderRule{Pattern: pattern, Provider: name})
+	}
+	return rules, nil
+}
+
+// MatchProviderRule returns the provider of the first rule matching dir, or "".
+//
+// Relative patterns and patterns starting with ~ are resolved against the home
+// directory. A pattern matches dir itself and every directory below it, so
+// "work/*" covers ~/work/foo and ~/work/foo/bar alike.
+func MatchProviderRule(rules []ProviderRule, dir string) string {
+	home, _ := os.UserHomeDir()
+	dir = filepath.Clean(dir)
+	for _, rule := range rules {
+		if matchDirPattern(expandRulePattern(rule.Pattern, home), dir) {
+			return rule.Provider
+		}
+	}
+	return ""
+}
+
+// ParseDirPatterns splits a list of directory globs separated by newlines or
+// semicolons. Blank entries and lines starting with # are ignored.
+func ParseDirPatterns(spec string) []string {
+	var patterns []string
+	entries := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == '\n' || r == ';'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		patterns = append(patterns, entry)
+	}
+	return patterns
+}
+
+// MatchDirPatterns reports whether dir matches any of patterns, following the
+// same rules as MatchProviderRule.
+func MatchDirPatterns(patterns []string, dir string) bool {
+	home, _ := os.UserHomeDir()
+	dir = filepath.Clean(dir)
+	for _, pattern := range patterns {
+		if matchDirPattern(expandRulePattern(pattern, home), dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func expandRulePattern(pattern string, home string) string {
+	switch {
+	case pattern == "~":
+		pattern = home
+	case strings.HasPrefix(pattern, "~/"):
+		pattern = filepath.Join(home, pattern[2:])
+	case !filepath.IsAbs(pattern):
+		pattern = filepath.Join(home, pattern)
+	}
+	pattern = strings.TrimSuffix(pattern, "/**")
+	return filepath.Clean(pattern)
+}
+
+func matchDirPattern(pattern string, dir string) bool {
+	patternParts := strings.Split(pattern, string(filepath.Separator))
+	dirParts := strings.Split(dir, string(filepath.Separator))
+	if len(dirParts) < len(patternParts) {
+		return false
+	}
+	prefix := strings.Join(dirParts[:len(patternParts)], string(filepath.Separator))
+	matched, err := filepath.Match(pattern, prefix)
+	return err == nil && matched
+}
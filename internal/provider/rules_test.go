@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProviderRules(t *testing.T) {
+	rules, err := ParseProviderRules("work/* -> azure_openai; personal -> openai\n# comment\n\n/srv/** -> gemini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []ProviderRule{
+		{Pattern: "work/*", Provider: "azure_openai"},
+		{Pattern: "personal", Provider: "openai"},
+		{Pattern: "/srv/**", Provider: "gemini"},
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %d rules, got %d", len(expected), len(rules))
+	}
+	for i := range expected {
+		if rules[i] != expected[i] {
+			t.Fatalf("rule %d: expected %+v, got %+v", i, expected[i], rules[i])
+		}
+	}
+
+	if rules, err := ParseProviderRules(""); err != nil || len(rules) != 0 {
+		t.Fatalf("expected no rules for empty spec, got %v, %v", rules, err)
+	}
+
+	if _, err := ParseProviderRules("work/* azure_openai"); err == nil {
+		t.Fatal("expected error for rule without arrow")
+	}
+	if _, err := ParseProviderRules("work/* -> "); err == nil {
+		t.Fatal("expected error for rule without provider")
+	}
+}
+
+func TestMatchProviderRule(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	rules := []ProviderRule{
+		{Pattern: "work/*", Provider: "azure_openai"},
+		{Pattern: "~/personal", Provider: "openai"},
+		{Pattern: "/srv/**", Provider: "gemini"},
+	}
+
+	cases := []struct {
+		name     string
+		dir      string
+		expected string
+	}{
+		{name: "work project", dir: filepath.Join(home, "work", "api"), expected: "azure_openai"},
+		{name: "work nested", dir: filepath.Join(home, "work", "api", "cmd"), expected: "azure_openai"},
+		{name: "work root only", dir: filepath.Join(home, "work"), expected: ""},
+		{name: "personal root", dir: filepath.Join(home, "personal"), expected: "openai"},
+		{name: "personal nested", dir: filepath.Join(home, "personal", "blog"), expected: "openai"},
+		{name: "personal prefix only", dir: filepath.Join(home, "personalities"), expected: ""},
+		{name: "absolute", dir: "/srv/data", expected: "gemini"},
+		{name: "no match", dir: filepath.Join(home, "other"), expected: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := MatchProviderRule(rules, tc.dir); got != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseDirPatterns(t *testing.T) {
+	patterns := ParseDirPatterns("~/.ssh; /vault/**\n# comment\n\n secrets ")
+	expected := []string{"~/.ssh", "/vault/**", "secrets"}
+	if len(patterns) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, patterns)
+	}
+	for i := range expected {
+		if patterns[i] != expected[i] {
+			t.Fatalf("pattern %d: expected %q, got %q", i, expected[i], patterns[i])
+		}
+	}
+}
+
+func TestMatchDirPatterns(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	patterns := []string{"~/.ssh", "/vault/**"}
+	if !MatchDirPatterns(patterns, filepath.Join(home, ".ssh")) {
+		t.Fatal("expected ~/.ssh to match")
+	}
+	if !MatchDirPatterns(patterns, "/vault/team/keys") {
+		t.Fatal("expected /vault subdirectory to match")
+	}
+	if MatchDirPatterns(patterns, filepath.Join(home, "src")) {
+		t.Fatal("expected ~/src not to match")
+	}
+	if MatchDirPatterns(nil, "/vault") {
+		t.Fatal("expected no match without patterns")
+	}
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretCommandPrefix marks a setting whose value is the output of a command,
+// such as "cmd:op read op://vault/openai/key", so keys and URLs can come from
+// any secret manager.
+const secretCommandPrefix = "cmd:"
+
+var (
+	execCommandContext = exec.CommandContext
+	// secretCommandTimeout bounds each command, which may be waiting for the
+	// user to unlock the secret manager.
+	secretCommandTimeout = 10 * time.Second
+
+	// secretCache holds command outputs by command line, so a command shared by
+	// several settings, or needed again by a fallback provider, runs only once.
+	secretCacheMu sync.Mutex
+	secretCache   = make(map[string]string)
+)
+
+// envValue returns the environment variable name, or the LLM_* variable
+// standing in for it, resolved by resolveValue.
+func envValue(name string) (string, error) {
+	value, source := providerEnv(name)
+	return resolveValue(source, value)
+}
+
+// resolveValue returns value unchanged unless it starts with "cmd:", in which
+// case the rest is run with sh -c and its trimmed output is returned. name
+// identifies the setting in errors.
+func resolveValue(name, value string) (string, error) {
+	command, ok := strings.CutPrefix(value, secretCommandPrefix)
+	if !ok {
+		return value, nil
+	}
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return "", fmt.Errorf("%s has an empty %s command", name, secretCommandPrefix)
+	}
+
+	secretCacheMu.Lock()
+	defer secretCacheMu.Unlock()
+	if cached, ok := secretCache[command]; ok {
+		return cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := execCommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = secretCommandTimeout
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to run the command for %s: %w", name, err)
+	}
+	err := cmd.Wait()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("the command for %s timed out after %s", name, secretCommandTimeout)
+	}
+	if err != nil {
+		// stderr may say what went wrong, stdout could hold part of a secret.
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("the command for %s failed: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("the command for %s failed: %w", name, err)
+	}
+
+	secret := strings.TrimSpace(stdout.String())
+	if secret == "" {
+		return "", fmt.Errorf("the command for %s printed nothing", name)
+	}
+	secretCache[command] = secret
+	return secret, nil
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetSecretCache forgets command outputs cached by earlier tests.
+func resetSecretCache(t *testing.T) {
+	t.Helper()
+	secretCacheMu.Lock()
+	secretCache = make(map[string]string)
+	secretCacheMu.Unlock()
+	t.Cleanup(func() {
+		secretCacheMu.Lock()
+		secretCache = make(map[string]string)
+		secretCacheMu.Unlock()
+	})
+}
+
+func TestResolveValue(t *testing.T) {
+	resetSecretCache(t)
+
+	if got, err := resolveValue("OPENAI_API_KEY", "sk-plain"); err != nil || got != "sk-plain" {
+		t.Errorf("expected plain values unchanged, got %q, %v", got, err)
+	}
+
+	got, err := resolveValue("OPENAI_API_KEY", "cmd: printf '  sk-from-command\\n'")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "sk-from-command" {
+		t.Errorf("expected the trimmed command output, got %q", got)
+	}
+
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{value: "cmd:", wantErr: "empty cmd: command"},
+		{value: "cmd:true", wantErr: "printed nothing"},
+		{value: "cmd:echo locked >&2; exit 3", wantErr: "failed: exit status 3: locked"},
+	}
+	for _, tt := range tests {
+		if _, err := resolveValue("OPENAI_API_KEY", tt.value); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.value, tt.wantErr, err)
+		}
+	}
+}
+
+func TestResolveValueCachesCommands(t *testing.T) {
+	resetSecretCache(t)
+	runs := filepath.Join(t.TempDir(), "runs")
+	command := "cmd:echo run >> " + runs + "; echo sk-cached"
+
+	for range 2 {
+		if got, err := resolveValue("OPENAI_API_KEY", command); err != nil || got != "sk-cached" {
+			t.Fatalf("expected the command output, got %q, %v", got, err)
+		}
+	}
+	data, err := os.ReadFile(runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "run") != 1 {
+		t.Errorf("expected the command to run once, got %q", data)
+	}
+}
+
+func TestResolveValueTimeout(t *testing.T) {
+	resetSecretCache(t)
+	oldTimeout := secretCommandTimeout
+	t.Cleanup(func() { secretCommandTimeout = oldTimeout })
+	secretCommandTimeout = 50 * time.Millisecond
+
+	_, err := resolveValue("OPENAI_API_KEY", "cmd:sleep 5")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+}
+
+func TestNewOpenAIProvider_CommandValues(t *testing.T) {
+	resetSecretCache(t)
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	t.Setenv("OPENAI_API_KEYS", "")
+	t.Setenv("OPENAI_API_KEY_FILE", "")
+	t.Setenv("OPENAI_API_KEY", "cmd:echo sk-from-vault")
+	t.Setenv("OPENAI_BASE_URL", "cmd:echo https://gateway.example.com/v1")
+
+	if _, err := NewOpenAIProvider(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key, err := resolveSecret("OPENAI_API_KEY"); err != nil || key != "sk-from-vault" {
+		t.Errorf("expected the key from the command, got %q, %v", key, err)
+	}
+
+	t.Setenv("OPENAI_BASE_URL", "cmd:exit 1")
+	if _, err := NewOpenAIProvider(); err == nil || !strings.Contains(err.Error(), "OPENAI_BASE_URL") {
+		t.Errorf("expected a failing base URL command to be reported, got %v", err)
+	}
+}
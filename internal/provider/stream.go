@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"context"
+	"strings"
+)
+
+// StreamChunk is a piece of a streamed response. A stream that fails ends
+// with a chunk carrying Err.
+type StreamChunk struct {
+	Text string
+	Err  error
+}
+
+// StreamingProvider is implemented by providers that can send their response
+// as it is generated.
+type StreamingProvider interface {
+	Provider
+	// FetchStream sends the request and returns the response as a channel of
+	// chunks, closed when the response is complete. Errors before the first
+	// chunk are returned directly. A stream cut short by ctx may close without
+	// an error chunk, so callers check ctx.Err() once it is closed.
+	FetchStream(ctx context.Context, input string, systemPrompt string, history []Message) (<-chan StreamChunk, error)
+}
+
+// FetchStream streams the response from p, or, when p cannot stream, sends its
+// whole response as a single chunk.
+func FetchStream(ctx context.Context, p Provider, input string, systemPrompt string, history []Message) (<-chan StreamChunk, error) {
+	if streaming, ok := p.(StreamingProvider); ok {
+		return streaming.FetchStream(ctx, input, systemPrompt, history)
+	}
+
+	response, err := p.FetchWithHistory(ctx, input, systemPrompt, history)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make(chan StreamChunk, 1)
+	chunks <- StreamChunk{Text: response}
+	close(chunks)
+	return chunks, nil
+}
+
+// sendChunk sends chunk unless ctx is done first, and reports whether it did.
+func sendChunk(ctx context.Context, chunks chan<- StreamChunk, chunk StreamChunk) bool {
+	select {
+	case chunks <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// StreamParser extracts the command from a response as it streams in. The
+// reasoning block is held back however the chunks split its tags, and the
+// command is released as soon as it starts after </reasoning>, or at once for
+// a response without reasoning.
+//
+// What it releases is a preview: ExtractCommand on the complete Response is
+// still the answer.
+type StreamParser struct {
+	response strings.Builder
+	released int
+}
+
+// Write adds chunk to the response and returns the part of the command that
+// chunk completes, or "" while the response is still in its reasoning.
+func (p *StreamParser) Write(chunk string) string {
+	p.response.WriteString(chunk)
+	command, ok := p.command()
+	if !ok || len(command) <= p.released {
+		return ""
+	}
+	text := command[p.released:]
+	p.released = len(command)
+	return text
+}
+
+// Response returns the response written so far.
+func (p *StreamParser) Response() string {
+	return p.response.String()
+}
+
+// command returns the command in the response so far, and false while the
+// response may still be in its reasoning.
+func (p *StreamParser) command() (string, bool) {
+	const openingTag, closingTag = "<reasoning>", "</reasoning>"
+	response := strings.TrimLeft(p.response.String(), " \t\r\n")
+	if strings.HasPrefix(openingTag, response) {
+		// Nothing yet, or a <reasoning> tag cut off by the chunk boundary.
+		return "", false
+	}
+	if strings.HasPrefix(response, openingTag) {
+		end := strings.Index(response, closingTag)
+		if end == -1 {
+			return "", false
+		}
+		response = response[end+len(closingTag):]
+	}
+	return strings.TrimLeft(response, " \t\r\n"), true
+}
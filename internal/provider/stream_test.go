@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestStreamParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		released []string
+	}{
+		{
+			name:     "no reasoning",
+			chunks:   []string{"=git ", "status"},
+			released: []string{"=git ", "status"},
+		},
+		{
+			name:     "reasoning held back",
+			chunks:   []string{"<reasoning>List", " files.</reasoning>", "\n=ls", " -la"},
+			released: []string{"", "", "=ls", " -la"},
+		},
+		{
+			name:     "tags split across chunks",
+			chunks:   []string{"<reas", "oning>Use ls.</reas", "oning>", "=", "ls"},
+			released: []string{"", "", "", "=", "ls"},
+		},
+		{
+			name:     "leading whitespace",
+			chunks:   []string{"\n ", " <reasoning>x</reasoning> ", " ", "+ -a"},
+			released: []string{"", "", "", "+ -a"},
+		},
+		{
+			name:     "unclosed reasoning",
+			chunks:   []string{"<reasoning>run =rm", " -rf"},
+			released: []string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var parser StreamParser
+			for i, chunk := range tt.chunks {
+				if got := parser.Write(chunk); got != tt.released[i] {
+					t.Errorf("Write(%q) = %q, want %q", chunk, got, tt.released[i])
+				}
+			}
+			if got, want := parser.Response(), strings.Join(tt.chunks, ""); got != want {
+				t.Errorf("Response() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+type staticProvider struct {
+	response string
+	err      error
+}
+
+func (p staticProvider) Fetch(ctx context.Context, input, systemPrompt string) (string, error) {
+	return p.FetchWithHistory(ctx, input, systemPrompt, nil)
+}
+
+func (p staticProvider) FetchWithHistory(ctx context.Context, input, systemPrompt string, history []Message) (string, error) {
+	return p.response, p.err
+}
+
+func TestFetchStream_NonStreamingProvider(t *testing.T) {
+	chunks, err := FetchStream(t.Context(), staticProvider{response: "=ls"}, "list", "prompt", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := collectStream(t, chunks); got != "=ls" {
+		t.Errorf("stream = %q, want %q", got, "=ls")
+	}
+
+	_, err = FetchStream(t.Context(), staticProvider{err: fmt.Errorf("boom")}, "list", "prompt", nil)
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("expected the provider error, got %v", err)
+	}
+}
+
+// collectStream joins the chunks and fails the test on a stream error.
+func collectStream(t *testing.T, chunks <-chan StreamChunk) string {
+	t.Helper()
+	var builder strings.Builder
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected stream error: %v", chunk.Err)
+		}
+		builder.WriteString(chunk.Text)
+	}
+	return builder.String()
+}
+
+// streamError returns the error that ends chunks, or nil.
+func streamError(chunks <-chan StreamChunk) error {
+	var err error
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			err = chunk.Err
+		}
+	}
+	return err
+}
+
+// newSSEServer answers every request with events as server-sent events.
+func newSSEServer(t *testing.T, events ...string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestOpenAIProvider(baseURL string) *OpenAIProvider {
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(baseURL),
+	)
+	return &OpenAIProvider{Model: "gpt-4o-mini", Client: &client}
+}
+
+func openAIStreamChunk(content, finishReason string) string {
+	finish := "null"
+	if finishReason != "" {
+		finish = fmt.Sprintf("%q", finishReason)
+	}
+	return fmt.Sprintf(`data: {"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":%q},"finish_reason":%s}]}`, content, finish)
+}
+
+func TestOpenAIProvider_FetchStream(t *testing.T) {
+	server := newSSEServer(t,
+		openAIStreamChunk("<reasoning>List.</reasoning>", ""),
+		openAIStreamChunk("=ls", ""),
+		openAIStreamChunk(" -la", "stop"),
+		"data: [DONE]",
+	)
+
+	chunks, err := newTestOpenAIProvider(server.URL).FetchStream(t.Context(), "list", "prompt", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := collectStream(t, chunks), "<reasoning>List.</reasoning>=ls -la"; got != want {
+		t.Errorf("stream = %q, want %q", got, want)
+	}
+}
+
+func TestOpenAIProvider_FetchStreamContentFilter(t *testing.T) {
+	server := newSSEServer(t,
+		openAIStreamChunk("=rm", ""),
+		openAIStreamChunk("", "content_filter"),
+		"data: [DONE]",
+	)
+
+	chunks, err := newTestOpenAIProvider(server.URL).FetchStream(t.Context(), "delete", "prompt", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var filtered *ContentFilteredError
+	if err := streamError(chunks); !errors.As(err, &filtered) {
+		t.Errorf("expected a ContentFilteredError, got %v", err)
+	}
+}
+
+func TestOpenAIProvider_FetchStreamAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"message": "invalid api key", "type": "invalid_request_error"}}`)
+	}))
+	defer server.Close()
+
+	_, err := newTestOpenAIProvider(server.URL).FetchStream(t.Context(), "list", "prompt", nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to create chat completion") {
+		t.Errorf("expected a request error, got %v", err)
+	}
+}
+
+func newTestAnthropicProvider(baseURL string) *AnthropicProvider {
+	client := anthropic.NewClient(
+		anthropicoption.WithAPIKey("test-key"),
+		anthropicoption.WithBaseURL(baseURL),
+	)
+	return &AnthropicProvider{Model: "claude-3-5-sonnet-20241022", Client: &client}
+}
+
+func anthropicStream(stopReason string, texts ...string) []string {
+	events := []string{
+		`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"stop_reason":null,"usage":{"input_tokens":1,"output_tokens":0}}}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	}
+	for _, text := range texts {
+		events = append(events, fmt.Sprintf(`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":%q}}`, text))
+	}
+	return append(events,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":0}`,
+		fmt.Sprintf(`event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":%q},"usage":{"output_tokens":5}}`, stopReason),
+		`event: message_stop
+data: {"type":"message_stop"}`,
+	)
+}
+
+func TestAnthropicProvider_FetchStream(t *testing.T) {
+	server := newSSEServer(t, anthropicStream("end_turn", "<reasoning>Status.</reas", "oning>", "=git status")...)
+
+	chunks, err := newTestAnthropicProvider(server.URL).FetchStream(t.Context(), "status", "prompt", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := collectStream(t, chunks), "<reasoning>Status.</reasoning>=git status"; got != want {
+		t.Errorf("stream = %q, want %q", got, want)
+	}
+}
+
+func TestAnthropicProvider_FetchStreamRefusal(t *testing.T) {
+	server := newSSEServer(t, anthropicStream("refusal")...)
+
+	chunks, err := newTestAnthropicProvider(server.URL).FetchStream(t.Context(), "kill -9 everything", "prompt", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var filtered *ContentFilteredError
+	if err := streamError(chunks); !errors.As(err, &filtered) {
+		t.Errorf("expected a ContentFilteredError, got %v", err)
+	}
+}
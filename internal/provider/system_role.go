@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"os"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// Roles the system prompt can be sent with to OpenAI-compatible APIs.
+const (
+	RoleSystem    = "system"
+	RoleDeveloper = "developer"
+)
+
+// reasoningModels lists the model name prefixes of OpenAI reasoning models.
+// They expect the system prompt in a developer message (they accept a system
+// message too, but follow its instructions less closely) and reject sampling
+// parameters such as temperature.
+var reasoningModels = []string{"o1", "o3", "o4", "gpt-5"}
+
+// isReasoningModel reports whether model is an OpenAI reasoning model.
+func isReasoningModel(model string) bool {
+	model = strings.ToLower(model)
+	for _, prefix := range reasoningModels {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SystemRole returns the role the system prompt is sent with to model:
+// SMART_SUGGESTION_SYSTEM_ROLE when set to system or developer, otherwise
+// developer for the models known to expect it and system for the rest.
+func SystemRole(model string) string {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv("SMART_SUGGESTION_SYSTEM_ROLE"))); value {
+	case RoleSystem, RoleDeveloper:
+		return value
+	case "", "auto":
+	default:
+		debug.Log("Ignoring invalid SMART_SUGGESTION_SYSTEM_ROLE", map[string]any{
+			"value": value,
+		})
+	}
+
+	if isReasoningModel(model) {
+		return RoleDeveloper
+	}
+	return RoleSystem
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestSystemRole(t *testing.T) {
+	tests := []struct {
+		model    string
+		env      string
+		expected string
+	}{
+		{model: "gpt-4o-mini", expected: RoleSystem},
+		{model: "gpt-4.1", expected: RoleSystem},
+		{model: "o1", expected: RoleDeveloper},
+		{model: "o3-mini", expected: RoleDeveloper},
+		{model: "o4-mini", expected: RoleDeveloper},
+		{model: "GPT-5-nano", expected: RoleDeveloper},
+		{model: "llama3", expected: RoleSystem},
+		{model: "gpt-4o-mini", env: "developer", expected: RoleDeveloper},
+		{model: "o3-mini", env: "System", expected: RoleSystem},
+		{model: "o3-mini", env: "auto", expected: RoleDeveloper},
+		{model: "gpt-4o", env: "admin", expected: RoleSystem},
+	}
+	for _, tt := range tests {
+		t.Setenv("SMART_SUGGESTION_SYSTEM_ROLE", tt.env)
+		if got := SystemRole(tt.model); got != tt.expected {
+			t.Errorf("%s with %q: expected %s, got %s", tt.model, tt.env, tt.expected, got)
+		}
+	}
+}
+
+func TestOpenAIProvider_FetchUsesSystemRole(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_SYSTEM_ROLE", "")
+	var body struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"index": 0, "message": {"role": "assistant", "content": "+la"}}]}`)
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(server.URL),
+	)
+	for model, role := range map[string]string{"gpt-4o-mini": RoleSystem, "o3-mini": RoleDeveloper} {
+		p := &OpenAIProvider{Model: model, Client: &client}
+		if _, err := p.Fetch(t.Context(), "ls -", "prompt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(body.Messages) == 0 || body.Messages[0].Role != role || body.Messages[0].Content != "prompt" {
+			t.Errorf("%s: expected the prompt in a %s message, got %+v", model, role, body.Messages)
+		}
+	}
+}
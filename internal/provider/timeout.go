@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// RequestTimeoutEnv sets how long providers wait for each request.
+const RequestTimeoutEnv = "SMART_SUGGESTION_TIMEOUT"
+
+// defaultRequestTimeout keeps a stalled connection from hanging the shell.
+const defaultRequestTimeout = 30 * time.Second
+
+// TimeoutError is returned when a provider did not answer a request within
+// SMART_SUGGESTION_TIMEOUT.
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("no answer within %s: %v", e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// requestTimeout returns SMART_SUGGESTION_TIMEOUT, 30s when it is unset or
+// invalid. 0 waits as long as the caller's context allows.
+func requestTimeout() time.Duration {
+	value := strings.TrimSpace(os.Getenv(RequestTimeoutEnv))
+	if value == "" {
+		return defaultRequestTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		debug.Log("Ignoring invalid "+RequestTimeoutEnv, map[string]any{
+			"value": value,
+		})
+		return defaultRequestTimeout
+	}
+	return timeout
+}
+
+// timeoutError returns err as a *TimeoutError when it is the request timeout
+// expiring, as opposed to ctx, the caller's own deadline, and unchanged
+// otherwise.
+func timeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if timeout > 0 && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return &TimeoutError{Timeout: timeout, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"":      defaultRequestTimeout,
+		"15s":   15 * time.Second,
+		" 2m ":  2 * time.Minute,
+		"0":     0,
+		"soon":  defaultRequestTimeout,
+		"-5s":   defaultRequestTimeout,
+		"1500":  defaultRequestTimeout,
+		"250ms": 250 * time.Millisecond,
+	} {
+		t.Setenv(RequestTimeoutEnv, value)
+		if got := requestTimeout(); got != want {
+			t.Errorf("%q: expected %s, got %s", value, want, got)
+		}
+	}
+}
+
+func TestValidateSettingsRequestTimeout(t *testing.T) {
+	t.Setenv(RequestTimeoutEnv, "soon")
+	if errs := ValidateSettings(); len(errs) != 1 {
+		t.Errorf("expected one error for an invalid timeout, got %v", errs)
+	}
+	t.Setenv(RequestTimeoutEnv, "15s")
+	if errs := ValidateSettings(); len(errs) != 0 {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+}
+
+func TestOpenAIProvider_RequestTimeout(t *testing.T) {
+	server := newStalledServer(t)
+
+	timeout := 50 * time.Millisecond
+	client := newOpenAIClient("openai", "test-key", server.URL, nil, timeout)
+	p := &OpenAIProvider{Model: "gpt-4o-mini", Client: &client, timeout: timeout}
+
+	_, err := p.Fetch(t.Context(), "list files", "prompt")
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Timeout != timeout {
+		t.Fatalf("expected a TimeoutError after %s, got %v", timeout, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error to wrap context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestOpenAIProvider_CallerDeadline(t *testing.T) {
+	server := newStalledServer(t)
+
+	client := openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(server.URL))
+	p := &OpenAIProvider{Model: "gpt-4o-mini", Client: &client, timeout: time.Minute}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err := p.Fetch(ctx, "list files", "prompt")
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		t.Errorf("expected the caller's deadline, not a TimeoutError: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// newStalledServer returns a server that never answers until the test ends.
+func newStalledServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server
+}
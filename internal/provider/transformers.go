@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"strings"
+	"sync"
+)
+
+// Transformer cleans up a command extracted from a model's response, fixing a
+// quirk that model is known for. It receives and returns the command with its
+// =/+ prefix.
+type Transformer func(command string) string
+
+var (
+	transformersMu sync.RWMutex
+	// transformers maps model name prefixes to cleanups, applied in the order
+	// they were registered.
+	transformers = []modelTransformer{
+		{prefix: "gemini", transform: stripBackticks},
+	}
+)
+
+type modelTransformer struct {
+	prefix    string
+	transform Transformer
+}
+
+// RegisterTransformer applies transform to the commands of every model whose
+// name starts with prefix (case-insensitively).
+func RegisterTransformer(prefix string, transform Transformer) {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+	transformers = append(transformers, modelTransformer{prefix: strings.ToLower(prefix), transform: transform})
+}
+
+// TransformCommand runs the transformers registered for model over command.
+func TransformCommand(model, command string) string {
+	model = strings.ToLower(model)
+
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+	for _, t := range transformers {
+		if model != "" && strings.HasPrefix(model, t.prefix) {
+			command = t.transform(command)
+		}
+	}
+	return command
+}
+
+// stripBackticks removes the backticks or code fence some models put around
+// the command, either around the whole answer or after its =/+ prefix.
+func stripBackticks(command string) string {
+	// unwrap leaves s alone unless it is wrapped, so the leading space of a
+	// completion survives.
+	unwrap := func(s string) string {
+		trimmed := strings.TrimSpace(s)
+		if body, ok := strings.CutPrefix(trimmed, "```"); ok {
+			if body, ok = strings.CutSuffix(body, "```"); ok {
+				// Drop a language tag on the opening fence.
+				if tag, rest, found := strings.Cut(body, "\n"); found && !strings.ContainsAny(tag, " \t") {
+					body = rest
+				}
+				return strings.TrimSpace(body)
+			}
+		}
+		if n := len(trimmed); n >= 2 && trimmed[0] == '`' && trimmed[n-1] == '`' && !strings.Contains(trimmed[1:n-1], "`") {
+			return trimmed[1 : n-1]
+		}
+		return s
+	}
+
+	command = unwrap(command)
+	if len(command) > 0 && (command[0] == '=' || command[0] == '+') {
+		return command[:1] + unwrap(command[1:])
+	}
+	return command
+}
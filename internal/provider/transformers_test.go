@@ -0,0 +1,46 @@
+package provider
+
+import "testing"
+
+func TestStripBackticks(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "=ls -la", expected: "=ls -la"},
+		{input: "`=ls -la`", expected: "=ls -la"},
+		{input: "=`ls -la`", expected: "=ls -la"},
+		{input: "```bash\n=ls -la\n```", expected: "=ls -la"},
+		{input: "=```\nls -la\n```", expected: "=ls -la"},
+		{input: "+ -la", expected: "+ -la"},
+		{input: "+` -la`", expected: "+ -la"},
+		{input: "=echo `date` `whoami`", expected: "=echo `date` `whoami`"},
+	}
+	for _, tc := range cases {
+		if got := stripBackticks(tc.input); got != tc.expected {
+			t.Errorf("stripBackticks(%q): expected %q, got %q", tc.input, tc.expected, got)
+		}
+	}
+}
+
+func TestTransformCommand(t *testing.T) {
+	old := transformers
+	t.Cleanup(func() { transformers = old })
+
+	RegisterTransformer("My-Model", func(command string) string {
+		return "=" + command[len("=Here's the command: "):]
+	})
+
+	if got := TransformCommand("my-model-v2", "=Here's the command: ls"); got != "=ls" {
+		t.Errorf("expected the registered transformer to run, got %q", got)
+	}
+	if got := TransformCommand("other-model", "=Here's the command: ls"); got != "=Here's the command: ls" {
+		t.Errorf("expected other models to be left alone, got %q", got)
+	}
+	if got := TransformCommand("", "=`ls`"); got != "=`ls`" {
+		t.Errorf("expected no transformer without a model, got %q", got)
+	}
+	if got := TransformCommand("gemini-2.5-flash", "=`ls`"); got != "=ls" {
+		t.Errorf("expected the built-in Gemini transformer, got %q", got)
+	}
+}
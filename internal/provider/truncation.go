@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// maxRetryTokens caps the completion-token limit of the retry after a
+// response was cut off, so a model that never stops cannot run up the bill.
+const maxRetryTokens int64 = 16384
+
+// fetchAttempt sends a request and reports whether the response stopped at
+// the completion-token limit.
+type fetchAttempt func(ctx context.Context) (response string, truncated bool, err error)
+
+// retryTruncated runs attempt and, when the token limit cut the response off
+// before its command (usually in the middle of the reasoning), runs it once
+// more with twice the limit, up to maxRetryTokens. defaultMaxTokens is the
+// limit the provider sends when maxTokensFor has none, or 0 when it sends
+// none: a response cut off by the model's own limit cannot get more room.
+func retryTruncated(ctx context.Context, providerName string, model string, defaultMaxTokens int64, attempt fetchAttempt) (string, error) {
+	response, truncated, err := attempt(ctx)
+	if !truncated || (err == nil && !missingCommand(response)) {
+		return response, err
+	}
+
+	current := maxTokensFor(ctx, model)
+	if current == 0 {
+		current = defaultMaxTokens
+	}
+	retry := min(current*2, maxRetryTokens)
+	if retry <= current {
+		debug.Log("Response cut off by the token limit, not retrying", map[string]any{
+			"provider":   providerName,
+			"max_tokens": current,
+		})
+		return response, err
+	}
+
+	debug.Log("Response cut off before the command, retrying with more tokens", map[string]any{
+		"provider":   providerName,
+		"max_tokens": current,
+		"retry":      retry,
+	})
+	opts := GenerationOptionsFromContext(ctx)
+	opts.MaxTokens = retry
+	response, _, err = attempt(WithGenerationOptions(ctx, opts))
+	return response, err
+}
+
+// missingCommand reports whether response holds no command, as when it ends
+// inside the reasoning.
+func missingCommand(response string) bool {
+	command, err := ExtractCommand(response)
+	return err != nil || command == ""
+}
@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// newTruncatingServer answers with truncated until it is asked for more than
+// limit tokens, and records the token limit of every request: OpenAI's
+// max_completion_tokens or Anthropic's max_tokens.
+func newTruncatingServer(t *testing.T, limit float64, truncated string, complete string) (*httptest.Server, *[]float64) {
+	t.Helper()
+	var requested []float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		maxTokens, ok := body["max_completion_tokens"].(float64)
+		if !ok {
+			maxTokens, _ = body["max_tokens"].(float64)
+		}
+		requested = append(requested, maxTokens)
+		w.Header().Set("Content-Type", "application/json")
+		if maxTokens <= limit {
+			fmt.Fprint(w, truncated)
+			return
+		}
+		fmt.Fprint(w, complete)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requested
+}
+
+func TestOpenAIProvider_RetriesTruncatedResponse(t *testing.T) {
+	server, requested := newTruncatingServer(t, 256,
+		`{"choices": [{"index": 0, "finish_reason": "length", "message": {"role": "assistant", "content": "<reasoning>The user wants"}}]}`,
+		`{"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "<reasoning>done</reasoning>=ls -la"}}]}`,
+	)
+	client := openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(server.URL))
+	p := &OpenAIProvider{Model: "gpt-4o-mini", Client: &client}
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "256")
+
+	response, err := p.Fetch(t.Context(), "ls", "prompt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if command, _ := ExtractCommand(response); command != "=ls -la" {
+		t.Errorf("expected the retried command, got %q", response)
+	}
+	if len(*requested) != 2 || (*requested)[1] != 512 {
+		t.Errorf("expected one retry with max_tokens 512, got %v", *requested)
+	}
+}
+
+func TestOpenAIProvider_TruncatedRetryBounded(t *testing.T) {
+	truncated := `{"choices": [{"index": 0, "finish_reason": "length", "message": {"role": "assistant", "content": "<reasoning>The user wants"}}]}`
+	server, requested := newTruncatingServer(t, float64(maxRetryTokens), truncated, truncated)
+	client := openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(server.URL))
+	p := &OpenAIProvider{Model: "gpt-4o-mini", Client: &client}
+
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "10000")
+	if _, err := p.Fetch(t.Context(), "ls", "prompt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*requested) != 2 || (*requested)[1] != float64(maxRetryTokens) {
+		t.Errorf("expected a single retry capped at %d, got %v", maxRetryTokens, *requested)
+	}
+
+	// Already at the ceiling, there is no room for a retry.
+	*requested = nil
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", fmt.Sprint(maxRetryTokens))
+	if _, err := p.Fetch(t.Context(), "ls", "prompt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*requested) != 1 {
+		t.Errorf("expected no retry at the ceiling, got %v", *requested)
+	}
+}
+
+func TestOpenAIProvider_TruncatedWithCommandNotRetried(t *testing.T) {
+	server, requested := newTruncatingServer(t, 256,
+		`{"choices": [{"index": 0, "finish_reason": "length", "message": {"role": "assistant", "content": "=ls -la"}}]}`,
+		`{"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "=pwd"}}]}`,
+	)
+	client := openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(server.URL))
+	p := &OpenAIProvider{Model: "gpt-4o-mini", Client: &client}
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "256")
+
+	response, err := p.Fetch(t.Context(), "ls", "prompt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response != "=ls -la" || len(*requested) != 1 {
+		t.Errorf("expected the first response without a retry, got %q after %v", response, *requested)
+	}
+}
+
+func TestAnthropicProvider_RetriesTruncatedResponse(t *testing.T) {
+	server, requested := newTruncatingServer(t, anthropicDefaultMaxTokens,
+		`{"id": "msg_1", "type": "message", "role": "assistant", "model": "m", "content": [{"type": "text", "text": "<reasoning>The user"}], "stop_reason": "max_tokens", "usage": {"input_tokens": 1, "output_tokens": 1000}}`,
+		`{"id": "msg_2", "type": "message", "role": "assistant", "model": "m", "content": [{"type": "text", "text": "=ls"}], "stop_reason": "end_turn", "usage": {"input_tokens": 1, "output_tokens": 1}}`,
+	)
+	client := anthropic.NewClient(anthropicoption.WithAPIKey("test-key"), anthropicoption.WithBaseURL(server.URL))
+	p := &AnthropicProvider{Model: "my-claude-finetune", Client: &client}
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "")
+
+	response, err := p.Fetch(t.Context(), "ls", "prompt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response != "=ls" {
+		t.Errorf("expected the retried response, got %q", response)
+	}
+	if len(*requested) != 2 || (*requested)[1] != 2*anthropicDefaultMaxTokens {
+		t.Errorf("expected one retry with max_tokens %d, got %v", 2*anthropicDefaultMaxTokens, *requested)
+	}
+}
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"os"
+	"strings"
+)
+
+// UnifiedProviderEnv selects the provider that the vendor-neutral LLM_*
+// variables configure, easing migration from tools that use them.
+const UnifiedProviderEnv = "LLM_PROVIDER"
+
+// unifiedEnv maps, per provider, each provider-specific variable to the
+// vendor-neutral variable that stands in for it.
+var unifiedEnv = map[string]map[string]string{
+	"openai": {
+		"OPENAI_API_KEY":  "LLM_API_KEY",
+		"OPENAI_MODEL":    "LLM_MODEL",
+		"OPENAI_BASE_URL": "LLM_BASE_URL",
+	},
+	"openai_compatible": {
+		"OPENAI_COMPATIBLE_API_KEY":  "LLM_API_KEY",
+		"OPENAI_COMPATIBLE_MODEL":    "LLM_MODEL",
+		"OPENAI_COMPATIBLE_BASE_URL": "LLM_BASE_URL",
+	},
+	"azure_openai": {
+		"AZURE_OPENAI_API_KEY":         "LLM_API_KEY",
+		"AZURE_OPENAI_DEPLOYMENT_NAME": "LLM_MODEL",
+		"AZURE_OPENAI_BASE_URL":        "LLM_BASE_URL",
+	},
+	"anthropic": {
+		"ANTHROPIC_API_KEY":  "LLM_API_KEY",
+		"ANTHROPIC_MODEL":    "LLM_MODEL",
+		"ANTHROPIC_BASE_URL": "LLM_BASE_URL",
+	},
+	"gemini": {
+		"GEMINI_API_KEY":  "LLM_API_KEY",
+		"GEMINI_MODEL":    "LLM_MODEL",
+		"GEMINI_BASE_URL": "LLM_BASE_URL",
+	},
+}
+
+// unifiedProviderAliases are the names other tools use for providers that
+// go by another name here.
+var unifiedProviderAliases = map[string]string{
+	"azure":  "azure_openai",
+	"claude": "anthropic",
+	"google": "gemini",
+}
+
+// UnifiedProvider returns the provider named by LLM_PROVIDER, translating the
+// names other tools use, or "" when it is unset.
+func UnifiedProvider() string {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv(UnifiedProviderEnv)))
+	if alias, ok := unifiedProviderAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// providerEnv returns the environment variable name and the variable it was
+// read from. When name is unset and LLM_PROVIDER selects the provider name
+// belongs to, the matching LLM_* variable is read instead, so the
+// provider-specific variable always takes precedence.
+func providerEnv(name string) (value string, source string) {
+	if value := os.Getenv(name); value != "" {
+		return value, name
+	}
+	if unified, ok := unifiedEnv[UnifiedProvider()][name]; ok {
+		if value := os.Getenv(unified); value != "" {
+			return value, unified
+		}
+	}
+	return "", name
+}
+
+// providerSetting returns the value of providerEnv.
+func providerSetting(name string) string {
+	value, _ := providerEnv(name)
+	return value
+}
@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// clearProviderEnv unsets every variable the LLM_* variables stand in for, so
+// only the unified configuration is left.
+func clearProviderEnv(t *testing.T) {
+	t.Helper()
+	for _, vars := range unifiedEnv {
+		for name := range vars {
+			t.Setenv(name, "")
+			if strings.HasSuffix(name, "_API_KEY") {
+				t.Setenv(name+"_FILE", "")
+			}
+		}
+	}
+	for _, name := range []string{"OPENAI_API_KEYS", "AZURE_OPENAI_RESOURCE_NAME", "AZURE_OPENAI_API_VERSION", "SMART_SUGGESTION_AI_PROVIDER"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+}
+
+// newUnifiedServer answers like whichever provider is asked and records the
+// request it received.
+func newUnifiedServer(t *testing.T, got *http.Request, body *string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		*got = *r.Clone(context.Background())
+		*body = string(data)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, ":generateContent"):
+			fmt.Fprint(w, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "=ls"}]}}]}`)
+		case strings.HasSuffix(r.URL.Path, "/messages"):
+			fmt.Fprint(w, `{"id": "msg_1", "type": "message", "role": "assistant", "model": "m", "content": [{"type": "text", "text": "=ls"}], "stop_reason": "end_turn", "usage": {"input_tokens": 1, "output_tokens": 1}}`)
+		default:
+			fmt.Fprint(w, `{"choices": [{"index": 0, "message": {"role": "assistant", "content": "=ls"}}]}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUnifiedEnvConfiguresEachProvider(t *testing.T) {
+	tests := []struct {
+		llmProvider string
+		newProvider func() (Provider, error)
+		keyHeader   string
+		keyValue    string
+	}{
+		{
+			llmProvider: "openai",
+			newProvider: func() (Provider, error) { return NewOpenAIProvider() },
+			keyHeader:   "Authorization",
+			keyValue:    "Bearer sk-unified",
+		},
+		{
+			llmProvider: "openai_compatible",
+			newProvider: func() (Provider, error) { return NewOpenAICompatibleProvider() },
+			keyHeader:   "Authorization",
+			keyValue:    "Bearer sk-unified",
+		},
+		{
+			llmProvider: "azure",
+			newProvider: func() (Provider, error) { return NewAzureOpenAIProvider() },
+			keyHeader:   "Api-Key",
+			keyValue:    "sk-unified",
+		},
+		{
+			llmProvider: "anthropic",
+			newProvider: func() (Provider, error) { return NewAnthropicProvider() },
+			keyHeader:   "X-Api-Key",
+			keyValue:    "sk-unified",
+		},
+		{
+			llmProvider: "google",
+			newProvider: func() (Provider, error) { return NewGeminiProvider(context.Background()) },
+			keyHeader:   "X-Goog-Api-Key",
+			keyValue:    "sk-unified",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.llmProvider, func(t *testing.T) {
+			clearProviderEnv(t)
+			var got http.Request
+			var body string
+			server := newUnifiedServer(t, &got, &body)
+			t.Setenv(UnifiedProviderEnv, tt.llmProvider)
+			t.Setenv("LLM_API_KEY", "sk-unified")
+			t.Setenv("LLM_MODEL", "unified-model")
+			t.Setenv("LLM_BASE_URL", server.URL)
+
+			p, err := tt.newProvider()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if model := ModelName(p); model != "unified-model" {
+				t.Errorf("expected model unified-model, got %q", model)
+			}
+			if _, err := p.Fetch(t.Context(), "ls", "prompt"); err != nil {
+				t.Fatalf("Fetch failed: %v", err)
+			}
+			if value := got.Header.Get(tt.keyHeader); value != tt.keyValue {
+				t.Errorf("expected %s %q, got %q", tt.keyHeader, tt.keyValue, value)
+			}
+			if !strings.Contains(got.URL.Path+body, "unified-model") {
+				t.Errorf("expected the request to use unified-model, got %s %s", got.URL.Path, body)
+			}
+		})
+	}
+}
+
+func TestUnifiedEnvPrecedence(t *testing.T) {
+	clearProviderEnv(t)
+	t.Setenv(UnifiedProviderEnv, "openai")
+	t.Setenv("LLM_API_KEY", "sk-unified")
+	t.Setenv("LLM_MODEL", "unified-model")
+	t.Setenv("OPENAI_MODEL", "gpt-4o")
+
+	if got := providerSetting("OPENAI_MODEL"); got != "gpt-4o" {
+		t.Errorf("expected OPENAI_MODEL to win, got %q", got)
+	}
+	if value, source := providerEnv("OPENAI_API_KEY"); value != "sk-unified" || source != "LLM_API_KEY" {
+		t.Errorf("expected LLM_API_KEY to stand in, got %q from %s", value, source)
+	}
+	// The unified variables only configure the provider LLM_PROVIDER names.
+	if got := providerSetting("ANTHROPIC_API_KEY"); got != "" {
+		t.Errorf("expected no Anthropic key, got %q", got)
+	}
+}
+
+func TestUnifiedProvider(t *testing.T) {
+	tests := map[string]string{
+		"":           "",
+		"OpenAI":     "openai",
+		" claude ":   "anthropic",
+		"azure":      "azure_openai",
+		"gemini":     "gemini",
+		"ollama-ish": "ollama-ish",
+	}
+	for value, want := range tests {
+		t.Setenv(UnifiedProviderEnv, value)
+		if got := UnifiedProvider(); got != want {
+			t.Errorf("UnifiedProvider() with %q = %q, want %q", value, got, want)
+		}
+	}
+}
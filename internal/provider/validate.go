@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var azureAPIVersionPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(-preview)?$`)
+
+// validateAPIKey catches keys that were pasted with quotes, whitespace or an
+// authorization scheme, which otherwise only surface as 401 responses.
+func validateAPIKey(envName string, key string) error {
+	switch {
+	case strings.TrimSpace(key) != key:
+		return fmt.Errorf("%s has leading or trailing whitespace; remove it", envName)
+	case len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0]:
+		return fmt.Errorf("%s is wrapped in quotes; set it to the bare key", envName)
+	case strings.HasPrefix(strings.ToLower(key), "bearer "):
+		return fmt.Errorf("%s should be the bare key without the \"Bearer \" prefix", envName)
+	case strings.ContainsAny(key, " \t\r\n"):
+		return fmt.Errorf("%s contains whitespace; check that only the key was copied", envName)
+	}
+	return nil
+}
+
+// validateBaseURL checks that a *_BASE_URL setting is an http(s) URL with a host.
+func validateBaseURL(envName string, baseURL string) error {
+	if baseURL == "" {
+		return nil
+	}
+	if strings.ContainsAny(baseURL, " \t\r\n") {
+		return fmt.Errorf("%s %q contains whitespace; remove it", envName, baseURL)
+	}
+	if scheme, _, ok := strings.Cut(baseURL, "://"); ok && scheme != "http" && scheme != "https" {
+		return fmt.Errorf("%s %q must use http or https, not %s", envName, baseURL, scheme)
+	}
+
+	u, err := url.Parse(normalizeBaseURL(baseURL))
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%s %q is not a valid URL; expected something like https://api.example.com/v1", envName, baseURL)
+	}
+	return nil
+}
+
+// validateAzureConfig catches Azure settings that were filled with the wrong
+// kind of value, such as a full endpoint in place of the resource name.
+func validateAzureConfig(resourceName string, deploymentName string, apiVersion string) error {
+	if strings.Contains(resourceName, "://") || strings.ContainsAny(resourceName, "./") {
+		return fmt.Errorf("AZURE_OPENAI_RESOURCE_NAME should be just the resource name (e.g. awesome-corp), not a URL; set AZURE_OPENAI_BASE_URL instead")
+	}
+	if strings.Contains(deploymentName, "/") {
+		return fmt.Errorf("AZURE_OPENAI_DEPLOYMENT_NAME should be just the deployment name (e.g. gpt-4o), not a path; put full endpoints in AZURE_OPENAI_BASE_URL")
+	}
+	if !azureAPIVersionPattern.MatchString(apiVersion) {
+		return fmt.Errorf("AZURE_OPENAI_API_VERSION %q is not a valid API version; expected a date such as 2024-10-21", apiVersion)
+	}
+	return nil
+}
+
+// ValidateSettings checks the provider settings that are otherwise ignored
+// when invalid, with only a debug log line to show for it.
+func ValidateSettings() []error {
+	var errs []error
+	if value := strings.TrimSpace(os.Getenv("SMART_SUGGESTION_MAX_TOKENS")); value != "" {
+		if _, err := parseMaxTokens(value); err != nil {
+			errs = append(errs, fmt.Errorf("SMART_SUGGESTION_MAX_TOKENS %w", err))
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv("SMART_SUGGESTION_TEMPERATURE")); value != "" {
+		if _, err := parseTemperature(value); err != nil {
+			errs = append(errs, fmt.Errorf("SMART_SUGGESTION_TEMPERATURE %w", err))
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(RetriesEnv)); value != "" {
+		if _, err := parseRetries(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", RetriesEnv, err))
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(ProxyURLEnv)); value != "" {
+		if _, err := parseProxyURL(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", ProxyURLEnv, err))
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(RequestTimeoutEnv)); value != "" {
+		if timeout, err := time.ParseDuration(value); err != nil || timeout < 0 {
+			errs = append(errs, fmt.Errorf("%s %q is not a duration like 15s", RequestTimeoutEnv, value))
+		}
+	}
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv("SMART_SUGGESTION_SYSTEM_ROLE"))); value {
+	case "", "auto", RoleSystem, RoleDeveloper:
+	default:
+		errs = append(errs, fmt.Errorf("SMART_SUGGESTION_SYSTEM_ROLE %q must be %s, %s or auto", value, RoleSystem, RoleDeveloper))
+	}
+	return errs
+}
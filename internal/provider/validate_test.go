@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAPIKey(t *testing.T) {
+	cases := []struct {
+		name     string
+		key      string
+		expected string
+	}{
+		{name: "valid", key: "sk-abc123"},
+		{name: "trailing newline", key: "sk-abc123\n", expected: "leading or trailing whitespace"},
+		{name: "quoted", key: `"sk-abc123"`, expected: "wrapped in quotes"},
+		{name: "single quoted", key: `'sk-abc123'`, expected: "wrapped in quotes"},
+		{name: "bearer prefix", key: "Bearer sk-abc123", expected: "Bearer"},
+		{name: "inner whitespace", key: "sk-abc 123", expected: "contains whitespace"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAPIKey("OPENAI_API_KEY", tc.key)
+			assertValidationError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestValidateBaseURL(t *testing.T) {
+	cases := []struct {
+		name     string
+		baseURL  string
+		expected string
+	}{
+		{name: "empty", baseURL: ""},
+		{name: "https", baseURL: "https://api.example.com/v1"},
+		{name: "no scheme", baseURL: "api.example.com"},
+		{name: "localhost port", baseURL: "http://localhost:11434/v1"},
+		{name: "whitespace", baseURL: "https://api.example.com /v1", expected: "contains whitespace"},
+		{name: "wrong scheme", baseURL: "ftp://api.example.com", expected: "must use http or https"},
+		{name: "no host", baseURL: "https:///v1", expected: "is not a valid URL"},
+		{name: "bad port", baseURL: "https://api.example.com:port", expected: "is not a valid URL"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateBaseURL("OPENAI_BASE_URL", tc.baseURL)
+			assertValidationError(t, err, tc.expected)
+			if err != nil && !strings.Contains(err.Error(), "OPENAI_BASE_URL") {
+				t.Fatalf("expected error to name the variable, got %q", err)
+			}
+		})
+	}
+}
+
+func TestValidateAzureConfig(t *testing.T) {
+	cases := []struct {
+		name       string
+		resource   string
+		deployment string
+		apiVersion string
+		expected   string
+	}{
+		{name: "valid", resource: "awesome-corp", deployment: "gpt-4o", apiVersion: "2024-10-21"},
+		{name: "preview version", resource: "awesome-corp", deployment: "gpt-4o", apiVersion: "2025-01-01-preview"},
+		{name: "base url only", resource: "", deployment: "gpt-4o", apiVersion: "2024-10-21"},
+		{name: "resource is url", resource: "https://awesome-corp.openai.azure.com", deployment: "gpt-4o", apiVersion: "2024-10-21", expected: "set AZURE_OPENAI_BASE_URL instead"},
+		{name: "resource is host", resource: "awesome-corp.openai.azure.com", deployment: "gpt-4o", apiVersion: "2024-10-21", expected: "AZURE_OPENAI_RESOURCE_NAME should be just the resource name"},
+		{name: "deployment is path", resource: "awesome-corp", deployment: "deployments/gpt-4o", apiVersion: "2024-10-21", expected: "AZURE_OPENAI_DEPLOYMENT_NAME"},
+		{name: "bad api version", resource: "awesome-corp", deployment: "gpt-4o", apiVersion: "v1", expected: "AZURE_OPENAI_API_VERSION"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAzureConfig(tc.resource, tc.deployment, tc.apiVersion)
+			assertValidationError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestProviderConstructorsValidateConfig(t *testing.T) {
+	t.Run("openai base url", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "sk-test")
+		t.Setenv("OPENAI_BASE_URL", "ftp://api.example.com")
+		_, err := NewOpenAIProvider()
+		assertValidationError(t, err, "OPENAI_BASE_URL")
+	})
+
+	t.Run("anthropic key", func(t *testing.T) {
+		t.Setenv("ANTHROPIC_API_KEY", "Bearer sk-ant")
+		_, err := NewAnthropicProvider()
+		assertValidationError(t, err, "ANTHROPIC_API_KEY")
+	})
+
+	t.Run("gemini base url", func(t *testing.T) {
+		t.Setenv("GEMINI_API_KEY", "key")
+		t.Setenv("GEMINI_BASE_URL", "https:///v1")
+		_, err := NewGeminiProvider(t.Context())
+		assertValidationError(t, err, "GEMINI_BASE_URL")
+	})
+
+	t.Run("azure resource url", func(t *testing.T) {
+		t.Setenv("AZURE_OPENAI_API_KEY", "key")
+		t.Setenv("AZURE_OPENAI_DEPLOYMENT_NAME", "gpt-4o")
+		t.Setenv("AZURE_OPENAI_RESOURCE_NAME", "https://awesome-corp.openai.azure.com")
+		t.Setenv("AZURE_OPENAI_BASE_URL", "")
+		t.Setenv("AZURE_OPENAI_API_VERSION", "")
+		_, err := NewAzureOpenAIProvider()
+		assertValidationError(t, err, "set AZURE_OPENAI_BASE_URL instead")
+	})
+}
+
+func TestValidateSettings(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "500")
+	t.Setenv("SMART_SUGGESTION_TEMPERATURE", "0.2")
+	t.Setenv("SMART_SUGGESTION_SYSTEM_ROLE", "developer")
+	t.Setenv(ProxyURLEnv, "http://proxy.example.com:8080")
+	t.Setenv(RetriesEnv, "3")
+	if errs := ValidateSettings(); len(errs) != 0 {
+		t.Errorf("expected valid settings to pass, got %v", errs)
+	}
+
+	t.Setenv("SMART_SUGGESTION_MAX_TOKENS", "-1")
+	t.Setenv("SMART_SUGGESTION_TEMPERATURE", "hot")
+	t.Setenv("SMART_SUGGESTION_SYSTEM_ROLE", "admin")
+	t.Setenv(ProxyURLEnv, "proxy.example.com:8080")
+	t.Setenv(RetriesEnv, "-1")
+	errs := ValidateSettings()
+	if len(errs) != 5 {
+		t.Fatalf("expected five problems, got %v", errs)
+	}
+	assertValidationError(t, errs[0], "SMART_SUGGESTION_MAX_TOKENS")
+	assertValidationError(t, errs[1], "SMART_SUGGESTION_TEMPERATURE")
+	assertValidationError(t, errs[2], RetriesEnv)
+	assertValidationError(t, errs[3], ProxyURLEnv)
+	assertValidationError(t, errs[4], "SMART_SUGGESTION_SYSTEM_ROLE")
+}
+
+func assertValidationError(t *testing.T, err error, expected string) {
+	t.Helper()
+	if expected == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected error containing %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"google.golang.org/genai"
+)
+
+// Warmer is implemented by providers that can open their connection before the
+// first suggestion, so that suggestion does not wait for DNS and the TLS
+// handshake. Warmup sends the cheapest request the API offers, which costs no
+// tokens, and does not retry: it is only worth doing if it is quick.
+type Warmer interface {
+	Warmup(ctx context.Context) error
+}
+
+// Warmup warms up p's connection when p supports it and is a no-op otherwise.
+func Warmup(ctx context.Context, p Provider) error {
+	w, ok := p.(Warmer)
+	if !ok {
+		return nil
+	}
+	return w.Warmup(ctx)
+}
+
+// Warmup looks up the model, which needs no tokens.
+func (p *OpenAIProvider) Warmup(ctx context.Context) error {
+	_, err := p.Client.Models.Get(ctx, p.Model, option.WithMaxRetries(0))
+	return warmupError(err)
+}
+
+// Warmup sends a model lookup that the deployment endpoint rejects, but only
+// after the connection to it is open.
+func (p *AzureOpenAIProvider) Warmup(ctx context.Context) error {
+	_, err := p.Client.Models.Get(ctx, p.DeploymentName, option.WithMaxRetries(0))
+	return warmupError(err)
+}
+
+// Warmup looks up the model, which needs no tokens.
+func (p *AnthropicProvider) Warmup(ctx context.Context) error {
+	_, err := p.Client.Models.Get(ctx, p.Model, anthropic.ModelGetParams{}, anthropicoption.WithMaxRetries(0))
+	return warmupError(err)
+}
+
+// Warmup looks up the model, which needs no tokens.
+func (p *GeminiProvider) Warmup(ctx context.Context) error {
+	_, err := p.Client.Models.Get(ctx, p.Model, nil)
+	return warmupError(err)
+}
+
+// warmupError ignores errors the API answered with: the connection is open
+// either way, and only a failure to reach the provider is worth reporting.
+func warmupError(err error) error {
+	var openAIErr *openai.Error
+	var anthropicErr *anthropic.Error
+	var geminiErr genai.APIError
+	if errors.As(err, &openAIErr) || errors.As(err, &anthropicErr) || errors.As(err, &geminiErr) {
+		return nil
+	}
+	return err
+}
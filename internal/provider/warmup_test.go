@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWarmup(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+
+	tests := []struct {
+		name     string
+		status   int
+		newP     func(t *testing.T, url string) Provider
+		wantPath string
+	}{
+		{
+			name:   "openai",
+			status: http.StatusOK,
+			newP: func(t *testing.T, url string) Provider {
+				t.Setenv("OPENAI_API_KEY", "sk-test-key")
+				t.Setenv("OPENAI_BASE_URL", url)
+				p, err := NewOpenAIProvider()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return p
+			},
+			wantPath: "/models/gpt-4o-mini",
+		},
+		{
+			name:   "anthropic model unknown to the API",
+			status: http.StatusNotFound,
+			newP: func(t *testing.T, url string) Provider {
+				t.Setenv("ANTHROPIC_API_KEY", "sk-ant-test")
+				t.Setenv("ANTHROPIC_BASE_URL", url)
+				t.Setenv("ANTHROPIC_MODEL", "claude-test")
+				p, err := NewAnthropicProvider()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return p
+			},
+			wantPath: "/v1/models/claude-test",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, `{"id":"model","object":"model"}`)
+			}))
+			defer server.Close()
+
+			if err := Warmup(t.Context(), tt.newP(t, server.URL)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(requests) != 1 || requests[0] != "GET "+tt.wantPath {
+				t.Errorf("expected a single GET %s, got %v", tt.wantPath, requests)
+			}
+		})
+	}
+}
+
+func TestWarmupUnreachable(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	t.Setenv("OPENAI_API_KEY", "sk-test-key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	p, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	if err := Warmup(ctx, p); err == nil {
+		t.Error("expected an error when the provider cannot be reached")
+	}
+}
+
+func TestWarmupUnsupported(t *testing.T) {
+	if err := Warmup(t.Context(), &mockProviderWithoutWarmup{}); err != nil {
+		t.Errorf("expected providers without Warmup to be skipped, got %v", err)
+	}
+}
+
+type mockProviderWithoutWarmup struct{}
+
+func (mockProviderWithoutWarmup) Fetch(ctx context.Context, input, systemPrompt string) (string, error) {
+	return "", nil
+}
+
+func (mockProviderWithoutWarmup) FetchWithHistory(ctx context.Context, input, systemPrompt string, history []Message) (string, error) {
+	return "", nil
+}
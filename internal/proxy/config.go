@@ -0,0 +1,140 @@
+//go:build unix
+
+package proxy
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// proxySettings are the config file settings a running proxy can pick up.
+type proxySettings struct {
+	ScrollbackLines int
+	// DenyCommands replaces the patterns the proxy started with when
+	// HasDenyCommands is set, even by an empty list.
+	DenyCommands    []*regexp.Regexp
+	HasDenyCommands bool
+}
+
+// readProxySettings extracts the proxy settings from a zsh config file. Only
+// plain assignments such as SMART_SUGGESTION_SCROLLBACK_LINES=200 (optionally
+// prefixed with export or typeset) are understood; anything else is skipped.
+// A quoted value may span several lines, as a list of deny patterns does.
+func readProxySettings(path string) (proxySettings, error) {
+	var settings proxySettings
+
+	f, err := os.Open(path)
+	if err != nil {
+		return settings, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		statement := scanner.Text()
+		for openQuote(statement) && scanner.Scan() {
+			statement += "\n" + scanner.Text()
+		}
+		name, value, ok := parseAssignment(statement)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "SMART_SUGGESTION_SCROLLBACK_LINES":
+			if lines, err := strconv.Atoi(value); err == nil && lines > 0 {
+				settings.ScrollbackLines = lines
+			}
+		case DenyCommandsEnvVar:
+			patterns, err := ParseCommandPatterns(value)
+			if err != nil {
+				// Keep the patterns in use rather than record what they hid.
+				debug.Log("Ignoring invalid "+DenyCommandsEnvVar+" in proxy config", map[string]any{
+					"error": err.Error(),
+					"file":  path,
+				})
+				continue
+			}
+			settings.DenyCommands = patterns
+			settings.HasDenyCommands = true
+		}
+	}
+	return settings, scanner.Err()
+}
+
+// openQuote reports whether statement assigns a quoted value that its line
+// does not close.
+func openQuote(statement string) bool {
+	_, value, ok := strings.Cut(statement, "=")
+	value = strings.TrimSpace(value)
+	if !ok || value == "" || (value[0] != '"' && value[0] != '\'') {
+		return false
+	}
+	return !strings.ContainsRune(value[1:], rune(value[0]))
+}
+
+func parseAssignment(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	for _, prefix := range []string{"export ", "typeset -g ", "typeset "} {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	name, value, ok := strings.Cut(line, "=")
+	if !ok || name == "" || strings.ContainsAny(name, " \t#") {
+		return "", "", false
+	}
+
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return name, value[1 : end+1], true
+		}
+	}
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = strings.TrimSpace(value[:comment])
+	}
+	return name, value, true
+}
+
+// configFollower re-reads the config file when it has changed. The proxy
+// asks it once per command the shell runs.
+type configFollower struct {
+	path    string
+	modTime time.Time
+}
+
+func newConfigFollower(path string) *configFollower {
+	f := &configFollower{path: path}
+	if info, err := os.Stat(path); err == nil {
+		f.modTime = info.ModTime()
+	}
+	return f
+}
+
+// poll returns the current settings when the file changed since the last poll.
+func (f *configFollower) poll() (proxySettings, bool) {
+	info, err := os.Stat(f.path)
+	if err != nil || info.ModTime().Equal(f.modTime) {
+		return proxySettings{}, false
+	}
+	f.modTime = info.ModTime()
+
+	settings, err := readProxySettings(f.path)
+	if err != nil {
+		debug.Log("Failed to reload proxy config", map[string]any{
+			"error": err.Error(),
+			"file":  f.path,
+		})
+		return proxySettings{}, false
+	}
+	debug.Log("Reloaded proxy config", map[string]any{
+		"file":             f.path,
+		"scrollback_lines": settings.ScrollbackLines,
+		"deny_commands":    len(settings.DenyCommands),
+	})
+	return settings, true
+}
@@ -0,0 +1,211 @@
+//go:build unix
+
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAssignment(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantName  string
+		wantValue string
+		wantOK    bool
+	}{
+		{"SMART_SUGGESTION_SCROLLBACK_LINES=200", "SMART_SUGGESTION_SCROLLBACK_LINES", "200", true},
+		{"export SMART_SUGGESTION_SCROLLBACK_LINES=50", "SMART_SUGGESTION_SCROLLBACK_LINES", "50", true},
+		{"typeset -g SMART_SUGGESTION_SCROLLBACK_LINES='75'", "SMART_SUGGESTION_SCROLLBACK_LINES", "75", true},
+		{`  FOO="bar baz" # comment`, "FOO", "bar baz", true},
+		{"FOO=bar # comment", "FOO", "bar", true},
+		{"# SMART_SUGGESTION_SCROLLBACK_LINES=10", "", "", false},
+		{"echo hello", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, value, ok := parseAssignment(tt.line)
+		if name != tt.wantName || value != tt.wantValue || ok != tt.wantOK {
+			t.Errorf("parseAssignment(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.line, name, value, ok, tt.wantName, tt.wantValue, tt.wantOK)
+		}
+	}
+}
+
+func TestReadProxySettings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.zsh")
+	content := "# smart-suggestion config\nSMART_SUGGESTION_PROVIDER=openai\nexport SMART_SUGGESTION_SCROLLBACK_LINES=42\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	settings, err := readProxySettings(configPath)
+	if err != nil {
+		t.Fatalf("readProxySettings failed: %v", err)
+	}
+	if settings.ScrollbackLines != 42 {
+		t.Errorf("expected 42 scrollback lines, got %d", settings.ScrollbackLines)
+	}
+	if settings.HasDenyCommands {
+		t.Error("expected no deny patterns from a config without them")
+	}
+
+	content = "SMART_SUGGESTION_PROXY_DENY_COMMANDS='^pass\n# secrets\n^vault '\nSMART_SUGGESTION_SCROLLBACK_LINES=7\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if settings, err = readProxySettings(configPath); err != nil {
+		t.Fatalf("readProxySettings failed: %v", err)
+	}
+	if !settings.HasDenyCommands || len(settings.DenyCommands) != 2 || settings.ScrollbackLines != 7 {
+		t.Errorf("expected 2 deny patterns and 7 lines, got %+v", settings)
+	}
+
+	if _, err := readProxySettings(filepath.Join(t.TempDir(), "missing.zsh")); err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
+
+func TestLineLimitedWriter_FollowConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "test.log")
+	configPath := filepath.Join(tempDir, "config.zsh")
+
+	if err := os.WriteFile(configPath, []byte("SMART_SUGGESTION_SCROLLBACK_LINES=3\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	w := newLineLimitedWriter(f, logPath, 3)
+	w.follower = newConfigFollower(configPath)
+
+	// Each line is the output of a command, whose C mark reloads the config.
+	writeLines := func(from, to int) {
+		for i := from; i <= to; i++ {
+			if _, err := w.Write([]byte("\x1b]133;C;cmdline=echo\x07line" + strconv.Itoa(i) + "\n")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+	}
+	readLines := func() []string {
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		content, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("failed to read log file: %v", err)
+		}
+		return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+
+	writeLines(1, 5)
+	if lines := readLines(); len(lines) != 3 {
+		t.Fatalf("expected 3 lines before reload, got %d: %v", len(lines), lines)
+	}
+
+	// Grow the buffer mid-session.
+	if err := os.WriteFile(configPath, []byte("SMART_SUGGESTION_SCROLLBACK_LINES=5\n"), 0644); err != nil {
+		t.Fatalf("failed to rewrite config: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(configPath, later, later); err != nil {
+		t.Fatalf("failed to touch config: %v", err)
+	}
+
+	writeLines(6, 10)
+	lines := readLines()
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines after growing, got %d: %v", len(lines), lines)
+	}
+	if lines[0] != "line6" || lines[4] != "line10" {
+		t.Errorf("expected line6..line10, got %v", lines)
+	}
+
+	// Shrink it again; the most recent lines are kept.
+	if err := os.WriteFile(configPath, []byte("SMART_SUGGESTION_SCROLLBACK_LINES=2\n"), 0644); err != nil {
+		t.Fatalf("failed to rewrite config: %v", err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(configPath, later, later); err != nil {
+		t.Fatalf("failed to touch config: %v", err)
+	}
+
+	writeLines(11, 11)
+	lines = readLines()
+	if len(lines) != 2 || lines[0] != "line10" || lines[1] != "line11" {
+		t.Errorf("expected [line10 line11] after shrinking, got %v", lines)
+	}
+}
+
+func TestLineLimitedWriter_FollowDenyCommands(t *testing.T) {
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "test.log")
+	configPath := filepath.Join(tempDir, "config.zsh")
+	if err := os.WriteFile(configPath, []byte("SMART_SUGGESTION_SCROLLBACK_LINES=10\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	w := newLineLimitedWriter(f, logPath, 10)
+	w.follower = newConfigFollower(configPath)
+	w.Write([]byte("$ pass show\n\x1b]133;C;cmdline=pass show\x07hunter2\n\x1b]133;D\x07"))
+
+	if err := os.WriteFile(configPath, []byte("SMART_SUGGESTION_PROXY_DENY_COMMANDS='^pass'\n"), 0644); err != nil {
+		t.Fatalf("failed to rewrite config: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(configPath, later, later); err != nil {
+		t.Fatalf("failed to touch config: %v", err)
+	}
+
+	// The new pattern applies from the very next command.
+	w.Write([]byte("$ pass show\n\x1b]133;C;cmdline=pass show\x07s3cret\n\x1b]133;D\x07"))
+	w.Write([]byte("$ ls\n\x1b]133;C;cmdline=ls\x07file\n"))
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if string(content) != "$ pass show\nhunter2\n$ ls\nfile\n" {
+		t.Errorf("expected only the command before the reload and ls, got %q", content)
+	}
+}
+
+func TestLineLimitedWriter_ResizeKeepsBlankLines(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	w := newLineLimitedWriter(f, logPath, 4)
+	w.Write([]byte("a\n\nb\n\n"))
+	w.resize(3)
+	w.Write([]byte("c\n"))
+	w.resize(5)
+	w.Write([]byte("\nd\n"))
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if string(content) != "b\n\nc\n\nd\n" {
+		t.Errorf("expected the blank lines to be kept, got %q", content)
+	}
+}
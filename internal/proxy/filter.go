@@ -0,0 +1,137 @@
+//go:build unix
+
+package proxy
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// commandMarkerRegex matches OSC 133 shell integration marks: A (prompt start),
+// B (command start), C (command executed, optionally carrying the command line
+// as cmdline=... or cmdline_url=...) and D (command finished).
+var commandMarkerRegex = regexp.MustCompile(`\x1b\]133;([A-D])((?:;[^\x07\x1b]*)?)(?:\x07|\x1b\\)`)
+
+// ParseCommandPatterns compiles a newline separated list of regular
+// expressions. Semicolons are not separators here since they are common in
+// both patterns and shell commands. Blank lines and lines starting with # are
+// ignored.
+func ParseCommandPatterns(spec string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for entry := range strings.SplitSeq(spec, "\n") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		re, err := regexp.Compile(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid command pattern %q: %w", entry, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// markerCommandLine extracts the command line carried by a C mark's parameters.
+func markerCommandLine(params string) string {
+	for param := range strings.SplitSeq(strings.TrimPrefix(params, ";"), ";") {
+		if value, ok := strings.CutPrefix(param, "cmdline_url="); ok {
+			if decoded, err := url.PathUnescape(value); err == nil {
+				return decoded
+			}
+			return value
+		}
+		if value, ok := strings.CutPrefix(param, "cmdline="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func matchesAny(patterns []*regexp.Regexp, command string) bool {
+	for _, re := range patterns {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCommands removes the parts of a raw output line that belong to a
+// denied command, tracking state across lines. When a denied command starts,
+// its already recorded command line is dropped as well. With recordInput, the
+// command lines of other commands are queued in inputLines.
+func (w *lineLimitedWriter) filterCommands(line string) string {
+	var kept strings.Builder
+	rest := line
+	for {
+		loc := commandMarkerRegex.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			break
+		}
+		if !w.suppressing {
+			kept.WriteString(rest[:loc[0]])
+		}
+		kind := rest[loc[2]:loc[3]]
+		params := rest[loc[4]:loc[5]]
+		rest = rest[loc[1]:]
+
+		switch kind {
+		case "C":
+			if w.follower != nil {
+				w.reloadConfig()
+			}
+			command := markerCommandLine(params)
+			if command == "" {
+				continue
+			}
+			if !matchesAny(w.denyCommands, command) {
+				if w.recordInput {
+					w.queueInput(command)
+				}
+				continue
+			}
+			debug.Log("Suppressing denied command in proxy log", map[string]any{})
+			w.suppressing = true
+			if kept.Len() == 0 {
+				w.dropLast(strings.Count(strings.TrimRight(command, "\n"), "\n") + 1)
+			}
+		case "A", "D":
+			w.suppressing = false
+		}
+	}
+	if w.suppressing {
+		if kept.Len() > 0 && !strings.HasSuffix(kept.String(), "\n") {
+			kept.WriteString("\n")
+		}
+		return kept.String()
+	}
+	kept.WriteString(rest)
+	return kept.String()
+}
+
+// queueInput queues each line of command, prefixed with InputMarker.
+func (w *lineLimitedWriter) queueInput(command string) {
+	for line := range strings.SplitSeq(strings.TrimRight(command, "\n"), "\n") {
+		w.inputLines = append(w.inputLines, InputMarker+line+"\n")
+	}
+}
+
+// dropLast removes the n most recently recorded lines. Since they may have
+// been written already, the file is rewritten on the next flush.
+func (w *lineLimitedWriter) dropLast(n int) {
+	w.compact = true
+	for range min(n, w.maxLines) {
+		prev := (w.writePos - 1 + w.maxLines) % w.maxLines
+		if w.lines[prev] == "" {
+			return
+		}
+		w.ringBytes -= len(w.lines[prev])
+		w.lines[prev] = ""
+		w.writePos = prev
+	}
+}
@@ -0,0 +1,139 @@
+//go:build unix
+
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCommandPatterns(t *testing.T) {
+	patterns, err := ParseCommandPatterns("# secrets\n^vault read\n\n  ^op( |$)  \n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("expected 2 patterns, got %d", len(patterns))
+	}
+	if !matchesAny(patterns, "op item get github") || !matchesAny(patterns, "vault read secret/db") {
+		t.Error("expected denied commands to match")
+	}
+	if matchesAny(patterns, "open README.md") {
+		t.Error("expected unrelated command not to match")
+	}
+
+	if _, err := ParseCommandPatterns("vault (read"); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestMarkerCommandLine(t *testing.T) {
+	tests := map[string]string{
+		";cmdline_url=vault%20read%20secret%2Fdb": "vault read secret/db",
+		";cmdline=op item get":                    "op item get",
+		";aid=1;cmdline_url=ls%3B%20pwd":          "ls; pwd",
+		"":                                        "",
+	}
+	for params, want := range tests {
+		if got := markerCommandLine(params); got != want {
+			t.Errorf("markerCommandLine(%q) = %q, want %q", params, got, want)
+		}
+	}
+}
+
+func TestLineLimitedWriter_DenyCommands(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	patterns, err := ParseCommandPatterns("^vault read\n^op ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := newLineLimitedWriter(f, logPath, 20)
+	w.denyCommands = patterns
+
+	session := []string{
+		"$ ls\r\n",
+		"\x1b]133;C;cmdline_url=ls\x07",
+		"file.txt\r\n",
+		"\x1b]133;D;0\x07$ vault read secret/db\r\n",
+		"\x1b]133;C;cmdline_url=vault%20read%20secret%2Fdb\x07password: hun",
+		"ter2\r\nlease: 768h\r\n",
+		"\x1b]133;D;0\x07$ op item get github\r\n",
+		"\x1b]133;C;cmdline=op item get github\x07token-abc\x1b]133;D;0\x07$ echo done\r\n",
+		"\x1b]133;C;cmdline_url=echo%20done\x07done\r\n",
+	}
+	for _, chunk := range session {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	got := string(content)
+	want := "$ ls\nfile.txt\n$ echo done\ndone\n"
+	if got != want {
+		t.Errorf("expected log %q, got %q", want, got)
+	}
+	for _, secret := range []string{"vault", "hunter2", "lease", "op item", "token-abc"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("expected %q to be absent from the log, got %q", secret, got)
+		}
+	}
+}
+
+func TestLineLimitedWriter_RecordInput(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	patterns, err := ParseCommandPatterns("^vault read")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := newLineLimitedWriter(f, logPath, 20)
+	w.denyCommands = patterns
+	w.recordInput = true
+
+	session := []string{
+		"$ ls\r\n",
+		"\x1b]133;C;cmdline_url=ls\x07",
+		"file.txt\r\n",
+		"\x1b]133;D;0\x07$ vault read secret/db\r\n",
+		"\x1b]133;C;cmdline_url=vault%20read%20secret%2Fdb\x07password: hunter2\r\n",
+		"\x1b]133;D;0\x07$ for f in *; do\r\n> echo $f; done\r\n",
+		"\x1b]133;C;cmdline_url=for%20f%20in%20%2A%3B%20do%0Aecho%20%24f%3B%20done\x07file.txt\r\n",
+	}
+	for _, chunk := range session {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	want := "$ ls\n" +
+		InputMarker + "ls\n" +
+		"file.txt\n" +
+		"$ for f in *; do\n> echo $f; done\n" +
+		InputMarker + "for f in *; do\n" +
+		InputMarker + "echo $f; done\n" +
+		"file.txt\n"
+	if got := string(content); got != want {
+		t.Errorf("expected log %q, got %q", want, got)
+	}
+}
@@ -17,74 +17,59 @@ import (
 	"time"
 
 	"github.com/creack/pty"
+	"github.com/xyenon/smart-suggestion/internal/ansi"
 	"github.com/xyenon/smart-suggestion/internal/debug"
+	"github.com/xyenon/smart-suggestion/internal/metrics"
 	"github.com/xyenon/smart-suggestion/internal/session"
 	"golang.org/x/term"
 )
 
-// ansiEscapeRegex matches ANSI escape sequences including:
-// - CSI sequences: ESC [ ... (most common, used for colors, cursor movement, etc.)
-// - OSC sequences: ESC ] ... BEL or ESC ] ... ST (operating system commands)
-// - Other escape sequences: ESC followed by various characters
-var ansiEscapeRegex = regexp.MustCompile(`\x1b(?:\[[0-9;?]*[a-zA-Z]|\][^\x07\x1b]*(?:\x07|\x1b\\)?|\[[^\x1b]*|[PX^_][^\x1b]*\x1b\\|.)`)
-
-// oscContentRegex matches leftover OSC content (e.g., "7;file://..." after ESC ] is stripped)
-var oscContentRegex = regexp.MustCompile(`^\d+;[^\n]*`)
-
-// stripANSI removes ANSI escape sequences and simulates terminal behavior for control characters
-func stripANSI(s string) string {
-	// First pass: remove ANSI escape sequences
-	s = ansiEscapeRegex.ReplaceAllString(s, "")
-	// Second pass: remove leftover OSC content at line start
-	s = oscContentRegex.ReplaceAllString(s, "")
-	// Third pass: simulate terminal behavior
-	s = simulateTerminal(s)
-	return s
-}
-
-// simulateTerminal processes control characters to simulate terminal display
-func simulateTerminal(s string) string {
-	runes := []rune(s)
-	var result []rune
-	for i := 0; i < len(runes); i++ {
-		r := runes[i]
-		switch r {
-		case '\x08': // Backspace: delete previous character
-			if len(result) > 0 && result[len(result)-1] != '\n' {
-				result = result[:len(result)-1]
-			}
-		case '\r': // Carriage return
-			// Check if this is \r\n (Windows line ending) - treat as just \n
-			if i+1 < len(runes) && runes[i+1] == '\n' {
-				continue // Skip \r, the \n will be added in next iteration
-			}
-			// Otherwise, move cursor to beginning of line (erase current line content)
-			lastNewline := -1
-			for j := len(result) - 1; j >= 0; j-- {
-				if result[j] == '\n' {
-					lastNewline = j
-					break
-				}
-			}
-			result = result[:lastNewline+1]
-		case '\x07': // Bell: ignore
-		case '\x00', '\x01', '\x02', '\x03', '\x04', '\x05', '\x06': // Control chars: ignore
-		case '\x0b', '\x0c': // Vertical tab, form feed: treat as newline
-			result = append(result, '\n')
-		case '\x0e', '\x0f', '\x10', '\x11', '\x12', '\x13', '\x14', '\x15', '\x16', '\x17', '\x18', '\x19', '\x1a', '\x1c', '\x1d', '\x1e', '\x1f', '\x7f': // Other control chars: ignore
-		default:
-			result = append(result, r)
-		}
-	}
-	return string(result)
-}
-
 type ProxyOptions struct {
 	LogFile         string
 	SessionID       string
 	ScrollbackLines int
+	// DenyCommands lists patterns for command lines whose echo and output
+	// are kept out of the log. Commands are recognized through OSC 133 marks.
+	DenyCommands []*regexp.Regexp
+	// RecordInput adds each command line the shell runs to the log, prefixed
+	// with InputMarker. Like DenyCommands it relies on OSC 133 marks, which
+	// the plugin emits when RecordInputEnvVar is "true".
+	RecordInput bool
+	// ConfigFile, when set, is re-read as it changes, once per command the
+	// shell runs, so SMART_SUGGESTION_SCROLLBACK_LINES and the deny patterns
+	// apply without restarting the shell. Commands are recognized through
+	// OSC 133 marks, which the plugin emits when FollowEnvVar is "true".
+	ConfigFile string
+	// SyncInterval throttles how often the log is synced to disk after a
+	// write, so a crash loses at most that much context. Zero syncs after
+	// every write; a negative interval syncs only when the session ends.
+	SyncInterval time.Duration
+	// KeepSessionLogs skips deleting session logs older than a day at
+	// startup, leaving retention to the rotate-logs and prune commands.
+	KeepSessionLogs bool
+	// ScrollbackBytes, when positive, also caps the size of the log: the
+	// oldest lines are dropped until the rest fits.
+	ScrollbackBytes int
+	// MetricsFile, when set, is where suggestions in the session record their
+	// latency. A summary of it is printed to stdout when the shell exits.
+	MetricsFile string
 }
 
+// InputMarker prefixes command lines recorded with RecordInput, telling them
+// apart from program output.
+const InputMarker = "[input] "
+
+// RecordInputEnvVar tells the shell inside the proxy to mark the commands it
+// runs, so they can be recorded.
+const RecordInputEnvVar = "SMART_SUGGESTION_PROXY_RECORD_INPUT"
+
+// FollowEnvVar tells the shell inside the proxy to mark the commands it runs,
+// so a followed config file is re-read for each of them.
+const FollowEnvVar = "SMART_SUGGESTION_PROXY_FOLLOW"
+
+// DenyCommandsEnvVar holds the patterns of commands kept out of the log.
+const DenyCommandsEnvVar = "SMART_SUGGESTION_PROXY_DENY_COMMANDS"
+
 var execCommand = exec.Command
 
 func RunProxy(shell string, opts ProxyOptions) error {
@@ -108,9 +93,31 @@ func RunProxyWithIO(shell string, opts ProxyOptions, stdin io.Reader, stdout io.
 	defer cleanupProcessLock(lockFile, sessionLockFile)
 
 	os.Setenv("SMART_SUGGESTION_SESSION_ID", opts.SessionID)
+	os.Setenv(session.ShellEnvVar, session.ShellKind(shell))
 	os.Setenv("SMART_SUGGESTION_PROXY_ACTIVE", fmt.Sprintf("%d", os.Getpid()))
+	if opts.RecordInput {
+		os.Setenv(RecordInputEnvVar, "true")
+	}
+	if opts.ConfigFile != "" {
+		os.Setenv(FollowEnvVar, "true")
+	}
+	if opts.MetricsFile != "" {
+		// Records left by an earlier session with the same ID do not count.
+		if err := os.Remove(opts.MetricsFile); err != nil && !os.IsNotExist(err) {
+			debug.Log("Failed to delete metrics file", map[string]any{
+				"error":        err.Error(),
+				"metrics_file": opts.MetricsFile,
+			})
+		}
+		os.Setenv(metrics.FileEnv, opts.MetricsFile)
+		// Deferred before raw mode is set, so it prints after the terminal
+		// is restored.
+		defer printSessionSummary(stdout, opts.MetricsFile)
+	}
 
-	if err := cleanupOldSessionLogs(opts.LogFile, 24*time.Hour); err != nil {
+	if opts.KeepSessionLogs {
+		debug.Log("Keeping old session logs", map[string]any{"log_file": opts.LogFile})
+	} else if err := cleanupOldSessionLogs(opts.LogFile, 24*time.Hour); err != nil {
 		debug.Log("Failed to cleanup old session logs", map[string]any{"error": err.Error()})
 	}
 
@@ -179,6 +186,13 @@ func RunProxyWithIO(shell string, opts ProxyOptions, stdin io.Reader, stdout io.
 		scrollbackLines = 100
 	}
 	limitedLogWriter := newLineLimitedWriter(logFile, sessionLogFile, scrollbackLines)
+	if opts.ConfigFile != "" {
+		limitedLogWriter.follower = newConfigFollower(opts.ConfigFile)
+	}
+	limitedLogWriter.denyCommands = opts.DenyCommands
+	limitedLogWriter.recordInput = opts.RecordInput
+	limitedLogWriter.syncInterval = opts.SyncInterval
+	limitedLogWriter.maxBytes = opts.ScrollbackBytes
 
 	teeWriter := io.MultiWriter(stdout, limitedLogWriter)
 
@@ -227,9 +241,37 @@ func RunProxyWithIO(shell string, opts ProxyOptions, stdin io.Reader, stdout io.
 
 	_ = c.Wait()
 
+	if err := limitedLogWriter.Close(); err != nil {
+		debug.Log("Failed to sync session log file", map[string]any{
+			"error":    err.Error(),
+			"log_file": sessionLogFile,
+		})
+	}
 	return nil
 }
 
+// printSessionSummary prints the metrics recorded in the session as one line
+// and deletes them. A session without suggestions prints nothing.
+func printSessionSummary(w io.Writer, metricsFile string) {
+	summary, err := metrics.SummarizeFile(metricsFile)
+	if err != nil {
+		debug.Log("Failed to read session metrics", map[string]any{
+			"error":        err.Error(),
+			"metrics_file": metricsFile,
+		})
+		return
+	}
+	if summary.Suggestions > 0 {
+		fmt.Fprintf(w, "smart-suggestion: %s\n", summary)
+	}
+	if err := os.Remove(metricsFile); err != nil && !os.IsNotExist(err) {
+		debug.Log("Failed to delete metrics file", map[string]any{
+			"error":        err.Error(),
+			"metrics_file": metricsFile,
+		})
+	}
+}
+
 func getSessionBasedLockFile(baseLockFile, sessionID string) string {
 	if sessionID == "" {
 		return baseLockFile
@@ -362,6 +404,11 @@ func cleanupOldSessionLogs(baseLogPath string, maxAge time.Duration) error {
 	return nil
 }
 
+// lineLimitedWriter keeps the last maxLines lines written to it in a ring and
+// mirrors them to file. New lines are appended, and the file is only rewritten
+// from the ring once it holds more than twice maxLines lines (or maxBytes
+// bytes), or when lines already written have to go. Close leaves at most
+// maxLines lines and maxBytes bytes in it.
 type lineLimitedWriter struct {
 	file     *os.File
 	filePath string
@@ -369,7 +416,33 @@ type lineLimitedWriter struct {
 	lines    []string
 	writePos int
 	buf      []byte
-	mu       sync.Mutex
+	// maxBytes, when positive, caps the total size of the lines in the ring,
+	// which is ringBytes.
+	maxBytes  int
+	ringBytes int
+	// pending are the lines stored since the last flush, and fileLines and
+	// fileBytes what is in file. compact makes the next flush rewrite it.
+	pending   []string
+	fileLines int
+	fileBytes int
+	compact   bool
+	// follower is polled at each C mark, before its command is matched
+	// against denyCommands.
+	follower *configFollower
+	// denyCommands hides matching commands and their output from the log.
+	denyCommands []*regexp.Regexp
+	suppressing  bool
+	// recordInput queues the command line of each C mark in inputLines, to
+	// be recorded ahead of the line the mark was found in.
+	recordInput bool
+	inputLines  []string
+	// sync is called at most once per syncInterval after a flush, and once
+	// more on Close if anything was written since.
+	sync         func() error
+	syncInterval time.Duration
+	lastSync     time.Time
+	unsynced     bool
+	mu           sync.Mutex
 }
 
 func newLineLimitedWriter(file *os.File, filePath string, maxLines int) *lineLimitedWriter {
@@ -381,6 +454,7 @@ func newLineLimitedWriter(file *os.File, filePath string, maxLines int) *lineLim
 		filePath: filePath,
 		maxLines: maxLines,
 		lines:    make([]string, maxLines),
+		sync:     file.Sync,
 	}
 }
 
@@ -405,26 +479,169 @@ func (w *lineLimitedWriter) Write(p []byte) (n int, err error) {
 		line := string(w.buf[:idx+1])
 		w.buf = w.buf[idx+1:]
 
+		if len(w.denyCommands) > 0 || w.recordInput || w.follower != nil {
+			line = w.filterCommands(line)
+			for _, input := range w.inputLines {
+				w.store(input)
+			}
+			w.inputLines = w.inputLines[:0]
+			if line == "" {
+				continue
+			}
+		}
+
 		// Strip ANSI escape sequences before storing
-		line = stripANSI(line)
-		w.lines[w.writePos] = line
-		w.writePos = (w.writePos + 1) % w.maxLines
+		w.store(ansi.Strip(line))
 	}
 
 	if err := w.flush(); err != nil {
 		return len(p), err
 	}
+	w.unsynced = true
+
+	if w.syncInterval >= 0 && time.Since(w.lastSync) >= w.syncInterval {
+		// A failed sync must not stop the copy to the terminal.
+		if err := w.syncFile(); err != nil {
+			debug.Log("Failed to sync session log file", map[string]any{
+				"error":    err.Error(),
+				"log_file": w.filePath,
+			})
+		}
+	}
 
 	return len(p), nil
 }
 
+// store records line in the ring, overwriting the oldest one when it is full,
+// then drops the oldest lines while the ring holds more than maxBytes. A line
+// longer than maxBytes on its own is dropped before it reaches either the ring
+// or the file.
+func (w *lineLimitedWriter) store(line string) {
+	if w.maxBytes > 0 && len(line) > w.maxBytes {
+		debug.Log("Dropping a line larger than the proxy log size limit", map[string]any{
+			"bytes":     len(line),
+			"max_bytes": w.maxBytes,
+		})
+		return
+	}
+	w.ringBytes += len(line) - len(w.lines[w.writePos])
+	w.lines[w.writePos] = line
+	w.writePos = (w.writePos + 1) % w.maxLines
+	w.pending = append(w.pending, line)
+
+	for i := 0; w.maxBytes > 0 && w.ringBytes > w.maxBytes && i < w.maxLines; i++ {
+		idx := (w.writePos + i) % w.maxLines
+		w.ringBytes -= len(w.lines[idx])
+		w.lines[idx] = ""
+	}
+}
+
+// overLimit reports whether a file with lines lines and size bytes holds more
+// than factor times the limits allow.
+func (w *lineLimitedWriter) overLimit(lines, size, factor int) bool {
+	return lines > factor*w.maxLines || (w.maxBytes > 0 && size > factor*w.maxBytes)
+}
+
+// Close syncs whatever was written since the last sync. The file itself is
+// left to its owner.
+func (w *lineLimitedWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.compact || len(w.pending) > 0 || w.overLimit(w.fileLines, w.fileBytes, 1) {
+		if err := w.rewrite(); err != nil {
+			return err
+		}
+		w.unsynced = true
+	}
+	if !w.unsynced {
+		return nil
+	}
+	return w.syncFile()
+}
+
+func (w *lineLimitedWriter) syncFile() error {
+	w.lastSync = time.Now()
+	w.unsynced = false
+	return w.sync()
+}
+
+// reloadConfig applies the settings of the followed config file, if it has
+// changed.
+func (w *lineLimitedWriter) reloadConfig() {
+	settings, ok := w.follower.poll()
+	if !ok {
+		return
+	}
+	if settings.ScrollbackLines > 0 {
+		w.resize(settings.ScrollbackLines)
+	}
+	if settings.HasDenyCommands {
+		w.denyCommands = settings.DenyCommands
+	}
+}
+
+// resize changes the number of kept lines, preserving the most recent ones.
+// The slots are moved in order, oldest first, whatever they hold, so lines
+// are only ever dropped from the old end.
+func (w *lineLimitedWriter) resize(maxLines int) {
+	if maxLines == w.maxLines {
+		return
+	}
+
+	kept := make([]string, w.maxLines)
+	for i := range kept {
+		kept[i] = w.lines[(w.writePos+i)%w.maxLines]
+	}
+	if len(kept) > maxLines {
+		kept = kept[len(kept)-maxLines:]
+	}
+
+	w.lines = make([]string, maxLines)
+	copy(w.lines, kept)
+	w.ringBytes = 0
+	for _, line := range kept {
+		w.ringBytes += len(line)
+	}
+	w.writePos = len(kept) % maxLines
+	w.maxLines = maxLines
+	w.compact = true
+}
+
+// flush appends the pending lines to the file, or rewrites it when it would
+// grow past twice its limits.
 func (w *lineLimitedWriter) flush() error {
+	pendingBytes := 0
+	for _, line := range w.pending {
+		pendingBytes += len(line)
+	}
+	if w.compact || w.overLimit(w.fileLines+len(w.pending), w.fileBytes+pendingBytes, 2) {
+		return w.rewrite()
+	}
+	for i, line := range w.pending {
+		if _, err := w.file.WriteString(line); err != nil {
+			// Whatever made it to the file is unknown now.
+			w.pending = w.pending[i:]
+			w.compact = true
+			return err
+		}
+		w.fileLines++
+		w.fileBytes += len(line)
+	}
+	w.pending = w.pending[:0]
+	return nil
+}
+
+// rewrite replaces the content of the file with the lines in the ring.
+func (w *lineLimitedWriter) rewrite() error {
+	w.compact = true
 	if err := w.file.Truncate(0); err != nil {
 		return err
 	}
 	if _, err := w.file.Seek(0, 0); err != nil {
 		return err
 	}
+	w.fileLines, w.fileBytes = 0, 0
 	for i := 0; i < w.maxLines; i++ {
 		idx := (w.writePos + i) % w.maxLines
 		line := w.lines[idx]
@@ -434,6 +651,10 @@ func (w *lineLimitedWriter) flush() error {
 		if _, err := w.file.WriteString(line); err != nil {
 			return err
 		}
+		w.fileLines++
+		w.fileBytes += len(line)
 	}
+	w.pending = w.pending[:0]
+	w.compact = false
 	return nil
 }
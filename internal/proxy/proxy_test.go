@@ -4,6 +4,7 @@ package proxy
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/xyenon/smart-suggestion/internal/metrics"
 	"github.com/xyenon/smart-suggestion/internal/session"
 )
 
@@ -284,6 +286,86 @@ func TestRunProxy_Simple(t *testing.T) {
 	}
 }
 
+func TestRunProxy_KeepSessionLogs(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_PROXY_ACTIVE", "")
+	for _, keep := range []bool{true, false} {
+		tempDir := t.TempDir()
+		logFile := filepath.Join(tempDir, "proxy.log")
+		oldLog := filepath.Join(tempDir, "proxy.old.log")
+		if err := os.WriteFile(oldLog, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		oldTime := time.Now().Add(-48 * time.Hour)
+		if err := os.Chtimes(oldLog, oldTime, oldTime); err != nil {
+			t.Fatal(err)
+		}
+
+		err := RunProxyWithIO("true", ProxyOptions{
+			LogFile:         logFile,
+			SessionID:       "test-keep",
+			KeepSessionLogs: keep,
+		}, strings.NewReader(""), io.Discard)
+		if err != nil {
+			t.Fatalf("RunProxy error: %v", err)
+		}
+
+		_, statErr := os.Stat(oldLog)
+		if keep && statErr != nil {
+			t.Errorf("expected the old session log to be kept, got %v", statErr)
+		}
+		if !keep && !os.IsNotExist(statErr) {
+			t.Errorf("expected the old session log to be deleted without KeepSessionLogs, got %v", statErr)
+		}
+	}
+}
+
+func TestRunProxy_SessionSummary(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_PROXY_ACTIVE", "")
+	t.Setenv(metrics.FileEnv, "")
+	tempDir := t.TempDir()
+	metricsFile := filepath.Join(tempDir, "metrics.test-summary.jsonl")
+	// Left over from an earlier session; must not be counted.
+	if err := os.WriteFile(metricsFile, []byte(`{"latency_ms":9000}`+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The shell records two suggestions the way the suggest command would.
+	shell := filepath.Join(tempDir, "shell.sh")
+	script := `#!/bin/sh
+echo '{"latency_ms":300}' >> "$SMART_SUGGESTION_METRICS_FILE"
+echo '{"latency_ms":500,"error":true}' >> "$SMART_SUGGESTION_METRICS_FILE"
+`
+	if err := os.WriteFile(shell, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	err := RunProxyWithIO(shell, ProxyOptions{
+		LogFile:     filepath.Join(tempDir, "proxy.log"),
+		SessionID:   "test-summary",
+		MetricsFile: metricsFile,
+	}, strings.NewReader(""), &stdout)
+	if err != nil {
+		t.Fatalf("RunProxy error: %v", err)
+	}
+
+	want := "smart-suggestion: this session: 2 suggestions, avg 400ms, 1 error\n"
+	if !strings.HasSuffix(stdout.String(), want) {
+		t.Errorf("expected output to end with %q, got %q", want, stdout.String())
+	}
+	if _, err := os.Stat(metricsFile); !os.IsNotExist(err) {
+		t.Errorf("expected the metrics file to be deleted, got %v", err)
+	}
+}
+
+func TestPrintSessionSummary_NoSuggestions(t *testing.T) {
+	var stdout bytes.Buffer
+	printSessionSummary(&stdout, filepath.Join(t.TempDir(), "metrics.jsonl"))
+	if stdout.Len() != 0 {
+		t.Errorf("expected no summary without suggestions, got %q", stdout.String())
+	}
+}
+
 func TestRunProxy_ExistingLog(t *testing.T) {
 	t.Setenv("SMART_SUGGESTION_PROXY_ACTIVE", "")
 	tempDir := t.TempDir()
@@ -351,7 +433,10 @@ func TestLineLimitedWriter_Basic(t *testing.T) {
 		}
 	}
 
-	// Read file content
+	// The file is compacted to the last 3 lines on Close.
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
 	content, err := os.ReadFile(logPath)
 	if err != nil {
 		t.Fatalf("failed to read log file: %v", err)
@@ -369,6 +454,97 @@ func TestLineLimitedWriter_Basic(t *testing.T) {
 	}
 }
 
+func TestLineLimitedWriter_Compaction(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "compact.log")
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	w := newLineLimitedWriter(f, logPath, 2)
+	readLog := func() string {
+		content, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("failed to read log file: %v", err)
+		}
+		return string(content)
+	}
+
+	// Lines are appended until the file holds twice the limit.
+	w.Write([]byte("a\nb\nc\nd\n"))
+	if got := readLog(); got != "a\nb\nc\nd\n" {
+		t.Errorf("expected the lines to be appended, got %q", got)
+	}
+
+	// One more rewrites it with the kept lines only.
+	w.Write([]byte("e\n"))
+	if got := readLog(); got != "d\ne\n" {
+		t.Errorf("expected the file to be compacted, got %q", got)
+	}
+
+	w.Write([]byte("f\n"))
+	if got := readLog(); got != "d\ne\nf\n" {
+		t.Errorf("expected the line to be appended, got %q", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := readLog(); got != "e\nf\n" {
+		t.Errorf("expected at most 2 lines after Close, got %q", got)
+	}
+}
+
+func TestLineLimitedWriter_MaxBytes(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "bytes.log")
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	w := newLineLimitedWriter(f, logPath, 10)
+	w.maxBytes = 10
+	readLog := func() string {
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		content, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("failed to read log file: %v", err)
+		}
+		return string(content)
+	}
+
+	w.Write([]byte("aaaa\nbbbb\n"))
+	if got := readLog(); got != "aaaa\nbbbb\n" {
+		t.Errorf("expected both lines within the limit, got %q", got)
+	}
+
+	// The oldest line goes once the size passes the limit.
+	w.Write([]byte("cc\n"))
+	if got := readLog(); got != "bbbb\ncc\n" {
+		t.Errorf("expected the oldest line to be dropped, got %q", got)
+	}
+
+	// A line larger than the limit is left out of both the ring and the
+	// file, without pushing out the lines before it.
+	w.Write([]byte("0123456789abc\n"))
+	if got := readLog(); got != "bbbb\ncc\n" {
+		t.Errorf("expected the oversized line to be dropped, got %q", got)
+	}
+	w.Write([]byte("d\n"))
+	if got := readLog(); got != "bbbb\ncc\nd\n" {
+		t.Errorf("expected the next line to be appended, got %q", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := readLog(); got != "bbbb\ncc\nd\n" {
+		t.Errorf("expected Close to leave the file as it is, got %q", got)
+	}
+}
+
 func TestLineLimitedWriter_PartialWrites(t *testing.T) {
 	tempDir := t.TempDir()
 	logPath := filepath.Join(tempDir, "partial.log")
@@ -452,6 +628,7 @@ func TestLineLimitedWriter_ExactLimit(t *testing.T) {
 
 	// Add one more line - oldest should be removed
 	w.Write([]byte("d\n"))
+	w.Close()
 	content, _ = os.ReadFile(logPath)
 	expected = "b\nc\nd\n"
 	if string(content) != expected {
@@ -473,6 +650,7 @@ func TestLineLimitedWriter_MultipleNewlinesInOneWrite(t *testing.T) {
 
 	// Write multiple lines at once
 	w.Write([]byte("line1\nline2\nline3\nline4\n"))
+	w.Close()
 
 	content, _ := os.ReadFile(logPath)
 	expected := "line3\nline4\n"
@@ -525,187 +703,6 @@ func TestLineLimitedWriter_SingleLine(t *testing.T) {
 	}
 }
 
-func TestStripANSI(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "no escape sequences",
-			input:    "hello world",
-			expected: "hello world",
-		},
-		{
-			name:     "simple color",
-			input:    "\x1b[31mred text\x1b[0m",
-			expected: "red text",
-		},
-		{
-			name:     "bold and color",
-			input:    "\x1b[1;32mbold green\x1b[0m",
-			expected: "bold green",
-		},
-		{
-			name:     "cursor movement",
-			input:    "\x1b[2Jclear screen\x1b[H",
-			expected: "clear screen",
-		},
-		{
-			name:     "OSC sequence (window title)",
-			input:    "\x1b]0;Window Title\x07content",
-			expected: "content",
-		},
-		{
-			name:     "OSC 7 file URL",
-			input:    "\x1b]7;file://hostname/path\x07content",
-			expected: "content",
-		},
-		{
-			name:     "leftover OSC content at line start",
-			input:    "7;file://M20RQRV6G4/Users/bytedance\nactual content",
-			expected: "\nactual content",
-		},
-		{
-			name:     "mixed content",
-			input:    "start \x1b[31mred\x1b[0m middle \x1b[1mbold\x1b[0m end",
-			expected: "start red middle bold end",
-		},
-		{
-			name:     "256 color",
-			input:    "\x1b[38;5;196mred\x1b[0m",
-			expected: "red",
-		},
-		{
-			name:     "RGB color",
-			input:    "\x1b[38;2;255;0;0mred\x1b[0m",
-			expected: "red",
-		},
-		{
-			name:     "cursor save/restore",
-			input:    "\x1b7saved\x1b8restored",
-			expected: "savedrestored",
-		},
-		{
-			name:     "erase line",
-			input:    "text\x1b[Kerased",
-			expected: "texterased",
-		},
-		{
-			name:     "backspace simulates deletion",
-			input:    "abc\x08\x08xy",
-			expected: "axy",
-		},
-		{
-			name:     "backspace at line start",
-			input:    "line1\n\x08\x08line2",
-			expected: "line1\nline2",
-		},
-		{
-			name:     "carriage return overwrites line",
-			input:    "old text\rnew",
-			expected: "new",
-		},
-		{
-			name:     "carriage return with newline",
-			input:    "line1\r\nline2",
-			expected: "line1\nline2",
-		},
-		{
-			name:     "bell character removed",
-			input:    "alert\x07text",
-			expected: "alerttext",
-		},
-		{
-			name:     "progress bar simulation",
-			input:    "Loading... 10%\rLoading... 50%\rLoading... 100%",
-			expected: "Loading... 100%",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := stripANSI(tt.input)
-			if got != tt.expected {
-				t.Errorf("stripANSI(%q) = %q, want %q", tt.input, got, tt.expected)
-			}
-		})
-	}
-}
-
-func TestSimulateTerminal(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "simple text",
-			input:    "hello",
-			expected: "hello",
-		},
-		{
-			name:     "backspace deletes char",
-			input:    "ab\x08c",
-			expected: "ac",
-		},
-		{
-			name:     "multiple backspaces",
-			input:    "abcd\x08\x08\x08xyz",
-			expected: "axyz",
-		},
-		{
-			name:     "backspace at start does nothing",
-			input:    "\x08\x08abc",
-			expected: "abc",
-		},
-		{
-			name:     "backspace stops at newline",
-			input:    "line1\n\x08\x08abc",
-			expected: "line1\nabc",
-		},
-		{
-			name:     "carriage return resets line",
-			input:    "hello\rworld",
-			expected: "world",
-		},
-		{
-			name:     "CR preserves previous lines",
-			input:    "line1\nold\rnew",
-			expected: "line1\nnew",
-		},
-		{
-			name:     "CRLF becomes LF",
-			input:    "a\r\nb",
-			expected: "a\nb",
-		},
-		{
-			name:     "vertical tab becomes newline",
-			input:    "a\x0bb",
-			expected: "a\nb",
-		},
-		{
-			name:     "form feed becomes newline",
-			input:    "a\x0cb",
-			expected: "a\nb",
-		},
-		{
-			name:     "spinner simulation",
-			input:    "|\r/\r-\r\\\r|",
-			expected: "|",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := simulateTerminal(tt.input)
-			if got != tt.expected {
-				t.Errorf("simulateTerminal(%q) = %q, want %q", tt.input, got, tt.expected)
-			}
-		})
-	}
-}
-
 func TestLineLimitedWriter_StripANSI(t *testing.T) {
 	tempDir := t.TempDir()
 	logPath := filepath.Join(tempDir, "ansi.log")
@@ -739,3 +736,75 @@ func TestLineLimitedWriter_StripANSI(t *testing.T) {
 		t.Errorf("expected 'normal line', got %q", lines[2])
 	}
 }
+
+func TestLineLimitedWriter_Sync(t *testing.T) {
+	tests := []struct {
+		name         string
+		syncInterval time.Duration
+		// wantSyncs after three writes, and after Close.
+		wantSyncs      int
+		wantCloseSyncs int
+	}{
+		{name: "every write", syncInterval: 0, wantSyncs: 3, wantCloseSyncs: 3},
+		{name: "throttled", syncInterval: time.Hour, wantSyncs: 1, wantCloseSyncs: 2},
+		{name: "only on close", syncInterval: -1, wantSyncs: 0, wantCloseSyncs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "sync.log")
+			f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+			if err != nil {
+				t.Fatalf("failed to create log file: %v", err)
+			}
+			defer f.Close()
+
+			w := newLineLimitedWriter(f, logPath, 5)
+			w.syncInterval = tt.syncInterval
+			syncs := 0
+			w.sync = func() error {
+				syncs++
+				return f.Sync()
+			}
+
+			for _, line := range []string{"one\n", "two\n", "three\n"} {
+				if _, err := w.Write([]byte(line)); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+			}
+			if syncs != tt.wantSyncs {
+				t.Errorf("expected %d syncs after writes, got %d", tt.wantSyncs, syncs)
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if syncs != tt.wantCloseSyncs {
+				t.Errorf("expected %d syncs after Close, got %d", tt.wantCloseSyncs, syncs)
+			}
+			// Nothing new to sync on a second Close.
+			w.Close()
+			if syncs != tt.wantCloseSyncs {
+				t.Errorf("expected no sync without new writes, got %d", syncs)
+			}
+		})
+	}
+}
+
+func TestLineLimitedWriter_SyncError(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "sync.log")
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	w := newLineLimitedWriter(f, logPath, 5)
+	w.sync = func() error { return errors.New("disk gone") }
+	if _, err := w.Write([]byte("line\n")); err != nil {
+		t.Errorf("expected a failed sync not to fail the write, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("expected nothing left to sync, got %v", err)
+	}
+}
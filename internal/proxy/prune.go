@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PrunedFile is a file removed by PruneCache.
+type PrunedFile struct {
+	Path string
+	Size int64
+}
+
+// PruneCache removes the files under dir that were last modified longer ago
+// than olderThan. Files of a live session are kept regardless of their age: a
+// lock file whose process is still running, and any file sharing its name
+// (a session log next to its proxy lock).
+func PruneCache(dir string, olderThan time.Duration) ([]PrunedFile, error) {
+	if olderThan <= 0 {
+		return nil, fmt.Errorf("age must be positive, got %s", olderThan)
+	}
+	cutoff := time.Now().Add(-olderThan)
+
+	var pruned []PrunedFile
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+		if entry.IsDir() || isLiveSessionFile(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		pruned = append(pruned, PrunedFile{Path: path, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return pruned, fmt.Errorf("failed to prune %s: %w", dir, err)
+	}
+	return pruned, nil
+}
+
+// isLiveSessionFile reports whether path is, or belongs to, a lock held by a
+// running process.
+func isLiveSessionFile(path string) bool {
+	lockPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".lock"
+	return isProcessRunning(lockPath)
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPruneCache(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-10 * 24 * time.Hour)
+
+	files := map[string]string{
+		"ratelimit.json":            "{}",
+		"debug.log":                 "log",
+		"proxy.dead.log":            "dead session",
+		"proxy.dead.lock":           "999999999\n",
+		"proxy.live.log":            "live session",
+		"proxy.live.lock":           fmt.Sprintf("%d\n", os.Getpid()),
+		"suggest.tty1.lock":         "999999999\n",
+		"records/2025-01-01.json":   "{}",
+		"fresh.json":                "{}",
+		"proxy.log-20250101.log.gz": "gz",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		if name != "fresh.json" {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatalf("failed to age %s: %v", name, err)
+			}
+		}
+	}
+
+	pruned, err := PruneCache(dir, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var removed []string
+	for _, file := range pruned {
+		rel, _ := filepath.Rel(dir, file.Path)
+		removed = append(removed, rel)
+		if file.Size != int64(len(files[rel])) {
+			t.Errorf("%s: expected size %d, got %d", rel, len(files[rel]), file.Size)
+		}
+	}
+	slices.Sort(removed)
+	want := []string{"debug.log", "proxy.dead.lock", "proxy.dead.log", "proxy.log-20250101.log.gz", "ratelimit.json", "records/2025-01-01.json", "suggest.tty1.lock"}
+	if !slices.Equal(removed, want) {
+		t.Errorf("expected %v removed, got %v", want, removed)
+	}
+
+	for _, kept := range []string{"proxy.live.log", "proxy.live.lock", "fresh.json"} {
+		if _, err := os.Stat(filepath.Join(dir, kept)); err != nil {
+			t.Errorf("expected %s to be kept: %v", kept, err)
+		}
+	}
+}
+
+func TestPruneCacheErrors(t *testing.T) {
+	if _, err := PruneCache(t.TempDir(), 0); err == nil {
+		t.Error("expected an error for a zero age")
+	}
+	pruned, err := PruneCache(filepath.Join(t.TempDir(), "missing"), time.Hour)
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("expected nothing to prune in a missing cache dir, got %v, %v", pruned, err)
+	}
+}
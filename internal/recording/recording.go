@@ -0,0 +1,72 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/jsonl"
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+// FileEnv names the bundle that suggestions are recorded in when --record is
+// not given.
+const FileEnv = "SMART_SUGGESTION_RECORD_FILE"
+
+// Entry is everything that went into and came out of a single suggestion,
+// enough to send the same request again or parse the same response again.
+type Entry struct {
+	Timestamp    time.Time          `json:"timestamp"`
+	Provider     string             `json:"provider"`
+	Model        string             `json:"model,omitempty"`
+	Input        string             `json:"input"`
+	Context      string             `json:"context,omitempty"`
+	SystemPrompt string             `json:"system_prompt"`
+	History      []provider.Message `json:"history,omitempty"`
+	// UserInput is the user message sent to the provider: the input
+	// described for the model, followed by the context.
+	UserInput string `json:"user_input"`
+	Response  string `json:"response,omitempty"`
+	// Parsed is the command extracted from Response; Suggestion is what was
+	// written for the shell after cleaning it up.
+	Parsed     string `json:"parsed,omitempty"`
+	Suggestion string `json:"suggestion,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Append writes entry as a JSON line to the bundle at path. The bundle holds
+// the user's context, so it is created readable by the owner only.
+func Append(path string, entry Entry) error {
+	return jsonl.Append(path, entry)
+}
+
+// Read returns the entries of the bundle at path, in the order they were
+// recorded.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	// Entries carry the whole scrollback, far beyond the default line limit.
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid recording at %s:%d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return entries, nil
+}
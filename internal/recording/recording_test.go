@@ -0,0 +1,72 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/provider"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "session.jsonl")
+	entries := []Entry{
+		{
+			Timestamp:    time.Unix(1700000000, 0).UTC(),
+			Provider:     "openai",
+			Model:        "gpt-4o-mini",
+			Input:        "ls",
+			Context:      "# Shell History:\ncd src",
+			SystemPrompt: "prompt",
+			History:      []provider.Message{{Role: "user", Content: "git st"}, {Role: "assistant", Content: "=git status"}},
+			UserInput:    "ls\n\n# Shell History:\ncd src",
+			Response:     "<reasoning>list</reasoning>=ls -la",
+			Parsed:       "=ls -la",
+			Suggestion:   "=ls -la",
+		},
+		{
+			Timestamp: time.Unix(1700000060, 0).UTC(),
+			Provider:  "anthropic",
+			Input:     "git",
+			Error:     "rate limited",
+		},
+	}
+	for _, entry := range entries {
+		if err := Append(path, entry); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat recording: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected permissions 0600, got %o", perm)
+	}
+
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("expected %+v, got %+v", entries, got)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte("{\"input\":\"ls\"}\n\n{oops\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(path); err == nil || !strings.Contains(err.Error(), ":3:") {
+		t.Errorf("expected an error naming line 3, got %v", err)
+	}
+
+	if _, err := Read(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("expected an error for a missing bundle")
+	}
+}
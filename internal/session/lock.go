@@ -0,0 +1,61 @@
+//go:build unix
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ErrLocked is returned by Lock when another process kept the lock for the
+// whole wait.
+var ErrLocked = errors.New("lock is held by another process")
+
+// lockRetryInterval is how often Lock retries while another process holds it.
+const lockRetryInterval = 20 * time.Millisecond
+
+// Lock takes an exclusive advisory lock on lockPath, waiting up to wait for
+// another holder to release it. The returned function releases the lock.
+//
+// The lock file is left in place on release: removing it would let a waiter
+// lock the unlinked file while a newcomer locks a fresh one.
+func Lock(lockPath string, wait time.Duration) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			file.Close()
+			return nil, fmt.Errorf("failed to acquire lock: %w", err)
+		}
+		if !time.Now().Before(deadline) {
+			file.Close()
+			return nil, ErrLocked
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	if err := file.Truncate(0); err == nil {
+		fmt.Fprintf(file, "%d\n", os.Getpid())
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
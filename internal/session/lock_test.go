@@ -0,0 +1,51 @@
+//go:build unix
+
+package session
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock_SecondHolderTimesOut(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "locks", "suggest.lock")
+
+	release, err := Lock(lockPath, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+
+	start := time.Now()
+	if _, err := Lock(lockPath, 50*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected Lock to wait, returned after %s", elapsed)
+	}
+}
+
+func TestLock_WaitsForRelease(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "suggest.lock")
+
+	release, err := Lock(lockPath, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.AfterFunc(30*time.Millisecond, release)
+
+	second, err := Lock(lockPath, time.Second)
+	if err != nil {
+		t.Fatalf("expected lock after release, got %v", err)
+	}
+	second()
+
+	// Released locks can be taken again straight away.
+	third, err := Lock(lockPath, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	third()
+}
@@ -0,0 +1,26 @@
+package session
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ShellEnvVar is set in the proxied shell's environment to the kind of shell
+// the proxy started, so context building knows which syntax is in use.
+const ShellEnvVar = "SMART_SUGGESTION_PROXY_SHELL"
+
+// ShellKind returns a short name for the shell at path: "zsh", "bash", "fish",
+// "pwsh" (for both pwsh and powershell), or the lower-cased executable name.
+func ShellKind(path string) string {
+	name := strings.ToLower(filepath.Base(strings.TrimSpace(path)))
+	name = strings.TrimPrefix(name, "-") // login shells
+	name = strings.TrimSuffix(name, ".exe")
+	switch name {
+	case "", ".":
+		return ""
+	case "powershell":
+		return "pwsh"
+	default:
+		return name
+	}
+}
@@ -0,0 +1,21 @@
+package session
+
+import "testing"
+
+func TestShellKind(t *testing.T) {
+	tests := map[string]string{
+		"/usr/bin/fish":          "fish",
+		"/opt/homebrew/bin/fish": "fish",
+		"/usr/bin/pwsh":          "pwsh",
+		"powershell.exe":         "pwsh",
+		"-zsh":                   "zsh",
+		"/bin/bash":              "bash",
+		"/usr/local/bin/nu":      "nu",
+		"":                       "",
+	}
+	for path, want := range tests {
+		if got := ShellKind(path); got != want {
+			t.Errorf("ShellKind(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -2,14 +2,23 @@ package shellcontext
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
+	"github.com/xyenon/smart-suggestion/internal/ansi"
 	"github.com/xyenon/smart-suggestion/internal/debug"
 	"github.com/xyenon/smart-suggestion/internal/paths"
 	"github.com/xyenon/smart-suggestion/internal/session"
@@ -17,34 +26,190 @@ import (
 
 var (
 	execCommand = exec.Command
-	runtimeGOOS = runtime.GOOS
+	// execCommandContext runs the commands that are bounded by a timeout.
+	execCommandContext = exec.CommandContext
+	runtimeGOOS        = runtime.GOOS
+	runtimeGOARCH      = runtime.GOARCH
+
+	currentUser       = user.Current
+	lookupGroupID     = user.LookupGroupId
+	osHostname        = os.Hostname
+	kernelReleaseFile = "/proc/sys/kernel/osrelease"
+
+	aliasDetectTimeout = 2 * time.Second
 )
 
+var errNoScrollback = errors.New("no scrollback available - not in tmux/screen session and no proxy log found")
+
+// TypescriptEnvVar holds the file a shell started under script(1) records its
+// session to. The plugin sets it in script mode so the typescript can serve as
+// scrollback on terminals offering nothing else.
+const TypescriptEnvVar = "SMART_SUGGESTION_TYPESCRIPT"
+
+// maxAliasBytes caps the detected alias list sent as context.
+const maxAliasBytes = 16 * 1024
+
+// shellSyntaxHints are added to the context for shells whose syntax differs
+// from the POSIX shells the prompt examples are written in.
+var shellSyntaxHints = map[string]string{
+	"fish": "Use fish syntax: `set -x NAME value` instead of `export`, `(command)` instead of `$(command)`, and `; and` / `; or` where older fish lacks `&&` / `||`.",
+	"pwsh": "Use PowerShell syntax: cmdlets such as Get-ChildItem and Select-String, `$env:NAME` for environment variables, and `;` to chain commands.",
+}
+
+// promptShellPatterns recognize the default prompts of fish (user@host ~>)
+// and PowerShell (PS /path>) in recorded scrollback.
+var promptShellPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{kind: "pwsh", re: regexp.MustCompile(`(?m)^PS [^\n>]*> `)},
+	{kind: "fish", re: regexp.MustCompile(`(?m)^[\w.-]+@[\w.-]+ [^\s>]*> `)},
+}
+
+// errorLinePattern recognizes lines worth keeping when scrollback is trimmed:
+// errors, failures, tracebacks and non-zero exit statuses.
+var errorLinePattern = regexp.MustCompile(`(?i)\b(?:error|fatal|panic|traceback|exception|failed|failure|segmentation fault|command not found|permission denied|no such file or directory)\b|\bexit(?:ed)?(?: with)?(?: code| status)?:? [1-9][0-9]*\b|non-zero exit`)
+
+// promptLinePattern recognizes common interactive prompts in scrollback:
+// user@host prompts (bash, zsh, fish) and directory prompts ending in $, #, %
+// or >, PowerShell's "PS path>", the arrows of starship and popular zsh
+// themes, and bare $ or % prompts.
+var promptLinePattern = regexp.MustCompile(`^(?:PS [^>]*> |[❯➜λ»] |\S*@\S+[^$#%>]{0,60}[$#%>] |[~/][^\s$#%>]*\s?[$#%>] |[$%] )`)
+
+// SystemContextOptions controls what BuildSystemContext gathers
+type SystemContextOptions struct {
+	// DetectAliases runs `$SHELL -ic alias` when SMART_SUGGESTION_ALIASES is not set
+	DetectAliases bool
+}
+
 // BuildSystemContext builds context info for system prompt (static: header, aliases, commands)
-func BuildSystemContext() (string, error) {
+func BuildSystemContext(opts SystemContextOptions) (string, error) {
 	var builder strings.Builder
 	builder.WriteString(buildContextHeader())
 
-	appendContextSection(&builder, "This is the alias defined in your shell", getAliases)
-	appendContextSection(&builder, "Available PATH commands", getAvailableCommands)
+	var sections contextSections
+	sections.add(sectionAliases, func() (string, error) {
+		return getAliases(opts)
+	})
+	sections.add(sectionCommands, getAvailableCommands)
+	sections.writeTo(&builder)
 
 	return strings.TrimSpace(builder.String()), nil
 }
 
-// BuildUserContext builds context info for user message (dynamic: history, scrollback)
-func BuildUserContext(scrollbackLines int, scrollbackFile string) (string, error) {
-	if scrollbackLines < 0 {
-		scrollbackLines = 0
+// UserContextOptions controls what BuildUserContext gathers
+type UserContextOptions struct {
+	// ScrollbackLines limits the scrollback to the most recent lines (0 = no
+	// scrollback section; CaptureScrollback reads it as all lines)
+	ScrollbackLines int
+	// HistoryLines limits the shell history to the most recent entries (0 = all)
+	HistoryLines int
+	// ScrollbackFile is a scrollback dump to read first (Ghostty integration)
+	ScrollbackFile string
+	// SessionID reads the proxy log of another session instead of the current one
+	SessionID string
+	// SelectHistory narrows the history lines sent as context (nil = send all)
+	SelectHistory func(entries []string) ([]string, error)
+	// SinceLastPrompt keeps only the scrollback from the last command's prompt on
+	SinceLastPrompt bool
+	// PrioritizeErrors keeps earlier error lines when the scrollback is trimmed
+	// to ScrollbackLines, instead of only the most recent lines
+	PrioritizeErrors bool
+	// Input is the command line being completed; its first word selects the
+	// tool sections (git, kube, docker) worth gathering
+	Input string
+	// MaxBytes limits the size of the context by dropping the oldest
+	// scrollback lines, then the oldest history entries (0 = no limit)
+	MaxBytes int
+	// Redact rewrites each section before the context is measured against
+	// MaxBytes, so the limit holds for what is sent (nil = send as gathered)
+	Redact func(string) string
+}
+
+// BuildUserContext builds context info for user message (dynamic: history, scrollback, tool state)
+func BuildUserContext(opts UserContextOptions) (string, error) {
+	if opts.ScrollbackLines < 0 {
+		opts.ScrollbackLines = 0
+	}
+
+	var sections contextSections
+	sections.add(sectionHistory, func() (string, error) {
+		return selectHistory(opts)
+	})
+	var scrollback string
+	if opts.ScrollbackLines > 0 {
+		sections.add(sectionScrollback, func() (string, error) {
+			content, err := getScrollback(opts)
+			scrollback = content
+			return content, err
+		})
+	}
+	addToolSections(&sections, opts.Input)
+	addProcSections(&sections)
+	if opts.Redact != nil {
+		for i := range sections {
+			sections[i].content = opts.Redact(sections[i].content)
+		}
+	}
+
+	duration := getLastDuration()
+	// Without a shell tag from the proxy, fall back to the recorded prompts.
+	// A tagged shell is trusted even when it gets no hint of its own.
+	var shellHint string
+	if os.Getenv(session.ShellEnvVar) == "" {
+		kind := promptShellKind(scrollback)
+		if hint, ok := shellSyntaxHints[kind]; ok {
+			shellHint = fmt.Sprintf("The scrollback shows %s prompts. %s", kind, hint)
+		}
 	}
 
+	context := writeUserContext(duration, sections, shellHint)
+	if opts.MaxBytes > 0 && len(context) > opts.MaxBytes {
+		var trim contextTrim
+		sections, trim = sections.trimmed(len(context) - opts.MaxBytes)
+		context = writeUserContext(duration, sections, shellHint)
+		debug.Log("Trimmed context to fit the prompt budget", map[string]any{
+			"max_bytes":        opts.MaxBytes,
+			"scrollback_bytes": trim.scrollback,
+			"history_bytes":    trim.history,
+			"context_bytes":    len(context),
+		})
+	}
+	return context, nil
+}
+
+// writeUserContext assembles the user context from the duration of the last
+// command, the sections and the shell syntax hint, any of which may be empty.
+func writeUserContext(duration string, sections contextSections, shellHint string) string {
 	var builder strings.Builder
+	if duration != "" {
+		builder.WriteString("\n\n" + duration)
+	}
+	sections.writeTo(&builder)
+	if shellHint != "" {
+		builder.WriteString("\n\n" + shellHint)
+	}
+	return strings.TrimSpace(builder.String())
+}
 
-	appendContextSection(&builder, "Shell history", getHistory)
-	appendContextSection(&builder, "Scrollback", func() (string, error) {
-		return getScrollback(scrollbackLines, scrollbackFile)
-	})
+// currentShellKind returns the shell the proxy tagged the session with, or
+// the kind of $SHELL otherwise.
+func currentShellKind() string {
+	if kind := os.Getenv(session.ShellEnvVar); kind != "" {
+		return kind
+	}
+	return session.ShellKind(os.Getenv("SHELL"))
+}
 
-	return strings.TrimSpace(builder.String()), nil
+// promptShellKind guesses the shell from prompts in the scrollback, returning
+// "" when none are recognized.
+func promptShellKind(scrollback string) string {
+	for _, pattern := range promptShellPatterns {
+		if pattern.re.MatchString(scrollback) {
+			return pattern.kind
+		}
+	}
+	return ""
 }
 
 func buildContextHeader() string {
@@ -59,6 +224,9 @@ func buildContextHeader() string {
 	}
 
 	shell := os.Getenv("SHELL")
+	if kind := os.Getenv(session.ShellEnvVar); kind != "" && kind != session.ShellKind(shell) {
+		shell = kind
+	}
 	if shell == "" {
 		shell = "unknown"
 	}
@@ -72,24 +240,12 @@ func buildContextHeader() string {
 	userID := getUserID()
 	unameInfo := getUnameInfo()
 
-	return fmt.Sprintf("# Context:\n\nYou are user %s with id %s in directory %s. Your shell is %s and your terminal is %s running on %s. %s",
+	header := fmt.Sprintf("# Context:\n\nYou are user %s with id %s in directory %s. Your shell is %s and your terminal is %s running on %s. %s",
 		currentUser, userID, currentDir, shell, term, unameInfo, sysInfo)
-}
-
-func appendContextSection(builder *strings.Builder, title string, getter func() (string, error)) {
-	value, err := getter()
-	if err != nil {
-		debug.Log("Failed to get context section", map[string]any{
-			"section": title,
-			"error":   err.Error(),
-		})
-		return
-	}
-	if value == "" {
-		return
+	if hint, ok := shellSyntaxHints[currentShellKind()]; ok {
+		header = strings.TrimRight(header, " ") + " " + hint
 	}
-	builder.WriteString(fmt.Sprintf("\n\n# %s:\n\n", title))
-	builder.WriteString(value)
+	return header
 }
 
 func getSystemInfo() string {
@@ -133,7 +289,17 @@ func getSystemInfo() string {
 	return fmt.Sprintf("Your system is %s.", processedContent)
 }
 
+// getUserID describes the current user like `id` does, falling back to
+// running `id` only when the user database has no entry for us.
 func getUserID() string {
+	if u, err := currentUser(); err == nil {
+		group := u.Gid
+		if g, err := lookupGroupID(u.Gid); err == nil {
+			group = fmt.Sprintf("%s(%s)", u.Gid, g.Name)
+		}
+		return fmt.Sprintf("uid=%s(%s) gid=%s", u.Uid, u.Username, group)
+	}
+
 	out, err := execCommand("id").Output()
 	if err != nil {
 		return "unknown"
@@ -141,7 +307,19 @@ func getUserID() string {
 	return strings.TrimSpace(string(out))
 }
 
+// getUnameInfo describes the OS, host, kernel release and architecture. It is
+// built from Go-native sources and only runs `uname -a` when the hostname is
+// unavailable.
 func getUnameInfo() string {
+	if hostname, err := osHostname(); err == nil {
+		parts := []string{unameSystemName(runtimeGOOS), hostname}
+		if release := kernelRelease(); release != "" {
+			parts = append(parts, release)
+		}
+		parts = append(parts, runtimeGOARCH)
+		return strings.Join(parts, " ")
+	}
+
 	out, err := execCommand("uname", "-a").Output()
 	if err != nil {
 		return "unknown"
@@ -149,14 +327,107 @@ func getUnameInfo() string {
 	return strings.TrimSpace(string(out))
 }
 
-func getAliases() (string, error) {
+// unameSystemName maps GOOS to the kernel name `uname -s` would print.
+func unameSystemName(goos string) string {
+	switch goos {
+	case "linux", "android":
+		return "Linux"
+	case "darwin":
+		return "Darwin"
+	case "freebsd":
+		return "FreeBSD"
+	case "openbsd":
+		return "OpenBSD"
+	case "netbsd":
+		return "NetBSD"
+	default:
+		return goos
+	}
+}
+
+// kernelRelease reads the kernel release where the OS exposes it as a file.
+func kernelRelease() string {
+	data, err := os.ReadFile(kernelReleaseFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func getAliases(opts SystemContextOptions) (string, error) {
 	aliases := os.Getenv("SMART_SUGGESTION_ALIASES")
 	if aliases != "" {
 		return strings.TrimSpace(aliases), nil
 	}
+	if opts.DetectAliases {
+		return detectAliases()
+	}
 	return "", nil
 }
 
+// detectAliases lists the aliases of the user's interactive shell. Starting an
+// interactive shell sources the user's rc files, so it is bounded by
+// aliasDetectTimeout and its output is sanitized and capped at maxAliasBytes.
+func detectAliases() (string, error) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), aliasDetectTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := execCommandContext(ctx, shell, "-ic", "alias")
+	cmd.Stdin = nil
+	cmd.Stdout = &stdout
+	cmd.Stderr = nil
+	// A background job of the rc files can keep stdout open after the shell
+	// is killed; stop waiting for it as well.
+	cmd.WaitDelay = aliasDetectTimeout
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start %s: %w", shell, err)
+	}
+	err := cmd.Wait()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("listing aliases with %s timed out after %s", shell, aliasDetectTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to list aliases with %s: %w", shell, err)
+	}
+
+	return sanitizeAliases(stdout.String(), maxAliasBytes), nil
+}
+
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b[@-_]`)
+
+// sanitizeAliases strips escape sequences and control characters, drops blank
+// lines and cuts the result at a line boundary so it fits in limit bytes.
+func sanitizeAliases(output string, limit int) string {
+	output = ansiEscapePattern.ReplaceAllString(output, "")
+
+	var builder strings.Builder
+	for line := range strings.Lines(output) {
+		line = strings.Map(func(r rune) rune {
+			if r == '\t' || !unicode.IsControl(r) {
+				return r
+			}
+			return -1
+		}, line)
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if builder.Len()+len(line)+1 > limit {
+			break
+		}
+		builder.WriteString(line)
+		builder.WriteByte('\n')
+	}
+	return strings.TrimSpace(builder.String())
+}
+
 func getAvailableCommands() (string, error) {
 	commands := os.Getenv("SMART_SUGGESTION_COMMANDS")
 	if commands != "" {
@@ -165,25 +436,133 @@ func getAvailableCommands() (string, error) {
 	return "", nil
 }
 
-func getHistory() (string, error) {
-	history := os.Getenv("SMART_SUGGESTION_HISTORY")
-	if history != "" {
-		return strings.TrimSpace(history), nil
+func getHistory(limit int) (string, error) {
+	history := strings.TrimSpace(os.Getenv("SMART_SUGGESTION_HISTORY"))
+	if history == "" || limit <= 0 {
+		return history, nil
 	}
-	return "", nil
+
+	lines := strings.Split(history, "\n")
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return strings.Join(lines, "\n"), nil
 }
 
-func getScrollback(scrollbackLines int, scrollbackFile string) (string, error) {
-	content, err := doGetScrollback(scrollbackLines, scrollbackFile)
+// selectHistory returns the shell history, narrowed by opts.SelectHistory when
+// set. If selection fails, the full history is used.
+func selectHistory(opts UserContextOptions) (string, error) {
+	history, err := getHistory(opts.HistoryLines)
+	if err != nil || history == "" || opts.SelectHistory == nil {
+		return history, err
+	}
+
+	var entries []string
+	for _, line := range strings.Split(history, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	selected, err := opts.SelectHistory(entries)
+	if err != nil {
+		debug.Log("Failed to select history, sending it all", map[string]any{
+			"error": err.Error(),
+		})
+		return history, nil
+	}
+	return strings.Join(selected, "\n"), nil
+}
+
+// getLastDuration describes SMART_SUGGESTION_LAST_DURATION, the run time of the
+// previous command in seconds as exported by the plugin. Invalid values are ignored.
+func getLastDuration() string {
+	value := strings.TrimSpace(os.Getenv("SMART_SUGGESTION_LAST_DURATION"))
+	if value == "" {
+		return ""
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		debug.Log("Ignoring invalid SMART_SUGGESTION_LAST_DURATION", map[string]any{
+			"value": value,
+		})
+		return ""
+	}
+
+	formatted := strconv.FormatFloat(seconds, 'f', 0, 64)
+	if seconds < 10 {
+		formatted = strings.TrimSuffix(strconv.FormatFloat(seconds, 'f', 1, 64), ".0")
+	}
+	unit := "seconds"
+	if formatted == "1" {
+		unit = "second"
+	}
+	return fmt.Sprintf("The last command took %s %s.", formatted, unit)
+}
+
+// SessionLogFile returns the proxy log of sessionID, or an error if that session has not recorded anything
+func SessionLogFile(sessionID string) (string, error) {
+	logFile := session.GetSessionBasedLogFile(paths.GetDefaultProxyLogFile(), sessionID)
+	if _, err := os.Stat(logFile); err != nil {
+		return "", fmt.Errorf("session %q not found: %w", sessionID, err)
+	}
+	return logFile, nil
+}
+
+// CaptureScrollback runs the scrollback acquisition chain on its own and
+// returns the most recent opts.ScrollbackLines lines it found (0 = all).
+func CaptureScrollback(opts UserContextOptions) (string, error) {
+	if opts.ScrollbackLines < 0 {
+		opts.ScrollbackLines = 0
+	}
+	return getScrollback(opts)
+}
+
+func getScrollback(opts UserContextOptions) (string, error) {
+	sourceOpts := opts
+	if opts.PrioritizeErrors {
+		// Read everything so errors older than the budget can still be kept.
+		sourceOpts.ScrollbackLines = 0
+	}
+	content, err := doGetScrollback(sourceOpts)
 	if err != nil {
 		return "", err
 	}
-	return readLatestLines(content, scrollbackLines)
+	// tmux, kitty and screen captures can carry colors and cursor movement;
+	// normalize them the same way the proxy does before they reach the model.
+	content = strings.TrimSpace(ansi.Strip(content))
+	if opts.PrioritizeErrors {
+		content = trimKeepingErrors(content, opts.ScrollbackLines)
+	} else {
+		content, err = readLatestLines(content, opts.ScrollbackLines)
+	}
+	if err != nil || !opts.SinceLastPrompt {
+		return content, err
+	}
+	return sinceLastPrompt(content), nil
 }
 
-func doGetScrollback(scrollbackLines int, scrollbackFile string) (string, error) {
+func doGetScrollback(opts UserContextOptions) (string, error) {
+	scrollbackLines := opts.ScrollbackLines
+	scrollbackFile := opts.ScrollbackFile
 	defaultProxyLogFile := paths.GetDefaultProxyLogFile()
 
+	// 0. Explicitly requested session (cross-pane context)
+	if opts.SessionID != "" {
+		sessionLogFile, err := SessionLogFile(opts.SessionID)
+		if err != nil {
+			return "", err
+		}
+		debug.Log("Using proxy log of requested session", map[string]any{
+			"file":       sessionLogFile,
+			"session_id": opts.SessionID,
+		})
+		return readLatestProxyContent(sessionLogFile, scrollbackLines)
+	}
+
+	if !scrollbackSourceAvailable(scrollbackFile, defaultProxyLogFile) {
+		return "", errNoScrollback
+	}
+
 	// 1. Ghostty scrollback file (highest priority)
 	if scrollbackFile != "" {
 		content, err := os.ReadFile(scrollbackFile)
@@ -248,16 +627,46 @@ func doGetScrollback(scrollbackLines int, scrollbackFile string) (string, error)
 		return content, nil
 	}
 
-	// 7. tput fallback
+	// 7. script(1) typescript
+	content, err = getTypescriptScrollback(scrollbackLines)
+	if err == nil {
+		return content, nil
+	}
+
+	// 8. tput fallback
 	content, err = getTerminalScrollbackWithTput()
 	if err == nil {
 		return content, nil
 	}
 
-	return "", fmt.Errorf("no scrollback available - not in tmux/screen session and no proxy log found")
+	return "", errNoScrollback
+}
+
+// scrollbackSourceAvailable reports whether any step of the acquisition chain
+// can succeed, using only environment lookups and stats so that plain
+// terminals don't spawn capture commands on every suggestion only to fail.
+func scrollbackSourceAvailable(scrollbackFile, defaultProxyLogFile string) bool {
+	if scrollbackFile != "" {
+		return true
+	}
+	for _, name := range []string{"TMUX", "KITTY_LISTEN_ON", "STY", TypescriptEnvVar} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	if _, err := os.Stat(defaultProxyLogFile); err == nil {
+		return true
+	}
+	// Any session log will do; resolving the current session would run tty.
+	sessionLogs, _ := filepath.Glob(session.GetSessionBasedLogFile(defaultProxyLogFile, "*"))
+	return len(sessionLogs) > 0
 }
 
+// readLatestLines returns the last maxLines lines of content (all of them when
+// maxLines <= 0). CRLF line endings are normalized and trailing newlines do
+// not count as an empty last line.
 func readLatestLines(content string, maxLines int) (string, error) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
 	content = strings.TrimSpace(content)
 	if content == "" {
 		return "", nil
@@ -273,6 +682,72 @@ func readLatestLines(content string, maxLines int) (string, error) {
 	return strings.Join(lines, "\n"), nil
 }
 
+// trimKeepingErrors trims content to maxLines lines (all of them when
+// maxLines <= 0) like readLatestLines, but spends up to half of the budget on
+// the most recent error lines from before the kept tail. Gaps are marked so
+// the model knows lines were left out.
+func trimKeepingErrors(content string, maxLines int) string {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	if content == "" {
+		return ""
+	}
+	lines := strings.Split(content, "\n")
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return content
+	}
+
+	keep := make([]bool, len(lines))
+	tailStart := len(lines) - (maxLines - maxLines/2)
+	budget := maxLines / 2
+	for i := tailStart - 1; i >= 0 && budget > 0; i-- {
+		if errorLinePattern.MatchString(lines[i]) {
+			keep[i] = true
+			budget--
+		}
+	}
+	// Spend what the errors did not use on more recent lines.
+	for ; budget > 0 && tailStart > 0; tailStart-- {
+		if !keep[tailStart-1] {
+			budget--
+		}
+	}
+	for i := tailStart; i < len(lines); i++ {
+		keep[i] = true
+	}
+
+	var kept []string
+	omitted := 0
+	for i, line := range lines {
+		if !keep[i] {
+			omitted++
+			continue
+		}
+		if omitted > 0 {
+			kept = append(kept, fmt.Sprintf("[... %d lines omitted ...]", omitted))
+			omitted = 0
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// sinceLastPrompt trims scrollback to the most recent command: the last prompt
+// line before the one the user is typing at, and everything after it.
+// Scrollback without a recognizable earlier prompt is returned unchanged.
+func sinceLastPrompt(scrollback string) string {
+	lines := strings.Split(scrollback, "\n")
+	end := len(lines)
+	if end > 0 && promptLinePattern.MatchString(lines[end-1]) {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if promptLinePattern.MatchString(lines[i]) {
+			return strings.Join(lines[i:], "\n")
+		}
+	}
+	return scrollback
+}
+
 func readLatestProxyContent(logFile string, maxLines int) (string, error) {
 	file, err := os.Open(logFile)
 	if err != nil {
@@ -332,6 +807,29 @@ func getScreenScrollback() (string, error) {
 	return strings.TrimSpace(string(content)), nil
 }
 
+// getTypescriptScrollback reads the last maxLines lines of the typescript
+// named by TypescriptEnvVar, without the header line script writes first.
+func getTypescriptScrollback(maxLines int) (string, error) {
+	typescript := os.Getenv(TypescriptEnvVar)
+	if typescript == "" {
+		return "", fmt.Errorf("not running under script")
+	}
+
+	content, err := readLatestProxyContent(typescript, maxLines)
+	if err != nil {
+		debug.Log("Failed to read typescript", map[string]any{
+			"error": err.Error(),
+			"file":  typescript,
+		})
+		return "", err
+	}
+	if strings.HasPrefix(content, "Script started on ") {
+		_, content, _ = strings.Cut(content, "\n")
+	}
+	debug.Log("Using typescript", map[string]any{"file": typescript})
+	return strings.TrimSpace(content), nil
+}
+
 func getTerminalScrollbackWithTput() (string, error) {
 	rowsCmd := execCommand("tput", "lines")
 	rowsOutput, err := rowsCmd.Output()
@@ -1,11 +1,19 @@
 package shellcontext
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/paths"
 )
 
 func TestReadLatestLines(t *testing.T) {
@@ -40,6 +48,117 @@ func TestReadLatestLines(t *testing.T) {
 			t.Fatalf("expected tail, got %q", got)
 		}
 	})
+
+	t.Run("crlf", func(t *testing.T) {
+		for _, input := range []string{
+			"one\r\ntwo\r\nthree",
+			"one\r\ntwo\r\nthree\r\n",
+			"one\r\ntwo\r\nthree\r\n\r\n",
+		} {
+			got, err := readLatestLines(input, 2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != "two\nthree" {
+				t.Fatalf("readLatestLines(%q, 2) = %q, want %q", input, got, "two\nthree")
+			}
+		}
+	})
+
+	t.Run("trailing-newline", func(t *testing.T) {
+		for _, input := range []string{"one\ntwo\nthree", "one\ntwo\nthree\n", "one\ntwo\nthree\n\n"} {
+			got, err := readLatestLines(input, 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != "three" {
+				t.Fatalf("readLatestLines(%q, 1) = %q, want %q", input, got, "three")
+			}
+		}
+	})
+}
+
+func TestPromptShellKind(t *testing.T) {
+	tests := []struct {
+		name       string
+		scrollback string
+		want       string
+	}{
+		{name: "pwsh unix", scrollback: "PS /home/me> Get-ChildItem\nfoo.txt", want: "pwsh"},
+		{name: "pwsh windows", scrollback: "PS C:\\Users\\me> dir\n", want: "pwsh"},
+		{name: "fish", scrollback: "me@laptop ~/src> ls\nREADME.md\nme@laptop ~/src> ", want: "fish"},
+		{name: "zsh", scrollback: "laptop% ls\nREADME.md", want: ""},
+		{name: "bash", scrollback: "me@laptop:~/src$ ls", want: ""},
+		{name: "empty", scrollback: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := promptShellKind(tt.scrollback); got != tt.want {
+				t.Errorf("promptShellKind(%q) = %q, want %q", tt.scrollback, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildContextHeaderShellTag(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+
+	t.Setenv("SMART_SUGGESTION_PROXY_SHELL", "fish")
+	header := buildContextHeader()
+	if !strings.Contains(header, "Your shell is fish") || !strings.Contains(header, "Use fish syntax") {
+		t.Errorf("expected fish shell and hint in header, got %q", header)
+	}
+
+	t.Setenv("SMART_SUGGESTION_PROXY_SHELL", "pwsh")
+	if header := buildContextHeader(); !strings.Contains(header, "Use PowerShell syntax") {
+		t.Errorf("expected PowerShell hint in header, got %q", header)
+	}
+
+	t.Setenv("SMART_SUGGESTION_PROXY_SHELL", "zsh")
+	header = buildContextHeader()
+	if !strings.Contains(header, "Your shell is /bin/zsh") || strings.Contains(header, "syntax") {
+		t.Errorf("expected plain zsh header, got %q", header)
+	}
+}
+
+func TestBuildUserContextPromptShellHint(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+	t.Setenv("SMART_SUGGESTION_PROXY_SHELL", "")
+	t.Setenv("SMART_SUGGESTION_HISTORY", "")
+	t.Setenv("SMART_SUGGESTION_LAST_DURATION", "")
+
+	scrollbackFile := filepath.Join(t.TempDir(), "screen.txt")
+	if err := os.WriteFile(scrollbackFile, []byte("PS /home/me> Get-Process\nNPM(K) PM(M)\n"), 0644); err != nil {
+		t.Fatalf("failed to write scrollback: %v", err)
+	}
+
+	got, err := BuildUserContext(UserContextOptions{ScrollbackLines: 10, ScrollbackFile: scrollbackFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "The scrollback shows pwsh prompts. Use PowerShell syntax") {
+		t.Errorf("expected PowerShell hint from prompts, got %q", got)
+	}
+
+	// A tagged shell already gets its hint in the header.
+	t.Setenv("SMART_SUGGESTION_PROXY_SHELL", "fish")
+	got, err = BuildUserContext(UserContextOptions{ScrollbackLines: 10, ScrollbackFile: scrollbackFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(got, "The scrollback shows") {
+		t.Errorf("expected no prompt-based hint for a tagged shell, got %q", got)
+	}
+
+	// So does a tagged shell without a hint of its own.
+	t.Setenv("SMART_SUGGESTION_PROXY_SHELL", "zsh")
+	got, err = BuildUserContext(UserContextOptions{ScrollbackLines: 10, ScrollbackFile: scrollbackFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(got, "The scrollback shows") {
+		t.Errorf("expected no prompt-based hint for a tagged zsh session, got %q", got)
+	}
 }
 
 func TestBuildContextSections(t *testing.T) {
@@ -62,7 +181,7 @@ func TestBuildContextSections(t *testing.T) {
 		cleanupShell()
 	})
 
-	systemContext, err := BuildSystemContext()
+	systemContext, err := BuildSystemContext(SystemContextOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -73,7 +192,7 @@ func TestBuildContextSections(t *testing.T) {
 		t.Fatal("expected commands section in system context")
 	}
 
-	userContext, err := BuildUserContext(0, "")
+	userContext, err := BuildUserContext(UserContextOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -158,7 +277,13 @@ func TestIsTermux(t *testing.T) {
 
 func TestGetUserID(t *testing.T) {
 	oldExec := execCommand
-	t.Cleanup(func() { execCommand = oldExec })
+	oldCurrentUser := currentUser
+	t.Cleanup(func() {
+		execCommand = oldExec
+		currentUser = oldCurrentUser
+	})
+
+	currentUser = func() (*user.User, error) { return nil, errors.New("unknown userid") }
 
 	execCommand = func(name string, args ...string) *exec.Cmd {
 		return exec.Command("echo", "uid=1000(user)")
@@ -172,7 +297,13 @@ func TestGetUserID(t *testing.T) {
 
 func TestGetUserIDError(t *testing.T) {
 	oldExec := execCommand
-	t.Cleanup(func() { execCommand = oldExec })
+	oldCurrentUser := currentUser
+	t.Cleanup(func() {
+		execCommand = oldExec
+		currentUser = oldCurrentUser
+	})
+
+	currentUser = func() (*user.User, error) { return nil, errors.New("unknown userid") }
 
 	execCommand = func(name string, args ...string) *exec.Cmd {
 		return exec.Command("false")
@@ -186,7 +317,13 @@ func TestGetUserIDError(t *testing.T) {
 
 func TestGetUnameInfo(t *testing.T) {
 	oldExec := execCommand
-	t.Cleanup(func() { execCommand = oldExec })
+	oldHostname := osHostname
+	t.Cleanup(func() {
+		execCommand = oldExec
+		osHostname = oldHostname
+	})
+
+	osHostname = func() (string, error) { return "", errors.New("no hostname") }
 
 	execCommand = func(name string, args ...string) *exec.Cmd {
 		return exec.Command("echo", "Darwin host 24.0.0")
@@ -200,7 +337,13 @@ func TestGetUnameInfo(t *testing.T) {
 
 func TestGetUnameInfoError(t *testing.T) {
 	oldExec := execCommand
-	t.Cleanup(func() { execCommand = oldExec })
+	oldHostname := osHostname
+	t.Cleanup(func() {
+		execCommand = oldExec
+		osHostname = oldHostname
+	})
+
+	osHostname = func() (string, error) { return "", errors.New("no hostname") }
 
 	execCommand = func(name string, args ...string) *exec.Cmd {
 		return exec.Command("false")
@@ -250,7 +393,7 @@ func TestGetScrollbackWithFile(t *testing.T) {
 		t.Fatalf("failed to write file: %v", err)
 	}
 
-	content, err := getScrollback(2, file)
+	content, err := getScrollback(UserContextOptions{ScrollbackLines: 2, ScrollbackFile: file})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -287,23 +430,23 @@ func TestGetScreenScrollbackNotInSession(t *testing.T) {
 	}
 }
 
-func TestAppendContextSectionError(t *testing.T) {
-	var builder strings.Builder
-	appendContextSection(&builder, "Test", func() (string, error) {
+func TestAddContextSectionError(t *testing.T) {
+	var sections contextSections
+	sections.add("Test", func() (string, error) {
 		return "", os.ErrNotExist
 	})
-	if builder.Len() != 0 {
-		t.Fatal("expected empty builder on error")
+	if len(sections) != 0 {
+		t.Fatal("expected no section on error")
 	}
 }
 
-func TestAppendContextSectionEmpty(t *testing.T) {
-	var builder strings.Builder
-	appendContextSection(&builder, "Test", func() (string, error) {
+func TestAddContextSectionEmpty(t *testing.T) {
+	var sections contextSections
+	sections.add("Test", func() (string, error) {
 		return "", nil
 	})
-	if builder.Len() != 0 {
-		t.Fatal("expected empty builder for empty value")
+	if len(sections) != 0 {
+		t.Fatal("expected no section for empty value")
 	}
 }
 
@@ -332,7 +475,7 @@ func TestGetAliasesEmpty(t *testing.T) {
 	t.Cleanup(func() { os.Setenv("SMART_SUGGESTION_ALIASES", oldAliases) })
 
 	os.Setenv("SMART_SUGGESTION_ALIASES", "")
-	aliases, err := getAliases()
+	aliases, err := getAliases(SystemContextOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -360,7 +503,7 @@ func TestGetHistoryEmpty(t *testing.T) {
 	t.Cleanup(func() { os.Setenv("SMART_SUGGESTION_HISTORY", oldHistory) })
 
 	os.Setenv("SMART_SUGGESTION_HISTORY", "")
-	history, err := getHistory()
+	history, err := getHistory(0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -385,7 +528,7 @@ func TestDoGetScrollbackTmux(t *testing.T) {
 		return exec.Command("false")
 	}
 
-	content, err := doGetScrollback(10, "")
+	content, err := doGetScrollback(UserContextOptions{ScrollbackLines: 10})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -394,6 +537,229 @@ func TestDoGetScrollbackTmux(t *testing.T) {
 	}
 }
 
+func TestGetScrollbackStripsANSI(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,12345,0")
+	oldExec := execCommand
+	t.Cleanup(func() { execCommand = oldExec })
+
+	colored := "\x1b[1;32muser@host\x1b[0m:\x1b[34m~/src\x1b[0m$ ls\r\n\x1b[01;34mdir\x1b[0m  file.txt\r\n\x1b[31merror\x1b[0m: dowload\x08\x08\x08\x08nload failed\n"
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if name == "tmux" {
+			return exec.Command("printf", "%s", colored)
+		}
+		return exec.Command("false")
+	}
+
+	content, err := getScrollback(UserContextOptions{ScrollbackLines: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "user@host:~/src$ ls\ndir  file.txt\nerror: download failed"
+	if content != want {
+		t.Fatalf("expected %q, got %q", want, content)
+	}
+}
+
+func TestSinceLastPrompt(t *testing.T) {
+	tests := []struct {
+		name       string
+		scrollback string
+		want       string
+	}{
+		{
+			name:       "bash prompts",
+			scrollback: "user@host:~/src$ ls\nmain.go\nuser@host:~/src$ go build\n./main.go:3:1: syntax error\nuser@host:~/src$ ",
+			want:       "user@host:~/src$ go build\n./main.go:3:1: syntax error\nuser@host:~/src$ ",
+		},
+		{
+			name:       "current prompt with partial input",
+			scrollback: "$ make\nok\n$ make test\nFAIL: TestFoo\n$ go te",
+			want:       "$ make test\nFAIL: TestFoo\n$ go te",
+		},
+		{
+			name:       "command without output",
+			scrollback: "% cd src\n% git status\n% ",
+			want:       "% git status\n% ",
+		},
+		{
+			name:       "fish and starship",
+			scrollback: "user@host ~> ls\na b\n❯ cargo build\nerror[E0425]: cannot find value\n❯ ",
+			want:       "❯ cargo build\nerror[E0425]: cannot find value\n❯ ",
+		},
+		{
+			name:       "powershell",
+			scrollback: "PS C:\\src> dir\nfile.txt\nPS C:\\src> npm test\n1 failing\nPS C:\\src> ",
+			want:       "PS C:\\src> npm test\n1 failing\nPS C:\\src> ",
+		},
+		{
+			name:       "output is not mistaken for a prompt",
+			scrollback: "$ cat notes.md\n# Title\n> quoted\ntotal 5% done\n$ ",
+			want:       "$ cat notes.md\n# Title\n> quoted\ntotal 5% done\n$ ",
+		},
+		{
+			name:       "no prompt",
+			scrollback: "line one\nline two",
+			want:       "line one\nline two",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sinceLastPrompt(tt.scrollback); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTrimKeepingErrors(t *testing.T) {
+	log := strings.Join([]string{
+		"$ make build",
+		"compiling pkg/a",
+		"compiling pkg/b",
+		"main.go:12:2: error: undefined: foo",
+		"make: *** [build] Error 1",
+		"$ ls",
+		"Makefile",
+		"main.go",
+		"README.md",
+		"go.mod",
+		"$ git status",
+		"On branch main",
+		"nothing to commit",
+		"$ ",
+	}, "\n")
+
+	t.Run("error lines survive trimming", func(t *testing.T) {
+		got := trimKeepingErrors(log, 6)
+		want := strings.Join([]string{
+			"[... 3 lines omitted ...]",
+			"main.go:12:2: error: undefined: foo",
+			"make: *** [build] Error 1",
+			"[... 5 lines omitted ...]",
+			"$ git status",
+			"On branch main",
+			"nothing to commit",
+			"$",
+		}, "\n")
+		if got != want {
+			t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+		}
+	})
+
+	t.Run("unused error budget goes to recent lines", func(t *testing.T) {
+		got := trimKeepingErrors(log, 10)
+		if !strings.Contains(got, "main.go:12:2: error: undefined: foo") {
+			t.Errorf("expected the error line to be kept, got:\n%s", got)
+		}
+		if !strings.Contains(got, "README.md") {
+			t.Errorf("expected remaining budget to keep recent lines, got:\n%s", got)
+		}
+		kept := 0
+		for _, line := range strings.Split(got, "\n") {
+			if !strings.HasPrefix(line, "[... ") {
+				kept++
+			}
+		}
+		if kept != 10 {
+			t.Errorf("expected 10 kept lines, got %d", kept)
+		}
+	})
+
+	t.Run("within budget", func(t *testing.T) {
+		if got := trimKeepingErrors(log, 100); got != strings.TrimSpace(log) {
+			t.Errorf("expected content unchanged, got:\n%s", got)
+		}
+	})
+
+	t.Run("non-zero exit and traceback", func(t *testing.T) {
+		for _, line := range []string{"Traceback (most recent call last):", "Process exited with code 2", "fatal: not a git repository", "zsh: command not found: kubctl"} {
+			if !errorLinePattern.MatchString(line) {
+				t.Errorf("expected %q to be recognized as an error line", line)
+			}
+		}
+		for _, line := range []string{"exit 0", "Exit status: 0", "compiling pkg/a"} {
+			if errorLinePattern.MatchString(line) {
+				t.Errorf("expected %q not to be recognized as an error line", line)
+			}
+		}
+	})
+}
+
+func TestGetScrollbackPrioritizeErrors(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,12345,0")
+	oldExec := execCommand
+	t.Cleanup(func() { execCommand = oldExec })
+
+	var lines []string
+	lines = append(lines, "$ npm test", "Error: Cannot find module 'jest'")
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf("output line %d", i))
+	}
+	capture := strings.Join(lines, "\n")
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if name == "tmux" {
+			return exec.Command("printf", "%s", capture)
+		}
+		return exec.Command("false")
+	}
+
+	content, err := getScrollback(UserContextOptions{ScrollbackLines: 4, PrioritizeErrors: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(content, "Error: Cannot find module 'jest'") || !strings.HasSuffix(content, "output line 19") {
+		t.Errorf("expected the error and the latest lines, got:\n%s", content)
+	}
+
+	content, err = getScrollback(UserContextOptions{ScrollbackLines: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(content, "Error:") {
+		t.Errorf("expected plain trimming to drop the error, got:\n%s", content)
+	}
+}
+
+func TestGetScrollbackSinceLastPrompt(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("KITTY_LISTEN_ON", "")
+	cacheDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", cacheDir)
+	t.Setenv("SMART_SUGGESTION_SESSION_ID", "since-prompt")
+
+	logFile := filepath.Join(cacheDir, "proxy.since-prompt.log")
+	log := strings.Join([]string{
+		"user@host:~/app$ git pull",
+		"Already up to date.",
+		"user@host:~/app$ npm install",
+		"added 12 packages",
+		"user@host:~/app$ npm test",
+		"Error: Cannot find module 'jest'",
+		"user@host:~/app$ ",
+	}, "\n")
+	if err := os.WriteFile(logFile, []byte(log), 0644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	content, err := getScrollback(UserContextOptions{ScrollbackLines: 100, SinceLastPrompt: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "user@host:~/app$ npm test\nError: Cannot find module 'jest'\nuser@host:~/app$"
+	if content != want {
+		t.Errorf("expected %q, got %q", want, content)
+	}
+
+	content, err = getScrollback(UserContextOptions{ScrollbackLines: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(content, "user@host:~/app$ git pull") {
+		t.Errorf("expected full scrollback without the option, got %q", content)
+	}
+}
+
 func TestDoGetScrollbackKitty(t *testing.T) {
 	oldTmux := os.Getenv("TMUX")
 	oldKitty := os.Getenv("KITTY_LISTEN_ON")
@@ -413,7 +779,7 @@ func TestDoGetScrollbackKitty(t *testing.T) {
 		return exec.Command("false")
 	}
 
-	content, err := doGetScrollback(10, "")
+	content, err := doGetScrollback(UserContextOptions{ScrollbackLines: 10})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -444,18 +810,18 @@ func TestGetScrollbackError(t *testing.T) {
 		return exec.Command("false")
 	}
 
-	_, err := getScrollback(10, "")
+	_, err := getScrollback(UserContextOptions{ScrollbackLines: 10})
 	if err == nil {
 		t.Fatal("expected error when no scrollback source available")
 	}
 }
 
 func TestBuildUserContextNegativeLines(t *testing.T) {
-	infoNegative, err := BuildUserContext(-10, "")
+	infoNegative, err := BuildUserContext(UserContextOptions{ScrollbackLines: -10})
 	if err != nil {
 		t.Fatalf("unexpected error with negative lines: %v", err)
 	}
-	infoZero, err := BuildUserContext(0, "")
+	infoZero, err := BuildUserContext(UserContextOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error with zero lines: %v", err)
 	}
@@ -463,3 +829,612 @@ func TestBuildUserContextNegativeLines(t *testing.T) {
 		t.Fatalf("expected same output for negative and zero lines, got (negative) %q and (zero) %q", infoNegative, infoZero)
 	}
 }
+
+func TestDoGetScrollbackRequestedSession(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", cacheDir)
+	t.Setenv("SMART_SUGGESTION_SESSION_ID", "current")
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,12345,0")
+
+	oldExec := execCommand
+	t.Cleanup(func() { execCommand = oldExec })
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("echo", "current pane scrollback")
+	}
+
+	otherLog := filepath.Join(cacheDir, "proxy.other.log")
+	if err := os.WriteFile(otherLog, []byte("$ make\nerror: missing target\n"), 0644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	content, err := doGetScrollback(UserContextOptions{ScrollbackLines: 10, SessionID: "other"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "$ make\nerror: missing target" {
+		t.Fatalf("expected other session's log, got %q", content)
+	}
+
+	if _, err := doGetScrollback(UserContextOptions{ScrollbackLines: 10, SessionID: "missing"}); err == nil {
+		t.Fatal("expected error for unknown session")
+	}
+}
+
+func TestSessionLogFile(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", cacheDir)
+
+	if _, err := SessionLogFile("pts_9"); err == nil {
+		t.Fatal("expected error for missing session log")
+	}
+
+	logFile := filepath.Join(cacheDir, "proxy.pts_9.log")
+	if err := os.WriteFile(logFile, []byte("ls\n"), 0644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+	got, err := SessionLogFile("pts_9")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != logFile {
+		t.Fatalf("expected %q, got %q", logFile, got)
+	}
+}
+
+func TestGetAliasesDetect(t *testing.T) {
+	oldExec := execCommandContext
+	t.Cleanup(func() { execCommandContext = oldExec })
+	t.Setenv("SMART_SUGGESTION_ALIASES", "")
+	t.Setenv("SHELL", "/bin/zsh")
+
+	var gotName string
+	var gotArgs []string
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		gotName = name
+		gotArgs = args
+		return exec.CommandContext(ctx, "printf", "ll='ls -l'\n\n\x1b[31mgs='git status'\x1b[0m\x07\n")
+	}
+
+	aliases, err := getAliases(SystemContextOptions{})
+	if err != nil || aliases != "" {
+		t.Fatalf("expected no aliases without opt-in, got %q, %v", aliases, err)
+	}
+	if gotName != "" {
+		t.Fatal("expected no shell to be spawned without opt-in")
+	}
+
+	aliases, err = getAliases(SystemContextOptions{DetectAliases: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotName != "/bin/zsh" || strings.Join(gotArgs, " ") != "-ic alias" {
+		t.Fatalf("expected $SHELL -ic alias, got %s %v", gotName, gotArgs)
+	}
+	if aliases != "ll='ls -l'\ngs='git status'" {
+		t.Fatalf("expected sanitized aliases, got %q", aliases)
+	}
+
+	t.Setenv("SMART_SUGGESTION_ALIASES", "la='ls -a'")
+	gotName = ""
+	aliases, err = getAliases(SystemContextOptions{DetectAliases: true})
+	if err != nil || aliases != "la='ls -a'" {
+		t.Fatalf("expected env aliases to win, got %q, %v", aliases, err)
+	}
+	if gotName != "" {
+		t.Fatal("expected no shell to be spawned when env aliases are set")
+	}
+}
+
+func TestDetectAliasesTimeout(t *testing.T) {
+	oldExec := execCommandContext
+	oldTimeout := aliasDetectTimeout
+	t.Cleanup(func() {
+		execCommandContext = oldExec
+		aliasDetectTimeout = oldTimeout
+	})
+	t.Setenv("SHELL", "/bin/zsh")
+
+	aliasDetectTimeout = 50 * time.Millisecond
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sleep", "5")
+	}
+
+	start := time.Now()
+	if _, err := detectAliases(); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected detection to stop at the timeout, took %s", elapsed)
+	}
+}
+
+func TestDetectAliasesNoShell(t *testing.T) {
+	t.Setenv("SHELL", "")
+	aliases, err := detectAliases()
+	if err != nil || aliases != "" {
+		t.Fatalf("expected no aliases without $SHELL, got %q, %v", aliases, err)
+	}
+}
+
+func TestSanitizeAliasesLimit(t *testing.T) {
+	got := sanitizeAliases("a='1'\nb='2'\nc='3'\n", 12)
+	if got != "a='1'\nb='2'" {
+		t.Fatalf("expected output cut at a line boundary, got %q", got)
+	}
+}
+
+func TestGetLastDuration(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected string
+	}{
+		{value: "", expected: ""},
+		{value: "45.2731", expected: "The last command took 45 seconds."},
+		{value: "2.349", expected: "The last command took 2.3 seconds."},
+		{value: "1.02", expected: "The last command took 1 second."},
+		{value: "0", expected: "The last command took 0 seconds."},
+		{value: "-3", expected: ""},
+		{value: "fast", expected: ""},
+		{value: "NaN", expected: ""},
+		{value: "Inf", expected: ""},
+	}
+	for _, tc := range cases {
+		t.Setenv("SMART_SUGGESTION_LAST_DURATION", tc.value)
+		if got := getLastDuration(); got != tc.expected {
+			t.Fatalf("%q: expected %q, got %q", tc.value, tc.expected, got)
+		}
+	}
+}
+
+func TestBuildUserContextLastDuration(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_LAST_DURATION", "45")
+	t.Setenv("SMART_SUGGESTION_HISTORY", "make build")
+
+	userContext, err := BuildUserContext(UserContextOptions{SessionID: "missing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(userContext, "The last command took 45 seconds.\n\n# Shell history:") {
+		t.Fatalf("expected duration line before history, got %q", userContext)
+	}
+}
+
+func TestSelectHistory(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_HISTORY", "git status\n\ndocker ps\ngit push\n")
+
+	all, err := selectHistory(UserContextOptions{})
+	if err != nil || all != "git status\n\ndocker ps\ngit push" {
+		t.Fatalf("expected full history without selector, got %q, %v", all, err)
+	}
+
+	var gotEntries []string
+	selected, err := selectHistory(UserContextOptions{SelectHistory: func(entries []string) ([]string, error) {
+		gotEntries = entries
+		return []string{entries[0], entries[2]}, nil
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(gotEntries, "|") != "git status|docker ps|git push" {
+		t.Fatalf("expected non-empty history entries, got %q", gotEntries)
+	}
+	if selected != "git status\ngit push" {
+		t.Fatalf("expected selected history, got %q", selected)
+	}
+
+	fallback, err := selectHistory(UserContextOptions{SelectHistory: func(entries []string) ([]string, error) {
+		return nil, errors.New("embeddings unavailable")
+	}})
+	if err != nil || fallback != all {
+		t.Fatalf("expected full history when selection fails, got %q, %v", fallback, err)
+	}
+}
+
+func TestGetUserIDNative(t *testing.T) {
+	oldExec := execCommand
+	oldCurrentUser := currentUser
+	oldLookupGroupID := lookupGroupID
+	t.Cleanup(func() {
+		execCommand = oldExec
+		currentUser = oldCurrentUser
+		lookupGroupID = oldLookupGroupID
+	})
+
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		t.Fatalf("expected no command to run, got %s %v", name, args)
+		return nil
+	}
+	currentUser = func() (*user.User, error) {
+		return &user.User{Uid: "1000", Gid: "100", Username: "alice"}, nil
+	}
+	lookupGroupID = func(gid string) (*user.Group, error) {
+		return &user.Group{Gid: gid, Name: "users"}, nil
+	}
+
+	if id := getUserID(); id != "uid=1000(alice) gid=100(users)" {
+		t.Fatalf("expected native id, got %q", id)
+	}
+
+	lookupGroupID = func(gid string) (*user.Group, error) { return nil, errors.New("unknown group") }
+	if id := getUserID(); id != "uid=1000(alice) gid=100" {
+		t.Fatalf("expected numeric gid without group entry, got %q", id)
+	}
+}
+
+func TestGetUnameInfoNative(t *testing.T) {
+	oldExec := execCommand
+	oldGOOS := runtimeGOOS
+	oldGOARCH := runtimeGOARCH
+	oldHostname := osHostname
+	oldRelease := kernelReleaseFile
+	t.Cleanup(func() {
+		execCommand = oldExec
+		runtimeGOOS = oldGOOS
+		runtimeGOARCH = oldGOARCH
+		osHostname = oldHostname
+		kernelReleaseFile = oldRelease
+	})
+
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		t.Fatalf("expected no command to run, got %s %v", name, args)
+		return nil
+	}
+	runtimeGOOS = "linux"
+	runtimeGOARCH = "arm64"
+	osHostname = func() (string, error) { return "box", nil }
+	kernelReleaseFile = filepath.Join(t.TempDir(), "osrelease")
+	if err := os.WriteFile(kernelReleaseFile, []byte("6.1.0-18-arm64\n"), 0644); err != nil {
+		t.Fatalf("failed to write release file: %v", err)
+	}
+
+	if info := getUnameInfo(); info != "Linux box 6.1.0-18-arm64 arm64" {
+		t.Fatalf("expected native uname info, got %q", info)
+	}
+
+	runtimeGOOS = "darwin"
+	kernelReleaseFile = filepath.Join(t.TempDir(), "missing")
+	if info := getUnameInfo(); info != "Darwin box arm64" {
+		t.Fatalf("expected native uname info without release, got %q", info)
+	}
+}
+
+func TestGetHistoryLimit(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 5000; i++ {
+		lines = append(lines, "echo "+strconv.Itoa(i))
+	}
+	t.Setenv("SMART_SUGGESTION_HISTORY", strings.Join(lines, "\n")+"\n")
+
+	history, err := getHistory(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if history != "echo 4998\necho 4999\necho 5000" {
+		t.Fatalf("expected the 3 most recent entries, got %q", history)
+	}
+
+	history, err = getHistory(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Count(history, "\n") != 4999 {
+		t.Fatal("expected the full history without a limit")
+	}
+
+	userContext, err := BuildUserContext(UserContextOptions{HistoryLines: 2, SessionID: "missing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(userContext, "# Shell history:\n\necho 4999\necho 5000") {
+		t.Fatalf("expected capped history in user context, got %q", userContext)
+	}
+}
+
+func TestGetScrollbackPlainTerminalSpawnsNothing(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("KITTY_LISTEN_ON", "")
+	t.Setenv("STY", "")
+	t.Setenv("SMART_SUGGESTION_SESSION_ID", "")
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	oldExec := execCommand
+	t.Cleanup(func() { execCommand = oldExec })
+
+	var spawned []string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		spawned = append(spawned, name)
+		return exec.Command("false")
+	}
+
+	_, err := getScrollback(UserContextOptions{ScrollbackLines: 10})
+	if !errors.Is(err, errNoScrollback) {
+		t.Fatalf("expected errNoScrollback, got %v", err)
+	}
+	if len(spawned) > 0 {
+		t.Errorf("expected no capture commands, got %v", spawned)
+	}
+
+	// Once a proxy log exists the chain runs again.
+	logFile := paths.GetDefaultProxyLogFile()
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+	if err := os.WriteFile(logFile, []byte("$ ls\nfile\n"), 0644); err != nil {
+		t.Fatalf("failed to write proxy log: %v", err)
+	}
+	got, err := getScrollback(UserContextOptions{ScrollbackLines: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "$ ls\nfile" {
+		t.Errorf("unexpected scrollback %q", got)
+	}
+}
+
+func TestGetScrollbackTypescript(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("KITTY_LISTEN_ON", "")
+	t.Setenv("STY", "")
+	t.Setenv("SMART_SUGGESTION_SESSION_ID", "")
+	t.Setenv("SMART_SUGGESTION_CACHE_DIR", t.TempDir())
+	oldExec := execCommand
+	t.Cleanup(func() { execCommand = oldExec })
+
+	var spawned []string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		spawned = append(spawned, name)
+		return exec.Command("false")
+	}
+
+	typescript := filepath.Join(t.TempDir(), "typescript")
+	content := "Script started on 2025-01-01 12:00:00+00:00 [COMMAND=\"zsh\" TERM=\"xterm\"]\r\n" +
+		"$ echo one\r\none\r\n" +
+		"\x1b[32m$\x1b[0m make\r\nmake: *** No rule to make target.  Stop.\r\n"
+	if err := os.WriteFile(typescript, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write typescript: %v", err)
+	}
+	t.Setenv(TypescriptEnvVar, typescript)
+
+	got, err := getScrollback(UserContextOptions{ScrollbackLines: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "$ make\nmake: *** No rule to make target.  Stop."; got != want {
+		t.Errorf("expected the typescript's tail %q, got %q", want, got)
+	}
+
+	got, err = getScrollback(UserContextOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(got, "Script started") || !strings.HasPrefix(got, "$ echo one") {
+		t.Errorf("expected the header line to be dropped, got %q", got)
+	}
+	for _, name := range spawned {
+		if name == "tput" {
+			t.Errorf("expected the typescript to be used before tput")
+		}
+	}
+}
+
+func BenchmarkGetScrollbackPlainTerminal(b *testing.B) {
+	b.Setenv("TMUX", "")
+	b.Setenv("KITTY_LISTEN_ON", "")
+	b.Setenv("STY", "")
+	b.Setenv("SMART_SUGGESTION_SESSION_ID", "")
+	b.Setenv("SMART_SUGGESTION_CACHE_DIR", b.TempDir())
+
+	for b.Loop() {
+		getScrollback(UserContextOptions{ScrollbackLines: 100})
+	}
+}
+
+func TestBuildUserContextZeroScrollbackLines(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+	t.Setenv("KITTY_LISTEN_ON", "unix:/tmp/kitty")
+	t.Setenv("SMART_SUGGESTION_HISTORY", "make build")
+	oldExec := execCommand
+	t.Cleanup(func() { execCommand = oldExec })
+
+	var spawned []string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		spawned = append(spawned, name)
+		return exec.Command("echo", "captured output")
+	}
+
+	scrollbackFile := filepath.Join(t.TempDir(), "scrollback.txt")
+	if err := os.WriteFile(scrollbackFile, []byte("$ make\nerror: boom\n"), 0644); err != nil {
+		t.Fatalf("failed to write scrollback file: %v", err)
+	}
+
+	userContext, err := BuildUserContext(UserContextOptions{ScrollbackFile: scrollbackFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(userContext, "# Scrollback:") {
+		t.Errorf("expected no scrollback section, got %q", userContext)
+	}
+	if !strings.Contains(userContext, "# Shell history:\n\nmake build") {
+		t.Errorf("expected history to still be sent, got %q", userContext)
+	}
+	if len(spawned) > 0 {
+		t.Errorf("expected no capture commands, got %v", spawned)
+	}
+}
+
+// stubToolCommands makes every tool print its own name, recording the calls.
+func stubToolCommands(t *testing.T) *[]string {
+	t.Helper()
+	oldExec := execCommandContext
+	t.Cleanup(func() { execCommandContext = oldExec })
+
+	var calls []string
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		calls = append(calls, name)
+		return exec.CommandContext(ctx, "echo", name+" output")
+	}
+	return &calls
+}
+
+func TestBuildUserContextToolSections(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_HISTORY", "ls")
+	t.Setenv(ContextRulesEnvVar, "")
+
+	tests := []struct {
+		input   string
+		want    string
+		notWant []string
+	}{
+		{input: "git commit -m", want: "# Git status:\n\ngit output", notWant: []string{"Kubernetes", "containers"}},
+		{input: "kubectl get pods", want: "# Kubernetes context:\n\nkubectl output", notWant: []string{"Git status", "containers"}},
+		{input: "sudo docker logs", want: "# Running containers:\n\ndocker output", notWant: []string{"Git status", "Kubernetes"}},
+		{input: "ls -la", notWant: []string{"Git status", "Kubernetes", "containers"}},
+		{input: "", notWant: []string{"Git status", "Kubernetes", "containers"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			calls := stubToolCommands(t)
+			userContext, err := BuildUserContext(UserContextOptions{SessionID: "missing", Input: tt.input})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.want != "" && !strings.Contains(userContext, tt.want) {
+				t.Errorf("expected %q in context, got %q", tt.want, userContext)
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(userContext, notWant) {
+					t.Errorf("did not expect %q in context, got %q", notWant, userContext)
+				}
+			}
+			if tt.want == "" && len(*calls) != 0 {
+				t.Errorf("expected no tool to run, ran %q", *calls)
+			}
+		})
+	}
+}
+
+func TestBuildUserContextCustomContextRules(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_HISTORY", "ls")
+	t.Setenv(ContextRulesEnvVar, "make -> git, docker")
+	stubToolCommands(t)
+
+	userContext, err := BuildUserContext(UserContextOptions{SessionID: "missing", Input: "make deploy"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(userContext, "# Git status:") || !strings.Contains(userContext, "# Running containers:") {
+		t.Fatalf("expected git and docker sections for make, got %q", userContext)
+	}
+
+	userContext, err = BuildUserContext(UserContextOptions{SessionID: "missing", Input: "git status"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(userContext, "# Git status:") {
+		t.Fatalf("expected custom rules to replace the defaults, got %q", userContext)
+	}
+}
+
+func TestParseContextRules(t *testing.T) {
+	rules, err := ParseContextRules("# tools\ngit -> git; helm -> kube,kube\n\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rules) != 2 || rules["git"][0] != "git" || len(rules["helm"]) != 1 || rules["helm"][0] != "kube" {
+		t.Fatalf("unexpected rules %v", rules)
+	}
+
+	for _, spec := range []string{"git", "-> git", "git ->", "terraform -> cloud"} {
+		if _, err := ParseContextRules(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+	if _, err := ParseContextRules(DefaultContextRules); err != nil {
+		t.Fatalf("expected the default rules to parse, got %v", err)
+	}
+}
+
+func TestRunToolCommandLimitsOutput(t *testing.T) {
+	oldExec := execCommandContext
+	t.Cleanup(func() { execCommandContext = oldExec })
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "seq", "1", "40")
+	}
+
+	output, err := runToolCommand(maxToolLines, "git", "status")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(output, "\n")
+	if len(lines) != maxToolLines+1 || lines[maxToolLines] != "... (10 more lines)" {
+		t.Fatalf("expected %d lines and a note, got %q", maxToolLines, output)
+	}
+}
+
+func TestBuildUserContextProcSections(t *testing.T) {
+	t.Setenv("SMART_SUGGESTION_HISTORY", "ls")
+	t.Setenv(ContextRulesEnvVar, "")
+	oldGOOS := runtimeGOOS
+	t.Cleanup(func() { runtimeGOOS = oldGOOS })
+	runtimeGOOS = "linux"
+
+	t.Setenv(IncludeProcsEnvVar, "")
+	calls := stubToolCommands(t)
+	userContext, err := BuildUserContext(UserContextOptions{SessionID: "missing", Input: "lsof -i :8080"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(userContext, "Listening ports") || len(*calls) != 0 {
+		t.Fatalf("expected no process sections by default, got %q after %q", userContext, *calls)
+	}
+
+	t.Setenv(IncludeProcsEnvVar, "true")
+	userContext, err = BuildUserContext(UserContextOptions{SessionID: "missing", Input: "lsof -i :8080"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"# Listening ports:\n\nss output", "# Top processes by CPU:\n\nps output"} {
+		if !strings.Contains(userContext, want) {
+			t.Errorf("expected %q in context, got %q", want, userContext)
+		}
+	}
+}
+
+func TestGetListeningPortsFallsBackToLsof(t *testing.T) {
+	oldExec := execCommandContext
+	t.Cleanup(func() { execCommandContext = oldExec })
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		if name == "ss" {
+			return exec.CommandContext(ctx, "smart-suggestion-missing-tool")
+		}
+		return exec.CommandContext(ctx, "echo", name+" "+strings.Join(args, " "))
+	}
+
+	ports, err := getListeningPorts()
+	if err != nil || ports != "lsof -nP -iTCP -sTCP:LISTEN" {
+		t.Fatalf("expected lsof output, got %q, %v", ports, err)
+	}
+
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "smart-suggestion-missing-tool")
+	}
+	if _, err := getListeningPorts(); err == nil {
+		t.Fatal("expected an error when no tool is installed")
+	}
+}
+
+func TestGetTopProcessesDarwin(t *testing.T) {
+	oldGOOS := runtimeGOOS
+	oldExec := execCommandContext
+	t.Cleanup(func() {
+		runtimeGOOS = oldGOOS
+		execCommandContext = oldExec
+	})
+	runtimeGOOS = "darwin"
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "echo", name+" "+strings.Join(args, " "))
+	}
+
+	if procs, err := getTopProcesses(); err != nil || procs != "ps -Ao pid,pcpu,pmem,comm -r" {
+		t.Fatalf("expected BSD ps flags, got %q, %v", procs, err)
+	}
+}
@@ -0,0 +1,80 @@
+package shellcontext
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// IncludeProcsEnvVar adds the listening ports and the busiest processes to the
+// context, for questions like "what is using port 8080".
+const IncludeProcsEnvVar = "SMART_SUGGESTION_INCLUDE_PROCS"
+
+// maxProcLines caps the process sections, whose interesting lines come first.
+const maxProcLines = 15
+
+// procCommand is one way to list something; the first one installed is used.
+type procCommand struct {
+	name string
+	args []string
+}
+
+// listeningPortCommands list listening TCP sockets with their processes: ss on
+// Linux, lsof where ss is missing (macOS, BSDs).
+var listeningPortCommands = []procCommand{
+	{name: "ss", args: []string{"-tlnp"}},
+	{name: "lsof", args: []string{"-nP", "-iTCP", "-sTCP:LISTEN"}},
+}
+
+// includeProcs reports whether SMART_SUGGESTION_INCLUDE_PROCS is enabled.
+func includeProcs() bool {
+	value := strings.TrimSpace(os.Getenv(IncludeProcsEnvVar))
+	if value == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		debug.Log("Ignoring invalid "+IncludeProcsEnvVar, map[string]any{
+			"value": value,
+		})
+		return false
+	}
+	return enabled
+}
+
+// addProcSections adds the listening ports and top processes when
+// SMART_SUGGESTION_INCLUDE_PROCS is enabled. Missing tools leave their section
+// out.
+func addProcSections(sections *contextSections) {
+	if !includeProcs() {
+		return
+	}
+	sections.add(sectionPorts, getListeningPorts)
+	sections.add(sectionTopProcs, getTopProcesses)
+}
+
+// getListeningPorts runs the first installed command of listeningPortCommands.
+func getListeningPorts() (string, error) {
+	for _, command := range listeningPortCommands {
+		output, err := runToolCommand(maxProcLines, command.name, command.args...)
+		if errors.Is(err, exec.ErrNotFound) {
+			continue
+		}
+		return output, err
+	}
+	return "", fmt.Errorf("none of ss or lsof is installed")
+}
+
+// getTopProcesses lists the processes using the most CPU. BSD ps, as on macOS,
+// has no --sort and sorts by CPU with -r.
+func getTopProcesses() (string, error) {
+	if runtimeGOOS == "linux" {
+		return runToolCommand(maxProcLines, "ps", "-eo", "pid,pcpu,pmem,comm", "--sort=-pcpu")
+	}
+	return runToolCommand(maxProcLines, "ps", "-Ao", "pid,pcpu,pmem,comm", "-r")
+}
@@ -0,0 +1,118 @@
+package shellcontext
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// Section titles, as the model sees them.
+const (
+	sectionAliases    = "This is the alias defined in your shell"
+	sectionCommands   = "Available PATH commands"
+	sectionHistory    = "Shell history"
+	sectionScrollback = "Scrollback"
+	sectionGit        = "Git status"
+	sectionKube       = "Kubernetes context"
+	sectionDocker     = "Running containers"
+	sectionPorts      = "Listening ports"
+	sectionTopProcs   = "Top processes by CPU"
+)
+
+// sectionOrder is the order sections are written in, whatever order they were
+// gathered in: the shell's static setup, then what the user did (history and
+// scrollback), then tool state from the most to the least specific to the
+// input. Sections not listed here follow, in the order they were gathered.
+var sectionOrder = []string{
+	sectionAliases,
+	sectionCommands,
+	sectionHistory,
+	sectionScrollback,
+	sectionGit,
+	sectionKube,
+	sectionDocker,
+	sectionPorts,
+	sectionTopProcs,
+}
+
+// contextSection is a titled block of context.
+type contextSection struct {
+	title   string
+	content string
+}
+
+// contextSections collects sections as they are gathered and writes them
+// normalized, so the context is the same however long each source took.
+type contextSections []contextSection
+
+// add runs getter and keeps its result as the section title, skipping errors
+// and empty results.
+func (s *contextSections) add(title string, getter func() (string, error)) {
+	value, err := getter()
+	if err != nil {
+		debug.Log("Failed to get context section", map[string]any{
+			"section": title,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if value == "" {
+		return
+	}
+	*s = append(*s, contextSection{title: title, content: value})
+}
+
+// normalized returns the sections in sectionOrder, with every block (text
+// between blank lines) that an earlier section already holds removed, and
+// sections left empty by that dropped. Repeated blocks within one section,
+// such as the same output printed twice in the scrollback, are kept.
+func (s contextSections) normalized() []contextSection {
+	sorted := slices.Clone(s)
+	slices.SortStableFunc(sorted, func(a, b contextSection) int {
+		return sectionRank(a.title) - sectionRank(b.title)
+	})
+
+	seen := make(map[string]bool)
+	var result []contextSection
+	for _, section := range sorted {
+		blocks := strings.Split(section.content, "\n\n")
+		kept := blocks[:0:0]
+		for _, block := range blocks {
+			if key := strings.TrimSpace(block); key == "" || !seen[key] {
+				kept = append(kept, block)
+			}
+		}
+		for _, block := range blocks {
+			if key := strings.TrimSpace(block); key != "" {
+				seen[key] = true
+			}
+		}
+
+		content := strings.TrimSpace(strings.Join(kept, "\n\n"))
+		if content == "" {
+			debug.Log("Dropping duplicate context section", map[string]any{"section": section.title})
+			continue
+		}
+		result = append(result, contextSection{title: section.title, content: content})
+	}
+	return result
+}
+
+// writeTo writes the normalized sections to builder.
+func (s contextSections) writeTo(builder *strings.Builder) {
+	for _, section := range s.normalized() {
+		builder.WriteString(fmt.Sprintf("\n\n# %s:\n\n", section.title))
+		builder.WriteString(section.content)
+	}
+}
+
+// sectionRank is the position of title in sectionOrder, or len(sectionOrder)
+// for unlisted titles.
+func sectionRank(title string) int {
+	if i := slices.Index(sectionOrder, title); i >= 0 {
+		return i
+	}
+	return len(sectionOrder)
+}
@@ -0,0 +1,62 @@
+package shellcontext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContextSectionsOrderAndDedupe(t *testing.T) {
+	gitStatus := "## main...origin/main\n M context.go"
+	var sections contextSections
+	// Gathered out of order, as concurrent sources would finish.
+	sections.add(sectionPorts, func() (string, error) { return "LISTEN 0 4096 *:8080", nil })
+	sections.add(sectionGit, func() (string, error) { return gitStatus, nil })
+	sections.add("Custom", func() (string, error) { return "custom block", nil })
+	sections.add(sectionScrollback, func() (string, error) {
+		return "$ git status -sb\n\n" + gitStatus + "\n\n$ make\n\nok\n\nok", nil
+	})
+	sections.add(sectionHistory, func() (string, error) { return "git status -sb\nmake", nil })
+
+	var builder strings.Builder
+	sections.writeTo(&builder)
+
+	want := "\n\n# Shell history:\n\ngit status -sb\nmake" +
+		"\n\n# Scrollback:\n\n$ git status -sb\n\n" + gitStatus + "\n\n$ make\n\nok\n\nok" +
+		"\n\n# Listening ports:\n\nLISTEN 0 4096 *:8080" +
+		"\n\n# Custom:\n\ncustom block"
+	if got := builder.String(); got != want {
+		t.Fatalf("unexpected context:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestContextSectionsPartialDuplicate(t *testing.T) {
+	sections := contextSections{
+		{title: sectionDocker, content: "web\tnginx\tUp 2 hours\n\ndb\tpostgres\tUp 2 hours"},
+		{title: sectionScrollback, content: "$ docker ps\n\nweb\tnginx\tUp 2 hours"},
+	}
+
+	got := sections.normalized()
+	if len(got) != 2 || got[0].title != sectionScrollback || got[1].title != sectionDocker {
+		t.Fatalf("unexpected sections %+v", got)
+	}
+	if got[1].content != "db\tpostgres\tUp 2 hours" {
+		t.Fatalf("expected only the new block to remain, got %q", got[1].content)
+	}
+}
+
+func TestContextSectionsStableForUnlistedTitles(t *testing.T) {
+	sections := contextSections{
+		{title: "B", content: "b"},
+		{title: "A", content: "a"},
+		{title: sectionCommands, content: "ls"},
+	}
+
+	got := sections.normalized()
+	var titles []string
+	for _, section := range got {
+		titles = append(titles, section.title)
+	}
+	if strings.Join(titles, "|") != sectionCommands+"|B|A" {
+		t.Fatalf("unexpected order %q", titles)
+	}
+}
@@ -0,0 +1,160 @@
+package shellcontext
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xyenon/smart-suggestion/internal/debug"
+)
+
+// ContextRulesEnvVar overrides which tool sections are gathered for which
+// commands, as rules of the form "command -> section[,section]" separated by
+// newlines or semicolons.
+const ContextRulesEnvVar = "SMART_SUGGESTION_CONTEXT_RULES"
+
+// DefaultContextRules gathers each tool's state only when the input starts
+// with a command that uses it: git status is of no help to kubectl and the
+// other way round, and every section costs time and tokens.
+const DefaultContextRules = "git -> git; gh -> git; tig -> git; " +
+	"kubectl -> kube; helm -> kube; k9s -> kube; kubectx -> kube; kubens -> kube; " +
+	"docker -> docker; docker-compose -> docker; podman -> docker"
+
+// toolSection is a context section filled with the output of a command.
+type toolSection struct {
+	title string
+	name  string
+	args  []string
+}
+
+// toolSections are the sections context rules can name.
+var toolSections = map[string]toolSection{
+	"git":    {title: sectionGit, name: "git", args: []string{"status", "--short", "--branch"}},
+	"kube":   {title: sectionKube, name: "kubectl", args: []string{"config", "view", "--minify", "-o", "jsonpath=context: {.current-context}, namespace: {..namespace}"}},
+	"docker": {title: sectionDocker, name: "docker", args: []string{"ps", "--format", "{{.Names}}\t{{.Image}}\t{{.Status}}"}},
+}
+
+var toolContextTimeout = time.Second
+
+// maxToolLines caps each tool section, so a dirty work tree or a busy Docker
+// host cannot crowd out the scrollback.
+const maxToolLines = 30
+
+// ContextSections lists the section names context rules can use.
+func ContextSections() []string {
+	names := make([]string, 0, len(toolSections))
+	for name := range toolSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseContextRules parses rules of the form "command -> section[,section]",
+// separated by newlines or semicolons, into the sections to gather for each
+// command. Blank entries and lines starting with # are ignored.
+func ParseContextRules(spec string) (map[string][]string, error) {
+	rules := make(map[string][]string)
+	entries := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == '\n' || r == ';'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		command, sections, ok := strings.Cut(entry, "->")
+		command = strings.TrimSpace(command)
+		if !ok || command == "" || strings.TrimSpace(sections) == "" {
+			return nil, fmt.Errorf("invalid context rule %q (expected \"command -> section\")", entry)
+		}
+		for section := range strings.SplitSeq(sections, ",") {
+			section = strings.TrimSpace(section)
+			if _, known := toolSections[section]; !known {
+				return nil, fmt.Errorf("invalid context rule %q: unknown section %q (valid: %s)", entry, section, strings.Join(ContextSections(), ", "))
+			}
+			if !slices.Contains(rules[command], section) {
+				rules[command] = append(rules[command], section)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// contextRules returns the rules from SMART_SUGGESTION_CONTEXT_RULES, or the
+// defaults when it is unset.
+func contextRules() (map[string][]string, error) {
+	if spec := os.Getenv(ContextRulesEnvVar); spec != "" {
+		return ParseContextRules(spec)
+	}
+	return ParseContextRules(DefaultContextRules)
+}
+
+// inputCommand returns the command the input starts with, looking past sudo
+// and leading environment assignments.
+func inputCommand(input string) string {
+	for _, word := range strings.Fields(input) {
+		if word == "sudo" || (strings.Contains(word, "=") && !strings.HasPrefix(word, "=")) {
+			continue
+		}
+		return word
+	}
+	return ""
+}
+
+// addToolSections adds the tool sections the rules select for input.
+func addToolSections(sections *contextSections, input string) {
+	command := inputCommand(input)
+	if command == "" {
+		return
+	}
+	rules, err := contextRules()
+	if err != nil {
+		debug.Log("Ignoring invalid context rules", map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	for _, name := range rules[command] {
+		section := toolSections[name]
+		sections.add(section.title, func() (string, error) {
+			return runToolCommand(maxToolLines, section.name, section.args...)
+		})
+	}
+}
+
+// runToolCommand runs a tool for a context section, giving up after
+// toolContextTimeout, and returns its first maxLines lines of output.
+func runToolCommand(maxLines int, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), toolContextTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := execCommandContext(ctx, name, args...)
+	cmd.Stdin = nil
+	cmd.Stdout = &stdout
+	cmd.Stderr = nil
+	cmd.WaitDelay = toolContextTimeout
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	err := cmd.Wait()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("%s timed out after %s", name, toolContextTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+
+	lines := strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n")
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], fmt.Sprintf("... (%d more lines)", len(lines)-maxLines))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
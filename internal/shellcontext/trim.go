@@ -0,0 +1,64 @@
+package shellcontext
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextTrim is how many bytes trimmed dropped from each section.
+type contextTrim struct {
+	scrollback int
+	history    int
+}
+
+// trimmed returns the normalized sections shortened by at least excess bytes,
+// headings included. It drops the oldest scrollback lines first, since the
+// latest ones matter most to the next command, then the oldest history
+// entries, and removes a section once it is empty. Other sections are kept,
+// so the result can still be longer than asked for.
+func (s contextSections) trimmed(excess int) (contextSections, contextTrim) {
+	sections := s.normalized()
+	var trim contextTrim
+	for _, title := range []string{sectionScrollback, sectionHistory} {
+		for i := 0; i < len(sections) && excess > 0; i++ {
+			if sections[i].title != title {
+				continue
+			}
+			size := sectionSize(sections[i])
+			sections[i].content = strings.TrimSpace(dropOldestLines(sections[i].content, excess))
+			dropped := size
+			if sections[i].content == "" {
+				sections = append(sections[:i], sections[i+1:]...)
+				i--
+			} else {
+				dropped -= sectionSize(sections[i])
+			}
+			excess -= dropped
+			if title == sectionScrollback {
+				trim.scrollback += dropped
+			} else {
+				trim.history += dropped
+			}
+		}
+	}
+	return sections, trim
+}
+
+// sectionSize is the number of bytes writeTo writes for section.
+func sectionSize(section contextSection) int {
+	return len(fmt.Sprintf("\n\n# %s:\n\n", section.title)) + len(section.content)
+}
+
+// dropOldestLines removes whole lines from the start of content until at
+// least n bytes are gone or nothing is left.
+func dropOldestLines(content string, n int) string {
+	for n > 0 && content != "" {
+		i := strings.IndexByte(content, '\n')
+		if i < 0 {
+			return ""
+		}
+		n -= i + 1
+		content = content[i+1:]
+	}
+	return content
+}
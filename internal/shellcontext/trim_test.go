@@ -0,0 +1,120 @@
+package shellcontext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContextSectionsTrimmed(t *testing.T) {
+	sections := contextSections{
+		{title: sectionGit, content: "On branch main"},
+		{title: sectionHistory, content: "cd src\nmake"},
+		{title: sectionScrollback, content: "$ make\nold output\nerror: missing file"},
+	}
+
+	cases := []struct {
+		name     string
+		excess   int
+		expected string
+		trim     contextTrim
+	}{
+		{
+			name:     "oldest scrollback lines first",
+			excess:   10,
+			expected: "# Shell history:\n\ncd src\nmake\n\n# Scrollback:\n\nerror: missing file\n\n# Git status:\n\nOn branch main",
+			trim:     contextTrim{scrollback: 18},
+		},
+		{
+			name:     "then history",
+			excess:   60,
+			expected: "# Shell history:\n\nmake\n\n# Git status:\n\nOn branch main",
+			trim:     contextTrim{scrollback: 54, history: 7},
+		},
+		{
+			name:     "other sections are kept",
+			excess:   1000,
+			expected: "# Git status:\n\nOn branch main",
+			trim:     contextTrim{scrollback: 54, history: 31},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trimmed, trim := sections.trimmed(tc.excess)
+			var builder strings.Builder
+			trimmed.writeTo(&builder)
+			if got := strings.TrimSpace(builder.String()); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+			if trim != tc.trim {
+				t.Errorf("expected %+v dropped, got %+v", tc.trim, trim)
+			}
+		})
+	}
+}
+
+func TestBuildUserContextMaxBytes(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+	t.Setenv("SMART_SUGGESTION_PROXY_SHELL", "")
+	t.Setenv("SMART_SUGGESTION_LAST_DURATION", "")
+	t.Setenv("SMART_SUGGESTION_HISTORY", "git status\ngit add .")
+
+	scrollbackFile := filepath.Join(t.TempDir(), "screen.txt")
+	scrollback := strings.Repeat("old build output\n", 20) + "$ git add .\n"
+	if err := os.WriteFile(scrollbackFile, []byte(scrollback), 0644); err != nil {
+		t.Fatalf("failed to write scrollback: %v", err)
+	}
+
+	opts := UserContextOptions{ScrollbackLines: 100, ScrollbackFile: scrollbackFile}
+	full, err := BuildUserContext(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	opts.MaxBytes = len(full) - 100
+	got, err := BuildUserContext(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) > opts.MaxBytes {
+		t.Errorf("expected at most %d bytes, got %d", opts.MaxBytes, len(got))
+	}
+	if !strings.Contains(got, "git status\ngit add .") || !strings.HasSuffix(got, "old build output\n$ git add .") {
+		t.Errorf("expected the history and the latest scrollback to be kept, got %q", got)
+	}
+}
+
+func TestBuildUserContextRedactsBeforeTrimming(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+	t.Setenv("SMART_SUGGESTION_PROXY_SHELL", "")
+	t.Setenv("SMART_SUGGESTION_LAST_DURATION", "")
+	t.Setenv("SMART_SUGGESTION_HISTORY", "ls /home/bo")
+
+	scrollbackFile := filepath.Join(t.TempDir(), "screen.txt")
+	scrollback := strings.Repeat("cd /home/bo\n", 20)
+	if err := os.WriteFile(scrollbackFile, []byte(scrollback), 0644); err != nil {
+		t.Fatalf("failed to write scrollback: %v", err)
+	}
+
+	opts := UserContextOptions{ScrollbackLines: 100, ScrollbackFile: scrollbackFile}
+	full, err := BuildUserContext(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The placeholder is longer than the name, so redacting grows the context
+	// past a limit the unredacted context fits in.
+	opts.MaxBytes = len(full)
+	opts.Redact = func(s string) string { return strings.ReplaceAll(s, "/home/bo", "/home/$USER") }
+	got, err := BuildUserContext(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) > opts.MaxBytes {
+		t.Errorf("expected at most %d bytes, got %d", opts.MaxBytes, len(got))
+	}
+	if strings.Contains(got, "/home/bo") || !strings.Contains(got, "ls /home/$USER") {
+		t.Errorf("expected every section to be redacted, got %q", got)
+	}
+}
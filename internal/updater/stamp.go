@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxBackoffShift caps the backoff after repeated failures at 16 intervals.
+const maxBackoffShift = 4
+
+// CheckStamp records the last automatic update check, so it runs at most once
+// per interval and, after failures, ever less often instead of downloading the
+// same broken release again on every shell start.
+type CheckStamp struct {
+	LastCheck time.Time `json:"last_check"`
+	Failures  int       `json:"failures,omitempty"`
+}
+
+// LoadStamp reads the stamp at path. A missing or unreadable stamp is the zero
+// stamp, which is always due.
+func LoadStamp(path string) CheckStamp {
+	var stamp CheckStamp
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CheckStamp{}
+	}
+	if err := json.Unmarshal(data, &stamp); err != nil {
+		return CheckStamp{}
+	}
+	return stamp
+}
+
+// Save writes the stamp to path, creating its directory.
+func (s CheckStamp) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// NextCheck returns when the next check is due: one interval after the last
+// one, doubled for every failure in a row.
+func (s CheckStamp) NextCheck(interval time.Duration) time.Time {
+	if s.LastCheck.IsZero() {
+		return time.Time{}
+	}
+	return s.LastCheck.Add(interval << min(s.Failures, maxBackoffShift))
+}
+
+// Due reports whether a check should run at now. A stamp from the future, left
+// by a clock change, is ignored.
+func (s CheckStamp) Due(now time.Time, interval time.Duration) bool {
+	return s.LastCheck.After(now) || !now.Before(s.NextCheck(interval))
+}
@@ -0,0 +1,54 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckStampDue(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name  string
+		stamp CheckStamp
+		want  bool
+	}{
+		{name: "never checked", stamp: CheckStamp{}, want: true},
+		{name: "checked within the interval", stamp: CheckStamp{LastCheck: now.Add(-time.Hour)}, want: false},
+		{name: "interval passed", stamp: CheckStamp{LastCheck: now.Add(-day)}, want: true},
+		{name: "backing off after two failures", stamp: CheckStamp{LastCheck: now.Add(-3 * day), Failures: 2}, want: false},
+		{name: "backoff passed", stamp: CheckStamp{LastCheck: now.Add(-4 * day), Failures: 2}, want: true},
+		{name: "backoff capped", stamp: CheckStamp{LastCheck: now.Add(-16 * day), Failures: 50}, want: true},
+		{name: "stamp from the future", stamp: CheckStamp{LastCheck: now.Add(day)}, want: true},
+	}
+	for _, tt := range tests {
+		if got := tt.stamp.Due(now, day); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCheckStampSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "auto_update.json")
+	if stamp := LoadStamp(path); !stamp.LastCheck.IsZero() {
+		t.Errorf("expected the zero stamp for a missing file, got %+v", stamp)
+	}
+
+	want := CheckStamp{LastCheck: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), Failures: 3}
+	if err := want.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := LoadStamp(path); !got.LastCheck.Equal(want.LastCheck) || got.Failures != want.Failures {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stamp := LoadStamp(path); !stamp.LastCheck.IsZero() {
+		t.Errorf("expected the zero stamp for a corrupt file, got %+v", stamp)
+	}
+}
@@ -18,9 +18,11 @@ import (
 
 var osExecutable = os.Executable
 var replaceWithBackupFunc = replaceWithBackup
+var osRename = os.Rename
 
 type GitHubRelease struct {
 	TagName string `json:"tag_name"`
+	Body    string `json:"body"`
 	Assets  []struct {
 		Name               string `json:"name"`
 		BrowserDownloadURL string `json:"browser_download_url"`
@@ -29,39 +31,54 @@ type GitHubRelease struct {
 
 var githubAPIURL = "https://api.github.com/repos/XYenon/smart-suggestion/releases/latest"
 
+// Release describes the latest published release. URL is empty when the
+// current version is already up to date.
+type Release struct {
+	Version string
+	URL     string
+	Notes   string
+}
+
 func CheckUpdate(currentVersion string) (string, string, error) {
+	release, err := CheckRelease(currentVersion)
+	return release.Version, release.URL, err
+}
+
+// CheckRelease is like CheckUpdate but also returns the release notes.
+func CheckRelease(currentVersion string) (Release, error) {
 	if currentVersion == "dev" {
-		return "", "", fmt.Errorf("cannot update development version. Please install from releases")
+		return Release{}, fmt.Errorf("cannot update development version. Please install from releases")
 	}
 
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Get(githubAPIURL)
 	if err != nil {
-		return "", "", err
+		return Release{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", "", fmt.Errorf("GitHub API error: %d %s", resp.StatusCode, string(body))
+		return Release{}, fmt.Errorf("GitHub API error: %d %s", resp.StatusCode, string(body))
 	}
 
 	var release GitHubRelease
 	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return "", "", err
+		return Release{}, err
 	}
 
 	latestVersion := strings.TrimPrefix(release.TagName, "v")
+	latest := Release{Version: latestVersion, Notes: release.Body}
 
 	currentSemver := "v" + strings.TrimPrefix(currentVersion, "v")
 	latestSemver := "v" + latestVersion
 
 	if semver.IsValid(currentSemver) && semver.IsValid(latestSemver) {
 		if semver.Compare(currentSemver, latestSemver) >= 0 {
-			return latestVersion, "", nil
+			return latest, nil
 		}
 	} else if latestVersion == strings.TrimPrefix(currentVersion, "v") {
-		return latestVersion, "", nil
+		return latest, nil
 	}
 
 	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
@@ -69,13 +86,14 @@ func CheckUpdate(currentVersion string) (string, string, error) {
 	for _, asset := range release.Assets {
 		if asset.Name == expectedAssetName {
 			if !strings.HasPrefix(asset.BrowserDownloadURL, "https://") {
-				return "", "", fmt.Errorf("insecure download URL: %s", asset.BrowserDownloadURL)
+				return Release{}, fmt.Errorf("insecure download URL: %s", asset.BrowserDownloadURL)
 			}
-			return latestVersion, asset.BrowserDownloadURL, nil
+			latest.URL = asset.BrowserDownloadURL
+			return latest, nil
 		}
 	}
 
-	return latestVersion, "", fmt.Errorf("no release found for platform %s", platform)
+	return latest, fmt.Errorf("no release found for platform %s", platform)
 }
 
 func InstallUpdate(downloadURL string) error {
@@ -147,41 +165,81 @@ func rollbackFromBackup(targetPath string) error {
 	return os.Rename(backupPath, targetPath)
 }
 
+// replaceWithBackup installs sourcePath at targetPath without ever leaving
+// targetPath missing: the new file is staged next to the target and renamed
+// over it in one step, and the old file is kept as targetPath+".backup" until
+// the returned cleanup runs.
 func replaceWithBackup(targetPath, sourcePath string, mode os.FileMode) (func(), error) {
 	backupPath := targetPath + ".backup"
-	backupCreated := false
 
+	stagedPath, err := stageFile(sourcePath, targetPath, mode)
+	if err != nil {
+		return func() {}, err
+	}
+
+	backupCreated := false
 	if _, err := os.Stat(targetPath); err == nil {
-		if err := os.Rename(targetPath, backupPath); err != nil {
-			return func() {}, err
+		_ = os.Remove(backupPath)
+		// A hard link keeps the old file in place while backing it up.
+		if err := os.Link(targetPath, backupPath); err != nil {
+			if err := copyFile(targetPath, backupPath); err != nil {
+				_ = os.Remove(backupPath)
+				_ = os.Remove(stagedPath)
+				return func() {}, fmt.Errorf("failed to back up %s: %w", targetPath, err)
+			}
 		}
 		backupCreated = true
 	} else if !os.IsNotExist(err) {
+		_ = os.Remove(stagedPath)
 		return func() {}, err
 	}
 
-	cleanup := func() {
+	if err := osRename(stagedPath, targetPath); err != nil {
+		_ = os.Remove(stagedPath)
 		if backupCreated {
 			_ = os.Remove(backupPath)
 		}
+		return func() {}, err
 	}
 
-	if err := copyFile(sourcePath, targetPath); err != nil {
+	cleanup := func() {
 		if backupCreated {
-			_ = os.Rename(backupPath, targetPath)
+			_ = os.Remove(backupPath)
 		}
-		return func() {}, err
 	}
+	return cleanup, nil
+}
 
-	if err := os.Chmod(targetPath, mode); err != nil {
-		_ = os.Remove(targetPath)
-		if backupCreated {
-			_ = os.Rename(backupPath, targetPath)
-		}
-		return func() {}, err
+// stageFile copies sourcePath to a temporary file in the directory of
+// targetPath, so it is on the same filesystem and can be renamed over it.
+func stageFile(sourcePath, targetPath string, mode os.FileMode) (string, error) {
+	s, err := os.Open(sourcePath)
+	if err != nil {
+		return "", err
 	}
+	defer s.Close()
 
-	return cleanup, nil
+	d, err := os.CreateTemp(filepath.Dir(targetPath), "."+filepath.Base(targetPath)+".new-*")
+	if err != nil {
+		return "", err
+	}
+	stagedPath := d.Name()
+
+	_, err = io.Copy(d, s)
+	if err == nil {
+		err = d.Sync()
+	}
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(stagedPath, mode)
+	}
+	if err != nil {
+		_ = os.Remove(stagedPath)
+		return "", err
+	}
+	return stagedPath, nil
 }
 
 func findExtractedAsset(extractDir, filename string) (string, bool) {
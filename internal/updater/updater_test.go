@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -624,6 +625,28 @@ func TestCheckUpdate_AlreadyUpToDate(t *testing.T) {
 	}
 }
 
+func TestCheckRelease_Notes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"tag_name": "v1.2.3", "body": "## Changes\n- Faster proxy"}`)
+	}))
+	defer ts.Close()
+
+	originalURL := githubAPIURL
+	githubAPIURL = ts.URL
+	defer func() { githubAPIURL = originalURL }()
+
+	release, err := CheckRelease("1.2.3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if release.Version != "1.2.3" {
+		t.Errorf("expected version 1.2.3, got %s", release.Version)
+	}
+	if release.Notes != "## Changes\n- Faster proxy" {
+		t.Errorf("unexpected notes: %q", release.Notes)
+	}
+}
+
 func TestCheckUpdate_CurrentVersionNewer(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, `{"tag_name": "v1.2.3"}`)
@@ -731,6 +754,129 @@ func TestCheckUpdate_Success(t *testing.T) {
 	}
 }
 
+// leftoverFiles lists the files in dir other than the expected ones.
+func leftoverFiles(t *testing.T, dir string, expected ...string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	var extra []string
+	for _, entry := range entries {
+		if !slices.Contains(expected, entry.Name()) {
+			extra = append(extra, entry.Name())
+		}
+	}
+	return extra
+}
+
+func TestReplaceWithBackup(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "smart-suggestion")
+	source := filepath.Join(t.TempDir(), "new")
+	os.WriteFile(target, []byte("old binary"), 0755)
+	os.WriteFile(source, []byte("new binary"), 0600)
+
+	cleanup, err := replaceWithBackup(target, source, 0755)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, _ := os.ReadFile(target)
+	if string(got) != "new binary" {
+		t.Errorf("expected new binary, got %q", got)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0755 {
+		t.Errorf("expected mode 0755, got %v", info.Mode().Perm())
+	}
+	backup, _ := os.ReadFile(target + ".backup")
+	if string(backup) != "old binary" {
+		t.Errorf("expected backup of the old binary, got %q", backup)
+	}
+
+	cleanup()
+	if extra := leftoverFiles(t, dir, "smart-suggestion"); len(extra) != 0 {
+		t.Errorf("expected only the target after cleanup, got %v", extra)
+	}
+}
+
+func TestReplaceWithBackup_NoExistingTarget(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "smart-suggestion.plugin.zsh")
+	source := filepath.Join(t.TempDir(), "plugin")
+	os.WriteFile(source, []byte("new plugin"), 0644)
+
+	cleanup, err := replaceWithBackup(target, source, 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cleanup()
+
+	got, _ := os.ReadFile(target)
+	if string(got) != "new plugin" {
+		t.Errorf("expected new plugin, got %q", got)
+	}
+	if extra := leftoverFiles(t, dir, "smart-suggestion.plugin.zsh"); len(extra) != 0 {
+		t.Errorf("unexpected files: %v", extra)
+	}
+}
+
+func TestReplaceWithBackup_FailureKeepsUsableTarget(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "smart-suggestion")
+	source := filepath.Join(t.TempDir(), "new")
+	os.WriteFile(target, []byte("old binary"), 0755)
+	os.WriteFile(source, []byte("new binary"), 0755)
+
+	oldRename := osRename
+	t.Cleanup(func() { osRename = oldRename })
+	osRename = func(oldpath, newpath string) error {
+		// This is the last step: the new binary is fully staged and the old
+		// one is still in place, so dying here leaves a working install.
+		got, _ := os.ReadFile(target)
+		if string(got) != "old binary" {
+			t.Errorf("expected the old binary in place before the rename, got %q", got)
+		}
+		staged, _ := os.ReadFile(oldpath)
+		if string(staged) != "new binary" || filepath.Dir(oldpath) != dir {
+			t.Errorf("expected the new binary staged next to the target, got %q at %s", staged, oldpath)
+		}
+		return fmt.Errorf("simulated rename failure")
+	}
+
+	if _, err := replaceWithBackup(target, source, 0755); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	got, _ := os.ReadFile(target)
+	if string(got) != "old binary" {
+		t.Errorf("expected the old binary to remain, got %q", got)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0755 {
+		t.Errorf("expected the old binary to stay executable, got %v", info.Mode().Perm())
+	}
+	if extra := leftoverFiles(t, dir, "smart-suggestion"); len(extra) != 0 {
+		t.Errorf("expected staged file and backup to be removed, got %v", extra)
+	}
+}
+
+func TestReplaceWithBackup_MissingSource(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "smart-suggestion")
+	os.WriteFile(target, []byte("old binary"), 0755)
+
+	if _, err := replaceWithBackup(target, filepath.Join(dir, "missing"), 0755); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	got, _ := os.ReadFile(target)
+	if string(got) != "old binary" {
+		t.Errorf("expected the old binary to remain, got %q", got)
+	}
+	if extra := leftoverFiles(t, dir, "smart-suggestion"); len(extra) != 0 {
+		t.Errorf("unexpected files: %v", extra)
+	}
+}
+
 func TestCopyFile(t *testing.T) {
 	tempDir := t.TempDir()
 	src := filepath.Join(tempDir, "src")
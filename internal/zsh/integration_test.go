@@ -190,6 +190,7 @@ SMART_SUGGESTION_DEBUG="true"
 		"ZDOTDIR="+tmpDir,
 		"HOME="+tmpDir,
 		"XDG_CACHE_HOME="+tmpDir,
+		"SMART_SUGGESTION_CACHE_DIR="+filepath.Join(tmpDir, "smart-suggestion"),
 		"XDG_CONFIG_HOME="+tmpDir,
 		"TERM=xterm-256color",
 		"MOCK_ERROR_FILE="+filepath.Join(tmpDir, "mock_error"),
@@ -479,6 +480,7 @@ bindkey "^o" | grep -q "_do_smart_suggestion" && echo "KEYBIND_REGISTERED" || ec
 		"ZDOTDIR="+tmpDir,
 		"HOME="+tmpDir,
 		"XDG_CACHE_HOME="+tmpDir,
+		"SMART_SUGGESTION_CACHE_DIR="+filepath.Join(tmpDir, "smart-suggestion"),
 		"XDG_CONFIG_HOME="+tmpDir,
 		"OPENAI_API_KEY=fake-key",
 		"SMART_SUGGESTION_AI_PROVIDER=openai",
@@ -541,6 +543,7 @@ fi
 		"ZDOTDIR="+tmpDir,
 		"HOME="+tmpDir,
 		"XDG_CACHE_HOME="+tmpDir,
+		"SMART_SUGGESTION_CACHE_DIR="+filepath.Join(tmpDir, "smart-suggestion"),
 		"XDG_CONFIG_HOME="+tmpDir,
 		"OPENAI_API_KEY=fake-key",
 		"SMART_SUGGESTION_AI_PROVIDER=openai",